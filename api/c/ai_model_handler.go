@@ -162,6 +162,11 @@ func AiModelStartRecord(c context.Context, streamId, className string) {
 }
 
 func AiModelStopRecord(c context.Context, streamId string) {
+	// 计划录像中的流由录像计划控制停止
+	if isRecordPlanStream(streamId) {
+		Logger.Info("流正在计划录像, 忽略AI模型录制停止", zap.String("streamId", streamId))
+		return
+	}
 	rm := NewRecordManager()
 	stopCh, err := rm.stopRecord(c, streamId)
 	if err != nil {
@@ -210,9 +215,9 @@ func (rm *RecordManager) startRecord(ctx context.Context, streamId, streamType s
 				resultChan <- false
 				return
 			default:
-				status := ZlmGetRecordStatus(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, streamId)
+				status := ZlmGetRecordStatus(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId)
 				if !status.Status {
-					resp := ZlmStartRecord(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, streamId, streamType)
+					resp := ZlmStartRecord(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId, streamType)
 					if resp.Code == 0 && resp.Result {
 						resultChan <- true
 						return
@@ -258,9 +263,9 @@ func (rm *RecordManager) stopRecord(ctx context.Context, streamId string) (<-cha
 				resultChan <- false
 				return
 			default:
-				status := ZlmGetRecordStatus(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, streamId)
+				status := ZlmGetRecordStatus(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId)
 				if status.Status {
-					resp := ZlmStopRecord(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, streamId)
+					resp := ZlmStopRecord(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId)
 					if resp.Code == 0 && resp.Result {
 						resultChan <- true
 						return
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	. "go-sip/logger"
+	"go-sip/m"
+	"os/exec"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// 加载pulseaudio回声消除模块, 已加载时先卸载再按当前音频配置重新加载
+func EnsureEchoCancelModuleLoaded() error {
+	// 1. 查询已加载模块
+	cmd := exec.Command("pactl", "list", "modules", "short")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("查询pulseaudio模块失败: %v", err)
+	}
+
+	// 2. 检查 module-echo-cancel 是否存在
+	if strings.Contains(string(output), "module-echo-cancel") {
+		// 2.1 获取模块ID
+		lines := strings.Split(string(output), "\n")
+		var moduleID string
+		for _, line := range lines {
+			if strings.Contains(line, "module-echo-cancel") {
+				fields := strings.Fields(line)
+				if len(fields) > 0 {
+					moduleID = fields[0]
+					break
+				}
+			}
+		}
+
+		// 2.2 卸载现有模块
+		if moduleID != "" {
+			err := exec.Command("pactl", "unload-module", moduleID).Run()
+			if err != nil {
+				return fmt.Errorf("卸载 module-echo-cancel 失败: %v", err)
+			}
+			Logger.Info("已卸载 module-echo-cancel ", zap.Any("moduleID", moduleID))
+		}
+	}
+
+	// 3. 校验配置
+	audio := m.ClientAudio()
+	if audio == nil || audio.InputDevice == "" || audio.OutputDevice == "" {
+		Logger.Error("音频输入或输出设备未配置，无法加载 module-echo-cancel")
+		return fmt.Errorf("音频输入或输出设备未配置")
+	}
+
+	sink := fmt.Sprintf("sink_master=%s", audio.OutputDevice)
+	source := fmt.Sprintf("source_master=%s", audio.InputDevice)
+
+	Logger.Info("加载 module-echo-cancel", zap.String("sink", sink), zap.String("source", source))
+
+	// 4. 加载 module-echo-cancel
+	loadCmd := exec.Command("pactl", "load-module",
+		"module-echo-cancel",
+		"sink_name=echo_cancel_sink",
+		"source_name=echo_cancel_source",
+		sink,
+		source,
+		"rate=8000",
+		"channels=1",
+		"aec_method=webrtc",
+	)
+	var stderr bytes.Buffer
+	loadCmd.Stderr = &stderr
+
+	if err := loadCmd.Run(); err != nil {
+		return fmt.Errorf("加载 module-echo-cancel 失败: %v\n%s", err, stderr.String())
+	}
+
+	Logger.Info("module-echo-cancel 已加载")
+
+	// 5. 设置默认输入设备（source）为 echo_cancel_source
+	if err := exec.Command("pactl", "set-default-source", "echo_cancel_source").Run(); err != nil {
+		Logger.Warn("设置默认输入设备失败", zap.Error(err))
+	} else {
+		Logger.Info("默认输入设备已设置为 echo_cancel_source")
+	}
+
+	// 6. 设置默认输出设备（sink）为 echo_cancel_sink
+	if err := exec.Command("pactl", "set-default-sink", "echo_cancel_sink").Run(); err != nil {
+		Logger.Warn("设置默认输出设备失败", zap.Error(err))
+	} else {
+		Logger.Info("默认输出设备已设置为 echo_cancel_sink")
+	}
+	return nil
+}
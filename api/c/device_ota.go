@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	. "go-sip/logger"
 	"go-sip/utils"
 	"os/exec"
@@ -22,8 +23,78 @@ func init() {
 	}
 }
 
-// 设备升级
-func DeviceOTA(DeviceID, firmwareId, firmwareDownloadUrl, firmwareMd5, firmwareVersion string) error {
+// 设备升级进度回调
+type OTAProgressFunc func(percent int, stage string)
+
+// 升级子进程会重启客户端, 升级结果先写入磁盘, 客户端重启后再上报
+const pendingOTAFile = "ota_pending.json"
+
+// 重启前未上报的设备升级
+type PendingOTA struct {
+	MsgID           int64  `json:"msgId"` // 升级命令的MsgID, 重启后按该id上报结果
+	DeviceID        string `json:"deviceId"`
+	FirmwareID      string `json:"firmwareId"`
+	FirmwareVersion string `json:"firmwareVersion"`
+	Done            bool   `json:"done"` // 升级子进程已执行结束
+	Error           string `json:"error"`
+}
+
+func pendingOTAPath(rootPath string) string {
+	return filepath.Join(rootPath, pendingOTAFile)
+}
+
+func savePendingOTA(rootPath string, pending *PendingOTA) error {
+	return os.WriteFile(pendingOTAPath(rootPath), utils.JSONEncode(pending), 0644)
+}
+
+// 升级子进程结束时记录升级结果
+func finishPendingOTA(rootPath string, err error) {
+	data, rerr := os.ReadFile(pendingOTAPath(rootPath))
+	if rerr != nil {
+		return
+	}
+	pending := &PendingOTA{}
+	if utils.JSONDecode(data, pending) != nil {
+		return
+	}
+	pending.Done = true
+	if err != nil {
+		pending.Error = err.Error()
+	}
+	savePendingOTA(rootPath, pending)
+}
+
+// 读取重启前未上报的设备升级, 没有时返回nil
+func LoadPendingOTA() *PendingOTA {
+	rootPath, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(pendingOTAPath(rootPath))
+	if err != nil {
+		return nil
+	}
+	pending := &PendingOTA{}
+	if err := utils.JSONDecode(data, pending); err != nil {
+		Logger.Error("升级结果文件内容错误", zap.Error(err))
+		os.Remove(pendingOTAPath(rootPath))
+		return nil
+	}
+	return pending
+}
+
+// 升级结果上报后删除
+func ClearPendingOTA() {
+	if rootPath, err := os.Getwd(); err == nil {
+		os.Remove(pendingOTAPath(rootPath))
+	}
+}
+
+// 设备升级, ctx取消时中止固件下载, msgID用于客户端重启后上报升级结果
+func DeviceOTA(ctx context.Context, msgID int64, DeviceID, firmwareId, firmwareDownloadUrl, firmwareMd5, firmwareVersion string, progress OTAProgressFunc) error {
+	if progress == nil {
+		progress = func(int, string) {}
+	}
 	// 项目根目录
 	rootPath, err := os.Getwd()
 	if err != nil {
@@ -74,7 +145,19 @@ func DeviceOTA(DeviceID, firmwareId, firmwareDownloadUrl, firmwareMd5, firmwareV
 	// 	}
 	// } else {
 	Logger.Info("固件开始下载", zap.Any("firmwareVersion", firmwareVersion), zap.Any("firmwareDownloadUrl", firmwareDownloadUrl))
-	_, err = utils.DownloadFileTo(otaFirmwarePath, firmwareDownloadUrl)
+	progress(0, "下载固件")
+	// 下载进度占整体进度的0-80%，每变化5%上报一次
+	lastPercent := 0
+	_, err = utils.DownloadFileToWithContext(ctx, otaFirmwarePath, firmwareDownloadUrl, func(written, total int64) {
+		if total <= 0 {
+			return
+		}
+		percent := int(written * 80 / total)
+		if percent-lastPercent >= 5 {
+			lastPercent = percent
+			progress(percent, "下载固件")
+		}
+	})
 	if err != nil {
 		Logger.Error("下载固件失败", zap.Any("firmwareVersion", firmwareVersion), zap.Any("firmwareDownloadUrl", firmwareDownloadUrl), zap.Error(err))
 		return fmt.Errorf("下载固件失败: %v", err)
@@ -86,6 +169,10 @@ func DeviceOTA(DeviceID, firmwareId, firmwareDownloadUrl, firmwareMd5, firmwareV
 	}
 	// }
 	Logger.Info("固件下载成功", zap.Any("otaFirmwareDir", otaFirmwareDir))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	progress(85, "备份固件")
 	// 备份老固件
 	lastOtaFirmwareDir, err := utils.FileDirHandler(rootPath, "last_ota_firmware")
 	if err != nil || lastOtaFirmwareDir == "" {
@@ -102,6 +189,19 @@ func DeviceOTA(DeviceID, firmwareId, firmwareDownloadUrl, firmwareMd5, firmwareV
 	Logger.Info("备份固件成功", zap.Any("lastOtaFirmwareDir", lastOtaFirmwareDir))
 
 	Logger.Info("开始升级固件", zap.Any("otaFirmwareDir", otaFirmwareDir))
+	// 升级子进程启动后不再响应取消
+	progress(90, "升级固件")
+	// 子进程会停止当前进程, 先保存升级记录
+	err = savePendingOTA(rootPath, &PendingOTA{
+		MsgID:           msgID,
+		DeviceID:        DeviceID,
+		FirmwareID:      firmwareId,
+		FirmwareVersion: firmwareVersion,
+	})
+	if err != nil {
+		Logger.Error("保存升级记录失败", zap.Any("err", err))
+		return fmt.Errorf("保存升级记录失败: %v", err)
+	}
 
 	// fork 子进程
 	cmd := reexec.Command("runOtaTask", rootPath, otaFirmwareDir)
@@ -109,10 +209,14 @@ func DeviceOTA(DeviceID, firmwareId, firmwareDownloadUrl, firmwareMd5, firmwareV
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Start(); err != nil {
+		ClearPendingOTA()
 		Logger.Error("fork子进程失败", zap.Any("err", err))
 		return fmt.Errorf("fork子进程失败: %v", err)
 	}
-	if err := cmd.Wait(); err != nil {
+	// 子进程未停止当前进程就退出时由当前进程上报结果
+	err = cmd.Wait()
+	ClearPendingOTA()
+	if err != nil {
 		Logger.Error("固件升级失败", zap.Any("err", err))
 		return fmt.Errorf("固件升级失败: %v", err)
 	}
@@ -149,17 +253,21 @@ func runOtaTask() {
 	err = rollbackOtaTask(rootPath)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "回滚固件失败")
+		finishPendingOTA(rootPath, fmt.Errorf("回滚固件失败: %v", err))
 		os.Exit(2) // 明确告诉父进程是失败
 	}
 
 	// 新固件覆盖旧固件
 	err = utils.CopyFile(fmt.Sprintf("%s/%s", otaFirmwareDir, "go-sip-client"), fmt.Sprintf("%s/%s", rootPath, "go-sip-client"))
+	var otaErr error
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "覆盖旧固件失败")
+		otaErr = fmt.Errorf("覆盖旧固件失败: %v", err)
 		// 回滚
 		err := rollbackOtaTask(rootPath)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "回滚固件失败")
+			finishPendingOTA(rootPath, fmt.Errorf("回滚固件失败: %v", err))
 			os.Exit(2) // 明确告诉父进程是失败
 		}
 	}
@@ -168,8 +276,10 @@ func runOtaTask() {
 	err = cmd.Run()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "启动sip服务失败")
+		finishPendingOTA(rootPath, fmt.Errorf("启动sip服务失败: %v", err))
 		os.Exit(2) // 明确告诉父进程是失败
 	}
+	finishPendingOTA(rootPath, otaErr)
 	fmt.Println(os.Stderr, "升级成功")
 	os.Exit(0)
 }
@@ -73,7 +73,7 @@ func GetDeviceRelationAiModelList(deviceId string) (map[string][]*model.AiModelI
 		return nil, fmt.Errorf("deviceId is empty")
 	}
 	// 获取网关地址
-	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s&deviceType=%s", m.CMConfig.Gateway, OpenAiModelRelationListURL, deviceId, m.CMConfig.DeviceType)
+	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s&deviceType=%s", m.ClientGateway(), OpenAiModelRelationListURL, deviceId, m.CMConfig.DeviceType)
 
 	// 调用网关接口
 	httpClient := middleware.GetHttpClient(m.CMConfig.OpenApi.ClientId, m.CMConfig.OpenApi.SecretKey)
@@ -108,7 +108,7 @@ func GetAiModelRelationList(deviceId string) ([]*model.IotDeviceRelationAiModelI
 		return nil, fmt.Errorf("deviceId is empty")
 	}
 	// 获取网关地址
-	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s", m.CMConfig.Gateway, OpenAiModelRelationListURL, deviceId)
+	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s", m.ClientGateway(), OpenAiModelRelationListURL, deviceId)
 
 	// 调用网关接口
 	httpClient := middleware.GetHttpClient(m.CMConfig.OpenApi.ClientId, m.CMConfig.OpenApi.SecretKey)
@@ -143,7 +143,7 @@ func IpcPlaybackRecord(data model.ZLMRecordMp4Data) model.ApiResult {
 	result := model.ApiResult{}
 
 	// 获取网关地址
-	gateway_url := fmt.Sprintf("http://%s%s", m.CMConfig.Gateway, OpenIpcPlaybackRecordURL)
+	gateway_url := fmt.Sprintf("http://%s%s", m.ClientGateway(), OpenIpcPlaybackRecordURL)
 
 	// 将结构体编码为 JSON
 	jsonData, err := json.Marshal(data)
@@ -192,7 +192,7 @@ func GetAllIpcList(deviceId string) ([]*model.IpcInfo, error) {
 		return nil, fmt.Errorf("deviceId is empty")
 	}
 	// 获取网关地址
-	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s", m.CMConfig.Gateway, OpenGetIpcListURL, deviceId)
+	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s", m.ClientGateway(), OpenGetIpcListURL, deviceId)
 
 	// 调用网关接口
 	httpClient := middleware.GetHttpClient(m.CMConfig.OpenApi.ClientId, m.CMConfig.OpenApi.SecretKey)
@@ -227,7 +227,7 @@ func GetNotGbIpcList(deviceId string) ([]*model.IotNotGbIpcInfo, error) {
 		return nil, fmt.Errorf("deviceId is empty")
 	}
 	// 获取网关地址
-	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s", m.CMConfig.Gateway, OpenGetNotGbIpcListURL, deviceId)
+	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s", m.ClientGateway(), OpenGetNotGbIpcListURL, deviceId)
 
 	// 调用网关接口
 	httpClient := middleware.GetHttpClient(m.CMConfig.OpenApi.ClientId, m.CMConfig.OpenApi.SecretKey)
@@ -256,6 +256,41 @@ func GetNotGbIpcList(deviceId string) ([]*model.IotNotGbIpcInfo, error) {
 	return nil, fmt.Errorf("get not gb ipc list error")
 }
 
+// 调用网关接口，根据设备id获取启用的录像计划
+func GetRecordPlanList(deviceId string) ([]*model.RecordPlan, error) {
+	if deviceId == "" {
+		return nil, fmt.Errorf("deviceId is empty")
+	}
+	// 获取网关地址
+	gateway_url := fmt.Sprintf("http://%s%s?deviceId=%s", m.ClientGateway(), OpenRecordPlanListURL, deviceId)
+
+	// 调用网关接口
+	httpClient := middleware.GetHttpClient(m.CMConfig.OpenApi.ClientId, m.CMConfig.OpenApi.SecretKey)
+	if httpClient == nil {
+		return nil, fmt.Errorf("httpClient is nil")
+	}
+	resp, err := httpClient.Get(gateway_url)
+	if err != nil {
+		return nil, fmt.Errorf("http.Get error: %v", err)
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		Logger.Error("io.ReadAll error", zap.Any("gateway_url", gateway_url), zap.Error(err))
+	} else {
+		// json 解析
+		result := model.RecordPlanListResult{}
+		err := utils.JSONDecode(bodyBytes, &result)
+		if err != nil || result.Code != model.CodeSucc {
+			Logger.Error("GetRecordPlanList json.Unmarshal error", zap.Any("gateway_url", gateway_url), zap.Error(err))
+		} else {
+			Logger.Debug("GetRecordPlanList success", zap.Any("gateway_url", gateway_url))
+			return result.Result, nil
+		}
+	}
+	return nil, fmt.Errorf("get record plan list error")
+}
+
 // 调用网关接口，更新非国标ipc信息
 func IpcNotGbInfoUpdate(ipcId, status string) error {
 	if ipcId == "" || status == "" {
@@ -265,7 +300,7 @@ func IpcNotGbInfoUpdate(ipcId, status string) error {
 		return fmt.Errorf("status error")
 	}
 	// 获取网关地址
-	gateway_url := fmt.Sprintf("http://%s%s?ipcId=%s&status=%s", m.CMConfig.Gateway, OpenGetNotGbIpcUpdateURL, ipcId, status)
+	gateway_url := fmt.Sprintf("http://%s%s?ipcId=%s&status=%s", m.ClientGateway(), OpenGetNotGbIpcUpdateURL, ipcId, status)
 
 	// 调用网关接口
 	httpClient := middleware.GetHttpClient(m.CMConfig.OpenApi.ClientId, m.CMConfig.OpenApi.SecretKey)
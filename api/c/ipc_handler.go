@@ -17,7 +17,7 @@ func IpcStreamReset(deviceId, ipcId string) error {
 	}
 
 	sd_stream_id := ipcId + "_0"
-	rtp_info := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, sd_stream_id, "rtp", 1)
+	rtp_info := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), sd_stream_id, "rtp", 1)
 	if rtp_info.Code != 0 || rtp_info.Port == 0 {
 		Logger.Error("open rtp server fail", zap.Int("code", rtp_info.Code))
 		return fmt.Errorf("open rtp server fail")
@@ -33,7 +33,7 @@ func IpcStreamReset(deviceId, ipcId string) error {
 	}
 
 	hd_stream_id := ipcId + "_1"
-	rtp_info2 := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, hd_stream_id, "rtp", 0)
+	rtp_info2 := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), hd_stream_id, "rtp", 0)
 	if rtp_info2.Code != 0 || rtp_info2.Port == 0 {
 		Logger.Error("open rtp server fail", zap.Int("code", rtp_info2.Code))
 		return fmt.Errorf("open rtp server fail")
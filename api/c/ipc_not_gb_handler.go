@@ -40,6 +40,10 @@ type IpcPushStreamData struct {
 }
 
 func IpcPushStreamHandler(deviceId string) {
+	if isNogbProxyMode() {
+		IpcStreamProxyHandler(deviceId)
+		return
+	}
 	notGbIpcList, err := GetNotGbIpcList(deviceId)
 	if err != nil {
 		Logger.Error("GetNotGbIpcList error", zap.Any("deviceId", deviceId), zap.Error(err))
@@ -60,7 +64,7 @@ func IpcPushStreamHandler(deviceId string) {
 			RtspSuffix:   ipcInfo.RtspSubSuffix,
 			IsMainStream: false,
 			ZlmIp:        m.CMConfig.ZlmInnerIp,
-			ZlmSecret:    m.CMConfig.ZlmSecret,
+			ZlmSecret:    m.ClientZlmSecret(),
 		}
 		startStreamDaemonWithRetry(subData)
 
@@ -72,13 +76,16 @@ func IpcPushStreamHandler(deviceId string) {
 			RtspSuffix:   ipcInfo.RtspMainSuffix,
 			IsMainStream: true,
 			ZlmIp:        m.CMConfig.ZlmInnerIp,
-			ZlmSecret:    m.CMConfig.ZlmSecret,
+			ZlmSecret:    m.ClientZlmSecret(),
 		}
 		startStreamDaemonWithRetry(mainData)
 	}
 }
 
 func IpcPushStreamReset(deviceId, ipcId string, zlmInfo *model.ZlmInfo) error {
+	if isNogbProxyMode() {
+		return IpcStreamProxyReconcile(deviceId, ipcId)
+	}
 	if deviceId == "" {
 		return fmt.Errorf("deviceId is empty")
 	}
@@ -108,7 +115,7 @@ func IpcPushStreamReset(deviceId, ipcId string, zlmInfo *model.ZlmInfo) error {
 		zlmGetMediaListReq.Vhost = "__defaultVhost__"
 		zlmGetMediaListReq.Schema = "rtsp"
 		zlmGetMediaListReq.StreamID = fmt.Sprintf("%s_%s", ipcInfo.IpcId, "0")
-		resp := zlm_api.ZlmGetMediaList(fmt.Sprintf("http://%s:9092", m.CMConfig.ZlmInnerIp), m.CMConfig.ZlmSecret, zlmGetMediaListReq)
+		resp := zlm_api.ZlmGetMediaList(fmt.Sprintf("http://%s:9092", m.CMConfig.ZlmInnerIp), m.ClientZlmSecret(), zlmGetMediaListReq)
 		if resp.Code == 0 && len(resp.Data) > 0 {
 			Logger.Debug("推流重置子码流存在", zap.Any("streamId", zlmGetMediaListReq.StreamID))
 			IpcNotGbInfoUpdate(ipcInfo.IpcId, "ON")
@@ -139,7 +146,7 @@ func IpcPushStreamReset(deviceId, ipcId string, zlmInfo *model.ZlmInfo) error {
 		if zlmInfo == nil {
 			zlmIp = m.CMConfig.ZlmInnerIp
 			zlmHost = fmt.Sprintf("http://%s:9092", m.CMConfig.ZlmInnerIp)
-			zlmSecret = m.CMConfig.ZlmSecret
+			zlmSecret = m.ClientZlmSecret()
 		} else {
 			zlmIp = zlmInfo.ZlmIp
 			zlmHost = zlmInfo.ZlmDomain
@@ -237,7 +244,7 @@ func startStreamDaemonWithRetry(s *IpcPushStreamData) {
 			}
 			Logger.Warn("推流失败，", zap.Int("次数：", i), zap.String("streamId", s.StreamId))
 			time.Sleep(20 * time.Second)
-			resp := zlm_api.ZlmGetMediaList(fmt.Sprintf("http://%s:9092", m.CMConfig.ZlmInnerIp), m.CMConfig.ZlmSecret, zlmGetMediaListReq)
+			resp := zlm_api.ZlmGetMediaList(fmt.Sprintf("http://%s:9092", m.CMConfig.ZlmInnerIp), m.ClientZlmSecret(), zlmGetMediaListReq)
 			if resp.Code == 0 && len(resp.Data) > 0 {
 				Logger.Debug("推流到本地zlm成功", zap.String("streamId", zlmGetMediaListReq.StreamID))
 				return
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+	sipapi "go-sip/sip"
+	"go-sip/utils"
+	"go-sip/zlm_api"
+
+	"go.uber.org/zap"
+)
+
+// 非国标摄像头推流方式
+const (
+	NogbPushModeFfmpeg = "ffmpeg" // 每路码流启动一个ffmpeg进程推流到本地zlm
+	NogbPushModeProxy  = "proxy"  // 本地zlm拉流代理
+)
+
+const (
+	ipcProxyApp            = "rtp"
+	ipcProxyAddRetry       = 3                // 添加拉流代理失败重试次数
+	ipcProxyAddRetryDelay  = 2 * time.Second  // 添加拉流代理重试间隔
+	ipcProxyReconcileDelay = 60 * time.Second // 定时对账间隔
+	ipcProxyTimeout        = 15 * time.Second // zlm接口超时, addStreamProxy在首次拉流结果返回后才响应
+)
+
+// 对账串行执行, 避免定时对账和推流重置同时增删代理
+var ipcProxyMu sync.Mutex
+
+var ipcProxyDaemonOnce sync.Once
+
+func isNogbProxyMode() bool {
+	return m.CMConfig.NogbPushMode == NogbPushModeProxy
+}
+
+// 期望的拉流代理
+type ipcProxyTarget struct {
+	IpcId  string
+	Stream string
+	URL    string
+}
+
+func ipcProxyRtspUrl(ipcInfo *model.IotNotGbIpcInfo, suffix string) string {
+	u := url.URL{
+		Scheme: "rtsp",
+		User:   url.UserPassword(ipcInfo.Username, ipcInfo.Password),
+		Host:   ipcInfo.InnerIP + ":554",
+	}
+	return u.String() + suffix
+}
+
+// 每个摄像头的子码流和主码流, 流id与ffmpeg推流方式一致
+func ipcProxyTargets(ipcInfo *model.IotNotGbIpcInfo) []ipcProxyTarget {
+	return []ipcProxyTarget{
+		{IpcId: ipcInfo.IpcId, Stream: fmt.Sprintf("%s_%s", ipcInfo.IpcId, "0"), URL: ipcProxyRtspUrl(ipcInfo, ipcInfo.RtspSubSuffix)},
+		{IpcId: ipcInfo.IpcId, Stream: fmt.Sprintf("%s_%s", ipcInfo.IpcId, "1"), URL: ipcProxyRtspUrl(ipcInfo, ipcInfo.RtspMainSuffix)},
+	}
+}
+
+// 启动拉流代理并定时对账, 摄像头上线或配置变更后自动补齐代理
+func IpcStreamProxyHandler(deviceId string) {
+	if err := IpcStreamProxyReconcile(deviceId, ""); err != nil {
+		Logger.Error("非国标摄像头拉流代理对账失败", zap.String("deviceId", deviceId), zap.Error(err))
+	}
+	ipcProxyDaemonOnce.Do(func() {
+		go func() {
+			timer := time.NewTicker(ipcProxyReconcileDelay)
+			defer timer.Stop()
+			for range timer.C {
+				if err := IpcStreamProxyReconcile(deviceId, ""); err != nil {
+					Logger.Error("非国标摄像头拉流代理对账失败", zap.String("deviceId", deviceId), zap.Error(err))
+				}
+			}
+		}()
+	})
+}
+
+// 以网关的非国标ipc列表为准, 对账本地zlm的拉流代理
+// ipcId不为空时只处理该摄像头, 为空时同时删除已不存在的摄像头的代理
+func IpcStreamProxyReconcile(deviceId, ipcId string) error {
+	if deviceId == "" {
+		return fmt.Errorf("deviceId is empty")
+	}
+	notGbIpcList, err := GetNotGbIpcList(deviceId)
+	if err != nil {
+		return fmt.Errorf("查询非国标ipc列表失败")
+	}
+
+	ipcProxyMu.Lock()
+	defer ipcProxyMu.Unlock()
+
+	// 单次请求超时由client控制
+	ctx := context.Background()
+	client := zlm_api.NewClient(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), zlm_api.WithTimeout(ipcProxyTimeout))
+	proxyList, err := client.ListStreamProxy(ctx)
+	if err != nil {
+		return fmt.Errorf("查询zlm拉流代理列表失败: %v", err)
+	}
+	proxies := make(map[string]zlm_api.StreamProxyInfo, len(proxyList))
+	for _, proxy := range proxyList {
+		if proxy.Src.App == ipcProxyApp {
+			proxies[proxy.Src.Stream] = proxy
+		}
+	}
+
+	desired := make(map[string]bool)
+	for _, ipcInfo := range notGbIpcList {
+		if ipcId != "" && ipcInfo.IpcId != ipcId {
+			continue
+		}
+		targets := ipcProxyTargets(ipcInfo)
+		for _, target := range targets {
+			desired[target.Stream] = true
+		}
+		if ipcInfo.InnerIP == "" {
+			continue
+		}
+		if !utils.CheckPort(ipcInfo.InnerIP, "554", 3*time.Second) {
+			// 摄像头离线时删除代理, 上线后由定时对账重新添加
+			for _, target := range targets {
+				if proxy, ok := proxies[target.Stream]; ok {
+					client.DelStreamProxy(ctx, proxy.Key)
+				}
+			}
+			IpcNotGbInfoUpdate(ipcInfo.IpcId, "OFFLINE")
+			continue
+		}
+
+		status := "ON"
+		for _, target := range targets {
+			if !ensureIpcProxy(ctx, client, target, proxies) {
+				status = "ERROR"
+			}
+		}
+		if ipcInfo.Status != status {
+			IpcNotGbInfoUpdate(ipcInfo.IpcId, status)
+		}
+	}
+
+	if ipcId != "" {
+		return nil
+	}
+	// 删除已不存在的摄像头的代理
+	for stream, proxy := range proxies {
+		if strings.HasPrefix(stream, "IPC") && !desired[stream] {
+			Logger.Info("删除无效的拉流代理", zap.String("stream", stream))
+			if err := client.DelStreamProxy(ctx, proxy.Key); err != nil {
+				Logger.Warn("删除拉流代理失败", zap.String("stream", stream), zap.Error(err))
+			}
+		}
+	}
+	return nil
+}
+
+// 确保拉流代理存在且地址正确, 返回代理是否正常
+func ensureIpcProxy(ctx context.Context, client *zlm_api.Client, target ipcProxyTarget, proxies map[string]zlm_api.StreamProxyInfo) bool {
+	if proxy, ok := proxies[target.Stream]; ok {
+		if proxy.URL == target.URL {
+			return proxy.Status == 0
+		}
+		// 摄像头地址或账号变更, 删除后重新添加
+		Logger.Info("拉流代理地址变更", zap.String("stream", target.Stream))
+		if err := client.DelStreamProxy(ctx, proxy.Key); err != nil {
+			Logger.Warn("删除拉流代理失败", zap.String("stream", target.Stream), zap.Error(err))
+			return false
+		}
+	}
+
+	// 从ffmpeg推流切换过来时先停止旧的推流进程
+	KillFfmpegIfExist(target.Stream)
+	for i := 1; i <= ipcProxyAddRetry; i++ {
+		key, err := client.AddStreamProxy(ctx, zlm_api.StreamProxyReq{
+			App:        ipcProxyApp,
+			Stream:     target.Stream,
+			URL:        target.URL,
+			RtpType:    0,
+			RetryCount: -1,
+		})
+		if err == nil {
+			Logger.Info("添加拉流代理成功", zap.String("stream", target.Stream), zap.String("key", key))
+			return true
+		}
+		Logger.Warn("添加拉流代理失败", zap.Int("次数", i), zap.String("stream", target.Stream), zap.Error(err))
+		if i < ipcProxyAddRetry {
+			time.Sleep(ipcProxyAddRetryDelay)
+		}
+	}
+	return false
+}
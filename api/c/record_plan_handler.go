@@ -0,0 +1,171 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	db "go-sip/db/sqlite"
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+	sipapi "go-sip/sip"
+	"go-sip/zlm_api"
+
+	"go.uber.org/zap"
+)
+
+const (
+	recordPlanInterval   = 60 * time.Second // 录像计划对账间隔, 计划修改后最迟在一个间隔内生效
+	recordPlanStreamWait = 5 * time.Second  // 点播后等待流注册的时间
+)
+
+// 由录像计划启动录制的流, AI事件结束时不停止这些流的录制
+var recordPlanStreams sync.Map
+
+// 由录像计划点播的流, 录像时间段结束且无人观看时关闭
+var recordPlanPulled sync.Map
+
+var recordPlanOnce sync.Once
+
+func isRecordPlanStream(streamId string) bool {
+	_, ok := recordPlanStreams.Load(streamId)
+	return ok
+}
+
+// 启动录像计划, 定时从网关获取录像计划并启停本地zlm录制
+func RecordPlanHandler(deviceId string) {
+	recordPlanOnce.Do(func() {
+		go func() {
+			recordPlanReconcile(deviceId)
+			timer := time.NewTicker(recordPlanInterval)
+			defer timer.Stop()
+			for range timer.C {
+				recordPlanReconcile(deviceId)
+			}
+		}()
+	})
+}
+
+// 以录像计划为准对账本地zlm的录制状态
+// 流断开后zlm停止录制, 流恢复后在下次对账时重新开始录制
+func recordPlanReconcile(deviceId string) {
+	planList, err := GetRecordPlanList(deviceId)
+	if err != nil {
+		// 获取失败时保持当前录制状态
+		Logger.Warn("获取录像计划失败", zap.String("deviceId", deviceId), zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	desired := make(map[string]bool)
+	for _, plan := range planList {
+		if plan.ShouldRecord(now) {
+			desired[fmt.Sprintf("%s_%d", plan.IpcId, plan.StreamType)] = true
+		}
+	}
+
+	for streamId := range desired {
+		status := zlm_api.ZlmGetRecordStatus(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId)
+		if status.Code != 0 {
+			// 国标摄像头只在有人观看时推流, 流不存在时由录像计划点播并保持到录像时间段结束
+			if status = pullRecordPlanStream(streamId); status.Code != 0 {
+				continue
+			}
+		}
+		if status.Status {
+			// AI事件触发的录制由录像计划接管
+			recordPlanStreams.Store(streamId, true)
+			continue
+		}
+		resp := zlm_api.ZlmStartRecord(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId, model.RecordPlanFileType)
+		if resp.Code == 0 && resp.Result {
+			Logger.Info("计划录像开始", zap.String("streamId", streamId))
+			recordPlanStreams.Store(streamId, true)
+		} else {
+			Logger.Warn("计划录像开始失败", zap.String("streamId", streamId), zap.Any("resp", resp))
+		}
+	}
+
+	// 停止已不在计划时间内的录制
+	recordPlanStreams.Range(func(key, value any) bool {
+		streamId := key.(string)
+		if desired[streamId] {
+			return true
+		}
+		resp := zlm_api.ZlmStopRecord(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId)
+		if resp.Code == 0 && resp.Result {
+			Logger.Info("计划录像停止", zap.String("streamId", streamId))
+			recordPlanStreams.Delete(streamId)
+			releaseRecordPlanStream(streamId)
+			return true
+		}
+		// 流已断开时录制已经停止
+		if status := zlm_api.ZlmGetRecordStatus(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId); status.Code != 0 || !status.Status {
+			recordPlanStreams.Delete(streamId)
+			releaseRecordPlanStream(streamId)
+			return true
+		}
+		Logger.Warn("计划录像停止失败", zap.String("streamId", streamId), zap.Any("resp", resp))
+		return true
+	})
+}
+
+// 点播录像计划需要的国标摄像头流, 等待流注册后返回录制状态
+func pullRecordPlanStream(streamId string) zlm_api.ZlmRecordStatusRes {
+	failed := zlm_api.ZlmRecordStatusRes{Code: -1}
+	ipcId, streamType, ok := strings.Cut(streamId, "_")
+	// 非国标摄像头由推流守护进程持续推流
+	if !ok || strings.HasPrefix(ipcId, "IPC") || len(ipcId) < 5 {
+		return failed
+	}
+	rtp_info := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId, "rtp", 0)
+	if rtp_info.Code != 0 || rtp_info.Port == 0 {
+		Logger.Warn("计划录像开启rtp端口失败", zap.String("streamId", streamId), zap.Int("code", rtp_info.Code))
+		return failed
+	}
+	resolution := 0
+	if streamType == "1" {
+		resolution = 1
+	}
+	pm := &sipapi.Streams{ChannelID: ipcId, StreamID: streamId,
+		ZlmIP: m.CMConfig.ZlmInnerIp, ZlmPort: rtp_info.Port, T: 0, Resolution: resolution,
+		Mode: 0, Ttag: db.M{}, Ftag: db.M{}, OnlyAudio: false, Ssrc: fmt.Sprintf("%s%s", ipcId[len(ipcId)-5:], streamType)}
+	if _, err := sipapi.SipPlay(pm); err != nil {
+		Logger.Warn("计划录像点播摄像头失败", zap.String("streamId", streamId), zap.Error(err))
+		return failed
+	}
+	recordPlanPulled.Store(streamId, true)
+	Logger.Info("计划录像点播摄像头", zap.String("streamId", streamId))
+
+	deadline := time.Now().Add(recordPlanStreamWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		if status := zlm_api.ZlmGetRecordStatus(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId); status.Code == 0 {
+			return status
+		}
+	}
+	// 流注册较慢时在下次对账时开始录制
+	return failed
+}
+
+// 录像时间段结束后关闭录像计划点播的流, 有人观看或ai分析时保留
+func releaseRecordPlanStream(streamId string) {
+	if _, ok := recordPlanPulled.LoadAndDelete(streamId); !ok {
+		return
+	}
+	media_list := zlm_api.ZlmGetMediaList(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), zlm_api.ZlmGetMediaListReq{
+		Schema: "rtsp", App: "rtp", StreamID: streamId,
+	})
+	if media_list.Code != 0 {
+		return
+	}
+	for _, media := range media_list.Data {
+		if media.TotalReaderCount > 0 {
+			return
+		}
+	}
+	Logger.Info("计划录像结束, 关闭点播的流", zap.String("streamId", streamId))
+	zlm_api.ZlmCloseStreams(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), streamId)
+}
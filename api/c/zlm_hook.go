@@ -2,7 +2,6 @@ package api
 
 import (
 	"fmt"
-	"net/http"
 	"time"
 
 	. "go-sip/db/alioss"
@@ -13,9 +12,9 @@ import (
 	sipapi "go-sip/sip"
 	"go-sip/utils"
 	"go-sip/zlm_api"
+	"go-sip/zlm_hook"
 
 	"go-sip/yolo"
-	"io"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -24,64 +23,51 @@ import (
 
 var YoloProcessorMap = make(map[string]*yolo.YoloProcessorStruct)
 
+// 等待流注册, 同一流的并发播放请求只点播一次
+var streamWaiter = zlm_hook.NewStreamWaiter()
+
+const streamWaitTimeout = 5 * time.Second
+
+var zlmHooks = newZlmHooks()
+
+func newZlmHooks() *zlm_hook.Registry {
+	r := zlm_hook.NewRegistry()
+	zlm_hook.On(r, zlm_hook.ServerStarted, zlmServerStart)         // zlm启动
+	zlm_hook.On(r, zlm_hook.Play, zlmStreamOnPlay)                 // 点播业务
+	zlm_hook.On(r, zlm_hook.Publish, zlmStreamPublish)             // 推流业务
+	zlm_hook.On(r, zlm_hook.StreamNoneReader, zlmStreamNoneReader) // 无人阅读通知 关闭流
+	zlm_hook.On(r, zlm_hook.StreamNotFound, zlmStreamNotFound)     // 请求播放时，流不存在时触发
+	zlm_hook.On(r, zlm_hook.RecordMp4, zlmRecordMp4)               // mp4录制完成
+	zlm_hook.On(r, zlm_hook.StreamChanged, zlmStreamChanged)       // 流注册和注销通知
+	return r
+}
+
+// 其他模块订阅zlm hook
+func ZlmHooks() *zlm_hook.Registry {
+	return zlmHooks
+}
+
 // ZLM WebHook
 func ZLMWebHook(c *gin.Context) {
-	method := c.Param("method")
-	// Logger.Info("client sip ZLMWebHook", zap.String("method", method))
-	switch method {
-	case "on_server_started":
-		// zlm启动
-		zlmServerStart(c)
-	case "on_http_access":
-		// http请求鉴权
-	case "on_play":
-		// 点播业务
-		zlmStreamOnPlay(c)
-	case "on_publish":
-		// 推流业务
-		zlmStreamPublish(c)
-	case "on_stream_none_reader":
-		// 无人阅读通知 关闭流
-		zlmStreamNoneReader(c)
-	case "on_stream_not_found":
-		// 请求播放时，流不存在时触发
-		zlmStreamNotFound(c)
-	case "on_record_mp4":
-		//  mp4录制完成
-		zlmRecordMp4(c)
-	case "on_stream_changed":
-		// 流注册和注销通知
-		zlmStreamChanged(c)
-	default:
-		m.ZlmWebHookResponse(c, 0, "success")
-	}
-
+	// Logger.Info("client sip ZLMWebHook", zap.String("method", c.Param("method")))
+	zlmHooks.Handle(c)
 }
 
-func zlmServerStart(c *gin.Context) {
-	m.ZlmWebHookResponse(c, 0, "zlm server start success")
+func zlmServerStart(c *gin.Context, req *model.ZlmServerStartDate) {
+	zlm_hook.Response(c, 0, "zlm server start success")
 }
 
 // zlm点播业务
-func zlmStreamOnPlay(c *gin.Context) {
-	m.ZlmWebHookResponse(c, 0, "on play success")
+func zlmStreamOnPlay(c *gin.Context, req *model.ZlmStreamOnPlayData) {
+	zlm_hook.Response(c, 0, "on play success")
 }
 
-func zlmStreamChanged(c *gin.Context) {
-
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-	var req = model.ZLMStreamChangedData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
+func zlmStreamChanged(c *gin.Context, req *model.ZLMStreamChangedData) {
 	if req.Regist {
+		if req.Schema == "rtsp" {
+			// 唤醒等待该流的播放请求
+			streamWaiter.Ready(zlm_hook.StreamWaitKey(req.MediaServerId, req.APP, req.Stream))
+		}
 		if req.Schema == "rtsp" && (req.APP == "rtp" || req.APP == "live") {
 			if strings.HasPrefix(req.Stream, "IPC") {
 				streamArr := strings.Split(req.Stream, "_")
@@ -107,50 +93,40 @@ func zlmStreamChanged(c *gin.Context) {
 				// 停止ffmpeg
 				KillFfmpegIfExist(req.Stream)
 			} else {
-				err = sipapi.SipStopPlay(req.Stream)
-				if err != nil {
+				if err := sipapi.SipStopPlay(req.Stream); err != nil {
 					Logger.Error("摄像头停止错误 ：", zap.Error(err))
 					return
 				}
 			}
 		}
 	}
-	m.ZlmWebHookResponse(c, 0, "success")
+	zlm_hook.Success(c)
 }
 
-func zlmStreamPublish(c *gin.Context) {
-	c.JSON(http.StatusOK, map[string]any{
-		"code":         0,
-		"enable_audio": true,
-		"enable_MP4":   false,
-		"msg":          "success",
-	})
+func zlmStreamPublish(c *gin.Context, req *model.ZlmStreamPublishData) {
+	zlm_hook.JSON(c, zlm_hook.PublishResponse{Code: 0, Msg: "success", EnableAudio: true, EnableMP4: false})
 }
 
-func zlmRecordMp4(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		Logger.Error("zlmRecordMp4 body error", zap.Error(err))
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-	var recordMp4Data = model.ZLMRecordMp4Data{}
-	if err := utils.JSONDecode(data, &recordMp4Data); err != nil {
-		Logger.Error("zlmRecordMp4 body error", zap.Error(err))
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-	m.ZlmWebHookResponse(c, 0, "success")
+func zlmRecordMp4(c *gin.Context, recordMp4Data *model.ZLMRecordMp4Data) {
+	zlm_hook.Success(c)
 	go func() {
 		var maxRetry = 5
 		var j = 1
 		var fileOssDownloadUrl = ""
+		// 上传前计算md5, 上传成功后本地文件会被删除
+		fileMd5, err := utils.ComputeFileMD5(recordMp4Data.FilePath)
+		if err != nil {
+			Logger.Warn("zlmRecordMp4 compute file md5 error", zap.Error(err))
+		}
+		// fmp4初始化段大小, 云端回放按字节范围拼接hls
+		initSize, err := utils.Mp4InitSegmentSize(recordMp4Data.FilePath)
+		if err != nil {
+			Logger.Warn("zlmRecordMp4 parse mp4 error", zap.Error(err))
+		}
+		objectKey := "ipc_video_playback/" + recordMp4Data.Stream + "/" + recordMp4Data.FileName
 		for ; j <= maxRetry; j++ {
 			// 上传到oss
 			filePath := recordMp4Data.FilePath
-			objectKey := "ipc_video_playback/" + recordMp4Data.Stream + "/" + recordMp4Data.FileName
 			fileUrl, err := UploadFile(objectKey, filePath)
 			if err != nil || fileUrl == "" {
 				Logger.Warn("zlmRecordMp4 upload file error", zap.Error(err))
@@ -170,8 +146,11 @@ func zlmRecordMp4(c *gin.Context) {
 			var i = 1
 			for ; i <= maxRetry; i++ {
 				recordMp4Data.FileOssDownloadUrl = fileOssDownloadUrl
+				recordMp4Data.OssKey = objectKey
+				recordMp4Data.FileMd5 = fileMd5
+				recordMp4Data.InitSize = initSize
 				// 调用 gateway 接口，存储录像信息
-				result := IpcPlaybackRecord(recordMp4Data)
+				result := IpcPlaybackRecord(*recordMp4Data)
 				if result.Code == model.CodeSucc {
 					Logger.Info("录像信息上报成功", zap.Any("result", result.Result))
 					break
@@ -190,45 +169,48 @@ func zlmRecordMp4(c *gin.Context) {
 
 }
 
-func zlmStreamNotFound(c *gin.Context) {
-
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
+func zlmStreamNotFound(c *gin.Context, req *model.ZLMStreamNotFoundData) {
+	wait, first := streamWaiter.Join(zlm_hook.StreamWaitKey(req.MediaServerID, req.APP, req.Stream))
+	defer wait.Leave()
+	// 第一个请求负责点播, 其他请求等待同一次点播
+	if first {
+		if err := startNotFoundStream(req); err != nil {
+			wait.Fail(err)
+		}
 	}
-	var req = model.ZLMStreamNotFoundData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
+	if err := wait.Wait(streamWaitTimeout); err != nil {
+		Logger.Warn("等待流失败", zap.String("stream", req.Stream), zap.Error(err))
 	}
 
+	zlm_hook.JSON(c, zlm_hook.CloseResponse{Code: 0, Close: true})
+}
+
+// 点播流不存在的国标摄像头, 同时拉起标清和高清流
+func startNotFoundStream(req *model.ZLMStreamNotFoundData) error {
 	stream_arr := strings.Split(req.Stream, "_")
 	// 判断req.Stream是否以IPC开头，不以IPC开头则表示为国标设备
 	if !strings.HasPrefix(stream_arr[0], "IPC") {
 		sd_stream_id := stream_arr[0] + "_0"
-		rtp_info := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, sd_stream_id, "rtp", 0)
+		rtp_info := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), sd_stream_id, "rtp", 0)
 		if rtp_info.Code != 0 || rtp_info.Port == 0 {
 			Logger.Error("open rtp server fail", zap.Int("code", rtp_info.Code))
-			return
+			return fmt.Errorf("open rtp server fail, code %d", rtp_info.Code)
 		}
 		// 向摄像头发送信令请求推实时标清流到zlm
 		pm := &sipapi.Streams{ChannelID: stream_arr[0], StreamID: sd_stream_id,
 			ZlmIP: m.CMConfig.ZlmInnerIp, ZlmPort: rtp_info.Port, T: 0, Resolution: 1,
 			Mode: 0, Ttag: db.M{}, Ftag: db.M{}, OnlyAudio: false, Ssrc: fmt.Sprintf("%s0", stream_arr[0][len(stream_arr[0])-5:])}
-		_, err = sipapi.SipPlay(pm)
+		_, err := sipapi.SipPlay(pm)
 		if err != nil {
 			Logger.Error("向摄像头发送信令请求实时标清流推流到zlm失败", zap.Any("ipcId", stream_arr[0]), zap.Error(err))
-			return
+			return err
 		}
 
 		hd_stream_id := stream_arr[0] + "_1"
-		rtp_info2 := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, hd_stream_id, "rtp", 0)
+		rtp_info2 := zlm_api.ZlmStartRtpServer(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), hd_stream_id, "rtp", 0)
 		if rtp_info2.Code != 0 || rtp_info2.Port == 0 {
 			Logger.Error("open rtp server fail", zap.Int("code", rtp_info2.Code))
-			return
+			return fmt.Errorf("open rtp server fail, code %d", rtp_info2.Code)
 		}
 		// 向摄像头发送信令请求推实时高清清流到zlm
 		pm2 := &sipapi.Streams{ChannelID: stream_arr[0], StreamID: hd_stream_id,
@@ -237,41 +219,13 @@ func zlmStreamNotFound(c *gin.Context) {
 		_, err = sipapi.SipPlay(pm2)
 		if err != nil {
 			Logger.Error("向摄像头发送信令请求实时高清流推流到zlm失败", zap.Any("ipcId", stream_arr[0]), zap.Error(err))
-			return
+			return err
 		}
 	}
-
-	c.JSON(http.StatusOK, map[string]any{
-		"code":  0,
-		"close": true,
-	})
+	return nil
 }
 
-func zlmStreamNoneReader(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-	var req = model.ZLMStreamNoneReaderData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-
+func zlmStreamNoneReader(c *gin.Context, req *model.ZLMStreamNoneReaderData) {
 	s_size := strings.Split(req.Stream, "_")
-	if len(s_size) == 3 {
-		c.JSON(http.StatusOK, map[string]any{
-			"code":  0,
-			"close": true,
-		})
-	} else {
-		c.JSON(http.StatusOK, map[string]any{
-			"code":  0,
-			"close": false,
-		})
-	}
-
+	zlm_hook.JSON(c, zlm_hook.CloseResponse{Code: 0, Close: len(s_size) == 3})
 }
@@ -8,7 +8,7 @@ import (
 	redis_util "go-sip/db/redis/redis_gateway_util"
 	. "go-sip/logger"
 	"go-sip/model"
-	"go-sip/utils"
+	"go-sip/zlm_api"
 	"sort"
 	"strconv"
 
@@ -161,8 +161,8 @@ func GatewayGetZlmInfo(device_id string) (*model.ZlmInfo, error) {
 			return nil, errors.New("获取zlm信息失败")
 		}
 	} else {
-		// 从 zlm_info_list 中使用hash算法选择一个zlm服务
-		zlmAndRegionInfo, err := SelectZlmConfig(zlmAndRegionInfoNewList, device_id)
+		// 从 zlm_info_list 中按地区配置的策略选择一个zlm服务
+		zlmAndRegionInfo, err := SelectZlmConfig(zlmAndRegionInfoNewList, region_code, device_id)
 		if err != nil || zlmAndRegionInfo == nil {
 			Logger.Error("获取zlm服务信息失败", zap.Error(err))
 			return nil, errors.New("获取zlm服务信息失败")
@@ -179,16 +179,11 @@ func GatewayGetZlmInfo(device_id string) (*model.ZlmInfo, error) {
 	return zlmInfo, nil
 }
 
-// 从 configs 中使用hash取余选出一个元素
-func SelectZlmConfig(configs []model.ZlmAndRegionInfo, key string) (*model.ZlmAndRegionInfo, error) {
-	if len(configs) == 0 {
-		return nil, errors.New("配置列表为空")
-	}
-	if key == "" {
-		return nil, errors.New("key不能为空")
-	}
-	index := int(utils.HashString(key)) % len(configs)
-	return &configs[index], nil
+// 按地区配置的选择策略从 configs 中选出一个元素
+func SelectZlmConfig(configs []model.ZlmAndRegionInfo, region_code, key string) (*model.ZlmAndRegionInfo, error) {
+	strategy, _ := redis_util.HGet_2(redis.WVP_REGION_ZLM_STRATEGY, region_code)
+	loads, _ := redis_util.HGetAll_2(redis.ZLM_NODE_LOAD_KEY)
+	return zlm_api.SelectZlmNode(strategy, configs, key, loads)
 }
 
 // 轮询从 map[string]string中选一个值，直到选中一个值
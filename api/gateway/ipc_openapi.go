@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"encoding/json"
+	"go-sip/api/record_service"
 	"go-sip/dao"
 	"go-sip/db/redis"
 	redis_util "go-sip/db/redis/redis_gateway_util"
@@ -104,6 +105,12 @@ func IpcNotGbInfoUpdate(c *gin.Context) {
 	model.JsonResponseSucc(c, "更新成功")
 }
 
+// 回放索引缓存
+var playbackCache = record_service.PlaybackCache{
+	ScanPlaybackRecords: redis_util.ScanPlaybackRecords,
+	ZRangeByScore:       redis_util.ZRangeByScore_2,
+}
+
 // @Summary		ipc回放视频时间列表
 // @Description	用来获取通道设备存储的可回放时间段列表，注意控制时间跨度，跨度越大，数据量越多，返回越慢，甚至会超时（最多10s）。
 // @Router		/open/ipc/recordList [post]
@@ -134,18 +141,9 @@ func IpcRecordsList(c *gin.Context) {
 		endStamp = time.Now().Unix()
 	}
 
-	// start和end都是秒级时间戳，转date
-	startDate := time.Unix(startStamp, 0).Format("2006-01-02")
-	endDate := time.Unix(endStamp, 0).Format("2006-01-02")
-	// start和end必须是同一天
-	if startDate != endDate {
-		model.JsonResponseSysERR(c, "开始时间和结束时间必须在同一天")
-		return
-	}
-
-	// 7天之前的录像不能查询
-	if startStamp < time.Now().Add(-7*24*time.Hour).Unix() {
-		model.JsonResponseSysERR(c, "开始时间不能小于7天")
+	// 查询跨度不能超过31天
+	if endStamp-startStamp > model.RecordQueryMaxDays*24*3600 {
+		model.JsonResponseSysERR(c, fmt.Sprintf("查询时间跨度不能超过%d天", model.RecordQueryMaxDays))
 		return
 	}
 
@@ -177,16 +175,11 @@ func IpcRecordsList(c *gin.Context) {
 		deviceId = ipc_info.DeviceID
 	}
 
-	var ipcRecordList []model.IpcPlaybackRecordData
-	if query.Type == "all" {
-		ipcRecordListSub, _ := redis_util.ScanPlaybackRecords(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, "*"), start, end)
-		ipcRecordList = append(ipcRecordList, ipcRecordListSub...)
-	} else {
-		typeArr := strings.Split(query.Type, ",")
-		for _, typeStr := range typeArr {
-			ipcRecordListSub, _ := redis_util.ZRangeByScore_2(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, typeStr), start, end)
-			ipcRecordList = append(ipcRecordList, ipcRecordListSub...)
-		}
+	ipcRecordList, err := playbackCache.RecordList(deviceId, ipcId, query.Type, startStamp, endStamp)
+	if err != nil {
+		Logger.Error("查询录像片段失败", zap.String("ipcId", ipcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像失败")
+		return
 	}
 	model.JsonResponseSucc(c, ipcRecordList)
 }
@@ -280,6 +273,41 @@ func IpcPlaybackRecord(c *gin.Context) {
 	if err != nil || className == "" {
 		className = "person"
 	}
+	// 计划录像不是AI事件触发
+	triggerType := model.RecordTriggerAi
+	if fileType == model.RecordPlanFileType {
+		className = model.RecordPlanFileType
+		triggerType = model.RecordTriggerPlan
+	}
+
+	// 录像片段写入mysql, redis只作为近期回放索引的缓存
+	ossKey := zlmRecordMp4Data.OssKey
+	if ossKey == "" {
+		ossKey = "ipc_video_playback/" + streamId + "/" + fileName
+	}
+	segment := &model.RecordSegment{
+		IpcId:       ipcId,
+		DeviceId:    deviceId,
+		StreamId:    streamId,
+		RecordType:  fileType,
+		TriggerType: triggerType,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Duration:    zlmRecordMp4Data.TimeLen,
+		FileSize:    zlmRecordMp4Data.FileSize,
+		OssKey:      ossKey,
+		FileUrl:     fileOssDownloadUrl,
+		Checksum:    zlmRecordMp4Data.FileMd5,
+		InitSize:    zlmRecordMp4Data.InitSize,
+	}
+	if triggerType == model.RecordTriggerAi {
+		segment.AiClass = className
+	}
+	if err := dao.CreateRecordSegment(segment); err != nil {
+		Logger.Error("录像片段写入mysql失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "录像片段写入失败")
+		return
+	}
 
 	var ipcPlaybackRecordDataList []model.IpcPlaybackRecordData
 	var ipcPlaybackRecordData = model.IpcPlaybackRecordData{}
@@ -289,12 +317,10 @@ func IpcPlaybackRecord(c *gin.Context) {
 	ipcPlaybackRecordData.AiModelType = className
 	ipcPlaybackRecordDataList = append(ipcPlaybackRecordDataList, ipcPlaybackRecordData)
 
-	// 存入redis
-	err = redis_util.ZAdd_2(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, fileType), ipcPlaybackRecordDataList, time.Hour*24*7)
+	// 存入redis缓存, 缓存失败不影响录像查询
+	err = redis_util.ZAdd_2(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, fileType), ipcPlaybackRecordDataList, time.Hour*24*model.RecordIndexCacheDays)
 	if err != nil {
-		Logger.Error("存入redis失败", zap.Error(err))
-		model.JsonResponseSysERR(c, "存入redis失败")
-		return
+		Logger.Warn("存入redis失败", zap.Error(err))
 	}
 	model.JsonResponseSucc(c, "成功")
 }
@@ -326,6 +352,14 @@ func IpcResetMergeStream(c *gin.Context) {
 
 	device_id := req.DeviceId
 	ipcListStr := req.IpcList
+	if req.LayoutId > 0 {
+		ipcResetMergeStreamByLayout(c, &req, &zlmInfo)
+		return
+	}
+	if ipcListStr == "" {
+		model.JsonResponseSysERR(c, "参数ipcList不能为空")
+		return
+	}
 	// 对sub_ipc进行分割
 	sub_ipc_list := strings.Split(ipcListStr, "_")
 
@@ -387,3 +421,36 @@ func IpcResetMergeStream(c *gin.Context) {
 	model.JsonResponseSucc(c, "成功")
 
 }
+
+// 按合屏布局切屏, 合屏流已存在时直接切换布局
+func ipcResetMergeStreamByLayout(c *gin.Context, req *model.IpcResetMergeStreamReq, zlmInfo *model.ZlmInfo) {
+	layout_id := strconv.FormatInt(req.LayoutId, 10)
+	layout_str, err := redis_util.HGet_2(redis.MERGE_LAYOUT_KEY, layout_id)
+	if err != nil || layout_str == "" {
+		model.JsonResponseSysERR(c, "合屏布局不存在")
+		return
+	}
+	layout := model.MergeLayout{}
+	if err := json.Unmarshal([]byte(layout_str), &layout); err != nil {
+		model.JsonResponseSysERR(c, "参数格式错误，json反序列化失败")
+		return
+	}
+
+	var ipc_list []string
+	if req.IpcList != "" {
+		ipc_list = strings.Split(req.IpcList, "_")
+	}
+	resp := zlm_api.ZlmMergeStreamByLayout(&layout, req.DeviceId, ipc_list, zlmInfo)
+	if resp.Code != 0 {
+		Logger.Error("重置合屏流失败", zap.String("stream_id", req.DeviceId), zap.String("layout_id", layout_id), zap.Any("resp", resp))
+		model.JsonResponseSysERR(c, "重置合屏流失败")
+		return
+	}
+
+	sub_ipc := zlm_api.MergeLayoutPrefix + layout_id
+	if req.IpcList != "" {
+		sub_ipc += ":" + req.IpcList
+	}
+	redis_util.HSet_2(redis.MERGE_VIDEO_STREAM_IPC_LIST_KEY, req.DeviceId, sub_ipc)
+	model.JsonResponseSucc(c, "成功")
+}
@@ -0,0 +1,30 @@
+package gateway
+
+import (
+	"go-sip/dao"
+	. "go-sip/logger"
+	"go-sip/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// @Summary		查询设备启用的录像计划
+// @Router		/open/record/planList [get]
+func GetRecordPlanList(c *gin.Context) {
+	deviceId := c.Query("deviceId")
+	if deviceId == "" {
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	planList, err := dao.GetEnabledRecordPlansByDevice(deviceId)
+	if err != nil {
+		Logger.Error("查询录像计划失败", zap.String("deviceId", deviceId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像计划失败")
+		return
+	}
+	if planList == nil {
+		planList = []*model.RecordPlan{}
+	}
+	model.JsonResponseSucc(c, planList)
+}
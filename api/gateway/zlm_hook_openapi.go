@@ -8,12 +8,14 @@ import (
 	"go-sip/m"
 	"go-sip/model"
 	"go-sip/utils"
+	"go-sip/zlm_hook"
 
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +23,21 @@ import (
 	"go.uber.org/zap"
 )
 
+var zlmHooks = newZlmHooks()
+
+// 网关只处理心跳, 其他事件转发到设备所在的sip服务
+func newZlmHooks() *zlm_hook.Registry {
+	r := zlm_hook.NewRegistry()
+	zlm_hook.On(r, zlm_hook.ServerKeepalive, zlmServerKeepalive) // zlm心跳
+	r.Fallback(forwardZlmHook)
+	return r
+}
+
+// 其他模块订阅zlm hook
+func ZlmHooks() *zlm_hook.Registry {
+	return zlmHooks
+}
+
 // @Summary		hook
 // @Description	zlm 启动，具体业务自行实现
 func ZLMWebHook(c *gin.Context) {
@@ -36,22 +53,20 @@ func ZLMWebHook(c *gin.Context) {
 		m.ZlmWebHookResponse(c, 401, "Unauthorized")
 		return
 	}
-	method := c.Param("method")
-	if method == "" {
+	if c.Param("method") == "" {
 		m.ZlmWebHookResponse(c, -1, "method不能为空")
 		return
 	}
+	zlmHooks.Handle(c)
+}
+
+// 转发hook到sip服务, 转发成功后按事件回复zlm
+func forwardZlmHook(c *gin.Context, method string, bodyBytes []byte) {
 	// 获取参数列表
-	body := c.Request.Body
-	defer body.Close()
-	bodyBytes, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
 	paramsMap := getHookBodyParams(c, bodyBytes)
 	if paramsMap == nil {
 		m.ZlmWebHookResponse(c, -1, "参数错误")
+		return
 	}
 	app := c.Param("app")
 	Logger.Info("gateway ZLMWebHook params", zap.Any("method", method), zap.Any("app", app), zap.Any("paramsMap", paramsMap))
@@ -79,23 +94,41 @@ func ZLMWebHook(c *gin.Context) {
 		return
 	}
 	resp, err := http.DefaultClient.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	if err != nil {
 		m.ZlmWebHookResponse(c, -1, "调用sip hook接口失败")
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		m.ZlmWebHookResponse(c, -1, "调用sip hook接口失败")
+		return
+	}
+	// 鉴权类hook以sip服务的结果为准
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		m.ZlmWebHookResponse(c, -1, "读取sip hook接口结果失败")
+		return
+	}
+	var sipResp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	json.Unmarshal(respBytes, &sipResp)
+	switch method {
+	case zlm_hook.Play.Name, zlm_hook.RtspRealm.Name, zlm_hook.RtspAuth.Name:
+		c.Data(http.StatusOK, "application/json", respBytes)
+		return
+	case zlm_hook.Publish.Name:
+		if sipResp.Code != 0 {
+			m.ZlmWebHookResponse(c, sipResp.Code, sipResp.Msg)
+			return
+		}
+	}
 	switch method {
 	case "on_http_access":
 		c.JSON(http.StatusOK, map[string]any{
 			"code":   0,
 			"second": 86400})
-	case "on_play":
-		var zlmStreamOnPlayData = model.ZlmStreamOnPlayData{}
-		if err := utils.JSONDecode(bodyBytes, &zlmStreamOnPlayData); err != nil {
-			m.ZlmWebHookResponse(c, -1, "json marshal error")
-			return
-		}
-		m.ZlmWebHookResponse(c, 0, "success")
 	case "on_publish":
 		// 推流鉴权
 		c.JSON(http.StatusOK, map[string]any{
@@ -252,3 +285,13 @@ func getSipServerHost(sipServerId string) (string, string, error) {
 	}
 	return sip_server_host, sip_server_id, nil
 }
+
+// zlm心跳不关联设备, 直接在网关记录最近一次心跳时间供wvp健康检查使用
+func zlmServerKeepalive(c *gin.Context, req *model.ZlmServerKeepaliveData) {
+	if req.MediaServerId == "" {
+		zlm_hook.Response(c, -1, "mediaServerId不能为空")
+		return
+	}
+	redis_util.HSet_2(redis.ZLM_NODE_KEEPALIVE_KEY, req.MediaServerId, strconv.FormatInt(time.Now().Unix(), 10))
+	zlm_hook.Success(c)
+}
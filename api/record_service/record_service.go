@@ -0,0 +1,115 @@
+package record_service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-sip/dao"
+	"go-sip/db/redis"
+	. "go-sip/logger"
+	"go-sip/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 回放索引redis缓存, gateway和wvp使用各自的redis客户端
+type PlaybackCache struct {
+	ScanPlaybackRecords func(pattern, min, max string) ([]model.IpcPlaybackRecordData, error)
+	ZRangeByScore       func(key, min, max string) ([]model.IpcPlaybackRecordData, error)
+}
+
+// 查询回放索引, 近期的单日查询优先使用redis缓存, 缓存缺少录像时从mysql查询
+func (p PlaybackCache) RecordList(deviceId, ipcId, recordType string, start, end int64) ([]model.IpcPlaybackRecordData, error) {
+	types := model.ParseRecordTypes(recordType)
+	startDate := time.Unix(start, 0).Format("2006-01-02")
+	endDate := time.Unix(end, 0).Format("2006-01-02")
+	if startDate == endDate && start >= time.Now().AddDate(0, 0, -model.RecordIndexCacheDays).Unix() {
+		ipcRecordList := p.listFromCache(deviceId, ipcId, recordType, start, end)
+		// 写入缓存失败或缓存过期时缓存不完整, 录像数量与mysql一致才使用缓存
+		total, err := dao.GetRecordSegmentTotal(ipcId, types, start, end)
+		if err == nil && int64(len(ipcRecordList)) >= total {
+			return ipcRecordList, nil
+		}
+	}
+
+	segments, err := dao.GetRecordSegments(ipcId, types, start, end)
+	if err != nil {
+		return nil, err
+	}
+	ipcRecordList := make([]model.IpcPlaybackRecordData, 0, len(segments))
+	for _, segment := range segments {
+		ipcRecordList = append(ipcRecordList, segment.PlaybackData())
+	}
+	return ipcRecordList, nil
+}
+
+// 从redis缓存查询回放索引
+func (p PlaybackCache) listFromCache(deviceId, ipcId, recordType string, start, end int64) []model.IpcPlaybackRecordData {
+	min_score := strconv.FormatInt(start, 10)
+	max_score := strconv.FormatInt(end, 10)
+	var ipcRecordList []model.IpcPlaybackRecordData
+	if recordType == "" || recordType == "all" {
+		ipcRecordListSub, _ := p.ScanPlaybackRecords(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, "*"), min_score, max_score)
+		ipcRecordList = append(ipcRecordList, ipcRecordListSub...)
+	} else {
+		typeArr := strings.Split(recordType, ",")
+		for _, typeStr := range typeArr {
+			ipcRecordListSub, _ := p.ZRangeByScore(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, typeStr), min_score, max_score)
+			ipcRecordList = append(ipcRecordList, ipcRecordListSub...)
+		}
+	}
+	return ipcRecordList
+}
+
+// @Summary		分页查询ipc录像片段, 返回查询时间内的缺失时间段和每天的录像覆盖情况
+// @Router		/open/ipc/recordSegments [post]
+// @Router		/wvp/ipc/recordSegments [post]
+func IpcRecordSegments(c *gin.Context) {
+	var req model.RecordSegmentQueryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	if req.End > time.Now().Unix() {
+		req.End = time.Now().Unix()
+	}
+	if req.End <= req.Start {
+		model.JsonResponseSysERR(c, "结束时间格式错误")
+		return
+	}
+	if req.End-req.Start > model.RecordQueryMaxDays*24*3600 {
+		model.JsonResponseSysERR(c, fmt.Sprintf("查询时间跨度不能超过%d天", model.RecordQueryMaxDays))
+		return
+	}
+
+	types := model.ParseRecordTypes(req.Type)
+	total, err := dao.GetRecordSegmentTotal(req.IpcId, types, req.Start, req.End)
+	if err != nil {
+		Logger.Error("查询录像片段总数失败", zap.String("ipcId", req.IpcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像失败")
+		return
+	}
+	list, err := dao.GetPageRecordSegments(req.IpcId, types, req.Start, req.End, req.Page, req.Size)
+	if err != nil {
+		Logger.Error("分页查询录像片段失败", zap.String("ipcId", req.IpcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像失败")
+		return
+	}
+	// 缺失时间段和覆盖情况按全部片段计算
+	segments, err := dao.GetRecordSegments(req.IpcId, types, req.Start, req.End)
+	if err != nil {
+		Logger.Error("查询录像片段失败", zap.String("ipcId", req.IpcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像失败")
+		return
+	}
+	gaps, days := model.BuildRecordTimeline(segments, req.Start, req.End)
+	model.JsonResponseSucc(c, model.RecordSegmentPageResult{
+		PageResult: model.PageResult{Total: total, Page: req.Page, PageSize: req.Size, Data: list},
+		Gaps:       gaps,
+		Days:       days,
+	})
+}
@@ -3,6 +3,7 @@ package api
 import (
 	gapi "go-sip/api/gateway"
 	"go-sip/api/middleware"
+	"go-sip/api/record_service"
 	sapi "go-sip/api/s"
 	wvpapi "go-sip/api/wvp"
 	. "go-sip/common"
@@ -35,6 +36,17 @@ func ServerApiInit(r *gin.Engine) {
 	{
 		r.GET(RecordsListURL, sapi.RecordsList)
 	}
+	// 截图
+	{
+		r.GET(IpcSnapshotURL, sapi.IpcSnapshot)
+	}
+	// 流会话
+	{
+		r.GET(StreamSessionListURL, sapi.StreamSessionList)
+		r.GET(StreamSessionDetailURL, sapi.StreamSessionDetail)
+		r.GET(StreamSessionCloseURL, sapi.StreamSessionClose)
+		r.GET(StreamWaiterStatsURL, sapi.StreamWaiterStats)
+	}
 	// server zlm webhook
 	{
 		r.POST(ZLMWebHookServerURL, sapi.ZLMWebHook)
@@ -47,6 +59,23 @@ func ServerApiInit(r *gin.Engine) {
 		r.GET(IpcPushStreamResetURL, sapi.IpcNoGbPushStreamReset)
 		r.GET(IpcStreamResetURL, sapi.IpcStreamReset)
 		r.GET(DeviceOtaFirmwarePullURL, sapi.OTAFirmwarePull)
+		r.GET(DeviceDiagnosticsURL, sapi.DeviceDiagnostics)
+		r.POST(DeviceConfigUpdateURL, sapi.DeviceConfigUpdate)
+		r.GET(DeviceLogStreamURL, sapi.DeviceLogStream)
+	}
+	// 异步命令类
+	{
+		r.GET(OperationInfoURL, sapi.OperationInfo)
+		r.GET(OperationCancelURL, sapi.OperationCancel)
+	}
+	// 批量命令类
+	{
+		r.POST(BulkJobCreateURL, sapi.BulkJobCreate)
+		r.GET(BulkJobInfoURL, sapi.BulkJobInfo)
+	}
+	// 运维类
+	{
+		r.GET(SipServerDrainURL, sapi.SipServerDrain)
 	}
 
 }
@@ -78,8 +107,10 @@ func GatewayApiInit(r *gin.Engine) {
 		r.GET(OpenGetNotGbIpcListURL, gapi.GetNotGbIpcList)
 		r.GET(OpenGetNotGbIpcUpdateURL, gapi.IpcNotGbInfoUpdate)
 		r.POST(OpenRecordsListURL, gapi.IpcRecordsList)
+		r.POST(OpenRecordSegmentsURL, record_service.IpcRecordSegments)
 		r.POST(OpenIpcPlaybackRecordURL, gapi.IpcPlaybackRecord)
 		r.POST(OpenIpcResetMergeStreamURL, gapi.IpcResetMergeStream)
+		r.GET(OpenRecordPlanListURL, gapi.GetRecordPlanList)
 	}
 
 }
@@ -98,6 +129,10 @@ func WvpApiInit(r *gin.Engine) {
 		r.POST(WvpIpcListURL, wvpapi.GetIpcPage)
 		// 视频回放相关接口
 		r.POST(WvpIpcRecordListURL, wvpapi.IpcRecordsList)
+		r.POST(WvpIpcRecordSegURL, record_service.IpcRecordSegments)
+		r.GET(WvpIpcPlaybackM3u8, wvpapi.IpcPlaybackPlaylist)
+		r.POST(WvpIpcTimelineURL, wvpapi.IpcRecordTimeline)
+		r.POST(WvpIpcTimelinePlay, wvpapi.IpcRecordTimelinePlay)
 
 		r.GET(WvpGetZlmNodeListURL, wvpapi.GetZlmNodeInfoList)
 		r.GET(WvpGetZlmNodeInfoURL, wvpapi.GetZlmNodeInfo)
@@ -108,10 +143,36 @@ func WvpApiInit(r *gin.Engine) {
 		r.GET(WvpGetZlmNodeRelationRegionListURL, wvpapi.ZlmNodeRelationRegionList)
 
 		r.GET(WvpGetZlmRegionListURL, wvpapi.ZlmNodeRegionInfoList)
+		r.POST(WvpAddZlmRegionURL, wvpapi.ZlmNodeRegionAdd)
 		r.POST(WvpUpdateZlmRegionURL, wvpapi.ZlmNodeRegionUpdate)
 
+		// 播放鉴权
+		r.POST(WvpStreamPlayUrlURL, wvpapi.StreamPlayUrl)
+		r.POST(WvpStreamTokenRevokeURL, wvpapi.StreamTokenRevoke)
+		r.PUT(WvpStreamAuthTenantKeyURL, wvpapi.StreamAuthTenantKeyUpdate)
+
+		// 合屏布局
+		r.GET(WvpMergeLayoutListURL, wvpapi.MergeLayoutList)
+		r.POST(WvpMergeLayoutAddURL, wvpapi.AddMergeLayout)
+		r.PUT(WvpMergeLayoutUpdateURL, wvpapi.UpdateMergeLayout)
+		r.DELETE(WvpMergeLayoutDeleteURL, wvpapi.DeleteMergeLayout)
+		r.POST(WvpMergeLayoutApplyURL, wvpapi.ApplyMergeLayout)
+
+		// 录像计划
+		r.GET(WvpRecordPlanListURL, wvpapi.RecordPlanList)
+		r.POST(WvpRecordPlanAddURL, wvpapi.AddRecordPlan)
+		r.PUT(WvpRecordPlanUpdateURL, wvpapi.UpdateRecordPlan)
+		r.DELETE(WvpRecordPlanDeleteURL, wvpapi.DeleteRecordPlan)
+
+		// 推流目标
+		r.GET(WvpPushTargetListURL, wvpapi.PushTargetList)
+		r.POST(WvpPushTargetAddURL, wvpapi.AddPushTarget)
+		r.PUT(WvpPushTargetUpdateURL, wvpapi.UpdatePushTarget)
+		r.DELETE(WvpPushTargetDeleteURL, wvpapi.DeletePushTarget)
+
 		r.GET(WvpGetIotDeviceListURL, wvpapi.GetIotDeviceList)
 		r.POST(WvpIotDeviceListByAiModelURL, wvpapi.GetIotDeviceListByAiModel)
+		r.GET(WvpIotDeviceDiagnosticsURL, wvpapi.GetIotDeviceDiagnostics)
 
 		r.POST(WvpAiModelListURL, wvpapi.QueryAiModelList)
 		r.GET(WvpAiModelCountURL, wvpapi.GetAiModelCount)
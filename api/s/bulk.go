@@ -0,0 +1,62 @@
+package api
+
+import (
+	grpc_server "go-sip/grpc_api/s"
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// @Summary 批量下发命令, 返回任务id
+// @Router /bulk/job/create [post]
+func BulkJobCreate(c *gin.Context) {
+	req := &model.BulkJobCreateReq{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "参数错误: "+err.Error())
+		return
+	}
+	if !grpc_server.IsBulkMethod(req.Method) {
+		m.JsonResponse(c, m.StatusParamsERR, "不支持批量下发的命令: "+req.Method)
+		return
+	}
+	devices, err := grpc_server.ResolveBulkTarget(&grpc_server.BulkTarget{
+		DeviceIds:  req.DeviceIds,
+		StoreNo:    req.StoreNo,
+		RegionCode: req.RegionCode,
+	})
+	if err != nil {
+		Logger.Error("查询批量命令目标设备失败", zap.Error(err))
+		m.JsonResponse(c, m.StatusSysERR, "查询目标设备失败")
+		return
+	}
+	if len(devices) == 0 {
+		m.JsonResponse(c, m.StatusParamsERR, "没有匹配的目标设备")
+		return
+	}
+	jobId := grpc_server.GetSipServer().StartBulkJob(devices, req.Method, req.Params, req.Concurrency, time.Duration(req.TimeoutSec)*time.Second)
+	m.JsonResponse(c, m.StatusSucc, map[string]any{"jobId": jobId, "total": len(devices)})
+}
+
+// @Summary 查询批量命令任务进度和各设备结果
+// @Router /bulk/job/info [get]
+func BulkJobInfo(c *gin.Context) {
+	jobId := c.Query("job_id")
+	if jobId == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "job_id不能为空")
+		return
+	}
+	job, err := grpc_server.GetBulkJob(jobId)
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, "查询任务失败")
+		return
+	}
+	if job == nil {
+		m.JsonResponse(c, m.StatusParamsERR, "任务不存在或已过期")
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, job)
+}
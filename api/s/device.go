@@ -2,16 +2,15 @@ package api
 
 import (
 	"encoding/json"
-	"go-sip/db/redis"
-	redis_util "go-sip/db/redis/redis_server_util"
 	"go-sip/grpc_api"
 	grpc_server "go-sip/grpc_api/s"
 	. "go-sip/logger"
 	"go-sip/m"
+	"go-sip/model"
 	pb "go-sip/signaling"
 
-	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -69,7 +68,7 @@ func DeviceControl(c *gin.Context) {
 		return
 	}
 
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	device_id, err := grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -128,3 +127,69 @@ func SetDevcieAudioVloume(c *gin.Context) {
 	}
 	m.JsonResponse(c, m.StatusSucc, string(result.Payload))
 }
+
+// 设备诊断信息采集超时时间
+const diagnosticsTimeout = 20 * time.Second
+
+// @Summary 采集设备诊断信息
+// @Router /device/diagnostics [GET]
+func DeviceDiagnostics(c *gin.Context) {
+	device_id := c.Query("device_id")
+	if device_id == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "device_id不能为空")
+		return
+	}
+	logLines, _ := strconv.Atoi(c.Query("log_lines"))
+	d, err := json.Marshal(&grpc_api.Diagnostics_Req{LogLines: logLines})
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
+		return
+	}
+
+	sip_server := grpc_server.GetSipServer()
+	result, err := sip_server.ExecuteCommandWithOptions(device_id, &pb.ServerCommand{
+		Method:  m.Diagnostics,
+		Payload: d,
+	}, &grpc_server.CommandOptions{Timeout: diagnosticsTimeout})
+	if err != nil {
+		Logger.Error("采集设备诊断信息失败", zap.String("device id", device_id), zap.Error(err))
+		m.JsonResponse(c, m.StatusSysERR, err)
+		return
+	}
+	if !result.Success {
+		m.JsonResponse(c, m.StatusSysERR, string(result.Payload))
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, json.RawMessage(result.Payload))
+}
+
+// @Summary 远程修改设备配置
+// @Router /device/config/update [POST]
+func DeviceConfigUpdate(c *gin.Context) {
+	req := &model.DeviceConfigUpdateReq{}
+	if err := c.ShouldBindJSON(req); err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "参数错误: "+err.Error())
+		return
+	}
+	d, err := json.Marshal(&grpc_api.Config_Update_Req{Values: req.Values})
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
+		return
+	}
+
+	sip_server := grpc_server.GetSipServer()
+	result, err := sip_server.ExecuteCommand(req.DeviceId, &pb.ServerCommand{
+		Method:  m.ConfigUpdate,
+		Payload: d,
+	})
+	if err != nil {
+		Logger.Error("远程修改设备配置失败", zap.String("device id", req.DeviceId), zap.Error(err))
+		m.JsonResponse(c, m.StatusSysERR, err)
+		return
+	}
+	if !result.Success {
+		m.JsonResponse(c, m.StatusSysERR, string(result.Payload))
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, json.RawMessage(result.Payload))
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	grpc_server "go-sip/grpc_api/s"
+	"go-sip/m"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 下线排空sip服务, 客户端迁移完成后进程退出
+// @Router /sip/drain [get]
+func SipServerDrain(c *gin.Context) {
+	s := grpc_server.GetSipServer()
+	if s.Draining() {
+		m.JsonResponse(c, m.StatusSucc, "sip服务正在下线")
+		return
+	}
+	go s.Drain()
+	m.JsonResponse(c, m.StatusSucc, "开始下线")
+}
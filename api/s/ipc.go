@@ -113,7 +113,7 @@ func IpcStreamReset(c *gin.Context) {
 		return
 	}
 
-	_, err = redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	_, err = grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"go-sip/grpc_api"
+	grpc_server "go-sip/grpc_api/s"
+	. "go-sip/logger"
+	"go-sip/m"
+	pb "go-sip/signaling"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLogStreamDuration = 300  // 默认订阅时长(秒)
+	maxLogStreamDuration     = 1800 // 最长订阅时长(秒)
+	logStreamBuffer          = 1000
+	logStreamExtraTimeout    = 10 * time.Second // 等待客户端订阅到期后返回结果的额外时间
+)
+
+// @Summary 订阅设备实时日志, 以SSE方式推送
+// @Router /device/log/stream [get]
+func DeviceLogStream(c *gin.Context) {
+	device_id := c.Query("device_id")
+	if device_id == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "device_id不能为空")
+		return
+	}
+	duration, _ := strconv.Atoi(c.Query("duration"))
+	if duration <= 0 {
+		duration = defaultLogStreamDuration
+	}
+	if duration > maxLogStreamDuration {
+		duration = maxLogStreamDuration
+	}
+	req := &grpc_api.Log_Subscribe_Req{
+		Level:    c.Query("level"),
+		Contains: c.Query("contains"),
+		Duration: duration,
+	}
+	// 字段过滤格式: key1=value1,key2=value2
+	if fields := c.Query("fields"); fields != "" {
+		req.Fields = map[string]string{}
+		for _, kv := range strings.Split(fields, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				m.JsonResponse(c, m.StatusParamsERR, "fields格式错误")
+				return
+			}
+			req.Fields[k] = v
+		}
+	}
+	d, err := json.Marshal(req)
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
+		return
+	}
+
+	sip_server := grpc_server.GetSipServer()
+	msgID := sip_server.NextMsgID()
+	lines := make(chan string, logStreamBuffer)
+	done := make(chan error, 1)
+	go func() {
+		_, err := sip_server.ExecuteCommandWithOptions(device_id, &pb.ServerCommand{
+			Method:  m.LogSubscribe,
+			Payload: d,
+		}, &grpc_server.CommandOptions{
+			MsgID:   msgID,
+			Timeout: time.Duration(duration)*time.Second + logStreamExtraTimeout,
+			OnProgress: func(p *grpc_api.Command_Progress) {
+				select {
+				case lines <- p.Msg:
+				default:
+				}
+			},
+		})
+		done <- err
+	}()
+	Logger.Info("开始订阅设备日志", zap.String("device id", device_id), zap.Any("req", req))
+
+	finished := false
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case line := <-lines:
+			c.SSEvent("log", line)
+			return true
+		case err := <-done:
+			finished = true
+			// 推送结束前剩余的日志
+			for len(lines) > 0 {
+				c.SSEvent("log", <-lines)
+			}
+			if err != nil {
+				c.SSEvent("error", err.Error())
+			} else {
+				c.SSEvent("end", "日志订阅已结束")
+			}
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+	// 调用方断开时取消客户端的订阅
+	if !finished {
+		Logger.Info("取消订阅设备日志", zap.String("device id", device_id))
+		if err := sip_server.CancelCommand(device_id, msgID); err != nil {
+			Logger.Warn("取消订阅设备日志失败", zap.String("device id", device_id), zap.Error(err))
+		}
+	}
+}
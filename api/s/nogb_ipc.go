@@ -2,9 +2,6 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
-	"go-sip/db/redis"
-	redis_util "go-sip/db/redis/redis_server_util"
 	"go-sip/grpc_api"
 	grpc_server "go-sip/grpc_api/s"
 	. "go-sip/logger"
@@ -35,7 +32,7 @@ func IpcNoGbPushStreamReset(c *gin.Context) {
 		return
 	}
 
-	_, err = redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	_, err = grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -0,0 +1,47 @@
+package api
+
+import (
+	grpc_server "go-sip/grpc_api/s"
+	. "go-sip/logger"
+	"go-sip/m"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// @Summary 查询异步命令执行进度和结果
+// @Router /operation/info [get]
+func OperationInfo(c *gin.Context) {
+	opId := c.Query("op_id")
+	if opId == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "op_id不能为空")
+		return
+	}
+	op, err := grpc_server.GetOperation(opId)
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, "查询操作失败")
+		return
+	}
+	if op == nil {
+		m.JsonResponse(c, m.StatusParamsERR, "操作不存在或已过期")
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, op)
+}
+
+// @Summary 取消执行中的异步命令
+// @Router /operation/cancel [get]
+func OperationCancel(c *gin.Context) {
+	opId := c.Query("op_id")
+	if opId == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "op_id不能为空")
+		return
+	}
+	err := grpc_server.GetSipServer().CancelOperation(opId)
+	if err != nil {
+		Logger.Error("取消异步命令失败", zap.String("op id", opId), zap.Error(err))
+		m.JsonResponse(c, m.StatusSysERR, err)
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "取消成功")
+}
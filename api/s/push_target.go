@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	. "go-sip/logger"
+	"go-sip/model"
+	"go-sip/zlm_api"
+
+	"go.uber.org/zap"
+)
+
+const (
+	pushTargetReconcileInterval = 30 * time.Second
+	pushTargetZlmTimeout        = 5 * time.Second
+	pushTargetStreamWait        = 10 * time.Second // 点播ipc后等待源流注册的时间
+	pushTargetRetryBase         = 30 * time.Second // 失败重试的基础间隔, 按失败次数指数退避
+	pushTargetRetryMaxShift     = 5
+)
+
+// 定时对账推流目标, 按推流时间段启停zlm推流代理, 失败时按退避间隔自动重启
+func PushTargetReconcile() {
+	go func() {
+		timer := time.NewTicker(pushTargetReconcileInterval)
+		defer timer.Stop()
+		for range timer.C {
+			runWithLock(redis.PUSH_TARGET_RECONCILE_LOCK_KEY, pushTargetReconcileInterval-5*time.Second, reconcilePushTargets)
+		}
+	}()
+}
+
+func reconcilePushTargets() {
+	target_map, err := redis_util.HGetAll_2(redis.PUSH_TARGET_KEY)
+	if err != nil {
+		Logger.Error("查询推流目标失败", zap.Error(err))
+		return
+	}
+	status_map, err := redis_util.HGetAll_2(redis.PUSH_TARGET_STATUS_KEY)
+	if err != nil {
+		Logger.Error("查询推流状态失败", zap.Error(err))
+		return
+	}
+
+	// 停止已删除目标的推流
+	for id, status_str := range status_map {
+		if _, ok := target_map[id]; ok {
+			continue
+		}
+		status := model.PushTargetStatus{}
+		if err := json.Unmarshal([]byte(status_str), &status); err == nil {
+			stopPushTarget(&status)
+		}
+		redis_util.HDel_2(redis.PUSH_TARGET_STATUS_KEY, id)
+	}
+
+	now := time.Now()
+	for id, target_str := range target_map {
+		target := model.PushTarget{}
+		if err := json.Unmarshal([]byte(target_str), &target); err != nil {
+			continue
+		}
+		status := &model.PushTargetStatus{Status: model.PushStatusStopped}
+		if status_str, ok := status_map[id]; ok {
+			json.Unmarshal([]byte(status_str), status)
+		}
+		reconcilePushTarget(id, &target, status, now)
+	}
+}
+
+func reconcilePushTarget(id string, target *model.PushTarget, status *model.PushTargetStatus, now time.Time) {
+	should_push := target.ShouldPush(now)
+	changed := status.Version != target.Version
+	defer func() {
+		status.UpdateTime = now.Unix()
+		redis_util.HSetStruct_2(redis.PUSH_TARGET_STATUS_KEY, id, status)
+	}()
+
+	// 目标更新或不在推流时间段时停止推流, 更新后重新计算失败次数
+	if status.Status == model.PushStatusRunning && (changed || !should_push) {
+		stopPushTarget(status)
+	}
+	if changed {
+		status.RetryCount = 0
+		status.NextRetry = 0
+		status.LastError = ""
+	}
+	if !should_push {
+		status.Status = model.PushStatusStopped
+		status.Version = target.Version
+		return
+	}
+
+	if status.Status == model.PushStatusRunning {
+		if pushSourceAlive(status.MediaServerId, target) {
+			return
+		}
+		stopPushTarget(status)
+		failPushTarget(target, status, now, errors.New("源流已断开"))
+		return
+	}
+	// 失败的目标未开启自动重启时, 等待目标更新后再推流
+	if status.Status == model.PushStatusFailed && !changed && (!target.AutoRestart || now.Unix() < status.NextRetry) {
+		return
+	}
+	if err := startPushTarget(target, status); err != nil {
+		Logger.Warn("推流失败", zap.String("id", id), zap.String("ipcId", target.IpcId), zap.Error(err))
+		failPushTarget(target, status, now, err)
+		return
+	}
+	Logger.Info("推流开始", zap.String("id", id), zap.String("ipcId", target.IpcId), zap.String("pusherKey", status.PusherKey))
+}
+
+// 标记推流失败, 自动重启的目标按失败次数指数退避
+func failPushTarget(target *model.PushTarget, status *model.PushTargetStatus, now time.Time, err error) {
+	status.Status = model.PushStatusFailed
+	status.Version = target.Version
+	status.LastError = err.Error()
+	shift := status.RetryCount
+	if shift > pushTargetRetryMaxShift {
+		shift = pushTargetRetryMaxShift
+	}
+	status.NextRetry = now.Add(pushTargetRetryBase << shift).Unix()
+	status.RetryCount++
+}
+
+// 开始推流, 源流不存在时先点播ipc
+func startPushTarget(target *model.PushTarget, status *model.PushTargetStatus) error {
+	device_id, err := ipcDeviceId(target.IpcId)
+	if err != nil {
+		return err
+	}
+	zlmInfo, err := ipcZlmInfo(target.IpcId)
+	if err != nil {
+		return err
+	}
+	stream_id := target.StreamId()
+	if !pushSourceAlive(zlmInfo.ZlmDomain, target) {
+		if err := playIpcStream(zlmInfo, device_id, "rtp", stream_id, 0); err != nil {
+			return err
+		}
+		deadline := time.Now().Add(pushTargetStreamWait)
+		for !pushSourceAlive(zlmInfo.ZlmDomain, target) {
+			if time.Now().After(deadline) {
+				return errors.New("点播ipc超时")
+			}
+			time.Sleep(time.Second)
+		}
+	}
+
+	// 失败重试由server对账控制, 开启自动重启时zlm推流断开也会无限重试
+	retry_count := 0
+	if target.AutoRestart {
+		retry_count = -1
+	}
+	client := zlm_api.NewClientByZlmInfo(zlmInfo, zlm_api.WithTimeout(pushTargetZlmTimeout))
+	key, err := client.AddStreamPusherProxy(context.Background(), zlm_api.StreamPusherProxyReq{
+		Schema:     target.SourceSchema(),
+		App:        "rtp",
+		Stream:     stream_id,
+		DstURL:     target.DstUrl,
+		RetryCount: retry_count,
+	})
+	if err != nil {
+		return err
+	}
+	status.Status = model.PushStatusRunning
+	status.MediaServerId = zlmInfo.ZlmDomain
+	status.StreamId = stream_id
+	status.PusherKey = key
+	status.Version = target.Version
+	status.StartTime = time.Now().Unix()
+	status.RetryCount = 0
+	status.NextRetry = 0
+	status.LastError = ""
+	return nil
+}
+
+// 停止推流, 删除zlm推流代理
+func stopPushTarget(status *model.PushTargetStatus) {
+	if status.PusherKey == "" {
+		return
+	}
+	if zlmInfo, err := zlmNodeInfo(status.MediaServerId); err == nil {
+		client := zlm_api.NewClientByZlmInfo(zlmInfo, zlm_api.WithTimeout(pushTargetZlmTimeout))
+		if err := client.DelStreamPusherProxy(context.Background(), status.PusherKey); err != nil {
+			Logger.Warn("删除推流代理失败", zap.String("pusherKey", status.PusherKey), zap.Error(err))
+		}
+	}
+	status.Status = model.PushStatusStopped
+	status.PusherKey = ""
+}
+
+// 推流源流是否在zlm上注册
+func pushSourceAlive(media_server_id string, target *model.PushTarget) bool {
+	zlmInfo, err := zlmNodeInfo(media_server_id)
+	if err != nil {
+		return false
+	}
+	client := zlm_api.NewClientByZlmInfo(zlmInfo, zlm_api.WithTimeout(pushTargetZlmTimeout))
+	media_list, err := client.GetMediaList(context.Background(), zlm_api.ZlmGetMediaListReq{
+		Schema:   target.SourceSchema(),
+		App:      "rtp",
+		StreamID: target.StreamId(),
+	})
+	return err == nil && len(media_list) > 0
+}
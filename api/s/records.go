@@ -2,9 +2,6 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
-	"go-sip/db/redis"
-	redis_util "go-sip/db/redis/redis_server_util"
 	"go-sip/grpc_api"
 	grpc_server "go-sip/grpc_api/s"
 	. "go-sip/logger"
@@ -12,11 +9,15 @@ import (
 	pb "go-sip/signaling"
 
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// 异步查询录像列表的超时时间
+const recordListAsyncTimeout = 60 * time.Second
+
 //	@Summary		回放文件时间列表
 //	@Description	用来获取通道设备存储的可回放时间段列表，注意控制时间跨度，跨度越大，数据量越多，返回越慢，甚至会超时（最多10s）。
 //	@Tags			records
@@ -66,16 +67,23 @@ func RecordsList(c *gin.Context) {
 		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
 		return
 	}
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	device_id, err := grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
 	}
-	result, err := sip_server.ExecuteCommand(device_id, &pb.ServerCommand{
+	cmd := &pb.ServerCommand{
 		MsgID:   m.MsgID_RecordList,
 		Method:  m.RecordList,
 		Payload: d,
-	})
+	}
+	// 跨度较大时可异步查询，通过操作id轮询结果
+	if c.Query("async") == "true" {
+		opId := sip_server.StartOperation(device_id, cmd, recordListAsyncTimeout)
+		m.JsonResponse(c, m.StatusSucc, map[string]string{"opId": opId})
+		return
+	}
+	result, err := sip_server.ExecuteCommand(device_id, cmd)
 	if err != nil {
 		m.JsonResponse(c, m.StatusSysERR, "中控请求错误，请检查是否掉线")
 		return
@@ -103,7 +111,7 @@ func PausePlay(c *gin.Context) {
 		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
 		return
 	}
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	device_id, err := grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-sip/db/alioss"
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	grpc_server "go-sip/grpc_api/s"
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+	"go-sip/zlm_api"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	snapshotDefaultTTL  = 300 * time.Second // 截图缓存默认有效期
+	snapshotTimeout     = 15                // zlm截图超时, 单位秒, 包含拉起子码流的时间
+	snapshotLockTime    = 30 * time.Second
+	snapshotUrlExpire   = time.Hour // 截图临时访问地址有效期
+	snapshotConcurrency = 4         // 封面刷新的并发截图数
+)
+
+// @Summary		ipc截图
+// @Description	使用zlm对ipc子码流截图, 流不存在时通过zlm流未找到事件拉起子码流, 截图缓存在oss, 有效期内直接返回缓存
+// @Tags			ipc
+// @Param			ipc_id	query		string	true	"ipc id"
+// @Param			refresh	query		bool	false	"是否忽略缓存重新截图"
+// @Success		0		{object}	model.IpcSnapshot
+// @Router			/ipc/snapshot [get]
+func IpcSnapshot(c *gin.Context) {
+	ipc_id := c.Query("ipc_id")
+	if ipc_id == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "ipc_id不能为空")
+		return
+	}
+	snapshot, err := ipcSnapshot(ipc_id, c.Query("refresh") == "true")
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, err.Error())
+		return
+	}
+	snapshot.Url, err = alioss.SignObjectURL(snapshot.OssKey, snapshotUrlExpire)
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, err.Error())
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, snapshot)
+}
+
+func snapshotTTL() time.Duration {
+	if m.SMConfig.Snapshot.TTL > 0 {
+		return time.Duration(m.SMConfig.Snapshot.TTL) * time.Second
+	}
+	return snapshotDefaultTTL
+}
+
+// 查询缓存的截图
+func cachedIpcSnapshot(ipc_id string) *model.IpcSnapshot {
+	snapshot_str, err := redis_util.HGet_2(redis.IPC_SNAPSHOT_KEY, ipc_id)
+	if err != nil || snapshot_str == "" {
+		return nil
+	}
+	snapshot := &model.IpcSnapshot{}
+	if err := json.Unmarshal([]byte(snapshot_str), snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// 获取ipc截图, 缓存有效期内且未强制刷新时返回缓存
+func ipcSnapshot(ipc_id string, force bool) (*model.IpcSnapshot, error) {
+	cached := cachedIpcSnapshot(ipc_id)
+	if cached != nil && !force && time.Since(time.Unix(cached.SnapTime, 0)) < snapshotTTL() {
+		return cached, nil
+	}
+	// 同一ipc同时只截图一次, 其他请求返回旧截图
+	ok, _ := redis_util.SetNX(fmt.Sprintf(redis.IPC_SNAPSHOT_LOCK_KEY, ipc_id), "ok", snapshotLockTime)
+	if !ok {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, errors.New("正在截图，请稍后重试")
+	}
+	defer redis_util.Del_2(fmt.Sprintf(redis.IPC_SNAPSHOT_LOCK_KEY, ipc_id))
+
+	zlmInfo, err := ipcZlmInfo(ipc_id)
+	if err != nil {
+		return nil, err
+	}
+	// 使用子码流截图, zlm从本节点拉流, 流不存在时触发流未找到事件点播ipc
+	// 使用节点ip而不是回环地址, 开启播放鉴权时按zlm节点识别为内部拉流
+	stream_id := fmt.Sprintf("%s_0", ipc_id)
+	stream_url := fmt.Sprintf("rtsp://%s:554/rtp/%s?mode=0", zlmInfo.ZlmIp, stream_id)
+	client := zlm_api.NewClientByZlmInfo(zlmInfo, zlm_api.WithTimeout((snapshotTimeout+5)*time.Second))
+	data, err := client.Snap(context.Background(), stream_url, snapshotTimeout, int(snapshotTTL().Seconds()))
+	if err != nil {
+		Logger.Error("ipc截图失败", zap.String("ipcId", ipc_id), zap.Error(err))
+		return nil, errors.New("截图失败")
+	}
+
+	// 每个ipc只保留最新截图
+	snapshot := &model.IpcSnapshot{
+		IpcId:    ipc_id,
+		StreamId: stream_id,
+		OssKey:   fmt.Sprintf("ipc_snapshot/%s.jpg", ipc_id),
+		SnapTime: time.Now().Unix(),
+	}
+	if err := alioss.UploadData(snapshot.OssKey, data); err != nil {
+		return nil, err
+	}
+	if err := redis_util.HSetStruct_2(redis.IPC_SNAPSHOT_KEY, ipc_id, snapshot); err != nil {
+		Logger.Warn("保存截图信息失败", zap.String("ipcId", ipc_id), zap.Error(err))
+	}
+	return snapshot, nil
+}
+
+// 查询ipc所属设备, ipc_id以IPC开头表示非国标摄像头
+func ipcDeviceId(ipc_id string) (string, error) {
+	var device_id string
+	var err error
+	if strings.HasPrefix(ipc_id, "IPC") {
+		device_id, err = redis_util.HGet_2(redis.NOT_GB_IPC_DEVICE, ipc_id)
+	} else {
+		device_id, err = grpc_server.GetIpcDeviceId(ipc_id)
+	}
+	if err != nil || device_id == "" {
+		return "", errors.New("ipc_id未注册，请检查摄像头是否正常")
+	}
+	return device_id, nil
+}
+
+// 查询ipc所属设备关联的zlm节点
+func ipcZlmInfo(ipc_id string) (*model.ZlmInfo, error) {
+	device_id, err := ipcDeviceId(ipc_id)
+	if err != nil {
+		return nil, err
+	}
+	zlm_domain, err := redis_util.HGet_2(redis.DEVICE_ZLM_KEY, device_id)
+	if err != nil || zlm_domain == "" {
+		return nil, errors.New("设备未关联zlm节点")
+	}
+	return zlmNodeInfo(zlm_domain)
+}
+
+// 根据zlm域名(即mediaServerId)查询zlm节点信息
+func zlmNodeInfo(zlm_domain string) (*model.ZlmInfo, error) {
+	zlm_info_str, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, zlm_domain)
+	if err != nil || zlm_info_str == "" {
+		return nil, errors.New("获取zlm信息失败")
+	}
+	zlmInfo := &model.ZlmInfo{}
+	if err := json.Unmarshal([]byte(zlm_info_str), zlmInfo); err != nil {
+		return nil, errors.New("zlm信息格式错误")
+	}
+	return zlmInfo, nil
+}
+
+// 定时刷新在线ipc的封面截图
+func IpcSnapshotRefresh() {
+	if m.SMConfig.Snapshot.Interval <= 0 {
+		Logger.Info("未开启ipc封面刷新")
+		return
+	}
+	interval := time.Duration(m.SMConfig.Snapshot.Interval) * time.Second
+	go func() {
+		timer := time.NewTicker(interval)
+		defer timer.Stop()
+		for range timer.C {
+			runWithLock(redis.IPC_SNAPSHOT_REFRESH_LOCK_KEY, interval/2, refreshIpcSnapshots)
+		}
+	}()
+}
+
+func refreshIpcSnapshots() {
+	device_ipc_info_map, err := redis_util.HGetAll_2(redis.DEVICE_IPC_INFO_KEY)
+	if err != nil || len(device_ipc_info_map) == 0 {
+		Logger.Debug("未查询到ipc列表")
+		return
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, snapshotConcurrency)
+	count := 0
+	for ipc_id := range device_ipc_info_map {
+		status, _ := redis_util.Get_2(fmt.Sprintf(redis.IPC_STATUS_KEY, ipc_id))
+		if status != m.DeviceStatusON {
+			continue
+		}
+		count++
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ipc_id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if _, err := ipcSnapshot(ipc_id, false); err != nil {
+				Logger.Warn("刷新ipc封面失败", zap.String("ipcId", ipc_id), zap.Error(err))
+			}
+		}(ipc_id)
+	}
+	wg.Wait()
+	Logger.Info("ipc封面刷新完成", zap.Int("count", count))
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	grpc_server "go-sip/grpc_api/s"
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+	"go-sip/stream_auth"
+
+	"go.uber.org/zap"
+)
+
+const (
+	rtspAuthRealm     = "go-sip"    // rtsp digest鉴权的realm
+	rtspSessionExpire = time.Minute // rtsp鉴权通过后, 等待on_play的有效期
+)
+
+type streamKeyStore struct{}
+
+func (streamKeyStore) TenantKey(tenant string) (string, error) {
+	if tenant != "" {
+		if key, _ := redis_util.HGet_2(redis.STREAM_AUTH_TENANT_KEY, tenant); key != "" {
+			return key, nil
+		}
+	}
+	key, err := redis_util.HGet_2(redis.STREAM_AUTH_TENANT_KEY, stream_auth.DefaultTenant)
+	if err != nil || key == "" {
+		return "", errors.New("播放鉴权密钥未初始化")
+	}
+	return key, nil
+}
+
+func (streamKeyStore) IsRevoked(id string) bool {
+	revoked, _ := redis_util.Get_2(fmt.Sprintf(redis.STREAM_AUTH_REVOKED_KEY, id))
+	return revoked != ""
+}
+
+// 流所属租户, 即ipc所在设备关联的门店, 合屏流的流id为设备id
+func streamTenant(stream string) string {
+	ipc_id := strings.Split(stream, "_")[0]
+	device_id, _ := grpc_server.GetIpcDeviceId(ipc_id)
+	if device_id == "" {
+		device_id = ipc_id
+	}
+	tenant, _ := redis_util.HGet_4(redis.IOT_DEVICE_STORE_KEY, device_id)
+	return tenant
+}
+
+// 校验播放token
+func checkStreamToken(token, stream, ip string) (*stream_auth.Claims, error) {
+	claims, err := stream_auth.Check(streamKeyStore{}, token, streamTenant(stream), stream, ip, stream_auth.ScopePlay)
+	if err != nil {
+		Logger.Warn("播放token校验失败", zap.String("stream", stream), zap.String("ip", ip), zap.Error(err))
+		return nil, err
+	}
+	return claims, nil
+}
+
+// zlm节点自身发起的拉流(如合屏)和配置的内部服务不需要token
+// 回环地址不直接放行, 同机部署的反向代理转发的播放请求也是回环地址
+func isInternalPlayer(mediaServerId, ip string) bool {
+	client := net.ParseIP(ip)
+	if client == nil {
+		return false
+	}
+	for _, allow_ip := range m.SMConfig.StreamAuthIps {
+		if client.Equal(net.ParseIP(allow_ip)) {
+			return true
+		}
+	}
+	zlm_info_str, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, mediaServerId)
+	if err != nil || zlm_info_str == "" {
+		return false
+	}
+	var zlmInfo model.ZlmInfo
+	if err := json.Unmarshal([]byte(zlm_info_str), &zlmInfo); err != nil {
+		return false
+	}
+	return client.Equal(net.ParseIP(zlmInfo.ZlmIp))
+}
+
+// 记录rtsp鉴权通过的会话, 之后的on_play不再要求url携带token
+func setRtspSession(sessionId, stream string) {
+	redis_util.Set_2(fmt.Sprintf(redis.STREAM_AUTH_RTSP_SESSION, sessionId), stream, rtspSessionExpire)
+}
+
+func isRtspSessionAuthed(sessionId, stream string) bool {
+	if sessionId == "" {
+		return false
+	}
+	authed, _ := redis_util.Get_2(fmt.Sprintf(redis.STREAM_AUTH_RTSP_SESSION, sessionId))
+	return authed == stream
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+	"go-sip/zlm_api"
+
+	"go.uber.org/zap"
+)
+
+const (
+	streamRecoveryInterval       = 5 * time.Second
+	streamRecoveryDefaultBackoff = 2 * time.Second
+	streamRecoveryMaxShift       = 5 // 重试间隔最多翻倍的次数
+	streamRecoveryZlmTimeout     = 5 * time.Second
+)
+
+func streamRecoveryBackoff(attempts int) time.Duration {
+	backoff := streamRecoveryDefaultBackoff
+	if m.SMConfig.StreamRecovery.Backoff > 0 {
+		backoff = time.Duration(m.SMConfig.StreamRecovery.Backoff) * time.Second
+	}
+	if attempts > streamRecoveryMaxShift {
+		attempts = streamRecoveryMaxShift
+	}
+	return backoff << attempts
+}
+
+// 流需要恢复的原因, 无人观看、未录像且未ai分析的流不恢复
+func streamRecoveryReason(session *model.StreamSession) string {
+	if session.Readers > 0 {
+		return "有人观看"
+	}
+	if session.Recording {
+		return "录像中"
+	}
+	if class_name, _ := redis_util.HGet_2(redis.AI_MODEL_STREAM_CLASSNAME_KEY, session.Stream); class_name != "" {
+		return "ai分析中"
+	}
+	return ""
+}
+
+// 流中断时登记待恢复的流, 需要在流会话删除前调用
+func streamInterrupted(media_server_id, app, stream string) {
+	if m.SMConfig.StreamRecovery.MaxRetry <= 0 || app != "rtp" {
+		return
+	}
+	key := streamSessionKey(media_server_id, app, stream)
+	if recovery_str, _ := redis_util.HGet_2(redis.STREAM_RECOVERY_KEY, key); recovery_str != "" {
+		return
+	}
+	// 合屏流由播放端重新请求拉起
+	session := getStreamSession(key)
+	if session == nil || session.IpcId == "" {
+		return
+	}
+	reason := streamRecoveryReason(session)
+	if reason == "" {
+		return
+	}
+	now := time.Now()
+	recovery := &model.StreamRecovery{
+		MediaServerId: media_server_id,
+		App:           app,
+		Stream:        stream,
+		IpcId:         session.IpcId,
+		DeviceId:      session.DeviceId,
+		Mode:          session.Mode,
+		Reason:        reason,
+		NextRetry:     now.Add(streamRecoveryBackoff(0)).Unix(),
+		StartTime:     now.Unix(),
+	}
+	if err := redis_util.HSetStruct_2(redis.STREAM_RECOVERY_KEY, key, recovery); err != nil {
+		Logger.Error("保存待恢复流失败", zap.String("key", key), zap.Error(err))
+		return
+	}
+	publishIpcStreamAlert(recovery, model.IpcStreamInterrupted, reason)
+}
+
+// 流重新注册时结束恢复
+func streamRecovered(media_server_id, app, stream string) {
+	key := streamSessionKey(media_server_id, app, stream)
+	recovery := getStreamRecovery(key)
+	if recovery == nil {
+		return
+	}
+	redis_util.HDel_2(redis.STREAM_RECOVERY_KEY, key)
+	publishIpcStreamAlert(recovery, model.IpcStreamRecovered, recovery.Reason)
+}
+
+func getStreamRecovery(key string) *model.StreamRecovery {
+	recovery_str, err := redis_util.HGet_2(redis.STREAM_RECOVERY_KEY, key)
+	if err != nil || recovery_str == "" {
+		return nil
+	}
+	recovery := &model.StreamRecovery{}
+	if err := json.Unmarshal([]byte(recovery_str), recovery); err != nil {
+		return nil
+	}
+	return recovery
+}
+
+// 定时重新点播中断的流, 按退避间隔重试, 超过最大次数后放弃
+func StreamRecoveryHandler() {
+	if m.SMConfig.StreamRecovery.MaxRetry <= 0 {
+		Logger.Info("未开启流中断自动恢复")
+		return
+	}
+	go func() {
+		timer := time.NewTicker(streamRecoveryInterval)
+		defer timer.Stop()
+		for range timer.C {
+			runWithLock(redis.STREAM_RECOVERY_LOCK_KEY, streamRecoveryInterval-time.Second, recoverStreams)
+		}
+	}()
+}
+
+func recoverStreams() {
+	recovery_map, err := redis_util.HGetAll_2(redis.STREAM_RECOVERY_KEY)
+	if err != nil {
+		Logger.Error("查询待恢复流失败", zap.Error(err))
+		return
+	}
+	now := time.Now()
+	for key, recovery_str := range recovery_map {
+		recovery := &model.StreamRecovery{}
+		if err := json.Unmarshal([]byte(recovery_str), recovery); err != nil {
+			redis_util.HDel_2(redis.STREAM_RECOVERY_KEY, key)
+			continue
+		}
+		if now.Unix() < recovery.NextRetry {
+			continue
+		}
+		zlmInfo, err := zlmNodeInfo(recovery.MediaServerId)
+		if err == nil {
+			// 错过流注册事件时按流列表判断是否已恢复
+			client := zlm_api.NewClientByZlmInfo(zlmInfo, zlm_api.WithTimeout(streamRecoveryZlmTimeout))
+			media_list, err := client.GetMediaList(context.Background(), zlm_api.ZlmGetMediaListReq{
+				Schema: "rtsp", App: recovery.App, StreamID: recovery.Stream,
+			})
+			if err == nil && len(media_list) > 0 {
+				streamRecovered(recovery.MediaServerId, recovery.App, recovery.Stream)
+				continue
+			}
+		}
+		if recovery.Attempts >= m.SMConfig.StreamRecovery.MaxRetry {
+			redis_util.HDel_2(redis.STREAM_RECOVERY_KEY, key)
+			publishIpcStreamAlert(recovery, model.IpcStreamAbandoned, recovery.LastError)
+			continue
+		}
+
+		// 先保存重试次数, 点播成功后流注册事件会删除待恢复记录
+		recovery.Attempts++
+		recovery.NextRetry = now.Add(streamRecoveryBackoff(recovery.Attempts)).Unix()
+		if err == nil {
+			redis_util.HSetStruct_2(redis.STREAM_RECOVERY_KEY, key, recovery)
+			err = playIpcStream(zlmInfo, recovery.DeviceId, recovery.App, recovery.Stream, recovery.Mode)
+		}
+		if err != nil {
+			Logger.Warn("重新点播中断的流失败", zap.String("stream", recovery.Stream), zap.Int("attempts", recovery.Attempts), zap.Error(err))
+			recovery.LastError = err.Error()
+			redis_util.HSetStruct_2(redis.STREAM_RECOVERY_KEY, key, recovery)
+			continue
+		}
+		Logger.Info("重新点播中断的流", zap.String("stream", recovery.Stream), zap.Int("attempts", recovery.Attempts))
+	}
+}
+
+// 发布ipc流事件
+func publishIpcStreamAlert(recovery *model.StreamRecovery, status, reason string) {
+	event := model.IpcStreamAlertEvent{
+		IpcId:         recovery.IpcId,
+		DeviceId:      recovery.DeviceId,
+		MediaServerId: recovery.MediaServerId,
+		Stream:        recovery.Stream,
+		Status:        status,
+		Reason:        reason,
+		Attempts:      recovery.Attempts,
+		Time:          time.Now().Unix(),
+	}
+	Logger.Warn("ipc流事件", zap.Any("event", event))
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	redis_util.Publish_2(redis.IPC_STREAM_ALERT_CHANNEL, string(data))
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"go-sip/m"
+)
+
+func TestStreamRecoveryBackoff(t *testing.T) {
+	old := m.SMConfig
+	defer func() { m.SMConfig = old }()
+
+	tests := []struct {
+		name     string
+		backoff  int
+		attempts int
+		want     time.Duration
+	}{
+		{"默认首次间隔", 0, 0, 2 * time.Second},
+		{"默认间隔翻倍", 0, 1, 4 * time.Second},
+		{"配置首次间隔", 3, 0, 3 * time.Second},
+		{"配置间隔翻倍", 3, 2, 12 * time.Second},
+		{"负数使用默认间隔", -1, 1, 4 * time.Second},
+		{"最多翻倍5次", 2, streamRecoveryMaxShift, 64 * time.Second},
+		{"超过最大次数不再翻倍", 2, 20, 64 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.SMConfig = &m.S_Config{StreamRecovery: m.StreamRecoveryConfig{Backoff: tt.backoff}}
+			if got := streamRecoveryBackoff(tt.attempts); got != tt.want {
+				t.Fatalf("streamRecoveryBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	grpc_server "go-sip/grpc_api/s"
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+	"go-sip/zlm_api"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	streamSessionSampleInterval = 30 * time.Second
+	streamSessionSeriesSize     = 120 // 保留最近1小时的采样点
+	streamSessionSeriesExpire   = 10 * time.Minute
+	streamSessionZlmTimeout     = 5 * time.Second
+)
+
+// 查询播放者时遍历的协议
+var streamPlayerSchemas = []string{"rtsp", "rtmp", "fmp4", "ts", "hls"}
+
+// 流会话field, 同一个流在各协议只记录一个会话
+func streamSessionKey(media_server_id, app, stream string) string {
+	return fmt.Sprintf("%s|%s|%s", media_server_id, app, stream)
+}
+
+func getStreamSession(key string) *model.StreamSession {
+	session_str, err := redis_util.HGet_2(redis.STREAM_SESSION_KEY, key)
+	if err != nil || session_str == "" {
+		return nil
+	}
+	session := &model.StreamSession{}
+	if err := json.Unmarshal([]byte(session_str), session); err != nil {
+		return nil
+	}
+	return session
+}
+
+func saveStreamSession(session *model.StreamSession) {
+	key := streamSessionKey(session.MediaServerId, session.App, session.Stream)
+	if err := redis_util.HSetStruct_2(redis.STREAM_SESSION_KEY, key, session); err != nil {
+		Logger.Warn("保存流会话失败", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func deleteStreamSession(key string) {
+	redis_util.HDel_2(redis.STREAM_SESSION_KEY, key)
+	redis_util.HDel_2(redis.STREAM_PLAY_MODE_KEY, key)
+	redis_util.Del_2(fmt.Sprintf(redis.STREAM_SESSION_SERIES_KEY, key))
+}
+
+// 记录点播时的rtp传输模式, 流中断后按原模式重新点播
+func saveStreamPlayMode(media_server_id, app, stream string, mode int) {
+	redis_util.HSet_2(redis.STREAM_PLAY_MODE_KEY, streamSessionKey(media_server_id, app, stream), strconv.Itoa(mode))
+}
+
+// 新建流会话, 流id格式为 ipcId_码流, 合屏流的流id为设备id
+func newStreamSession(media_server_id, app, stream string) *model.StreamSession {
+	session := &model.StreamSession{
+		MediaServerId: media_server_id,
+		App:           app,
+		Stream:        stream,
+		IpcId:         strings.Split(stream, "_")[0],
+		StartTime:     time.Now().Unix(),
+	}
+	if mode, _ := redis_util.HGet_2(redis.STREAM_PLAY_MODE_KEY, streamSessionKey(media_server_id, app, stream)); mode != "" {
+		session.Mode, _ = strconv.Atoi(mode)
+	}
+	if strings.HasPrefix(session.IpcId, "IPC") {
+		session.DeviceId, _ = redis_util.HGet_2(redis.NOT_GB_IPC_DEVICE, session.IpcId)
+	} else if device_id, err := grpc_server.GetIpcDeviceId(session.IpcId); err == nil && device_id != "" {
+		session.DeviceId = device_id
+	} else {
+		// 合屏流
+		session.DeviceId = session.IpcId
+		session.IpcId = ""
+	}
+	return session
+}
+
+// 流注册和注销时维护流会话, 每个协议都会触发, 只处理rtsp
+func streamSessionChanged(req *model.ZLMStreamChangedData) {
+	if req.Schema != "rtsp" {
+		return
+	}
+	key := streamSessionKey(req.MediaServerId, req.APP, req.Stream)
+	if !req.Regist {
+		deleteStreamSession(key)
+		return
+	}
+	if getStreamSession(key) == nil {
+		saveStreamSession(newStreamSession(req.MediaServerId, req.APP, req.Stream))
+	}
+}
+
+// 播放结束时累计播放次数和流量
+func streamSessionFlow(req *model.ZlmFlowReportData) {
+	if !req.Player {
+		return
+	}
+	session := getStreamSession(streamSessionKey(req.MediaServerID, req.App, req.Stream))
+	if session == nil {
+		return
+	}
+	session.PlayCount++
+	session.PlayBytes += req.TotalBytes
+	saveStreamSession(session)
+}
+
+// 无人观看关闭流前清零观看人数, 流注销时不再按采集的观看人数恢复流
+func streamSessionNoneReader(media_server_id, app, stream string) {
+	session := getStreamSession(streamSessionKey(media_server_id, app, stream))
+	if session == nil || session.Readers == 0 {
+		return
+	}
+	session.Readers = 0
+	saveStreamSession(session)
+}
+
+// 定时采集zlm流列表, 更新流会话的码率和观看人数
+func StreamSessionSample() {
+	go func() {
+		timer := time.NewTicker(streamSessionSampleInterval)
+		defer timer.Stop()
+		for range timer.C {
+			runWithLock(redis.STREAM_SESSION_SAMPLE_LOCK_KEY, streamSessionSampleInterval-5*time.Second, sampleStreamSessions)
+		}
+	}()
+}
+
+func sampleStreamSessions() {
+	zlm_node_map, err := redis_util.HGetAll_2(redis.WVP_ZLM_NODE_INFO)
+	if err != nil {
+		Logger.Error("查询zlm节点失败", zap.Error(err))
+		return
+	}
+	session_map, err := redis_util.HGetAll_2(redis.STREAM_SESSION_KEY)
+	if err != nil {
+		Logger.Error("查询流会话失败", zap.Error(err))
+		return
+	}
+
+	now := time.Now().Unix()
+	seen := map[string]bool{}
+	failed := map[string]bool{}
+	for media_server_id, zlm_info_str := range zlm_node_map {
+		zlmInfo := model.ZlmInfo{}
+		if err := json.Unmarshal([]byte(zlm_info_str), &zlmInfo); err != nil {
+			failed[media_server_id] = true
+			continue
+		}
+		client := zlm_api.NewClientByZlmInfo(&zlmInfo, zlm_api.WithTimeout(streamSessionZlmTimeout))
+		media_list, err := client.GetMediaList(context.Background(), zlm_api.ZlmGetMediaListReq{Schema: "rtsp"})
+		if err != nil {
+			// 节点暂时不可用时保留会话
+			Logger.Warn("采集zlm流列表失败", zap.String("mediaServerId", media_server_id), zap.Error(err))
+			failed[media_server_id] = true
+			continue
+		}
+		for _, media := range media_list {
+			key := streamSessionKey(media_server_id, media.App, media.Stream)
+			seen[key] = true
+			session := getStreamSession(key)
+			if session == nil {
+				session = newStreamSession(media_server_id, media.App, media.Stream)
+				if media.CreateStamp > 0 {
+					session.StartTime = media.CreateStamp
+				}
+			}
+			session.OriginType = media.OriginType
+			session.OriginTypeStr = media.OriginTypeStr
+			session.Bitrate = media.BytesSpeed * 8
+			session.Readers = media.TotalReaderCount
+			session.UpdateTime = now
+			if media.App == "rtp" {
+				session.Recording, _ = client.IsRecording(context.Background(), media.Stream)
+			}
+			for _, track := range media.Tracks {
+				if track.Type == 0 {
+					session.VideoCodec = track.CodecIdName
+					session.Width = track.Width
+					session.Height = track.Height
+					session.Fps = track.FPS
+				} else {
+					session.AudioCodec = track.CodecIdName
+				}
+			}
+			saveStreamSession(session)
+			appendStreamSample(key, model.StreamSample{Time: now, Bitrate: session.Bitrate, Readers: session.Readers})
+		}
+	}
+
+	// 清理错过注销事件的会话
+	for key, session_str := range session_map {
+		if seen[key] {
+			continue
+		}
+		session := model.StreamSession{}
+		if err := json.Unmarshal([]byte(session_str), &session); err == nil && failed[session.MediaServerId] {
+			continue
+		}
+		deleteStreamSession(key)
+	}
+}
+
+// 追加采样点, 只保留最近的采样点
+func appendStreamSample(key string, sample model.StreamSample) {
+	series := getStreamSeries(key)
+	series = append(series, sample)
+	if len(series) > streamSessionSeriesSize {
+		series = series[len(series)-streamSessionSeriesSize:]
+	}
+	data, err := json.Marshal(series)
+	if err != nil {
+		return
+	}
+	redis_util.Set_2(fmt.Sprintf(redis.STREAM_SESSION_SERIES_KEY, key), string(data), streamSessionSeriesExpire)
+}
+
+func getStreamSeries(key string) []model.StreamSample {
+	series := []model.StreamSample{}
+	series_str, err := redis_util.Get_2(fmt.Sprintf(redis.STREAM_SESSION_SERIES_KEY, key))
+	if err != nil || series_str == "" {
+		return series
+	}
+	json.Unmarshal([]byte(series_str), &series)
+	return series
+}
+
+// @Summary		流会话列表
+// @Description	按开始时间倒序返回当前的流会话, 可按设备、ipc和zlm节点过滤
+// @Tags			streams
+// @Param			device_id		query		string	false	"设备id"
+// @Param			ipc_id			query		string	false	"ipc id"
+// @Param			media_server_id	query		string	false	"zlm节点id"
+// @Success		0				{object}	[]model.StreamSession
+// @Router			/stream/sessions [get]
+func StreamSessionList(c *gin.Context) {
+	device_id := c.Query("device_id")
+	ipc_id := c.Query("ipc_id")
+	media_server_id := c.Query("media_server_id")
+
+	session_map, err := redis_util.HGetAll_2(redis.STREAM_SESSION_KEY)
+	if err != nil {
+		m.JsonResponse(c, m.StatusSysERR, "查询流会话失败")
+		return
+	}
+	list := []model.StreamSession{}
+	for _, session_str := range session_map {
+		session := model.StreamSession{}
+		if err := json.Unmarshal([]byte(session_str), &session); err != nil {
+			continue
+		}
+		if (device_id != "" && session.DeviceId != device_id) || (ipc_id != "" && session.IpcId != ipc_id) ||
+			(media_server_id != "" && session.MediaServerId != media_server_id) {
+			continue
+		}
+		list = append(list, session)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartTime > list[j].StartTime })
+	m.JsonResponse(c, m.StatusSucc, list)
+}
+
+// @Summary		流会话详情
+// @Description	返回流会话、近期码率和观看人数, 以及当前播放者
+// @Tags			streams
+// @Param			media_server_id	query		string	true	"zlm节点id"
+// @Param			app				query		string	true	"应用名"
+// @Param			stream			query		string	true	"流id"
+// @Success		0				{object}	model.StreamSessionDetail
+// @Router			/stream/session [get]
+func StreamSessionDetail(c *gin.Context) {
+	media_server_id := c.Query("media_server_id")
+	app := c.Query("app")
+	stream := c.Query("stream")
+	if media_server_id == "" || app == "" || stream == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "参数错误")
+		return
+	}
+	key := streamSessionKey(media_server_id, app, stream)
+	session := getStreamSession(key)
+	if session == nil {
+		m.JsonResponse(c, m.StatusParamsERR, "流会话不存在")
+		return
+	}
+	detail := model.StreamSessionDetail{
+		StreamSession: *session,
+		Series:        getStreamSeries(key),
+		Players:       []model.StreamPlayer{},
+	}
+	if zlmInfo, err := zlmNodeInfo(media_server_id); err == nil {
+		client := zlm_api.NewClientByZlmInfo(zlmInfo, zlm_api.WithTimeout(streamSessionZlmTimeout))
+		for _, schema := range streamPlayerSchemas {
+			players, err := client.GetMediaPlayerList(context.Background(), schema, app, stream)
+			if err != nil {
+				continue
+			}
+			for _, player := range players {
+				detail.Players = append(detail.Players, model.StreamPlayer{Ip: player.PeerIp, Port: player.PeerPort, Protocol: schema})
+			}
+		}
+	}
+	m.JsonResponse(c, m.StatusSucc, detail)
+}
+
+// @Summary		强制关闭流会话
+// @Description	关闭zlm上该流的所有协议, 国标流注销时通知设备停止推流
+// @Tags			streams
+// @Param			media_server_id	query		string	true	"zlm节点id"
+// @Param			app				query		string	true	"应用名"
+// @Param			stream			query		string	true	"流id"
+// @Success		0				{object}	string
+// @Router			/stream/session/close [get]
+func StreamSessionClose(c *gin.Context) {
+	media_server_id := c.Query("media_server_id")
+	app := c.Query("app")
+	stream := c.Query("stream")
+	if media_server_id == "" || app == "" || stream == "" {
+		m.JsonResponse(c, m.StatusParamsERR, "参数错误")
+		return
+	}
+	zlmInfo, err := zlmNodeInfo(media_server_id)
+	if err != nil {
+		m.JsonResponse(c, m.StatusParamsERR, err.Error())
+		return
+	}
+	// 先删除流会话, 手动关闭的流注销时不自动恢复
+	deleteStreamSession(streamSessionKey(media_server_id, app, stream))
+	client := zlm_api.NewClientByZlmInfo(zlmInfo, zlm_api.WithTimeout(streamSessionZlmTimeout))
+	if err := client.CloseStream(context.Background(), app, stream); err != nil {
+		Logger.Error("关闭流失败", zap.String("mediaServerId", media_server_id), zap.String("stream", stream), zap.Error(err))
+		m.JsonResponse(c, m.StatusSysERR, "关闭流失败")
+		return
+	}
+	m.JsonResponse(c, m.StatusSucc, "关闭成功")
+}
+
+// @Summary		等待流注册统计
+// @Description	返回流不存在时等待流注册的请求数、点播次数、超时次数和平均等待耗时
+// @Tags			streams
+// @Success		0	{object}	zlm_hook.StreamWaiterStats
+// @Router			/stream/waiter/stats [get]
+func StreamWaiterStats(c *gin.Context) {
+	m.JsonResponse(c, m.StatusSucc, streamWaiter.Stats())
+}
@@ -3,8 +3,6 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"go-sip/db/redis"
-	redis_util "go-sip/db/redis/redis_server_util"
 	"go-sip/grpc_api"
 	grpc_server "go-sip/grpc_api/s"
 	"go-sip/m"
@@ -13,10 +11,14 @@ import (
 
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 异步回放点播的超时时间
+const playbackAsyncTimeout = 30 * time.Second
+
 // @Summary		监控播放（直播/回放）
 // @Description	直播一个通道最多存在一个流，回放每请求一次生成一个流
 // @Tags			streams
@@ -41,7 +43,7 @@ func Play(c *gin.Context) {
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
 	}
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	device_id, err := grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 	}
@@ -73,14 +75,18 @@ func Play(c *gin.Context) {
 // @Failure		1003	{object}	string
 // @Router			/channels/{id}/streams [post]
 func Playback(c *gin.Context) {
+	// 兼容query参数, wvp通过get请求调用
 	ipc_id := c.Param("ipc_id")
+	if ipc_id == "" {
+		ipc_id = c.Query("ipc_id")
+	}
 
-	s, _ := strconv.ParseInt(c.PostForm("start"), 10, 64)
+	s, _ := strconv.ParseInt(c.DefaultPostForm("start", c.Query("start")), 10, 64)
 	if s == 0 {
 		m.JsonResponse(c, m.StatusParamsERR, "开始时间错误")
 		return
 	}
-	e, _ := strconv.ParseInt(c.PostForm("end"), 10, 64)
+	e, _ := strconv.ParseInt(c.DefaultPostForm("end", c.Query("end")), 10, 64)
 	if e == 0 {
 		m.JsonResponse(c, m.StatusParamsERR, "结束时间错误")
 		return
@@ -100,17 +106,26 @@ func Playback(c *gin.Context) {
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
 	}
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	device_id, err := grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
+		return
 	}
-	result, err := sip_server.ExecuteCommand(device_id, &pb.ServerCommand{
+	cmd := &pb.ServerCommand{
 		MsgID:   m.MsgID_PlayBack,
 		Method:  m.PlayBack,
 		Payload: d,
-	})
+	}
+	// 回放点播耗时较长时可异步执行，通过操作id轮询结果
+	if c.Query("async") == "true" {
+		opId := sip_server.StartOperation(device_id, cmd, playbackAsyncTimeout)
+		m.JsonResponse(c, m.StatusSucc, map[string]string{"opId": opId})
+		return
+	}
+	result, err := sip_server.ExecuteCommand(device_id, cmd)
 	if err != nil {
 		m.JsonResponse(c, m.StatusSysERR, "中控请求错误，请检查是否掉线")
+		return
 	}
 
 	m.JsonResponse(c, m.StatusSucc, string(result.Payload))
@@ -147,7 +162,7 @@ func Stop(c *gin.Context) {
 	}
 
 	channel_id := s_size[0]
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), channel_id)
+	device_id, err := grpc_server.GetIpcDeviceId(channel_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -191,7 +206,7 @@ func Pause(c *gin.Context) {
 		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
 		return
 	}
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), channel_id)
+	device_id, err := grpc_server.GetIpcDeviceId(channel_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -240,7 +255,7 @@ func Resume(c *gin.Context) {
 		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
 		return
 	}
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), channel_id)
+	device_id, err := grpc_server.GetIpcDeviceId(channel_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -297,7 +312,7 @@ func Speed(c *gin.Context) {
 		m.JsonResponse(c, m.StatusParamsERR, "参数格式错误，json序列化失败")
 		return
 	}
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), channel_id)
+	device_id, err := grpc_server.GetIpcDeviceId(channel_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
@@ -343,7 +358,7 @@ func Seek(c *gin.Context) {
 	}
 
 	ipc_id := s_size[0]
-	device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
+	device_id, err := grpc_server.GetIpcDeviceId(ipc_id)
 	if err != nil {
 		m.JsonResponse(c, m.StatusParamsERR, "ipc_id未注册，请检查摄像头是否正常")
 		return
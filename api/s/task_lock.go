@@ -0,0 +1,39 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	redis_util "go-sip/db/redis/redis_server_util"
+	. "go-sip/logger"
+
+	"go.uber.org/zap"
+)
+
+// 多实例部署时只由抢到锁的实例执行定时任务, 执行期间定时续期锁, 避免任务执行时间超过锁有效期时其他实例重复执行
+// 执行结束后不释放锁, 锁过期前其他实例不会执行, 保持任务间隔
+func runWithLock(key string, expiration time.Duration, task func()) bool {
+	token := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if ok, _ := redis_util.SetNX(key, token, expiration); !ok {
+		return false
+	}
+	done := make(chan struct{})
+	go func() {
+		timer := time.NewTicker(expiration / 3)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+				if ok, _ := redis_util.RenewLock(key, token, expiration); !ok {
+					Logger.Warn("任务锁续期失败", zap.String("key", key))
+					return
+				}
+			}
+		}
+	}()
+	defer close(done)
+	task()
+	return true
+}
@@ -2,9 +2,8 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -17,8 +16,10 @@ import (
 	"go-sip/m"
 	"go-sip/model"
 	pb "go-sip/signaling"
+	"go-sip/stream_auth"
 	"go-sip/utils"
 	"go-sip/zlm_api"
+	"go-sip/zlm_hook"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -29,59 +30,45 @@ var ZLM_Node = make(map[string]model.ZlmInfo)
 
 var stream_hd = make(map[string]int)
 
-var StreamWait = make(map[string]chan struct{})
+// 等待流注册, 同一流的并发播放请求只点播一次
+var streamWaiter = zlm_hook.NewStreamWaiter()
 
-func ZLMWebHook(c *gin.Context) {
-	method := c.Param("method")
-	Logger.Info("server sip ZLMWebHook", zap.String("method", method))
-	switch method {
-	case "on_server_started":
-		// zlm启动
-		zlmServerStart(c)
-	case "on_http_access":
-		// http请求鉴权
-	case "on_play":
-		// 点播业务
-		zlmStreamOnPlay(c)
-	case "on_publish":
-		// 推流业务
-		zlmStreamPublish(c)
-	case "on_stream_none_reader":
-		// 无人阅读通知 关闭流
-		zlmStreamNoneReader(c)
-	case "on_stream_not_found":
-		// 请求播放时，流不存在时触发
-		zlmStreamNotFound(c)
-	case "on_record_mp4":
-		//  mp4录制完成
-		zlmRecordMp4(c)
-	case "on_stream_changed":
-		// 流注册和注销通知
-		zlmStreamChanged(c)
-	case "on_rtp_server_timeout":
-		// rtp server 长时间未收到数据
-		zlmRtpServerTimeout(c)
-	default:
-		m.ZlmWebHookResponse(c, 0, "success")
-	}
+const (
+	streamWaitTimeout      = 5 * time.Second
+	mergeStreamWaitTimeout = 10 * time.Second // 合屏需要先点播所有拼接的摄像头
+	ipcBroadcastZlmExpire  = 5 * time.Minute  // 广播推流后等待客户端invite的时间
+)
 
+var zlmHooks = newZlmHooks()
+
+func newZlmHooks() *zlm_hook.Registry {
+	r := zlm_hook.NewRegistry()
+	zlm_hook.On(r, zlm_hook.ServerStarted, zlmServerStart)         // zlm启动
+	zlm_hook.On(r, zlm_hook.Play, zlmStreamOnPlay)                 // 点播业务
+	zlm_hook.On(r, zlm_hook.Publish, zlmStreamPublish)             // 推流业务
+	zlm_hook.On(r, zlm_hook.RtspRealm, zlmRtspRealm)               // rtsp是否开启专属鉴权
+	zlm_hook.On(r, zlm_hook.RtspAuth, zlmRtspAuth)                 // rtsp专属鉴权
+	zlm_hook.On(r, zlm_hook.StreamNoneReader, zlmStreamNoneReader) // 无人阅读通知 关闭流
+	zlm_hook.On(r, zlm_hook.StreamNotFound, zlmStreamNotFound)     // 请求播放时，流不存在时触发
+	zlm_hook.On(r, zlm_hook.RecordMp4, zlmRecordMp4)               // mp4录制完成
+	zlm_hook.On(r, zlm_hook.StreamChanged, zlmStreamChanged)       // 流注册和注销通知
+	zlm_hook.On(r, zlm_hook.RtpServerTimeout, zlmRtpServerTimeout) // rtp server 长时间未收到数据
+	zlm_hook.On(r, zlm_hook.SendRtpStopped, zlmSendRtpStopped)     // rtp推流停止
+	zlm_hook.On(r, zlm_hook.FlowReport, zlmFlowReport)             // 流量统计
+	return r
 }
 
-func zlmServerStart(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
+// 其他模块订阅zlm hook
+func ZlmHooks() *zlm_hook.Registry {
+	return zlmHooks
+}
 
-	var req model.ZlmServerStartDate
-	if err := utils.JSONDecode(data, &req); err != nil {
-		fmt.Println("JSON解析失败：", err)
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
+func ZLMWebHook(c *gin.Context) {
+	Logger.Info("server sip ZLMWebHook", zap.String("method", c.Param("method")))
+	zlmHooks.Handle(c)
+}
+
+func zlmServerStart(c *gin.Context, req *model.ZlmServerStartDate) {
 	// 获取redis数据, 没有则存入zlm返回的信息到redis
 	zlmInfoRedisStr, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerId)
 	if err != nil || zlmInfoRedisStr == "" {
@@ -92,28 +79,16 @@ func zlmServerStart(c *gin.Context) {
 		zlmRedisDTO.ZlmPort = req.HTTPPort
 		err = redis_util.HSetStruct_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerId, zlmRedisDTO)
 		if err != nil {
-			m.ZlmWebHookResponse(c, -1, "redis error")
+			zlm_hook.Response(c, -1, "redis error")
 			return
 		}
 	}
 
-	m.ZlmWebHookResponse(c, 0, "zlm server start success")
+	zlm_hook.Response(c, 0, "zlm server start success")
 }
 
 // zlm点播业务
-func zlmStreamOnPlay(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-	var req = model.ZlmStreamOnPlayData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "参数格式错误，json反序列化失败")
-		return
-	}
+func zlmStreamOnPlay(c *gin.Context, req *model.ZlmStreamOnPlayData) {
 	Logger.Info("server sip zlmStreamOnPlay", zap.Any("req", req))
 	// 获取参数列表
 	// paramsMap := make(map[string]string)
@@ -122,14 +97,14 @@ func zlmStreamOnPlay(c *gin.Context) {
 	// for _, params := range paramsArray {
 	// 	param := strings.Split(params, "=")
 	// 	if len(param) != 2 {
-	// 		m.ZlmWebHookResponse(c, 401, "Unauthorized")
+	// 		zlm_hook.Unauthorized(c, "Unauthorized")
 	// 		return
 	// 	}
 	// 	paramsMap[param[0]] = param[1]
 	// }
 	// redisZlmInfo, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerID)
 	// if err != nil {
-	// 	m.ZlmWebHookResponse(c, -1, "redis error")
+	// 	zlm_hook.Response(c, -1, "redis error")
 	// 	return
 	// }
 
@@ -137,7 +112,7 @@ func zlmStreamOnPlay(c *gin.Context) {
 	// var zlmInfo model.ZlmInfo
 	// err = json.Unmarshal([]byte(redisZlmInfo), &zlmInfo)
 	// if err != nil {
-	// 	m.ZlmWebHookResponse(c, -1, "参数格式错误，json反序列化失败")
+	// 	zlm_hook.Response(c, -1, "参数格式错误，json反序列化失败")
 	// 	return
 	// }
 
@@ -145,7 +120,7 @@ func zlmStreamOnPlay(c *gin.Context) {
 	if req.Stream != "" {
 		stream_id_arr = strings.Split(req.Stream, "_")
 	} else {
-		m.ZlmWebHookResponse(c, -1, "参数格式错误")
+		zlm_hook.Response(c, -1, "参数格式错误")
 		return
 	}
 
@@ -153,26 +128,50 @@ func zlmStreamOnPlay(c *gin.Context) {
 	if len(stream_id_arr) == 1 || len(stream_id_arr) == 2 {
 		Logger.Info("ZlmStreamOnPlay 实时流", zap.Any("req.Stream", req.Stream))
 	} else {
-		m.ZlmWebHookResponse(c, -1, "stream参数格式错误")
+		zlm_hook.Response(c, -1, "stream参数格式错误")
 		return
 	}
 	//视频播放触发鉴权
-	m.ZlmWebHookResponse(c, 0, "on play success")
+	if m.SMConfig.StreamAuth && !isInternalPlayer(req.MediaServerID, req.IP) && !isRtspSessionAuthed(req.ID, req.Stream) {
+		if _, err := checkStreamToken(stream_auth.TokenFromParams(req.Params), req.Stream, req.IP); err != nil {
+			zlm_hook.Unauthorized(c, err.Error())
+			return
+		}
+	}
+	zlm_hook.Response(c, 0, "on play success")
 }
 
-func zlmStreamChanged(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
+// rtsp是否开启专属鉴权, url未携带token的rtsp播放需要digest鉴权
+func zlmRtspRealm(c *gin.Context, req *model.ZlmRtspRealmData) {
+	if !m.SMConfig.StreamAuth || isInternalPlayer(req.MediaServerID, req.IP) || stream_auth.TokenFromParams(req.Params) != "" {
+		zlm_hook.JSON(c, zlm_hook.RtspRealmResponse{Code: 0})
 		return
 	}
-	var req = model.ZLMStreamChangedData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
+	zlm_hook.JSON(c, zlm_hook.RtspRealmResponse{Code: 0, Realm: rtspAuthRealm})
+}
+
+// rtsp专属鉴权, 用户名为播放token, 密码为token中的观看者标识
+func zlmRtspAuth(c *gin.Context, req *model.ZlmRtspAuthData) {
+	claims, err := checkStreamToken(req.UserName, req.Stream, req.IP)
+	if err != nil {
+		zlm_hook.Unauthorized(c, err.Error())
 		return
 	}
+	setRtspSession(req.ID, req.Stream)
+	zlm_hook.JSON(c, zlm_hook.RtspAuthResponse{Code: 0, Encrypted: false, Passwd: claims.Viewer})
+}
+
+func zlmStreamChanged(c *gin.Context, req *model.ZLMStreamChangedData) {
+	// 流注销时按流会话判断是否需要恢复, 需要在删除流会话前处理
+	if !req.Regist && req.Schema == "rtsp" {
+		streamInterrupted(req.MediaServerId, req.APP, req.Stream)
+	}
+	streamSessionChanged(req)
+	// 唤醒等待该流的播放请求
+	if req.Regist && req.Schema == "rtsp" {
+		streamWaiter.Ready(zlm_hook.StreamWaitKey(req.MediaServerId, req.APP, req.Stream))
+		streamRecovered(req.MediaServerId, req.APP, req.Stream)
+	}
 	if req.Regist {
 		Logger.Info("流注册 ", zap.Any("req", req))
 		if req.APP == "audio" && req.Schema == "rtsp" {
@@ -181,7 +180,7 @@ func zlmStreamChanged(c *gin.Context) {
 			for _, params := range paramsArray {
 				param := strings.Split(params, "=")
 				if len(param) != 2 {
-					m.ZlmWebHookResponse(c, 401, "Unauthorized")
+					zlm_hook.Unauthorized(c, "Unauthorized")
 					return
 				}
 				paramsMap[param[0]] = param[1]
@@ -197,12 +196,12 @@ func zlmStreamChanged(c *gin.Context) {
 					}
 					d, err := json.Marshal(sip_req)
 					if err != nil {
-						m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
+						zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
 						return
 					}
 					sip_id, err := redis_util.HGet_2(redis.DEVICE_SIP_KEY, device_id)
 					if err != nil || sip_id == "" {
-						m.ZlmWebHookResponse(c, -1, "sip_id未找到， 或者不在该sip服务")
+						zlm_hook.Response(c, -1, "sip_id未找到， 或者不在该sip服务")
 						return
 					}
 					go func() {
@@ -222,20 +221,13 @@ func zlmStreamChanged(c *gin.Context) {
 				audio_pull_map[req.Stream] = device_id
 			}
 		}
-		if req.Schema == "rtsp" { // 如果是国标流注册
-			if st, ok := StreamWait[req.Stream]; ok {
-				st <- struct{}{}
-			}
-		}
 
 	} else {
 		Logger.Info("流注销 :", zap.Any("req", req))
 		if req.APP == "rtp" && req.Schema == "rtsp" {
-			s_size := strings.Split(req.Stream, "_")
-
 			redisZlmInfo, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerId)
 			if err != nil {
-				m.ZlmWebHookResponse(c, -1, "查询redis错误")
+				zlm_hook.Response(c, -1, "查询redis错误")
 				return
 			}
 
@@ -243,37 +235,13 @@ func zlmStreamChanged(c *gin.Context) {
 			var zlmInfo model.ZlmInfo
 			err = json.Unmarshal([]byte(redisZlmInfo), &zlmInfo)
 			if err != nil {
-				m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
+				zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
 				return
 			}
 
-			sip_server := grpc_server.GetSipServer()
-			sip_req := &grpc_api.Sip_Stop_Play_Req{
-				App:         req.APP,
-				StreamID:    req.Stream,
-				ZlmIP:       zlmInfo.ZlmIp,
-				ZlmDomain:   zlmInfo.ZlmDomain,
-				ZlmHttpPort: zlmInfo.ZlmPort,
-				ZlmSecret:   zlmInfo.ZlmSecret,
-			}
-			d, err := json.Marshal(sip_req)
-			if err != nil {
-				m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
-			}
-			device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), s_size[0])
-			if err != nil || device_id == "" {
-				m.ZlmWebHookResponse(c, -1, "ipc_id未注册，请检查摄像头是否正常")
-				return
+			if err = stopIpcPlay(&zlmInfo, req.APP, req.Stream); err != nil {
+				zlm_hook.Response(c, -1, err.Error())
 			}
-			_, err = sip_server.ExecuteCommand(device_id, &pb.ServerCommand{
-				MsgID:   m.MsgID_StopPlay,
-				Method:  m.StopPlay,
-				Payload: d,
-			})
-			if err != nil {
-				m.ZlmWebHookResponse(c, -1, "终端请求错误，请检查是否掉线")
-			}
-
 		}
 
 		if req.APP == "audio" && req.Schema == "rtsp" {
@@ -282,22 +250,10 @@ func zlmStreamChanged(c *gin.Context) {
 			delete(stream_hd, req.Stream)
 		}
 	}
-	m.ZlmWebHookResponse(c, 0, "success")
+	zlm_hook.Success(c)
 }
 
-func zlmStreamPublish(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-	var req = model.ZlmStreamPublishData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
+func zlmStreamPublish(c *gin.Context, req *model.ZlmStreamPublishData) {
 
 	// 获取参数列表
 	paramsMap := make(map[string]string)
@@ -307,7 +263,7 @@ func zlmStreamPublish(c *gin.Context) {
 		for _, params := range paramsArray {
 			param := strings.Split(params, "=")
 			if len(param) != 2 {
-				m.ZlmWebHookResponse(c, 401, "Unauthorized")
+				zlm_hook.Unauthorized(c, "Unauthorized")
 				return
 			}
 			paramsMap[param[0]] = param[1]
@@ -320,7 +276,7 @@ func zlmStreamPublish(c *gin.Context) {
 				if device_id, ok := paramsMap["device_id"]; ok {
 					redisZlmInfo, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerID)
 					if err != nil {
-						m.ZlmWebHookResponse(c, -1, "redis error")
+						zlm_hook.Response(c, -1, "redis error")
 						return
 					}
 
@@ -328,7 +284,7 @@ func zlmStreamPublish(c *gin.Context) {
 					var zlmInfo model.ZlmInfo
 					err = json.Unmarshal([]byte(redisZlmInfo), &zlmInfo)
 					if err != nil {
-						m.ZlmWebHookResponse(c, -1, "参数格式错误，json反序列化失败")
+						zlm_hook.Response(c, -1, "参数格式错误，json反序列化失败")
 						return
 					}
 
@@ -344,17 +300,17 @@ func zlmStreamPublish(c *gin.Context) {
 		}
 		d, err := json.Marshal(sip_req)
 		if err != nil {
-			m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
+			zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
 		}
-		device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), req.Stream)
+		device_id, err := grpc_server.GetIpcDeviceId(req.Stream)
 		if err != nil || device_id == "" {
-			m.ZlmWebHookResponse(c, -1, "ipc_id未注册，请检查摄像头是否正常")
+			zlm_hook.Response(c, -1, "ipc_id未注册，请检查摄像头是否正常")
 			return
 		}
 
 		sip_server := grpc_server.GetSipServer()
 
-		sip_server.StreamMap[req.Stream] = req.MediaServerID
+		redis_util.Set_2(fmt.Sprintf(redis.IPC_BROADCAST_ZLM_KEY, req.Stream), req.MediaServerID, ipcBroadcastZlmExpire)
 		go func() {
 
 			_, err = sip_server.ExecuteCommand(device_id, &pb.ServerCommand{
@@ -369,75 +325,158 @@ func zlmStreamPublish(c *gin.Context) {
 
 	} else {
 
+		// 推流只接受静态sign, 播放token不能用于推流
 		if sign, ok := paramsMap["sign"]; ok {
 			token := utils.GetMD5(sign)
 			if token != utils.GetMD5(m.SMConfig.Sign) {
-				m.ZlmWebHookResponse(c, 401, "Unauthorized")
+				zlm_hook.Unauthorized(c, "Unauthorized")
 				return
 			}
 		} else {
-			m.ZlmWebHookResponse(c, 401, "Unauthorized")
+			zlm_hook.Unauthorized(c, "Unauthorized")
 			return
 		}
 	}
 
-	c.JSON(http.StatusOK, map[string]any{
-		"code":         0,
-		"enable_audio": true,
-		"enable_MP4":   false,
-		"msg":          "success",
-	})
+	zlm_hook.JSON(c, zlm_hook.PublishResponse{Code: 0, Msg: "success", EnableAudio: true})
 }
 
-func zlmRtpServerTimeout(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
+// rtp server长时间未收到数据, 通知设备停止推流并关闭rtp server
+func zlmRtpServerTimeout(c *gin.Context, req *model.ZlmRtpServerTimeoutData) {
+	Logger.Info("=== rtp服务超时", zap.Any("req", req))
+	if req.StreamID == "" {
 		return
 	}
-	var req = model.ZlmRtpServerTimeoutData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
+	// 有人观看的流断开后自动重新点播
+	streamInterrupted(req.MediaServerID, "rtp", req.StreamID)
+	redisZlmInfo, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerID)
+	if err != nil || redisZlmInfo == "" {
+		Logger.Error("rtp服务超时, zlm节点信息查询失败", zap.String("mediaServerId", req.MediaServerID), zap.Error(err))
 		return
 	}
-	Logger.Info("=== rtp服务超时", zap.Any("req", req))
+	var zlmInfo model.ZlmInfo
+	if err = json.Unmarshal([]byte(redisZlmInfo), &zlmInfo); err != nil {
+		Logger.Error("rtp服务超时, zlm节点信息反序列化失败", zap.Error(err))
+		return
+	}
+	if err = stopIpcPlay(&zlmInfo, "rtp", req.StreamID); err != nil {
+		Logger.Warn("rtp服务超时, 通知设备停止推流失败", zap.String("stream_id", req.StreamID), zap.Error(err))
+	}
+	zlm_api.ZlmCloseRtpServer(zlmInfo.ZlmDomain, zlmInfo.ZlmSecret, req.StreamID)
 }
 
-func zlmRecordMp4(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
+// 通知ipc所在设备停止推流, stream格式为 ipcId_码流
+func stopIpcPlay(zlmInfo *model.ZlmInfo, app, stream string) error {
+	sip_req := &grpc_api.Sip_Stop_Play_Req{
+		App:         app,
+		StreamID:    stream,
+		ZlmIP:       zlmInfo.ZlmIp,
+		ZlmDomain:   zlmInfo.ZlmDomain,
+		ZlmHttpPort: zlmInfo.ZlmPort,
+		ZlmSecret:   zlmInfo.ZlmSecret,
+	}
+	d, err := json.Marshal(sip_req)
 	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
+		return fmt.Errorf("参数格式错误，json序列化失败")
 	}
-	var req = model.ZLMRecordMp4Data{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
+	device_id, err := grpc_server.GetIpcDeviceId(strings.Split(stream, "_")[0])
+	if err != nil || device_id == "" {
+		return fmt.Errorf("ipc_id未注册，请检查摄像头是否正常")
+	}
+	_, err = grpc_server.GetSipServer().ExecuteCommand(device_id, &pb.ServerCommand{
+		MsgID:   m.MsgID_StopPlay,
+		Method:  m.StopPlay,
+		Payload: d,
+	})
+	if err != nil {
+		return fmt.Errorf("终端请求错误，请检查是否掉线")
 	}
+	return nil
+}
 
-	m.ZlmWebHookResponse(c, 0, "success")
+// rtp推流停止, 推流异常断开时记录原因
+func zlmSendRtpStopped(c *gin.Context, req *model.ZlmSendRtpStoppedData) {
+	Logger.Warn("rtp推流停止", zap.String("stream", req.Stream), zap.String("ssrc", req.Ssrc), zap.Int("err", req.Err), zap.String("msg", req.Msg))
 }
 
-// mode 0 UDP 1 Tcp被动
-func zlmStreamNotFound(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
+// 流量统计
+func zlmFlowReport(c *gin.Context, req *model.ZlmFlowReportData) {
+	Logger.Debug("zlm流量统计", zap.String("app", req.App), zap.String("stream", req.Stream), zap.Bool("player", req.Player),
+		zap.Int64("total_bytes", req.TotalBytes), zap.Int("duration", req.Duration), zap.String("ip", req.IP))
+	streamSessionFlow(req)
+}
+
+func zlmRecordMp4(c *gin.Context, req *model.ZLMRecordMp4Data) {
+	zlm_hook.Success(c)
+}
+
+// 从redis读取合屏布局, 生成拼接流参数和需要点播的流id列表
+func mergeLayoutConfig(layout_id, stream_id string, zlmInfo *model.ZlmInfo, ipc_list []string) (model.StreamMergeConfigDTO, []string, error) {
+	layout_str, err := redis_util.HGet_2(redis.MERGE_LAYOUT_KEY, layout_id)
+	if err != nil || layout_str == "" {
+		return model.StreamMergeConfigDTO{}, nil, fmt.Errorf("合屏布局%s不存在", layout_id)
 	}
-	var req = model.ZLMStreamNotFoundData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
+	layout := model.MergeLayout{}
+	if err := json.Unmarshal([]byte(layout_str), &layout); err != nil {
+		return model.StreamMergeConfigDTO{}, nil, err
 	}
+	return zlm_api.BuildMergeLayoutConfig(&layout, stream_id, zlmInfo, ipc_list)
+}
+
+// 通知设备点播ipc, 设备推流到zlm的rtp端口
+func playIpcStream(zlmInfo *model.ZlmInfo, device_id, app, stream_id string, mode int) error {
+	saveStreamPlayMode(zlmInfo.ZlmDomain, app, stream_id, mode)
+	rtp_info := zlm_api.ZlmStartRtpServer(zlmInfo.ZlmDomain, zlmInfo.ZlmSecret, stream_id, app, mode)
+	sip_req := &grpc_api.Sip_Play_Req{
+		DeviceID:    device_id,
+		ChannelID:   stream_id,
+		ZLMIP:       zlmInfo.ZlmIp,
+		ZlmDomain:   zlmInfo.ZlmDomain,
+		ZlmSecret:   zlmInfo.ZlmSecret,
+		ZlmHttpPort: zlmInfo.ZlmPort,
+		ZLMPort:     rtp_info.Port,
+		Resolution:  0, // 废弃
+		Mode:        mode,
+		App:         app,
+	}
+	d, err := json.Marshal(sip_req)
+	if err != nil {
+		return err
+	}
+	_, err = grpc_server.GetSipServer().ExecuteCommand(device_id, &pb.ServerCommand{
+		MsgID:   m.MsgID_Play,
+		Method:  m.Play,
+		Payload: d,
+	})
+	return err
+}
+
+func zlmStreamNotFound(c *gin.Context, req *model.ZLMStreamNotFoundData) {
 	Logger.Info("server sip zlmStreamNotFound", zap.Any("req", req))
 
+	wait, first := streamWaiter.Join(zlm_hook.StreamWaitKey(req.MediaServerID, req.APP, req.Stream))
+	defer wait.Leave()
+	// 第一个请求负责拉起流, 其他请求等待同一次拉起
+	if first {
+		if err := startNotFoundStream(c, req); err != nil {
+			wait.Fail(err)
+			return
+		}
+	}
+	timeout := streamWaitTimeout
+	if strings.Contains(req.Params, "sub_ipc=") {
+		timeout = mergeStreamWaitTimeout
+	}
+	if err := wait.Wait(timeout); err != nil {
+		Logger.Warn("等待流失败", zap.Any("stream", req.Stream), zap.Error(err))
+	}
+
+	zlm_hook.JSON(c, zlm_hook.CloseResponse{Code: 0, Close: true})
+}
+
+// 拉起流不存在的流, 失败时已回复zlm
+// mode 0 UDP 1 Tcp被动
+func startNotFoundStream(c *gin.Context, req *model.ZLMStreamNotFoundData) error {
 	// 获取参数列表
 	paramsMap := make(map[string]string)
 	paramsArray := strings.Split(req.Params, "&")
@@ -445,23 +484,23 @@ func zlmStreamNotFound(c *gin.Context) {
 	for _, params := range paramsArray {
 		param := strings.Split(params, "=")
 		if len(param) != 2 {
-			m.ZlmWebHookResponse(c, -1, "传参格式错误")
-			return
+			zlm_hook.Response(c, -1, "传参格式错误")
+			return errors.New("传参格式错误")
 		}
 		paramsMap[param[0]] = param[1]
 	}
 	redisZlmInfo, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerID)
 	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "查询redis错误")
-		return
+		zlm_hook.Response(c, -1, "查询redis错误")
+		return errors.New("查询redis错误")
 	}
 
 	// 反序列化 JSON 字符串
 	var zlmInfo model.ZlmInfo
 	err = json.Unmarshal([]byte(redisZlmInfo), &zlmInfo)
 	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
-		return
+		zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
+		return errors.New("参数格式错误，json序列化失败")
 	}
 	sip_server := grpc_server.GetSipServer()
 
@@ -469,31 +508,44 @@ func zlmStreamNotFound(c *gin.Context) {
 	if req.Stream != "" {
 		stream_id_arr = strings.Split(req.Stream, "_")
 	} else {
-		m.ZlmWebHookResponse(c, -1, "参数格式错误")
-		return
+		zlm_hook.Response(c, -1, "参数格式错误")
+		return errors.New("参数格式错误")
 	}
 
 	if req.APP == "rtp" || req.APP == "live" {
 		mode, err := strconv.Atoi(paramsMap["mode"]) // 返回 (int, error)
 		if err != nil || mode < 0 || mode > 1 {
-			m.ZlmWebHookResponse(c, -1, "参数格式错误，mode参数错误")
-			return
+			zlm_hook.Response(c, -1, "参数格式错误，mode参数错误")
+			return errors.New("参数格式错误，mode参数错误")
 		}
 		var device_id string
 
 		// 请求zlm进行合屏
 		if sub_ipc, ok := paramsMap["sub_ipc"]; ok {
 			device_id = stream_id_arr[0]
-			// 对sub_ipc进行分割
-			sub_stream_id_arr := strings.Split(sub_ipc, "_")
-			stream_id_list := make([]string, len(sub_stream_id_arr))
+			var stream_id_list []string
+			var layout_cfg *model.StreamMergeConfigDTO
+			if layout_id, layout_ipc_list, ok := zlm_api.ParseLayoutSubIpc(sub_ipc); ok {
+				// 使用合屏布局
+				cfg, list, err := mergeLayoutConfig(layout_id, device_id, &zlmInfo, layout_ipc_list)
+				if err != nil {
+					Logger.Error("合屏布局错误", zap.String("sub_ipc", sub_ipc), zap.Error(err))
+					zlm_hook.Response(c, -1, "合屏布局错误")
+					return errors.New("合屏布局错误")
+				}
+				layout_cfg = &cfg
+				stream_id_list = list
+			} else {
+				// 对sub_ipc进行分割, 默认使用标清流
+				for _, ipc_id := range strings.Split(sub_ipc, "_") {
+					stream_id_list = append(stream_id_list, fmt.Sprintf("%s_0", ipc_id))
+				}
+			}
 			// 点播所有拼接的摄像头
-			for i, ipc_id := range sub_stream_id_arr {
-				// 默认使用标清流
-				stream_id := fmt.Sprintf("%s_0", ipc_id)
-				stream_id_list[i] = stream_id
+			for _, stream_id := range stream_id_list {
 				rtpinfo := zlm_api.ZlmGetMediaInfo(zlmInfo.ZlmDomain, zlmInfo.ZlmSecret, stream_id)
 				if rtpinfo.Code == 0 && !rtpinfo.Exist {
+					saveStreamPlayMode(zlmInfo.ZlmDomain, req.APP, stream_id, mode)
 					rtp_info := zlm_api.ZlmStartRtpServer("http://"+zlmInfo.ZlmIp+":"+zlmInfo.ZlmPort, zlmInfo.ZlmSecret, stream_id, req.APP, mode)
 					sip_req := &grpc_api.Sip_Play_Req{
 						DeviceID:    device_id,
@@ -518,74 +570,43 @@ func zlmStreamNotFound(c *gin.Context) {
 						Payload: d,
 					})
 					if err != nil {
-						Logger.Error("ipc点播失败", zap.Any("ipcId", ipc_id))
+						Logger.Error("ipc点播失败", zap.String("streamId", stream_id))
 					}
 				}
 			}
 
 			// 进行合屏
-			dto := model.StreamMergeInfoDTO{
-				DeviceId:  device_id,
-				IpcIdList: stream_id_list,
-				StreamId:  device_id, // 默认使用设备ID
-				Type:      1,
+			var resp zlm_api.StreamMergeInfoVO
+			if layout_cfg != nil {
+				resp = zlm_api.ZlmMergeStreamConfig(*layout_cfg, &zlmInfo, false)
+			} else {
+				dto := model.StreamMergeInfoDTO{
+					DeviceId:  device_id,
+					IpcIdList: stream_id_list,
+					StreamId:  device_id, // 默认使用设备ID
+					Type:      1,
+				}
+				resp = zlm_api.ZlmMergeStream(dto, &zlmInfo)
 			}
-			resp := zlm_api.ZlmMergeStream(dto, &zlmInfo)
 			if resp.Code != 0 {
-				m.ZlmWebHookResponse(c, -1, "参数格式错误，合屏失败")
-				return
+				zlm_hook.Response(c, -1, "参数格式错误，合屏失败")
+				return errors.New("参数格式错误，合屏失败")
 			}
 			redis_util.HSet_2(redis.MERGE_VIDEO_STREAM_IPC_LIST_KEY, device_id, sub_ipc)
 		} else { // 非合屏
-			ipc_id := stream_id_arr[0]
-			// 如果ipc_id以IPC开头，则表示为非国标摄像头
-			if !strings.HasPrefix(ipc_id, "IPC") {
-				device_id, err = redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipc_id)
-				if err != nil || device_id == "" {
-					m.ZlmWebHookResponse(c, -1, "ipc_id未注册，请检查摄像头是否正常")
-					return
-				}
-			} else {
-				device_id, err = redis_util.HGet_2(redis.NOT_GB_IPC_DEVICE, ipc_id)
-				if err != nil || device_id == "" {
-					m.ZlmWebHookResponse(c, -1, "ipc_id未注册，请检查摄像头是否正常")
-					return
-				}
-			}
-			rtp_info := zlm_api.ZlmStartRtpServer(zlmInfo.ZlmDomain, zlmInfo.ZlmSecret, req.Stream, req.APP, mode)
-
 			// rtp实时流
-			if len(stream_id_arr) == 1 || len(stream_id_arr) == 2 {
-				// 点播ipc
-				sip_req := &grpc_api.Sip_Play_Req{
-					DeviceID:    device_id,
-					ChannelID:   req.Stream,
-					ZLMIP:       zlmInfo.ZlmIp,
-					ZlmDomain:   zlmInfo.ZlmDomain,
-					ZlmSecret:   zlmInfo.ZlmSecret,
-					ZlmHttpPort: zlmInfo.ZlmPort,
-					ZLMPort:     rtp_info.Port,
-					Resolution:  0, // 废弃
-					Mode:        mode,
-					App:         req.APP,
-				}
-				d, err := json.Marshal(sip_req)
-				if err != nil {
-					Logger.Error("ipc的json序列化失败")
-				} else {
-					_, err = sip_server.ExecuteCommand(device_id, &pb.ServerCommand{
-						MsgID:   m.MsgID_Play,
-						Method:  m.Play,
-						Payload: d,
-					})
-					if err != nil {
-						Logger.Error("ipc点播失败", zap.Any("stream_id", req.Stream))
-					}
-				}
-
-			} else {
-				m.ZlmWebHookResponse(c, -1, "stream参数格式错误")
-				return
+			if len(stream_id_arr) != 1 && len(stream_id_arr) != 2 {
+				zlm_hook.Response(c, -1, "stream参数格式错误")
+				return errors.New("stream参数格式错误")
+			}
+			device_id, err = ipcDeviceId(stream_id_arr[0])
+			if err != nil {
+				zlm_hook.Response(c, -1, err.Error())
+				return err
+			}
+			// 点播ipc
+			if err := playIpcStream(&zlmInfo, device_id, req.APP, req.Stream, mode); err != nil {
+				Logger.Error("ipc点播失败", zap.Any("stream_id", req.Stream), zap.Error(err))
 			}
 		}
 
@@ -597,12 +618,12 @@ func zlmStreamNotFound(c *gin.Context) {
 						if sign, ok := paramsMap["sign"]; ok {
 							token := utils.GetMD5(sign)
 							if token != utils.GetMD5(m.SMConfig.Sign) {
-								m.ZlmWebHookResponse(c, 401, "参数格式错误，sign错误")
-								return
+								zlm_hook.Unauthorized(c, "参数格式错误，sign错误")
+								return errors.New("参数格式错误，sign错误")
 							}
 						} else {
-							m.ZlmWebHookResponse(c, 401, "参数格式错误，sign错误")
-							return
+							zlm_hook.Unauthorized(c, "参数格式错误，sign错误")
+							return errors.New("参数格式错误，sign错误")
 						}
 
 						sip_req := &grpc_api.Sip_Audio_Push_Req{
@@ -614,13 +635,13 @@ func zlmStreamNotFound(c *gin.Context) {
 						}
 						d, err := json.Marshal(sip_req)
 						if err != nil {
-							m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
-							return
+							zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
+							return errors.New("参数格式错误，json序列化失败")
 						}
 						sip_id, err := redis_util.HGet_2(redis.DEVICE_SIP_KEY, device_id)
 						if err != nil || sip_id == "" {
-							m.ZlmWebHookResponse(c, -1, "sip_id未找到， 或者不在该sip服务")
-							return
+							zlm_hook.Response(c, -1, "sip_id未找到， 或者不在该sip服务")
+							return errors.New("sip_id未找到， 或者不在该sip服务")
 						}
 
 						_, err = sip_server.ExecuteCommand(device_id, &pb.ServerCommand{
@@ -630,8 +651,8 @@ func zlmStreamNotFound(c *gin.Context) {
 						})
 						if err != nil {
 							Logger.Error("执行远程推送命令失败", zap.Any("device_id", device_id), zap.Error(err))
-							m.ZlmWebHookResponse(c, -1, "执行远程推送命令失败")
-							return
+							zlm_hook.Response(c, -1, "执行远程推送命令失败")
+							return errors.New("执行远程推送命令失败")
 						}
 
 					}
@@ -639,43 +660,16 @@ func zlmStreamNotFound(c *gin.Context) {
 			}
 		}
 	}
-	StreamWait[req.Stream] = make(chan struct{})
-
-	tick := time.NewTicker(5 * time.Second)
-	select {
-	case <-StreamWait[req.Stream]:
-		close(StreamWait[req.Stream])
-		delete(StreamWait, req.Stream)
-		break
-	case <-tick.C:
-		Logger.Warn("等待流超时", zap.Any("stream", req.Stream))
-		break
-	}
-
-	c.JSON(http.StatusOK, map[string]any{
-		"code":  0,
-		"close": true,
-	})
+	return nil
 }
 
-func zlmStreamNoneReader(c *gin.Context) {
-	body := c.Request.Body
-	defer body.Close()
-	data, err := io.ReadAll(body)
-	if err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
-	var req = model.ZLMStreamNoneReaderData{}
-	if err := utils.JSONDecode(data, &req); err != nil {
-		m.ZlmWebHookResponse(c, -1, "body error")
-		return
-	}
+func zlmStreamNoneReader(c *gin.Context, req *model.ZLMStreamNoneReaderData) {
 
 	if req.APP == "rtp" {
+		streamSessionNoneReader(req.MediaServerID, req.APP, req.Stream)
 		redisZlmInfo, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, req.MediaServerID)
 		if err != nil {
-			m.ZlmWebHookResponse(c, -1, "查询redis错误")
+			zlm_hook.Response(c, -1, "查询redis错误")
 			return
 		}
 
@@ -683,7 +677,7 @@ func zlmStreamNoneReader(c *gin.Context) {
 		var zlmInfo model.ZlmInfo
 		err = json.Unmarshal([]byte(redisZlmInfo), &zlmInfo)
 		if err != nil {
-			m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
+			zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
 			return
 		}
 		s_size := strings.Split(req.Stream, "_")
@@ -701,12 +695,12 @@ func zlmStreamNoneReader(c *gin.Context) {
 		// }
 		// d, err := json.Marshal(sip_req)
 		// if err != nil {
-		// 	m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
+		// 	zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
 		// 	return
 		// }
 		// device_id, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), req.Stream)
 		// if err != nil {
-		// 	m.ZlmWebHookResponse(c, -1, "ipc_id未注册，请检查摄像头是否正常")
+		// 	zlm_hook.Response(c, -1, "ipc_id未注册，请检查摄像头是否正常")
 		// 	return
 		// }
 		// _, err = sip_server.ExecuteCommand(device_id, &pb.ServerCommand{
@@ -715,19 +709,19 @@ func zlmStreamNoneReader(c *gin.Context) {
 		// 	Payload: d,
 		// })
 		// if err != nil {
-		// 	m.ZlmWebHookResponse(c, -1, "终端请求错误，请检查是否掉线")
+		// 	zlm_hook.Response(c, -1, "终端请求错误，请检查是否掉线")
 		// 	return
 		// }
 		// closeRtpServer 服务端
 		closeRtpRsp := zlm_api.ZlmCloseRtpServer("http://"+zlmInfo.ZlmIp+":"+zlmInfo.ZlmPort, zlmInfo.ZlmSecret, req.Stream)
 		if closeRtpRsp.Code != 0 {
-			m.ZlmWebHookResponse(c, -1, "关闭RtpServer失败")
+			zlm_hook.Response(c, -1, "关闭RtpServer失败")
 			return
 		}
 		if closeRtpRsp.Hit >= 1 {
-			m.ZlmWebHookResponse(c, 0, "成功命中并关闭RtpServer")
+			zlm_hook.Response(c, 0, "成功命中并关闭RtpServer")
 		} else {
-			m.ZlmWebHookResponse(c, 0, "未命中RtpServer")
+			zlm_hook.Response(c, 0, "未命中RtpServer")
 		}
 
 	} else if req.APP == "audio" {
@@ -736,7 +730,7 @@ func zlmStreamNoneReader(c *gin.Context) {
 		sip_req := &grpc_api.Sip_Close_Audio_Req{}
 		d, err := json.Marshal(sip_req)
 		if err != nil {
-			m.ZlmWebHookResponse(c, -1, "参数格式错误，json序列化失败")
+			zlm_hook.Response(c, -1, "参数格式错误，json序列化失败")
 		}
 
 		if device_id, ok := audio_pull_map[req.Stream]; ok {
@@ -747,14 +741,11 @@ func zlmStreamNoneReader(c *gin.Context) {
 				Payload: d,
 			})
 			if err != nil {
-				m.ZlmWebHookResponse(c, -1, "终端请求错误，请检查是否掉线")
+				zlm_hook.Response(c, -1, "终端请求错误，请检查是否掉线")
 			}
 
 		}
 
 	}
-	c.JSON(http.StatusOK, map[string]any{
-		"code":  0,
-		"close": true,
-	})
+	zlm_hook.JSON(c, zlm_hook.CloseResponse{Code: 0, Close: true})
 }
@@ -2,11 +2,14 @@ package wvp
 
 import (
 	"fmt"
+	. "go-sip/common"
 	"go-sip/dao"
 	"go-sip/db/redis"
 	redis_util "go-sip/db/redis/redis_wvp_util"
 	. "go-sip/logger"
+	"go-sip/m"
 	"go-sip/model"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -73,3 +76,27 @@ func GetIotDeviceListByAiModel(c *gin.Context) {
 	}
 	model.JsonResponsePageSucc(c, dao.GetIotDeviceCountByAiModel(aiModelId), pageReq.Page, pageReq.Size, list)
 }
+
+// @Summary 远程采集中控设备诊断信息
+// @Router /wvp/iotdevice/diagnostics [get]
+func GetIotDeviceDiagnostics(c *gin.Context) {
+	deviceId := c.Query("deviceId")
+	if deviceId == "" {
+		model.JsonResponseSysERR(c, "deviceId不能为空")
+		return
+	}
+	params := url.Values{}
+	params.Add("device_id", deviceId)
+	params.Add("log_lines", c.Query("logLines"))
+
+	response := WvpDeviceGetRequestHandler(deviceId, DeviceDiagnosticsURL, params)
+	if response == nil || response.Data == nil {
+		model.JsonResponseSysERR(c, "调用失败")
+		return
+	}
+	if response.Code != m.StatusSucc {
+		model.JsonResponseSysERR(c, fmt.Sprintf("%v", response.Data))
+		return
+	}
+	model.JsonResponseSucc(c, response.Data)
+}
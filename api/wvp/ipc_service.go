@@ -10,8 +10,10 @@ import (
 	"go.uber.org/zap"
 
 	"encoding/json"
+	"go-sip/api/record_service"
 	. "go-sip/common"
 	"go-sip/dao"
+	. "go-sip/db/alioss"
 	"go-sip/db/redis"
 	redis_util "go-sip/db/redis/redis_wvp_util"
 	. "go-sip/logger"
@@ -20,6 +22,9 @@ import (
 	"net/url"
 )
 
+// ipc封面临时访问地址有效期
+const ipcCoverUrlExpire = time.Hour
+
 // ipc列表初始化到mysql数据库
 func IpcInfoInit() {
 	go func() {
@@ -135,9 +140,18 @@ func GetIpcPage(c *gin.Context) {
 		model.JsonResponseSysERR(c, "获取IPC列表失败")
 		return
 	}
+	for i := range ipcList {
+		fillIpcCover(&ipcList[i])
+	}
 	model.JsonResponsePageSucc(c, dao.GetIpcInfoTotal(req.DeviceID, req.GB), req.Page, req.Size, ipcList)
 }
 
+// 回放索引缓存
+var playbackCache = record_service.PlaybackCache{
+	ScanPlaybackRecords: redis_util.ScanPlaybackRecords,
+	ZRangeByScore:       redis_util.ZRangeByScore_2,
+}
+
 // @Summary		ipc回放视频时间列表（新）
 // @Description	用来获取通道设备存储的可回放时间段列表，注意控制时间跨度，跨度越大，数据量越多，返回越慢，甚至会超时（最多10s）。
 // @Router		/wvp/ipc/recordList [post]
@@ -168,18 +182,9 @@ func IpcRecordsList(c *gin.Context) {
 		endStamp = time.Now().Unix()
 	}
 
-	// start和end都是秒级时间戳，转date
-	startDate := time.Unix(startStamp, 0).Format("2006-01-02")
-	endDate := time.Unix(endStamp, 0).Format("2006-01-02")
-	// start和end必须是同一天
-	if startDate != endDate {
-		model.JsonResponseSysERR(c, "开始时间和结束时间必须在同一天")
-		return
-	}
-
-	// 7天之前的录像不能查询
-	if startStamp < time.Now().Add(-7*24*time.Hour).Unix() {
-		model.JsonResponseSysERR(c, "开始时间不能小于7天")
+	// 查询跨度不能超过31天
+	if endStamp-startStamp > model.RecordQueryMaxDays*24*3600 {
+		model.JsonResponseSysERR(c, fmt.Sprintf("查询时间跨度不能超过%d天", model.RecordQueryMaxDays))
 		return
 	}
 
@@ -211,16 +216,11 @@ func IpcRecordsList(c *gin.Context) {
 		deviceId = ipc_info.DeviceID
 	}
 
-	var ipcRecordList []model.IpcPlaybackRecordData
-	if query.Type == "all" {
-		ipcRecordListSub, _ := redis_util.ScanPlaybackRecords(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, "*"), start, end)
-		ipcRecordList = append(ipcRecordList, ipcRecordListSub...)
-	} else {
-		typeArr := strings.Split(query.Type, ",")
-		for _, typeStr := range typeArr {
-			ipcRecordListSub, _ := redis_util.ZRangeByScore_2(fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, deviceId, ipcId, typeStr), start, end)
-			ipcRecordList = append(ipcRecordList, ipcRecordListSub...)
-		}
+	ipcRecordList, err := playbackCache.RecordList(deviceId, ipcId, query.Type, startStamp, endStamp)
+	if err != nil {
+		Logger.Error("查询录像片段失败", zap.String("ipcId", ipcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像失败")
+		return
 	}
 	model.JsonResponseSucc(c, ipcRecordList)
 }
@@ -283,3 +283,21 @@ func IpcDelete(c *gin.Context) {
 	}
 	model.JsonResponseSucc(c, "删除成功")
 }
+
+// 填充sip服务定时刷新的ipc封面截图
+func fillIpcCover(ipc *model.IpcInfo) {
+	snapshot_str, err := redis_util.HGet_2(redis.IPC_SNAPSHOT_KEY, ipc.IpcId)
+	if err != nil || snapshot_str == "" {
+		return
+	}
+	snapshot := model.IpcSnapshot{}
+	if err := json.Unmarshal([]byte(snapshot_str), &snapshot); err != nil {
+		return
+	}
+	cover_url, err := SignObjectURL(snapshot.OssKey, ipcCoverUrlExpire)
+	if err != nil {
+		return
+	}
+	ipc.CoverUrl = cover_url
+	ipc.CoverTime = snapshot.SnapTime
+}
@@ -0,0 +1,163 @@
+package wvp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-sip/dao"
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_wvp_util"
+	. "go-sip/logger"
+	"go-sip/model"
+	"go-sip/zlm_api"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 合屏布局初始化, server和网关从redis读取布局
+func MergeLayoutInit() error {
+	layout_list, err := dao.GetAllMergeLayouts()
+	if err != nil {
+		Logger.Error("合屏布局列表查询失败", zap.Error(err))
+		return fmt.Errorf("合屏布局列表查询失败")
+	}
+	for _, layout := range layout_list {
+		redis_util.HSetStruct_2(redis.MERGE_LAYOUT_KEY, strconv.FormatInt(layout.ID, 10), layout)
+	}
+	Logger.Info("合屏布局列表初始化完成")
+	return nil
+}
+
+// @Summary 查询合屏布局列表
+// @Router /wvp/mergeLayout/list [get]
+func MergeLayoutList(c *gin.Context) {
+	layout_list, err := dao.GetAllMergeLayouts()
+	if err != nil {
+		Logger.Error("合屏布局列表查询失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "合屏布局列表查询失败")
+		return
+	}
+	model.JsonResponseSucc(c, layout_list)
+}
+
+// @Summary 新增合屏布局
+// @Router /wvp/mergeLayout/add [post]
+func AddMergeLayout(c *gin.Context) {
+	var dto model.MergeLayoutSaveDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	layout := model.FromMergeLayoutSaveDTO(&dto)
+	if err := zlm_api.ValidateMergeLayout(layout); err != nil {
+		model.JsonResponseSysERR(c, err.Error())
+		return
+	}
+
+	id, err := dao.CreateMergeLayout(layout)
+	if err != nil {
+		Logger.Error("新增合屏布局失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "新增失败")
+		return
+	}
+	layout.ID = id
+	redis_util.HSetStruct_2(redis.MERGE_LAYOUT_KEY, strconv.FormatInt(id, 10), layout)
+	model.JsonResponseSucc(c, layout)
+}
+
+// @Summary 更新合屏布局
+// @Router /wvp/mergeLayout/update/{id} [put]
+func UpdateMergeLayout(c *gin.Context) {
+	id := c.Param("id")
+	var dto model.MergeLayoutSaveDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+
+	old, err := dao.GetMergeLayoutByID(id)
+	if err != nil || old == nil {
+		Logger.Error("合屏布局不存在", zap.String("id", id), zap.Error(err))
+		model.JsonResponseSysERR(c, "合屏布局不存在")
+		return
+	}
+	layout := model.FromMergeLayoutSaveDTO(&dto)
+	layout.ID = old.ID
+	if err := zlm_api.ValidateMergeLayout(layout); err != nil {
+		model.JsonResponseSysERR(c, err.Error())
+		return
+	}
+
+	if err := dao.UpdateMergeLayout(layout); err != nil {
+		Logger.Error("更新合屏布局失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "更新失败")
+		return
+	}
+	// 已合屏的流在下次切换时使用新布局
+	redis_util.HSetStruct_2(redis.MERGE_LAYOUT_KEY, id, layout)
+	model.JsonResponseSucc(c, layout)
+}
+
+// @Summary 删除合屏布局
+// @Router /wvp/mergeLayout/delete/{id} [delete]
+func DeleteMergeLayout(c *gin.Context) {
+	id := c.Param("id")
+	layout, err := dao.GetMergeLayoutByID(id)
+	if err != nil || layout == nil {
+		Logger.Error("合屏布局不存在", zap.String("id", id), zap.Error(err))
+		model.JsonResponseSysERR(c, "合屏布局不存在")
+		return
+	}
+	if err := dao.DeleteMergeLayout(id); err != nil {
+		Logger.Error("删除合屏布局失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "删除失败")
+		return
+	}
+	redis_util.HDel_2(redis.MERGE_LAYOUT_KEY, id)
+	model.JsonResponseSucc(c, "删除成功")
+}
+
+// @Summary 切换合屏布局, 合屏流已存在时不中断播放直接切换
+// @Router /wvp/mergeLayout/apply [post]
+func ApplyMergeLayout(c *gin.Context) {
+	var req model.MergeLayoutApplyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	layout_id := strconv.FormatInt(req.LayoutId, 10)
+	layout, err := dao.GetMergeLayoutByID(layout_id)
+	if err != nil || layout == nil {
+		Logger.Error("合屏布局不存在", zap.Int64("layoutId", req.LayoutId), zap.Error(err))
+		model.JsonResponseSysERR(c, "合屏布局不存在")
+		return
+	}
+	zlmInfo, err := WvpGetZlmInfo(req.DeviceId)
+	if err != nil || zlmInfo == nil {
+		model.JsonResponseSysERR(c, "获取zlm服务信息失败")
+		return
+	}
+
+	var ipc_list []string
+	if req.IpcList != "" {
+		ipc_list = strings.Split(req.IpcList, "_")
+	}
+	resp := zlm_api.ZlmMergeStreamByLayout(layout, req.DeviceId, ipc_list, zlmInfo)
+	if resp.Code != 0 {
+		Logger.Error("切换合屏布局失败", zap.String("deviceId", req.DeviceId), zap.Any("resp", resp))
+		model.JsonResponseSysERR(c, "切换合屏布局失败")
+		return
+	}
+
+	sub_ipc := zlm_api.MergeLayoutPrefix + layout_id
+	if req.IpcList != "" {
+		sub_ipc += ":" + req.IpcList
+	}
+	redis_util.HSet_2(redis.MERGE_VIDEO_STREAM_IPC_LIST_KEY, req.DeviceId, sub_ipc)
+	model.JsonResponseSucc(c, "成功")
+}
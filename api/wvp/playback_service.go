@@ -0,0 +1,256 @@
+package wvp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	. "go-sip/common"
+	"go-sip/dao"
+	. "go-sip/db/alioss"
+	. "go-sip/logger"
+	"go-sip/m"
+	"go-sip/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	playbackUrlDefaultExpire = 6 * time.Hour  // 回放列表中录像地址默认有效期
+	playbackUrlMaxExpire     = 24 * time.Hour // 回放列表中录像地址最长有效期
+)
+
+// @Summary		ipc云端回放列表, 将时间段内的fmp4录像按字节范围拼接为hls点播列表
+// @Router		/wvp/ipc/playback.m3u8 [get]
+func IpcPlaybackPlaylist(c *gin.Context) {
+	ipcId := c.Query("ipcId")
+	if ipcId == "" {
+		model.JsonResponseSysERR(c, "参数ipcId不能为空")
+		return
+	}
+	start, err := strconv.ParseInt(c.Query("start"), 10, 64)
+	if err != nil || start <= 0 {
+		model.JsonResponseSysERR(c, "开始时间格式错误")
+		return
+	}
+	end, err := strconv.ParseInt(c.Query("end"), 10, 64)
+	if err != nil || end <= start {
+		model.JsonResponseSysERR(c, "结束时间格式错误")
+		return
+	}
+	if end-start > model.RecordQueryMaxDays*24*3600 {
+		model.JsonResponseSysERR(c, fmt.Sprintf("查询时间跨度不能超过%d天", model.RecordQueryMaxDays))
+		return
+	}
+	streamType := c.DefaultQuery("streamType", "0")
+	if streamType != "0" && streamType != "1" {
+		model.JsonResponseSysERR(c, "参数streamType错误")
+		return
+	}
+	expire := playbackUrlDefaultExpire
+	if v := c.Query("expire"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > playbackUrlMaxExpire {
+			model.JsonResponseSysERR(c, "参数expire错误")
+			return
+		}
+		expire = time.Duration(seconds) * time.Second
+	}
+
+	segments, err := dao.GetRecordSegments(ipcId, nil, start, end)
+	if err != nil {
+		Logger.Error("查询录像片段失败", zap.String("ipcId", ipcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像失败")
+		return
+	}
+	// 同一时间不同码流的录像重叠, 只使用一路码流
+	streamId := ipcId + "_" + streamType
+	streamSegments := make([]*model.RecordSegment, 0, len(segments))
+	for _, segment := range segments {
+		if segment.StreamId == streamId {
+			streamSegments = append(streamSegments, segment)
+		}
+	}
+	playlist, count, err := buildHlsPlaylist(streamSegments, expire)
+	if err != nil {
+		model.JsonResponseSysERR(c, "生成回放列表失败")
+		return
+	}
+	if count == 0 {
+		model.JsonResponseSysERR(c, "没有可回放的录像")
+		return
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(playlist))
+}
+
+// 生成hls点播列表, 每个fmp4录像文件的初始化段作为EXT-X-MAP, 其余部分作为一个分片
+// 录像文件的时间戳各自从0开始, 文件之间都加不连续标记, 缺失录像的时间由EXT-X-PROGRAM-DATE-TIME体现
+func buildHlsPlaylist(segments []*model.RecordSegment, expire time.Duration) (string, int, error) {
+	var body strings.Builder
+	targetDuration := 1.0
+	var lastEnd int64
+	count := 0
+	for _, segment := range segments {
+		// 非fmp4录像不能按字节范围拼接
+		if segment.InitSize <= 0 || segment.FileSize <= segment.InitSize || segment.OssKey == "" {
+			continue
+		}
+		// 同一路流的录像不会重叠, 重叠的是重复上报的片段
+		if segment.StartTime < lastEnd {
+			continue
+		}
+		fileUrl, err := SignObjectURL(segment.OssKey, expire)
+		if err != nil {
+			return "", 0, err
+		}
+		if count > 0 {
+			body.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(&body, "#EXT-X-PROGRAM-DATE-TIME:%s\n", time.Unix(segment.StartTime, 0).Format("2006-01-02T15:04:05.000Z07:00"))
+		fmt.Fprintf(&body, "#EXT-X-MAP:URI=\"%s\",BYTERANGE=\"%d@0\"\n", fileUrl, segment.InitSize)
+		fmt.Fprintf(&body, "#EXTINF:%.3f,\n", segment.Duration)
+		fmt.Fprintf(&body, "#EXT-X-BYTERANGE:%d@%d\n", segment.FileSize-segment.InitSize, segment.InitSize)
+		body.WriteString(fileUrl + "\n")
+
+		targetDuration = math.Max(targetDuration, segment.Duration)
+		lastEnd = segment.EndTime
+		count++
+	}
+
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n")
+	playlist.WriteString("#EXT-X-VERSION:7\n")
+	playlist.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	fmt.Fprintf(&playlist, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(targetDuration)))
+	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	playlist.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	playlist.WriteString(body.String())
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+	return playlist.String(), count, nil
+}
+
+// @Summary		ipc统一录像时间轴, 合并设备端国标录像和云端录像, 标记重叠时间段并返回播放句柄
+// @Router		/wvp/ipc/recordTimeline [post]
+func IpcRecordTimeline(c *gin.Context) {
+	var req model.RecordTimelineQueryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	if req.End > time.Now().Unix() {
+		req.End = time.Now().Unix()
+	}
+	if req.End <= req.Start {
+		model.JsonResponseSysERR(c, "结束时间格式错误")
+		return
+	}
+	if req.End-req.Start > model.RecordQueryMaxDays*24*3600 {
+		model.JsonResponseSysERR(c, fmt.Sprintf("查询时间跨度不能超过%d天", model.RecordQueryMaxDays))
+		return
+	}
+
+	segments, err := dao.GetRecordSegments(req.IpcId, model.ParseRecordTypes(req.Type), req.Start, req.End)
+	if err != nil {
+		Logger.Error("查询录像片段失败", zap.String("ipcId", req.IpcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "查询录像失败")
+		return
+	}
+	cloud := make([]*model.RecordSegment, 0, len(segments))
+	for _, segment := range segments {
+		if req.StreamType == "" || segment.StreamId == req.IpcId+"_"+req.StreamType {
+			cloud = append(cloud, segment)
+		}
+	}
+
+	// 非国标ipc没有设备端录像, 设备端查询失败时只返回云端录像
+	var device []model.TimeItem
+	var deviceErr error
+	if !strings.HasPrefix(req.IpcId, "IPC") && (req.Device == nil || *req.Device) {
+		device, deviceErr = ipcDeviceRecords(req.IpcId, req.Start, req.End)
+		if deviceErr != nil {
+			Logger.Warn("查询设备端录像失败", zap.String("ipcId", req.IpcId), zap.Error(deviceErr))
+		}
+	}
+	timeline := model.BuildRecordTimelineMerge(req.IpcId, device, cloud, req.Start, req.End)
+	if deviceErr != nil {
+		timeline.DeviceError = deviceErr.Error()
+	}
+	model.JsonResponseSucc(c, timeline)
+}
+
+// 通过sip服务查询设备端国标录像, 设备最多10秒返回已获取到的部分数据
+func ipcDeviceRecords(ipcId string, start, end int64) ([]model.TimeItem, error) {
+	params := url.Values{}
+	params.Add("ipc_id", ipcId)
+	params.Add("start", strconv.FormatInt(start, 10))
+	params.Add("end", strconv.FormatInt(end, 10))
+	response := WvpIpcGetRequestHandler(ipcId, RecordsListURL, params)
+	if response == nil || response.Data == nil {
+		return nil, errors.New("调用sip服务失败")
+	}
+	payload, ok := response.Data.(string)
+	if response.Code != m.StatusSucc || !ok {
+		return nil, fmt.Errorf("%v", response.Data)
+	}
+	records := model.DataContent{}
+	if err := json.Unmarshal([]byte(payload), &records); err != nil {
+		return nil, err
+	}
+	items := []model.TimeItem{}
+	for _, date := range records.List {
+		items = append(items, date.Items...)
+	}
+	return items, nil
+}
+
+// @Summary		根据时间轴的播放句柄获取播放信息, gb发起国标回放点播, vod返回云端hls列表地址
+// @Router		/wvp/ipc/recordTimelinePlay [post]
+func IpcRecordTimelinePlay(c *gin.Context) {
+	var handle model.RecordPlayHandle
+	if err := c.ShouldBindJSON(&handle); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	switch handle.Type {
+	case model.RecordPlayGB:
+		params := url.Values{}
+		params.Add("ipc_id", handle.IpcId)
+		params.Add("start", strconv.FormatInt(handle.Start, 10))
+		params.Add("end", strconv.FormatInt(handle.End, 10))
+		response := WvpIpcGetRequestHandler(handle.IpcId, PlaybackURL, params)
+		if response == nil || response.Data == nil {
+			model.JsonResponseSysERR(c, "调用失败")
+			return
+		}
+		if response.Code != m.StatusSucc {
+			model.JsonResponseSysERR(c, fmt.Sprintf("%v", response.Data))
+			return
+		}
+		model.JsonResponseSucc(c, model.RecordPlayVO{Type: handle.Type, Stream: response.Data})
+	case model.RecordPlayVod:
+		params := url.Values{}
+		params.Add("ipcId", handle.IpcId)
+		params.Add("start", strconv.FormatInt(handle.Start, 10))
+		params.Add("end", strconv.FormatInt(handle.End, 10))
+		if handle.StreamType != "" {
+			params.Add("streamType", handle.StreamType)
+		}
+		model.JsonResponseSucc(c, model.RecordPlayVO{Type: handle.Type, Url: WvpIpcPlaybackM3u8 + "?" + params.Encode()})
+	case model.RecordPlayFile:
+		if handle.FileUrl == "" {
+			model.JsonResponseSysERR(c, "录像文件地址不能为空")
+			return
+		}
+		model.JsonResponseSucc(c, model.RecordPlayVO{Type: handle.Type, Url: handle.FileUrl})
+	}
+}
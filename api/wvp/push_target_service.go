@@ -0,0 +1,152 @@
+package wvp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go-sip/dao"
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_wvp_util"
+	. "go-sip/logger"
+	"go-sip/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 推流目标初始化, server从redis读取推流目标并对账
+func PushTargetInit() error {
+	target_list, err := dao.GetPushTargets("")
+	if err != nil {
+		Logger.Error("推流目标列表查询失败", zap.Error(err))
+		return fmt.Errorf("推流目标列表查询失败")
+	}
+	for _, target := range target_list {
+		redis_util.HSetStruct_2(redis.PUSH_TARGET_KEY, strconv.FormatInt(target.ID, 10), target)
+	}
+	Logger.Info("推流目标列表初始化完成")
+	return nil
+}
+
+// @Summary 查询推流目标列表, 包含server上报的推流状态
+// @Router /wvp/pushTarget/list [get]
+func PushTargetList(c *gin.Context) {
+	target_list, err := dao.GetPushTargets(c.Query("ipcId"))
+	if err != nil {
+		Logger.Error("推流目标列表查询失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "推流目标列表查询失败")
+		return
+	}
+	status_map, _ := redis_util.HGetAll_2(redis.PUSH_TARGET_STATUS_KEY)
+	for _, target := range target_list {
+		status_str, ok := status_map[strconv.FormatInt(target.ID, 10)]
+		if !ok {
+			continue
+		}
+		status := &model.PushTargetStatus{}
+		if err := json.Unmarshal([]byte(status_str), status); err == nil {
+			target.Status = status
+		}
+	}
+	model.JsonResponseSucc(c, target_list)
+}
+
+// @Summary 新增推流目标
+// @Router /wvp/pushTarget/add [post]
+func AddPushTarget(c *gin.Context) {
+	var dto model.PushTargetSaveDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	target := model.FromPushTargetSaveDTO(&dto)
+	if err := target.Validate(); err != nil {
+		model.JsonResponseSysERR(c, err.Error())
+		return
+	}
+	device_id, err := wvpIpcDeviceId(target.IpcId)
+	if err != nil || device_id == "" {
+		Logger.Warn("ipcId没有关联任何设备", zap.String("ipcId", target.IpcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "ipcId没有关联任何设备")
+		return
+	}
+	target.DeviceId = device_id
+
+	id, err := dao.CreatePushTarget(target)
+	if err != nil {
+		Logger.Error("新增推流目标失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "新增失败")
+		return
+	}
+	target.ID = id
+	redis_util.HSetStruct_2(redis.PUSH_TARGET_KEY, strconv.FormatInt(id, 10), target)
+	model.JsonResponseSucc(c, target)
+}
+
+// @Summary 更新推流目标, server下次对账时重启推流
+// @Router /wvp/pushTarget/update/{id} [put]
+func UpdatePushTarget(c *gin.Context) {
+	id := c.Param("id")
+	var dto model.PushTargetSaveDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	old, err := dao.GetPushTargetByID(id)
+	if err != nil || old == nil {
+		Logger.Error("推流目标不存在", zap.String("id", id), zap.Error(err))
+		model.JsonResponseSysERR(c, "推流目标不存在")
+		return
+	}
+	if old.IpcId != dto.IpcId {
+		model.JsonResponseSysERR(c, "不能修改推流目标的ipcId")
+		return
+	}
+	target := model.FromPushTargetSaveDTO(&dto)
+	if err := target.Validate(); err != nil {
+		model.JsonResponseSysERR(c, err.Error())
+		return
+	}
+	target.ID = old.ID
+	target.DeviceId = old.DeviceId
+	// ipc换绑设备后同步设备id
+	if device_id, _ := wvpIpcDeviceId(target.IpcId); device_id != "" {
+		target.DeviceId = device_id
+	}
+
+	if err := dao.UpdatePushTarget(target); err != nil {
+		Logger.Error("更新推流目标失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "更新失败")
+		return
+	}
+	// 重新查询数据库中加1后的版本号
+	if target, err = dao.GetPushTargetByID(id); err != nil || target == nil {
+		Logger.Error("查询推流目标失败", zap.String("id", id), zap.Error(err))
+		model.JsonResponseSysERR(c, "更新失败")
+		return
+	}
+	redis_util.HSetStruct_2(redis.PUSH_TARGET_KEY, id, target)
+	model.JsonResponseSucc(c, target)
+}
+
+// @Summary 删除推流目标, server下次对账时停止推流
+// @Router /wvp/pushTarget/delete/{id} [delete]
+func DeletePushTarget(c *gin.Context) {
+	id := c.Param("id")
+	target, err := dao.GetPushTargetByID(id)
+	if err != nil || target == nil {
+		Logger.Error("推流目标不存在", zap.String("id", id), zap.Error(err))
+		model.JsonResponseSysERR(c, "推流目标不存在")
+		return
+	}
+	if err := dao.DeletePushTarget(id); err != nil {
+		Logger.Error("删除推流目标失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "删除失败")
+		return
+	}
+	redis_util.HDel_2(redis.PUSH_TARGET_KEY, id)
+	model.JsonResponseSucc(c, "删除成功")
+}
@@ -0,0 +1,181 @@
+package wvp
+
+import (
+	"fmt"
+	"time"
+
+	"go-sip/dao"
+	. "go-sip/db/alioss"
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_wvp_util"
+	. "go-sip/logger"
+	"go-sip/model"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	recordRetentionInterval = time.Hour
+	recordRetentionLockTime = 50 * time.Minute
+)
+
+// @Summary 查询录像计划列表
+// @Router /wvp/recordPlan/list [get]
+func RecordPlanList(c *gin.Context) {
+	plan_list, err := dao.GetRecordPlans(c.Query("ipcId"))
+	if err != nil {
+		Logger.Error("录像计划列表查询失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "录像计划列表查询失败")
+		return
+	}
+	model.JsonResponseSucc(c, plan_list)
+}
+
+// @Summary 新增录像计划
+// @Router /wvp/recordPlan/add [post]
+func AddRecordPlan(c *gin.Context) {
+	var dto model.RecordPlanSaveDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	plan := model.FromRecordPlanSaveDTO(&dto)
+	if err := plan.Validate(); err != nil {
+		model.JsonResponseSysERR(c, err.Error())
+		return
+	}
+	if old, _ := dao.GetRecordPlanByIpcId(plan.IpcId); old != nil {
+		model.JsonResponseSysERR(c, "该ipc已存在录像计划")
+		return
+	}
+	device_id, err := wvpIpcDeviceId(plan.IpcId)
+	if err != nil || device_id == "" {
+		Logger.Warn("ipcId没有关联任何设备", zap.String("ipcId", plan.IpcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "ipcId没有关联任何设备")
+		return
+	}
+	plan.DeviceId = device_id
+
+	id, err := dao.CreateRecordPlan(plan)
+	if err != nil {
+		// 并发新增时由唯一索引拦截
+		if old, _ := dao.GetRecordPlanByIpcId(plan.IpcId); old != nil {
+			model.JsonResponseSysERR(c, "该ipc已存在录像计划")
+			return
+		}
+		Logger.Error("新增录像计划失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "新增失败")
+		return
+	}
+	plan.ID = id
+	model.JsonResponseSucc(c, plan)
+}
+
+// @Summary 更新录像计划, 设备端下次对账时生效
+// @Router /wvp/recordPlan/update/{id} [put]
+func UpdateRecordPlan(c *gin.Context) {
+	id := c.Param("id")
+	var dto model.RecordPlanSaveDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	old, err := dao.GetRecordPlanByID(id)
+	if err != nil || old == nil {
+		Logger.Error("录像计划不存在", zap.String("id", id), zap.Error(err))
+		model.JsonResponseSysERR(c, "录像计划不存在")
+		return
+	}
+	if old.IpcId != dto.IpcId {
+		model.JsonResponseSysERR(c, "不能修改录像计划的ipcId")
+		return
+	}
+	plan := model.FromRecordPlanSaveDTO(&dto)
+	if err := plan.Validate(); err != nil {
+		model.JsonResponseSysERR(c, err.Error())
+		return
+	}
+	plan.ID = old.ID
+	plan.DeviceId = old.DeviceId
+	// ipc换绑设备后同步设备id
+	if device_id, _ := wvpIpcDeviceId(plan.IpcId); device_id != "" {
+		plan.DeviceId = device_id
+	}
+
+	if err := dao.UpdateRecordPlan(plan); err != nil {
+		Logger.Error("更新录像计划失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "更新失败")
+		return
+	}
+	model.JsonResponseSucc(c, plan)
+}
+
+// @Summary 删除录像计划
+// @Router /wvp/recordPlan/delete/{id} [delete]
+func DeleteRecordPlan(c *gin.Context) {
+	id := c.Param("id")
+	plan, err := dao.GetRecordPlanByID(id)
+	if err != nil || plan == nil {
+		Logger.Error("录像计划不存在", zap.String("id", id), zap.Error(err))
+		model.JsonResponseSysERR(c, "录像计划不存在")
+		return
+	}
+	if err := dao.DeleteRecordPlan(id); err != nil {
+		Logger.Error("删除录像计划失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "删除失败")
+		return
+	}
+	model.JsonResponseSucc(c, "删除成功")
+}
+
+// 录像保留期清理, 按录像计划的保留天数删除oss录像文件和回放索引
+func RecordRetentionHandler() {
+	go func() {
+		timer := time.NewTicker(recordRetentionInterval)
+		defer timer.Stop()
+		for range timer.C {
+			// 多实例部署时只由一个实例执行
+			ok, _ := redis_util.SetNX(redis.RECORD_RETENTION_LOCK_KEY, "ok", recordRetentionLockTime)
+			if !ok {
+				continue
+			}
+			cleanExpiredRecords()
+		}
+	}()
+}
+
+func cleanExpiredRecords() {
+	plan_list, err := dao.GetRecordPlans("")
+	if err != nil {
+		Logger.Error("录像计划列表查询失败", zap.Error(err))
+		return
+	}
+	for _, plan := range plan_list {
+		if plan.RetentionDays <= 0 {
+			continue
+		}
+		// 录像文件按流id存储, ipc的所有码流一起清理
+		prefix := fmt.Sprintf("ipc_video_playback/%s_", plan.IpcId)
+		if err := DeleteOldObjects(prefix, plan.RetentionDays); err != nil {
+			Logger.Error("删除过期录像文件失败", zap.String("ipcId", plan.IpcId), zap.Error(err))
+			continue
+		}
+		// 回放索引以录像开始时间为分数
+		expire_time := time.Now().AddDate(0, 0, -plan.RetentionDays).Unix()
+		pattern := fmt.Sprintf(redis.DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY, plan.DeviceId, plan.IpcId, "*")
+		removed, err := redis_util.ScanZRemRangeByScore(pattern, "-inf", fmt.Sprintf("(%d", expire_time))
+		if err != nil {
+			Logger.Error("删除过期回放索引失败", zap.String("ipcId", plan.IpcId), zap.Error(err))
+			continue
+		}
+		if n, err := dao.DeleteRecordSegmentsBefore(plan.IpcId, expire_time); err != nil {
+			Logger.Error("删除过期录像片段失败", zap.String("ipcId", plan.IpcId), zap.Error(err))
+		} else {
+			removed += n
+		}
+		Logger.Info("录像保留期清理完成", zap.String("ipcId", plan.IpcId), zap.Int("retentionDays", plan.RetentionDays), zap.Int64("removed", removed))
+	}
+}
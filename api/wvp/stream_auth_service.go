@@ -0,0 +1,207 @@
+package wvp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_wvp_util"
+	. "go-sip/logger"
+	"go-sip/model"
+	"go-sip/stream_auth"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	streamTokenDefaultExpire = 300   // 播放token默认有效期, 单位秒
+	streamTokenMaxExpire     = 86400 // 播放token最长有效期, 单位秒
+)
+
+// 租户的签名密钥, 租户没有单独的密钥时使用默认密钥, 默认密钥不存在时自动生成
+func streamTenantKey(tenant string) (string, error) {
+	if tenant != "" {
+		if key, _ := redis_util.HGet_2(redis.STREAM_AUTH_TENANT_KEY, tenant); key != "" {
+			return key, nil
+		}
+	}
+	key, err := redis_util.HGet_2(redis.STREAM_AUTH_TENANT_KEY, stream_auth.DefaultTenant)
+	if err != nil {
+		return "", err
+	}
+	if key != "" {
+		return key, nil
+	}
+	// 多实例同时生成时以先写入的为准
+	if err := redis_util.HSetIfNotExist_2(redis.STREAM_AUTH_TENANT_KEY, stream_auth.DefaultTenant, stream_auth.GenerateKey()); err != nil {
+		return "", err
+	}
+	key, err = redis_util.HGet_2(redis.STREAM_AUTH_TENANT_KEY, stream_auth.DefaultTenant)
+	if err != nil || key == "" {
+		return "", errors.New("播放鉴权默认密钥初始化失败")
+	}
+	return key, nil
+}
+
+// 根据ipcId查询关联的设备id
+func wvpIpcDeviceId(ipcId string) (string, error) {
+	if strings.HasPrefix(ipcId, "IPC") {
+		return redis_util.HGet_2(redis.NOT_GB_IPC_DEVICE, ipcId)
+	}
+	device_ipc_info_str, err := redis_util.HGet_2(redis.DEVICE_IPC_INFO_KEY, ipcId)
+	if err != nil || device_ipc_info_str == "" {
+		return "", err
+	}
+	ipc_info := model.IpcInfo{}
+	if err := json.Unmarshal([]byte(device_ipc_info_str), &ipc_info); err != nil {
+		return "", err
+	}
+	return ipc_info.DeviceID, nil
+}
+
+// zlm地址, zlmDomain不带协议时默认http
+func zlmPlayBaseUrl(zlmInfo *model.ZlmInfo) (*url.URL, error) {
+	domain := zlmInfo.ZlmDomain
+	if !strings.Contains(domain, "://") {
+		domain = "http://" + domain
+	}
+	return url.Parse(strings.TrimRight(domain, "/"))
+}
+
+// @Summary 获取带播放token的播放地址
+// @Router /wvp/stream/playUrl [post]
+func StreamPlayUrl(c *gin.Context) {
+	req := model.StreamPlayUrlReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	if req.Expire <= 0 {
+		req.Expire = streamTokenDefaultExpire
+	}
+	if req.Expire > streamTokenMaxExpire {
+		model.JsonResponseSysERR(c, fmt.Sprintf("有效期不能超过%d秒", streamTokenMaxExpire))
+		return
+	}
+	if req.ClientIp != "" && net.ParseIP(req.ClientIp) == nil {
+		model.JsonResponseSysERR(c, "参数clientIp错误")
+		return
+	}
+
+	device_id, err := wvpIpcDeviceId(req.IpcId)
+	if err != nil || device_id == "" {
+		Logger.Warn("ipcId没有关联任何设备", zap.String("ipcId", req.IpcId), zap.Error(err))
+		model.JsonResponseSysERR(c, "ipcId没有关联任何设备")
+		return
+	}
+	zlmInfo, err := WvpGetZlmInfo(device_id)
+	if err != nil || zlmInfo == nil {
+		model.JsonResponseSysERR(c, "获取zlm服务信息失败")
+		return
+	}
+	base, err := zlmPlayBaseUrl(zlmInfo)
+	if err != nil {
+		Logger.Error("zlm地址格式错误", zap.String("zlmDomain", zlmInfo.ZlmDomain), zap.Error(err))
+		model.JsonResponseSysERR(c, "zlm地址格式错误")
+		return
+	}
+
+	// token签发给流所属的租户, 即设备关联的门店
+	tenant, _ := redis_util.HGet_4(redis.IOT_DEVICE_STORE_KEY, device_id)
+	key, err := streamTenantKey(tenant)
+	if err != nil {
+		Logger.Error("获取播放鉴权密钥失败", zap.String("tenant", tenant), zap.Error(err))
+		model.JsonResponseSysERR(c, "获取播放鉴权密钥失败")
+		return
+	}
+	// 未指定码流时token可用于该ipc的所有码流, 播放地址默认标清
+	claims := &stream_auth.Claims{
+		Stream: req.IpcId,
+		Viewer: req.Viewer,
+		Tenant: tenant,
+		IP:     req.ClientIp,
+		Scope:  stream_auth.ScopePlay,
+		Expire: time.Now().Unix() + int64(req.Expire),
+	}
+	stream := req.IpcId + "_0"
+	if req.StreamType != nil {
+		stream = fmt.Sprintf("%s_%d", req.IpcId, *req.StreamType)
+		claims.Stream = stream
+	}
+	token, err := stream_auth.Sign(key, claims)
+	if err != nil {
+		Logger.Error("播放token签发失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "播放token签发失败")
+		return
+	}
+
+	query := url.Values{stream_auth.TokenParam: {token}}.Encode()
+	Logger.Info("签发播放token", zap.String("tokenId", claims.ID), zap.String("stream", claims.Stream), zap.String("viewer", req.Viewer), zap.String("tenant", tenant))
+	model.JsonResponseSucc(c, model.StreamPlayUrlVO{
+		TokenId:  claims.ID,
+		Token:    token,
+		ExpireAt: claims.Expire,
+		Stream:   stream,
+		Rtsp:     fmt.Sprintf("rtsp://%s:554/rtp/%s?%s", base.Hostname(), stream, query),
+		Flv:      fmt.Sprintf("%s/rtp/%s.live.flv?%s", base.String(), stream, query),
+		Hls:      fmt.Sprintf("%s/rtp/%s/hls.m3u8?%s", base.String(), stream, query),
+	})
+}
+
+// @Summary 吊销播放token
+// @Router /wvp/stream/tokenRevoke [post]
+func StreamTokenRevoke(c *gin.Context) {
+	req := model.StreamTokenRevokeReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	claims, err := stream_auth.Parse(req.Token)
+	if err != nil {
+		model.JsonResponseSysERR(c, "token格式错误")
+		return
+	}
+	// 吊销记录保留到token过期
+	ttl := time.Until(time.Unix(claims.Expire, 0))
+	if ttl <= 0 {
+		model.JsonResponseSucc(c, "token已过期")
+		return
+	}
+	if err := redis_util.Set_2(fmt.Sprintf(redis.STREAM_AUTH_REVOKED_KEY, claims.ID), "1", ttl); err != nil {
+		model.JsonResponseSysERR(c, "吊销token失败")
+		return
+	}
+	Logger.Info("吊销播放token", zap.String("tokenId", claims.ID), zap.String("stream", claims.Stream), zap.String("viewer", claims.Viewer))
+	model.JsonResponseSucc(c, "吊销成功")
+}
+
+// @Summary 设置租户播放鉴权签名密钥, 更换密钥后该租户已签发的token全部失效
+// @Router /wvp/stream/tenantKey [put]
+func StreamAuthTenantKeyUpdate(c *gin.Context) {
+	req := model.StreamAuthTenantKeyReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	if req.Key == "" {
+		req.Key = stream_auth.GenerateKey()
+	} else if len(req.Key) < 16 {
+		model.JsonResponseSysERR(c, "签名密钥长度不能小于16")
+		return
+	}
+	if err := redis_util.HSet_2(redis.STREAM_AUTH_TENANT_KEY, req.Tenant, req.Key); err != nil {
+		model.JsonResponseSysERR(c, "设置签名密钥失败")
+		return
+	}
+	Logger.Info("设置租户播放鉴权签名密钥", zap.String("tenant", req.Tenant))
+	model.JsonResponseSucc(c, req)
+}
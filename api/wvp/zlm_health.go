@@ -0,0 +1,183 @@
+package wvp
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"go-sip/dao"
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_wvp_util"
+	. "go-sip/logger"
+	"go-sip/model"
+	"go-sip/zlm_api"
+
+	"go.uber.org/zap"
+)
+
+const (
+	zlmHealthCheckInterval = 30 * time.Second
+	zlmHealthCheckLockTime = 25 * time.Second
+	zlmProbeTimeout        = 3 * time.Second
+	zlmProbeFailThreshold  = 3                // 连续探测失败次数达到阈值后下线
+	zlmKeepaliveExpire     = 60 * time.Second // 超过该时间未收到心跳视为心跳超时
+)
+
+// zlm节点健康检查, 定时探测所有节点并结合on_server_keepalive心跳判断节点状态
+// 节点异常时自动下线并把关联的设备重新分配到同地区的可用节点
+func ZlmNodeHealthCheck() {
+	ctx := context.Background()
+	go func() {
+		timer := time.NewTicker(zlmHealthCheckInterval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				Logger.Info("ZlmNodeHealthCheck exit")
+				return
+			case <-timer.C:
+				// 多实例部署时只由一个实例执行
+				ok, _ := redis_util.SetNX(redis.ZLM_NODE_HEALTH_CHECK_LOCK_KEY, "ok", zlmHealthCheckLockTime)
+				if !ok {
+					continue
+				}
+				checkZlmNodes(ctx)
+			}
+		}
+	}()
+}
+
+func checkZlmNodes(ctx context.Context) {
+	zlm_node_list, err := dao.GetAllZlmNodes()
+	if err != nil {
+		Logger.Error("zlm节点信息列表查询失败", zap.Error(err))
+		return
+	}
+	keepalive_map, err := redis_util.HGetAll_2(redis.ZLM_NODE_KEEPALIVE_KEY)
+	if err != nil {
+		keepalive_map = map[string]string{}
+	}
+
+	events := []model.ZlmNodeAlertEvent{}
+	for i := range zlm_node_list {
+		node := &zlm_node_list[i]
+		// 手动禁用的节点不参与健康检查
+		if node.ZlmNodeStatus == model.ZlmNodeStatusDisable {
+			continue
+		}
+		healthy, reason := probeZlmNode(ctx, node, keepalive_map[node.ZlmDomain])
+		if healthy {
+			sampleZlmNodeLoad(ctx, node)
+		}
+		if healthy && node.ZlmNodeStatus == model.ZlmNodeStatusOffline {
+			node.ZlmNodeStatus = model.ZlmNodeStatusEnable
+		} else if !healthy && node.ZlmNodeStatus == model.ZlmNodeStatusEnable {
+			node.ZlmNodeStatus = model.ZlmNodeStatusOffline
+		} else {
+			continue
+		}
+		if err := dao.UpdateZlmNodeStatus(node.ID, node.ZlmNodeStatus); err != nil {
+			Logger.Error("更新mysql节点状态失败", zap.String("zlm_domain", node.ZlmDomain), zap.Error(err))
+			continue
+		}
+		if healthy {
+			reason = "健康检查恢复"
+		}
+		events = append(events, model.ZlmNodeAlertEvent{
+			ZlmDomain:  node.ZlmDomain,
+			RegionCode: node.RegionCode,
+			Status:     node.ZlmNodeStatus,
+			Reason:     reason,
+			Time:       time.Now().Unix(),
+		})
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	// 刷新地区关联zlm缓存后再重新分配设备
+	if err := ZlmNodeRegionInfoInit(); err != nil {
+		Logger.Error("地区关联zlm重新初始化失败", zap.Error(err))
+	}
+	for _, event := range events {
+		if event.Status == model.ZlmNodeStatusOffline {
+			event.Devices, event.Failed = repinZlmDevices(event.ZlmDomain)
+		}
+		publishZlmNodeAlert(event)
+	}
+}
+
+// 探测zlm节点, 接口探测连续失败达到阈值且心跳超时才判定为异常
+func probeZlmNode(ctx context.Context, node *model.ZlmNodeInfo, keepalive string) (bool, string) {
+	client := zlm_api.NewClient(node.ZlmDomain, node.ZlmSecret, zlm_api.WithTimeout(zlmProbeTimeout), zlm_api.WithRetries(0))
+	_, err := client.Version(ctx)
+	if err == nil {
+		redis_util.HDel_2(redis.ZLM_NODE_PROBE_FAIL_KEY, node.ZlmDomain)
+		return true, ""
+	}
+
+	fail_count := 0
+	if v, _ := redis_util.HGet_2(redis.ZLM_NODE_PROBE_FAIL_KEY, node.ZlmDomain); v != "" {
+		fail_count, _ = strconv.Atoi(v)
+	}
+	fail_count++
+	redis_util.HSet_2(redis.ZLM_NODE_PROBE_FAIL_KEY, node.ZlmDomain, strconv.Itoa(fail_count))
+	Logger.Warn("zlm节点探测失败", zap.String("zlm_domain", node.ZlmDomain), zap.Int("fail_count", fail_count), zap.Error(err))
+
+	if keepalive != "" {
+		last, _ := strconv.ParseInt(keepalive, 10, 64)
+		if time.Since(time.Unix(last, 0)) < zlmKeepaliveExpire {
+			return true, ""
+		}
+	}
+	if fail_count < zlmProbeFailThreshold {
+		return node.ZlmNodeStatus == model.ZlmNodeStatusEnable, ""
+	}
+	return false, err.Error()
+}
+
+// 采集节点负载, 供按负载选择zlm节点的策略使用, 采集失败时保留旧采样直到过期
+func sampleZlmNodeLoad(ctx context.Context, node *model.ZlmNodeInfo) {
+	client := zlm_api.NewClient(node.ZlmDomain, node.ZlmSecret, zlm_api.WithTimeout(zlmProbeTimeout), zlm_api.WithRetries(0))
+	load, err := zlm_api.SampleNodeLoad(ctx, client, time.Now())
+	if err != nil {
+		Logger.Warn("zlm节点负载采集失败", zap.String("zlm_domain", node.ZlmDomain), zap.Error(err))
+		return
+	}
+	redis_util.HSetStruct_2(redis.ZLM_NODE_LOAD_KEY, node.ZlmDomain, load)
+}
+
+// 把关联到已下线节点的设备重新分配到同地区的可用节点
+func repinZlmDevices(zlmDomain string) (devices []string, failed []string) {
+	device_zlm_map, err := redis_util.HGetAll_2(redis.DEVICE_ZLM_KEY)
+	if err != nil {
+		Logger.Error("设备关联zlm列表查询失败", zap.Error(err))
+		return nil, nil
+	}
+	for device_id, domain := range device_zlm_map {
+		if domain != zlmDomain {
+			continue
+		}
+		redis_util.HDel_2(redis.DEVICE_ZLM_KEY, device_id)
+		zlmInfo, err := WvpGetZlmInfo(device_id)
+		if err != nil || zlmInfo == nil {
+			Logger.Error("设备重新分配zlm节点失败", zap.String("device_id", device_id), zap.Error(err))
+			failed = append(failed, device_id)
+			continue
+		}
+		Logger.Info("设备重新分配zlm节点", zap.String("device_id", device_id), zap.String("from", zlmDomain), zap.String("to", zlmInfo.ZlmDomain))
+		devices = append(devices, device_id)
+	}
+	return devices, failed
+}
+
+// 发布zlm节点告警事件
+func publishZlmNodeAlert(event model.ZlmNodeAlertEvent) {
+	Logger.Error("zlm节点告警", zap.Any("event", event))
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	redis_util.Publish_2(redis.ZLM_NODE_ALERT_CHANNEL, string(data))
+}
@@ -165,6 +165,10 @@ func UpdateZlmNodeInfo(c *gin.Context) {
 	}
 	zlmNodeInfoNew := model.FromZlmNodeInfoSaveDTO(&dto)
 	zlmNodeInfoNew.ID = zlmNodeInfo.ID
+	// 未传权重时保留原权重
+	if dto.Weight <= 0 {
+		zlmNodeInfoNew.Weight = zlmNodeInfo.Weight
+	}
 	// 更新msyql
 	err = dao.UpdateZlmNode(zlmNodeInfoNew)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"go-sip/dao"
 	. "go-sip/logger"
 	"go-sip/model"
+	"go-sip/zlm_api"
 
 	"go-sip/db/redis"
 	redis_util "go-sip/db/redis/redis_wvp_util"
@@ -23,12 +24,19 @@ func ZlmNodeRegionInfoInit() error {
 	}
 	// 遍历列表，写入redis
 	for _, zlm_node_region := range zlm_node_region_list {
+		redis_util.HSet_2(redis.WVP_REGION_ZLM_STRATEGY, zlm_node_region.RegionCode, zlm_node_region.SelectStrategy)
 		// 根据关联地区编码查询zlm节点信息
 		zlm_node_info_list, err := dao.GetZlmNodesByCode(zlm_node_region.RelationRegionCode)
-		if err != nil || len(zlm_node_info_list) == 0 {
+		if err != nil {
 			Logger.Error("地区关联的zlm节点信息查询失败", zap.Error(err))
 			continue
 		}
+		if len(zlm_node_info_list) == 0 {
+			// 没有可用节点时删除缓存, 避免继续分配已下线的节点
+			Logger.Warn("地区没有可用的zlm节点", zap.String("region_code", zlm_node_region.RegionCode))
+			redis_util.HDel_2(redis.WVP_REGION_RELATION_ZLM_INFO, zlm_node_region.RegionCode)
+			continue
+		}
 		redis_util.HSetStruct_2(redis.WVP_REGION_RELATION_ZLM_INFO, zlm_node_region.RegionCode, zlm_node_info_list)
 	}
 	Logger.Info("zlm节点地区信息列表初始化完成")
@@ -48,6 +56,56 @@ func ZlmNodeRegionInfoList(c *gin.Context) {
 	model.JsonResponseSucc(c, zlm_node_region_list)
 }
 
+// @Summary 新增zlm节点地区信息
+// @Router /wvp/zlm/regionAdd [post]
+func ZlmNodeRegionAdd(c *gin.Context) {
+	dto := model.ZlmRegionDTO{}
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		Logger.Error("参数错误", zap.Error(err))
+		model.JsonResponseSysERR(c, "参数错误")
+		return
+	}
+	if !zlm_api.IsValidSelectStrategy(dto.SelectStrategy) {
+		model.JsonResponseSysERR(c, "参数selectStrategy错误")
+		return
+	}
+	region, err := dao.GetRegionByCode(dto.RegionCode)
+	if err != nil {
+		Logger.Error("zlm节点地区信息查询失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "zlm节点地区信息查询失败")
+		return
+	}
+	if region != nil {
+		model.JsonResponseSysERR(c, "地区已存在")
+		return
+	}
+
+	zlmRegionInfo := &model.ZlmRegionInfo{
+		RegionCode:         dto.RegionCode,
+		RegionName:         dto.RegionName,
+		RelationRegionCode: dto.RelationRegionCode,
+		RelationRegionName: dto.RelationRegionName,
+		SelectStrategy:     dto.SelectStrategy,
+		Remarks:            dto.Remarks,
+	}
+	id, err := dao.CreateRegion(zlmRegionInfo)
+	if err != nil {
+		Logger.Error("新增zlm节点地区信息失败", zap.Error(err))
+		model.JsonResponseSysERR(c, "新增失败")
+		return
+	}
+	zlmRegionInfo.ID = id
+	redis_util.HSet_2(redis.WVP_REGION_ZLM_STRATEGY, zlmRegionInfo.RegionCode, zlmRegionInfo.SelectStrategy)
+	// 根据关联地区编码查询zlm节点信息
+	zlm_node_info_list, err := dao.GetZlmNodesByCode(zlmRegionInfo.RelationRegionCode)
+	if err != nil || len(zlm_node_info_list) == 0 {
+		Logger.Error("地区关联的zlm节点信息查询失败", zap.Error(err))
+	} else {
+		redis_util.HSetStruct_2(redis.WVP_REGION_RELATION_ZLM_INFO, zlmRegionInfo.RegionCode, zlm_node_info_list)
+	}
+	model.JsonResponseSucc(c, zlmRegionInfo)
+}
+
 // @Summary 更新zlm节点地区信息
 // @Router /wvp/zlm/regionUpdate/{id} [post]
 func ZlmNodeRegionUpdate(c *gin.Context) {
@@ -68,7 +126,13 @@ func ZlmNodeRegionUpdate(c *gin.Context) {
 		return
 	}
 
-	if zlmRegionInfo.RelationRegionCode == zlmRegionInfoDTO.RelationRegionCode {
+	if !zlm_api.IsValidSelectStrategy(zlmRegionInfoDTO.SelectStrategy) {
+		model.JsonResponseSysERR(c, "参数selectStrategy错误")
+		return
+	}
+
+	if zlmRegionInfo.RelationRegionCode == zlmRegionInfoDTO.RelationRegionCode &&
+		zlmRegionInfo.SelectStrategy == zlmRegionInfoDTO.SelectStrategy {
 		Logger.Warn("关联节点地区相同，不需要修改")
 		model.JsonResponseSucc(c, "关联节点地区相同，不需要修改")
 		return
@@ -77,6 +141,7 @@ func ZlmNodeRegionUpdate(c *gin.Context) {
 	// 更新字段
 	zlmRegionInfo.RelationRegionCode = zlmRegionInfoDTO.RelationRegionCode
 	zlmRegionInfo.RelationRegionName = zlmRegionInfoDTO.RelationRegionName
+	zlmRegionInfo.SelectStrategy = zlmRegionInfoDTO.SelectStrategy
 
 	// 更新mysql
 	err = dao.UpdateRegion(zlmRegionInfo)
@@ -84,6 +149,7 @@ func ZlmNodeRegionUpdate(c *gin.Context) {
 		model.JsonResponseSysERR(c, "更新失败")
 		return
 	}
+	redis_util.HSet_2(redis.WVP_REGION_ZLM_STRATEGY, zlmRegionInfo.RegionCode, zlmRegionInfo.SelectStrategy)
 	// 根据关联地区编码查询zlm节点信息
 	zlm_node_info_list, err := dao.GetZlmNodesByCode(zlmRegionInfo.RelationRegionCode)
 	if err != nil || len(zlm_node_info_list) == 0 {
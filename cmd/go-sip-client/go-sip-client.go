@@ -1,8 +1,7 @@
 package main
 
 import (
-	"bytes"
-	"fmt"
+	"context"
 	capi "go-sip/api/c"
 	. "go-sip/common"
 	"go-sip/db/alioss"
@@ -13,7 +12,6 @@ import (
 	sipapi "go-sip/sip"
 	"go-sip/zlm_api"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -21,85 +19,6 @@ import (
 	"go.uber.org/zap"
 )
 
-func ensureEchoCancelModuleLoaded() {
-	// 1. 查询已加载模块
-	cmd := exec.Command("pactl", "list", "modules", "short")
-	output, err := cmd.Output()
-	if err != nil {
-		panic(err)
-	}
-
-	// 2. 检查 module-echo-cancel 是否存在
-	if strings.Contains(string(output), "module-echo-cancel") {
-		// 2.1 获取模块ID
-		lines := strings.Split(string(output), "\n")
-		var moduleID string
-		for _, line := range lines {
-			if strings.Contains(line, "module-echo-cancel") {
-				fields := strings.Fields(line)
-				if len(fields) > 0 {
-					moduleID = fields[0]
-					break
-				}
-			}
-		}
-
-		// 2.2 卸载现有模块
-		if moduleID != "" {
-			err := exec.Command("pactl", "unload-module", moduleID).Run()
-			if err != nil {
-				panic(fmt.Errorf("卸载 module-echo-cancel 失败: %v", err))
-			}
-			Logger.Info("已卸载 module-echo-cancel ", zap.Any("moduleID", moduleID))
-		}
-	}
-
-	// 3. 校验配置
-	if m.CMConfig.Audio.InputDevice == "" || m.CMConfig.Audio.OutputDevice == "" {
-		Logger.Error("音频输入或输出设备未配置，无法加载 module-echo-cancel")
-		panic("音频输入或输出设备未配置")
-	}
-
-	sink := fmt.Sprintf("sink_master=%s", m.CMConfig.Audio.OutputDevice)
-	source := fmt.Sprintf("source_master=%s", m.CMConfig.Audio.InputDevice)
-
-	Logger.Info("加载 module-echo-cancel", zap.String("sink", sink), zap.String("source", source))
-
-	// 4. 加载 module-echo-cancel
-	loadCmd := exec.Command("pactl", "load-module",
-		"module-echo-cancel",
-		"sink_name=echo_cancel_sink",
-		"source_name=echo_cancel_source",
-		sink,
-		source,
-		"rate=8000",
-		"channels=1",
-		"aec_method=webrtc",
-	)
-	var stderr bytes.Buffer
-	loadCmd.Stderr = &stderr
-
-	if err := loadCmd.Run(); err != nil {
-		panic(fmt.Errorf("加载 module-echo-cancel 失败: %v\n%s", err, stderr.String()))
-	}
-
-	Logger.Info("module-echo-cancel 已加载")
-
-	// 5. 设置默认输入设备（source）为 echo_cancel_source
-	if err := exec.Command("pactl", "set-default-source", "echo_cancel_source").Run(); err != nil {
-		Logger.Warn("设置默认输入设备失败", zap.Error(err))
-	} else {
-		Logger.Info("默认输入设备已设置为 echo_cancel_source")
-	}
-
-	// 6. 设置默认输出设备（sink）为 echo_cancel_sink
-	if err := exec.Command("pactl", "set-default-sink", "echo_cancel_sink").Run(); err != nil {
-		Logger.Warn("设置默认输出设备失败", zap.Error(err))
-	} else {
-		Logger.Info("默认输出设备已设置为 echo_cancel_sink")
-	}
-}
-
 func GetPlatform() string {
 
 	if model, err := os.ReadFile("/proc/device-tree/model"); err == nil {
@@ -120,7 +39,9 @@ func main() {
 	m.LoadClientConfig()
 	logger.InitLogger(m.CMConfig.LogLevel)
 
-	ensureEchoCancelModuleLoaded()
+	if err := capi.EnsureEchoCancelModuleLoaded(); err != nil {
+		panic(err)
+	}
 	device_id, err := os.Hostname()
 	if err != nil || device_id == "" {
 		panic(err)
@@ -135,7 +56,14 @@ func main() {
 	os.Setenv("PULSE_PROP", "filter.want=echo-cancel")
 
 	// 关闭本地zlm所有流
-	zlm_api.ZlmCloseAllStreams(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret)
+	zlm_api.ZlmCloseAllStreams(sipapi.Local_ZLM_Host, m.ClientZlmSecret())
+
+	// 录像格式
+	if m.CMConfig.RecordFmp4 {
+		if err := zlm_api.NewClient(sipapi.Local_ZLM_Host, m.ClientZlmSecret()).SetServerConfig(context.Background(), map[string]string{"record.enableFmp4": "1"}); err != nil {
+			Logger.Error("设置zlm录像格式失败", zap.Error(err))
+		}
+	}
 
 	// 初始化alioss
 	alioss.SipClientInitAliOSS()
@@ -146,6 +74,9 @@ func main() {
 	// 非国标摄像头推流
 	capi.IpcPushStreamHandler(device_id)
 
+	// 录像计划
+	capi.RecordPlanHandler(device_id)
+
 	// 启动AI事件触发监听
 	go capi.StartAiModelTriggerHandler()
 
@@ -165,7 +96,11 @@ func main() {
 	}()
 
 	for {
-		tcp_addr := capi.GetSipServerTcpAddr(m.CMConfig.Gateway, device_id)
+		// sip服务下线时优先使用其指定的地址, 否则通过网关选择
+		tcp_addr := client.TakeReconnectAddr()
+		if tcp_addr == "" {
+			tcp_addr = capi.GetSipServerTcpAddr(m.ClientGateway(), device_id)
+		}
 		if tcp_addr == "" {
 			// 默认获取配置中的tcp地址
 			tcp_addr = m.CMConfig.TCP
@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"net"
 	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"go-sip/api"
 	"go-sip/api/middleware"
 	sapi "go-sip/api/s"
+	"go-sip/db/alioss"
 	"go-sip/db/redis"
 	redis_util "go-sip/db/redis/redis_server_util"
 	_ "go-sip/docs"
@@ -33,12 +37,21 @@ func main() {
 	m.LoadServerConfig()
 	logger.InitLogger(m.SMConfig.LogLevel)
 	redis.InitServerRedisMulti(2, 4)
+	alioss.ServerInitAliOSS()
 	r := gin.Default()
 	r.Use(middleware.Recovery)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	api.ServerApiInit(r)
 	// 定时监控ipc状态
 	sapi.IpcStatusSync()
+	// 定时刷新在线ipc封面
+	sapi.IpcSnapshotRefresh()
+	// 定时采集流会话
+	sapi.StreamSessionSample()
+	// 定时对账推流目标
+	sapi.PushTargetReconcile()
+	// 流中断自动恢复
+	sapi.StreamRecoveryHandler()
 	// 初始化kafka
 	go kafka.InitKafkaProducer()
 	// 初始化mqtt
@@ -59,11 +72,28 @@ func main() {
 	sip_server := grpc_server.GetSipServer()
 	pb.RegisterSipServiceServer(grpcServer, sip_server)
 	go grpcServer.Serve(lis)
+	// 跨实例命令转发
+	sip_server.StartCommandRoute()
 
 	// sip服务id对应sip公网url存入redis
 	redis_util.HSet_2(redis.SIP_SERVER_HOST, m.SMConfig.SipID, fmt.Sprintf("%s:%s", m.SMConfig.SipInnerIp, m.SMConfig.SipPort))
 	redis_util.HSet_2(redis.SIP_SERVER_PUBLIC_TCP_HOST, m.SMConfig.SipID, fmt.Sprintf("%s:%s", m.SMConfig.SipOutIp, m.SMConfig.TcpPort))
 
+	// 收到SIGTERM或调用下线接口时排空客户端后退出
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		select {
+		case sig := <-sigCh:
+			logger.Logger.Info("收到退出信号", zap.String("signal", sig.String()))
+			go sip_server.Drain()
+		case <-sip_server.Drained():
+		}
+		<-sip_server.Drained()
+		grpcServer.Stop()
+		os.Exit(0)
+	}()
+
 	err := r.Run(m.SMConfig.API)
 	if err != nil {
 		logger.Logger.Error("sip server启动失败", zap.Error(err))
@@ -38,6 +38,12 @@ func main() {
 
 	wvp.ZlmNodeInfoInit()
 	wvp.ZlmNodeRegionInfoInit()
+	wvp.MergeLayoutInit()
+	wvp.PushTargetInit()
+	// zlm节点健康检查
+	wvp.ZlmNodeHealthCheck()
+	// 录像保留期清理
+	wvp.RecordRetentionHandler()
 	api.WvpApiInit(r)
 
 	err := r.Run(m.WVPConfig.API)
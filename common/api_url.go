@@ -17,6 +17,13 @@ const (
 	StopURL     = "/ipc/stop"
 	// 录像列表接口
 	RecordsListURL = "/ipc/records"
+	// ipc截图接口
+	IpcSnapshotURL = "/ipc/snapshot"
+	// 流会话接口
+	StreamSessionListURL   = "/stream/sessions"
+	StreamSessionDetailURL = "/stream/session"
+	StreamSessionCloseURL  = "/stream/session/close"
+	StreamWaiterStatsURL   = "/stream/waiter/stats"
 
 	// 服务端ZLM Webhook接口
 	ZLMWebHookServerURL = ZLMWebHookBaseURL + "/:method"
@@ -30,6 +37,22 @@ const (
 	IpcStreamResetURL = "/ipc/streamReset"
 	// 设备OTA固件拉取升级
 	DeviceOtaFirmwarePullURL = "/device/ota/firmwarePull"
+	// 设备远程诊断
+	DeviceDiagnosticsURL = "/device/diagnostics"
+	// 设备远程修改配置
+	DeviceConfigUpdateURL = "/device/config/update"
+	// 设备实时日志订阅
+	DeviceLogStreamURL = "/device/log/stream"
+	// 批量下发命令
+	BulkJobCreateURL = "/bulk/job/create"
+	// 批量命令任务进度查询
+	BulkJobInfoURL = "/bulk/job/info"
+	// 异步命令执行进度查询
+	OperationInfoURL = "/operation/info"
+	// 异步命令取消
+	OperationCancelURL = "/operation/cancel"
+	// sip服务下线排空
+	SipServerDrainURL = "/sip/drain"
 
 	// 获取所有已启用的AI模型接口
 	AiModelListURL = "/aiModel/list"
@@ -38,6 +61,8 @@ const (
 	OpenSipServerInfoURL = "/open/sip/getSipServerInfo"
 	// 对外开放的录像列表接口
 	OpenRecordsListURL = "/open/ipc/recordList"
+	// 分页查询录像片段
+	OpenRecordSegmentsURL = "/open/ipc/recordSegments"
 	// 对外开放的ZLM信息接口
 	OpenZLMInfoURL = "/open/ipc/getZlm"
 	// 对外开放的回放速度接口
@@ -70,6 +95,8 @@ const (
 	OpenGetNotGbIpcUpdateURL = "/open/ipc/notGbUpdate"
 	// 设备推流
 	OpenIpcPushStreamURL = "/open/ipc/pushStream"
+	// 查询设备的录像计划
+	OpenRecordPlanListURL = "/open/record/planList"
 
 	// 登录接口
 	WvpAuthURL = "/login/auth"
@@ -82,6 +109,10 @@ const (
 	WvpIpcClarityURL    = "/wvp/ipc/clarity"
 	WvpIpcListURL       = "/wvp/ipc/list"
 	WvpIpcRecordListURL = "/wvp/ipc/recordList"
+	WvpIpcRecordSegURL  = "/wvp/ipc/recordSegments"
+	WvpIpcPlaybackM3u8  = "/wvp/ipc/playback.m3u8"
+	WvpIpcTimelineURL   = "/wvp/ipc/recordTimeline"
+	WvpIpcTimelinePlay  = "/wvp/ipc/recordTimelinePlay"
 	WvpRecordsListURL   = "/wvp/ipc/records"
 	WvpZLMInfoURL       = "/wvp/ipc/getZlm"
 	WvpPlaybackSpeedURL = "/wvp/ipc/playbackSpeed"
@@ -99,10 +130,32 @@ const (
 	WvpGetZlmNodeRelationRegionListURL = "/wvp/zlm/nodeRelationRegionList/:nodeId"
 
 	WvpGetZlmRegionListURL = "/wvp/zlm/regionList"
+	WvpAddZlmRegionURL     = "/wvp/zlm/regionAdd"
 	WvpUpdateZlmRegionURL  = "/wvp/zlm/regionUpdate/:id"
 
+	WvpStreamPlayUrlURL       = "/wvp/stream/playUrl"
+	WvpStreamTokenRevokeURL   = "/wvp/stream/tokenRevoke"
+	WvpStreamAuthTenantKeyURL = "/wvp/stream/tenantKey"
+
+	WvpMergeLayoutListURL   = "/wvp/mergeLayout/list"
+	WvpMergeLayoutAddURL    = "/wvp/mergeLayout/add"
+	WvpMergeLayoutUpdateURL = "/wvp/mergeLayout/update/:id"
+	WvpMergeLayoutDeleteURL = "/wvp/mergeLayout/delete/:id"
+	WvpMergeLayoutApplyURL  = "/wvp/mergeLayout/apply"
+
+	WvpRecordPlanListURL   = "/wvp/recordPlan/list"
+	WvpRecordPlanAddURL    = "/wvp/recordPlan/add"
+	WvpRecordPlanUpdateURL = "/wvp/recordPlan/update/:id"
+	WvpRecordPlanDeleteURL = "/wvp/recordPlan/delete/:id"
+
+	WvpPushTargetListURL   = "/wvp/pushTarget/list"
+	WvpPushTargetAddURL    = "/wvp/pushTarget/add"
+	WvpPushTargetUpdateURL = "/wvp/pushTarget/update/:id"
+	WvpPushTargetDeleteURL = "/wvp/pushTarget/delete/:id"
+
 	WvpGetIotDeviceListURL       = "/wvp/iotdevice/list"
 	WvpIotDeviceListByAiModelURL = "/wvp/iotdevice/listByAiModel"
+	WvpIotDeviceDiagnosticsURL   = "/wvp/iotdevice/diagnostics"
 
 	WvpAiModelListURL         = "/wvp/aiModel/list"
 	WvpAiModelCountURL        = "/wvp/aiModel/count"
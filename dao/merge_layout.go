@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-sip/db/mysql"
+	"go-sip/model"
+)
+
+// 创建合屏布局, tiles以json格式存储
+func CreateMergeLayout(layout *model.MergeLayout) (int64, error) {
+	tiles, err := json.Marshal(layout.Tiles)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO gowvp_merge_layout (
+			name, width, height, fps, row_num, col_num, gap_v, gap_h, tiles, remarks
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := mysql.MysqlDB.Exec(query, layout.Name, layout.Width, layout.Height, layout.Fps,
+		layout.Row, layout.Col, layout.GapV, layout.GapH, string(tiles), layout.Remarks)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// 查询全部合屏布局
+func GetAllMergeLayouts() ([]model.MergeLayout, error) {
+	query := `SELECT id, name, width, height, IFNULL(fps, 0), row_num, col_num, 
+	IFNULL(gap_v, 0), IFNULL(gap_h, 0), tiles, IFNULL(remarks, '') FROM gowvp_merge_layout`
+	rows, err := mysql.MysqlDB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.MergeLayout
+	for rows.Next() {
+		l, err := scanMergeLayout(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *l)
+	}
+	return list, nil
+}
+
+// 根据 ID 查询合屏布局
+func GetMergeLayoutByID(id string) (*model.MergeLayout, error) {
+	query := `SELECT id, name, width, height, IFNULL(fps, 0), row_num, col_num, 
+	IFNULL(gap_v, 0), IFNULL(gap_h, 0), tiles, IFNULL(remarks, '') FROM gowvp_merge_layout WHERE id = ?`
+	l, err := scanMergeLayout(mysql.MysqlDB.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return l, nil
+}
+
+// 更新合屏布局
+func UpdateMergeLayout(layout *model.MergeLayout) error {
+	tiles, err := json.Marshal(layout.Tiles)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE gowvp_merge_layout 
+		SET name = ?, width = ?, height = ?, fps = ?, row_num = ?, col_num = ?, gap_v = ?, gap_h = ?, tiles = ?, remarks = ?
+		WHERE id = ?`
+	_, err = mysql.MysqlDB.Exec(query, layout.Name, layout.Width, layout.Height, layout.Fps,
+		layout.Row, layout.Col, layout.GapV, layout.GapH, string(tiles), layout.Remarks, layout.ID)
+	return err
+}
+
+// 删除合屏布局
+func DeleteMergeLayout(id string) error {
+	query := `DELETE FROM gowvp_merge_layout WHERE id = ?`
+	_, err := mysql.MysqlDB.Exec(query, id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMergeLayout(row rowScanner) (*model.MergeLayout, error) {
+	var l model.MergeLayout
+	var tiles string
+	if err := row.Scan(&l.ID, &l.Name, &l.Width, &l.Height, &l.Fps, &l.Row, &l.Col,
+		&l.GapV, &l.GapH, &tiles, &l.Remarks); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tiles), &l.Tiles); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
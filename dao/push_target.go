@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-sip/db/mysql"
+	"go-sip/model"
+)
+
+const pushTargetColumns = `id, IFNULL(name, ''), ipc_id, IFNULL(device_id, ''), protocol, dst_url, IFNULL(stream_type, 0),
+	IFNULL(windows, '[]'), auto_restart, enable, IFNULL(remarks, ''), version, IFNULL(update_time, 0)`
+
+// 创建推流目标, windows以json格式存储
+func CreatePushTarget(t *model.PushTarget) (int64, error) {
+	windows, err := json.Marshal(t.Windows)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO gowvp_push_target (
+			name, ipc_id, device_id, protocol, dst_url, stream_type, windows, auto_restart, enable, remarks, version, update_time
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := mysql.MysqlDB.Exec(query, t.Name, t.IpcId, t.DeviceId, t.Protocol, t.DstUrl, t.StreamType,
+		string(windows), t.AutoRestart, t.Enable, t.Remarks, t.Version, t.UpdateTime)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// 查询推流目标列表, ipcId为空时查询全部
+func GetPushTargets(ipcId string) ([]*model.PushTarget, error) {
+	query := `SELECT ` + pushTargetColumns + ` FROM gowvp_push_target WHERE 1=1`
+	args := []any{}
+	if ipcId != "" {
+		query += " AND ipc_id = ?"
+		args = append(args, ipcId)
+	}
+	rows, err := mysql.MysqlDB.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.PushTarget
+	for rows.Next() {
+		t, err := scanPushTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// 根据 ID 查询推流目标
+func GetPushTargetByID(id string) (*model.PushTarget, error) {
+	query := `SELECT ` + pushTargetColumns + ` FROM gowvp_push_target WHERE id = ?`
+	t, err := scanPushTarget(mysql.MysqlDB.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// 更新推流目标, 版本号加1
+func UpdatePushTarget(t *model.PushTarget) error {
+	windows, err := json.Marshal(t.Windows)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE gowvp_push_target
+		SET name = ?, device_id = ?, protocol = ?, dst_url = ?, stream_type = ?, windows = ?,
+			auto_restart = ?, enable = ?, remarks = ?, version = version + 1, update_time = ?
+		WHERE id = ?`
+	_, err = mysql.MysqlDB.Exec(query, t.Name, t.DeviceId, t.Protocol, t.DstUrl, t.StreamType, string(windows),
+		t.AutoRestart, t.Enable, t.Remarks, t.UpdateTime, t.ID)
+	return err
+}
+
+// 删除推流目标
+func DeletePushTarget(id string) error {
+	query := `DELETE FROM gowvp_push_target WHERE id = ?`
+	_, err := mysql.MysqlDB.Exec(query, id)
+	return err
+}
+
+func scanPushTarget(row rowScanner) (*model.PushTarget, error) {
+	var t model.PushTarget
+	var windows string
+	if err := row.Scan(&t.ID, &t.Name, &t.IpcId, &t.DeviceId, &t.Protocol, &t.DstUrl, &t.StreamType,
+		&windows, &t.AutoRestart, &t.Enable, &t.Remarks, &t.Version, &t.UpdateTime); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(windows), &t.Windows); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,123 @@
+package dao
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-sip/db/mysql"
+	"go-sip/model"
+)
+
+const recordPlanColumns = `id, ipc_id, IFNULL(device_id, ''), mode, IFNULL(stream_type, 0),
+	IFNULL(windows, '[]'), IFNULL(retention_days, 0), enable, IFNULL(remarks, '')`
+
+// 创建录像计划, windows以json格式存储
+func CreateRecordPlan(plan *model.RecordPlan) (int64, error) {
+	windows, err := json.Marshal(plan.Windows)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO gowvp_record_plan (
+			ipc_id, device_id, mode, stream_type, windows, retention_days, enable, remarks
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := mysql.MysqlDB.Exec(query, plan.IpcId, plan.DeviceId, plan.Mode, plan.StreamType,
+		string(windows), plan.RetentionDays, plan.Enable, plan.Remarks)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// 查询录像计划列表, ipcId为空时查询全部
+func GetRecordPlans(ipcId string) ([]*model.RecordPlan, error) {
+	query := `SELECT ` + recordPlanColumns + ` FROM gowvp_record_plan WHERE 1=1`
+	args := []any{}
+	if ipcId != "" {
+		query += " AND ipc_id = ?"
+		args = append(args, ipcId)
+	}
+	return queryRecordPlans(query, args...)
+}
+
+// 查询设备下启用的录像计划
+func GetEnabledRecordPlansByDevice(deviceId string) ([]*model.RecordPlan, error) {
+	query := `SELECT ` + recordPlanColumns + ` FROM gowvp_record_plan WHERE device_id = ? AND enable = 1`
+	return queryRecordPlans(query, deviceId)
+}
+
+// 根据 ID 查询录像计划
+func GetRecordPlanByID(id string) (*model.RecordPlan, error) {
+	query := `SELECT ` + recordPlanColumns + ` FROM gowvp_record_plan WHERE id = ?`
+	return queryRecordPlan(query, id)
+}
+
+// 根据 ipcId 查询录像计划
+func GetRecordPlanByIpcId(ipcId string) (*model.RecordPlan, error) {
+	query := `SELECT ` + recordPlanColumns + ` FROM gowvp_record_plan WHERE ipc_id = ? LIMIT 1`
+	return queryRecordPlan(query, ipcId)
+}
+
+// 更新录像计划
+func UpdateRecordPlan(plan *model.RecordPlan) error {
+	windows, err := json.Marshal(plan.Windows)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE gowvp_record_plan
+		SET device_id = ?, mode = ?, stream_type = ?, windows = ?, retention_days = ?, enable = ?, remarks = ?
+		WHERE id = ?`
+	_, err = mysql.MysqlDB.Exec(query, plan.DeviceId, plan.Mode, plan.StreamType, string(windows),
+		plan.RetentionDays, plan.Enable, plan.Remarks, plan.ID)
+	return err
+}
+
+// 删除录像计划
+func DeleteRecordPlan(id string) error {
+	query := `DELETE FROM gowvp_record_plan WHERE id = ?`
+	_, err := mysql.MysqlDB.Exec(query, id)
+	return err
+}
+
+func queryRecordPlans(query string, args ...any) ([]*model.RecordPlan, error) {
+	rows, err := mysql.MysqlDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.RecordPlan
+	for rows.Next() {
+		p, err := scanRecordPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+func queryRecordPlan(query string, args ...any) (*model.RecordPlan, error) {
+	p, err := scanRecordPlan(mysql.MysqlDB.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func scanRecordPlan(row rowScanner) (*model.RecordPlan, error) {
+	var p model.RecordPlan
+	var windows string
+	if err := row.Scan(&p.ID, &p.IpcId, &p.DeviceId, &p.Mode, &p.StreamType,
+		&windows, &p.RetentionDays, &p.Enable, &p.Remarks); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(windows), &p.Windows); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
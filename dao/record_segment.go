@@ -0,0 +1,115 @@
+package dao
+
+import (
+	"go-sip/db/mysql"
+	"go-sip/model"
+	"strings"
+)
+
+// 查询时间内全部录像片段时每批读取的数量
+const recordSegmentBatchSize = 5000
+
+const recordSegmentColumns = `id, ipc_id, device_id, stream_id, record_type, trigger_type, IFNULL(ai_class, ''),
+	start_time, end_time, duration, IFNULL(file_size, 0), oss_key, IFNULL(file_url, ''), IFNULL(checksum, ''),
+	IFNULL(init_size, 0)`
+
+// 写入录像片段, 同一个oss文件重复上报时忽略
+func CreateRecordSegment(s *model.RecordSegment) error {
+	query := `
+		INSERT IGNORE INTO gowvp_record_segment (
+			ipc_id, device_id, stream_id, record_type, trigger_type, ai_class,
+			start_time, end_time, duration, file_size, oss_key, file_url, checksum, init_size
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := mysql.MysqlDB.Exec(query, s.IpcId, s.DeviceId, s.StreamId, s.RecordType, s.TriggerType, s.AiClass,
+		s.StartTime, s.EndTime, s.Duration, s.FileSize, s.OssKey, s.FileUrl, s.Checksum, s.InitSize)
+	return err
+}
+
+// 录像片段查询条件, 查询与时间段有交集的片段
+func recordSegmentWhere(ipcId string, types []string, start, end int64) (string, []any) {
+	where := " WHERE ipc_id = ? AND start_time < ? AND end_time > ?"
+	args := []any{ipcId, end, start}
+	if len(types) > 0 {
+		where += " AND record_type IN (?" + strings.Repeat(", ?", len(types)-1) + ")"
+		for _, t := range types {
+			args = append(args, t)
+		}
+	}
+	return where, args
+}
+
+// 分页查询录像片段, types为空时查询全部类型
+func GetPageRecordSegments(ipcId string, types []string, start, end int64, pageNum, pageSize int) ([]*model.RecordSegment, error) {
+	if pageNum <= 0 {
+		pageNum = 1
+	}
+	if pageSize <= 0 || pageSize > 1000 {
+		pageSize = 20
+	}
+	where, args := recordSegmentWhere(ipcId, types, start, end)
+	query := `SELECT ` + recordSegmentColumns + ` FROM gowvp_record_segment` + where +
+		` ORDER BY start_time, id LIMIT ? OFFSET ?`
+	args = append(args, pageSize, (pageNum-1)*pageSize)
+	return queryRecordSegments(query, args...)
+}
+
+// 查询录像片段总数
+func GetRecordSegmentTotal(ipcId string, types []string, start, end int64) (int64, error) {
+	where, args := recordSegmentWhere(ipcId, types, start, end)
+	var total int64
+	err := mysql.MysqlDB.QueryRow(`SELECT COUNT(*) FROM gowvp_record_segment`+where, args...).Scan(&total)
+	return total, err
+}
+
+// 查询时间内的全部录像片段, 按开始时间排序, 按(start_time, id)分批读取
+func GetRecordSegments(ipcId string, types []string, start, end int64) ([]*model.RecordSegment, error) {
+	where, args := recordSegmentWhere(ipcId, types, start, end)
+	list := []*model.RecordSegment{}
+	for {
+		query := `SELECT ` + recordSegmentColumns + ` FROM gowvp_record_segment` + where
+		batch_args := append([]any{}, args...)
+		if len(list) > 0 {
+			last := list[len(list)-1]
+			query += " AND (start_time > ? OR (start_time = ? AND id > ?))"
+			batch_args = append(batch_args, last.StartTime, last.StartTime, last.ID)
+		}
+		query += " ORDER BY start_time, id LIMIT ?"
+		batch_args = append(batch_args, recordSegmentBatchSize)
+		batch, err := queryRecordSegments(query, batch_args...)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, batch...)
+		if len(batch) < recordSegmentBatchSize {
+			return list, nil
+		}
+	}
+}
+
+// 删除开始时间早于指定时间的录像片段
+func DeleteRecordSegmentsBefore(ipcId string, before int64) (int64, error) {
+	res, err := mysql.MysqlDB.Exec(`DELETE FROM gowvp_record_segment WHERE ipc_id = ? AND start_time < ?`, ipcId, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func queryRecordSegments(query string, args ...any) ([]*model.RecordSegment, error) {
+	rows, err := mysql.MysqlDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*model.RecordSegment{}
+	for rows.Next() {
+		var s model.RecordSegment
+		if err := rows.Scan(&s.ID, &s.IpcId, &s.DeviceId, &s.StreamId, &s.RecordType, &s.TriggerType, &s.AiClass,
+			&s.StartTime, &s.EndTime, &s.Duration, &s.FileSize, &s.OssKey, &s.FileUrl, &s.Checksum, &s.InitSize); err != nil {
+			return nil, err
+		}
+		list = append(list, &s)
+	}
+	return list, nil
+}
@@ -12,10 +12,10 @@ func CreateZlmNode(node *model.ZlmNodeInfo) (int64, error) {
 	query := `
 		INSERT INTO gowvp_zlm_node_info (
 			zlm_ip, zlm_port, zlm_domain, zlm_secret, 
-			zlm_node_region, region_code, zlm_node_status, remarks
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+			zlm_node_region, region_code, zlm_node_status, weight, remarks
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	res, err := mysql.MysqlDB.Exec(query, node.ZlmIP, node.ZlmPort, node.ZlmDomain, node.ZlmSecret,
-		node.ZlmNodeRegion, node.RegionCode, node.ZlmNodeStatus, node.Remarks)
+		node.ZlmNodeRegion, node.RegionCode, node.ZlmNodeStatus, node.Weight, node.Remarks)
 	if err != nil {
 		return 0, err
 	}
@@ -25,7 +25,7 @@ func CreateZlmNode(node *model.ZlmNodeInfo) (int64, error) {
 // 查询全部节点
 func GetAllZlmNodes() ([]model.ZlmNodeInfo, error) {
 	query := `SELECT id, zlm_ip, zlm_port, zlm_domain, zlm_secret, 
-	zlm_node_region, region_code, zlm_node_status, IFNULL(remarks, '') 
+	zlm_node_region, region_code, zlm_node_status, IFNULL(weight, 1), IFNULL(remarks, '') 
 	FROM gowvp_zlm_node_info`
 	rows, err := mysql.MysqlDB.Query(query)
 	if err != nil {
@@ -37,7 +37,7 @@ func GetAllZlmNodes() ([]model.ZlmNodeInfo, error) {
 	for rows.Next() {
 		var n model.ZlmNodeInfo
 		if err := rows.Scan(&n.ID, &n.ZlmIP, &n.ZlmPort, &n.ZlmDomain, &n.ZlmSecret,
-			&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Remarks); err != nil {
+			&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Weight, &n.Remarks); err != nil {
 			return nil, err
 		}
 		list = append(list, n)
@@ -48,12 +48,12 @@ func GetAllZlmNodes() ([]model.ZlmNodeInfo, error) {
 // 根据 ID 查询节点
 func GetZlmNodeByID(id string) (*model.ZlmNodeInfo, error) {
 	query := `SELECT id, zlm_ip, zlm_port, zlm_domain, zlm_secret, zlm_node_region, 
-	region_code, zlm_node_status, IFNULL(remarks, '') FROM gowvp_zlm_node_info WHERE id = ?`
+	region_code, zlm_node_status, IFNULL(weight, 1), IFNULL(remarks, '') FROM gowvp_zlm_node_info WHERE id = ?`
 	row := mysql.MysqlDB.QueryRow(query, id)
 
 	var n model.ZlmNodeInfo
 	if err := row.Scan(&n.ID, &n.ZlmIP, &n.ZlmPort, &n.ZlmDomain, &n.ZlmSecret,
-		&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Remarks); err != nil {
+		&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Weight, &n.Remarks); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -65,11 +65,11 @@ func GetZlmNodeByID(id string) (*model.ZlmNodeInfo, error) {
 // 根据zlm_ip+zlm_port 查询节点
 func GetZlmNodeByZlmIPAndZlmPort(zlmIP string, zlmPort int) (*model.ZlmNodeInfo, error) {
 	query := `SELECT id, zlm_ip, zlm_port, zlm_domain, zlm_secret, zlm_node_region, 
-	region_code, zlm_node_status, IFNULL(remarks, '') FROM gowvp_zlm_node_info WHERE zlm_ip = ? AND zlm_port = ?`
+	region_code, zlm_node_status, IFNULL(weight, 1), IFNULL(remarks, '') FROM gowvp_zlm_node_info WHERE zlm_ip = ? AND zlm_port = ?`
 	row := mysql.MysqlDB.QueryRow(query, zlmIP, zlmPort)
 	var n model.ZlmNodeInfo
 	if err := row.Scan(&n.ID, &n.ZlmIP, &n.ZlmPort, &n.ZlmDomain, &n.ZlmSecret,
-		&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Remarks); err != nil {
+		&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Weight, &n.Remarks); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -83,11 +83,18 @@ func UpdateZlmNode(node *model.ZlmNodeInfo) error {
 	query := `
 		UPDATE gowvp_zlm_node_info 
 		SET zlm_ip = ?, zlm_port = ?, zlm_domain = ?, zlm_secret = ?, 
-		    zlm_node_region = ?, region_code = ?, zlm_node_status = ?, remarks = ?
+		    zlm_node_region = ?, region_code = ?, zlm_node_status = ?, weight = ?, remarks = ?
 		WHERE id = ?
 	`
 	_, err := mysql.MysqlDB.Exec(query, node.ZlmIP, node.ZlmPort, node.ZlmDomain, node.ZlmSecret,
-		node.ZlmNodeRegion, node.RegionCode, node.ZlmNodeStatus, node.Remarks, node.ID)
+		node.ZlmNodeRegion, node.RegionCode, node.ZlmNodeStatus, node.Weight, node.Remarks, node.ID)
+	return err
+}
+
+// 更新节点状态
+func UpdateZlmNodeStatus(id int64, status string) error {
+	query := `UPDATE gowvp_zlm_node_info SET zlm_node_status = ? WHERE id = ?`
+	_, err := mysql.MysqlDB.Exec(query, status, id)
 	return err
 }
 
@@ -101,7 +108,7 @@ func DeleteZlmNode(id string) error {
 // 根据 region_code 查询节点列表
 func GetZlmNodesByCode(regionCode string) ([]model.ZlmNodeInfo, error) {
 	query := `SELECT id, zlm_ip, zlm_port, zlm_domain, zlm_secret, zlm_node_region, 
-	region_code, zlm_node_status, IFNULL(remarks, '') FROM gowvp_zlm_node_info 
+	region_code, zlm_node_status, IFNULL(weight, 1), IFNULL(remarks, '') FROM gowvp_zlm_node_info 
 	WHERE zlm_node_status = 'enable' and region_code = ?`
 	rows, err := mysql.MysqlDB.Query(query, regionCode)
 	if err != nil {
@@ -113,7 +120,7 @@ func GetZlmNodesByCode(regionCode string) ([]model.ZlmNodeInfo, error) {
 	for rows.Next() {
 		var n model.ZlmNodeInfo
 		if err := rows.Scan(&n.ID, &n.ZlmIP, &n.ZlmPort, &n.ZlmDomain, &n.ZlmSecret,
-			&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Remarks); err != nil {
+			&n.ZlmNodeRegion, &n.RegionCode, &n.ZlmNodeStatus, &n.Weight, &n.Remarks); err != nil {
 			return nil, err
 		}
 		list = append(list, n)
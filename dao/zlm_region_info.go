@@ -11,10 +11,10 @@ import (
 func CreateRegion(region *model.ZlmRegionInfo) (int64, error) {
 	query := `
 		INSERT INTO gowvp_zlm_region_info (region_code, region_name, 
-		relation_region_code, relation_region_name, remarks)
-		VALUES (?, ?, ?, ?, ?)`
+		relation_region_code, relation_region_name, select_strategy, remarks)
+		VALUES (?, ?, ?, ?, ?, ?)`
 	result, err := mysql.MysqlDB.Exec(query, region.RegionCode, region.RegionName, 
-		region.RelationRegionCode, region.RelationRegionName, region.Remarks)
+		region.RelationRegionCode, region.RelationRegionName, region.SelectStrategy, region.Remarks)
 	if err != nil {
 		return 0, err
 	}
@@ -24,7 +24,7 @@ func CreateRegion(region *model.ZlmRegionInfo) (int64, error) {
 // 查询记录（可选条件）
 func GetRegions() ([]model.ZlmRegionInfo, error) {
 	query := `SELECT id, region_code, region_name, relation_region_code, 
-	relation_region_name, IFNULL(remarks, '') FROM gowvp_zlm_region_info`
+	relation_region_name, IFNULL(select_strategy, ''), IFNULL(remarks, '') FROM gowvp_zlm_region_info`
 	rows, err := mysql.MysqlDB.Query(query)
 	if err != nil {
 		return nil, err
@@ -35,7 +35,7 @@ func GetRegions() ([]model.ZlmRegionInfo, error) {
 	for rows.Next() {
 		var r model.ZlmRegionInfo
 		if err := rows.Scan(&r.ID, &r.RegionCode, &r.RegionName, 
-			&r.RelationRegionCode, &r.RelationRegionName, &r.Remarks); err != nil {
+			&r.RelationRegionCode, &r.RelationRegionName, &r.SelectStrategy, &r.Remarks); err != nil {
 			return nil, err
 		}
 		list = append(list, r)
@@ -46,12 +46,12 @@ func GetRegions() ([]model.ZlmRegionInfo, error) {
 // 根据 ID 查询
 func GetRegionByID(id string) (*model.ZlmRegionInfo, error) {
 	query := `SELECT id, region_code, region_name, relation_region_code, 
-	relation_region_name, IFNULL(remarks, '') FROM gowvp_zlm_region_info WHERE id = ?`
+	relation_region_name, IFNULL(select_strategy, ''), IFNULL(remarks, '') FROM gowvp_zlm_region_info WHERE id = ?`
 	row := mysql.MysqlDB.QueryRow(query, id)
 
 	var r model.ZlmRegionInfo
 	if err := row.Scan(&r.ID, &r.RegionCode, &r.RegionName, 
-		&r.RelationRegionCode, &r.RelationRegionName, &r.Remarks); err != nil {
+		&r.RelationRegionCode, &r.RelationRegionName, &r.SelectStrategy, &r.Remarks); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -65,7 +65,7 @@ func GetRegionByCode(regionCode string) (*model.ZlmRegionInfo, error) {
 	query := `
 		SELECT id, region_code, region_name, 
 		       relation_region_code, relation_region_name, 
-		       IFNULL(remarks, '') 
+		       IFNULL(select_strategy, ''), IFNULL(remarks, '') 
 		FROM gowvp_zlm_region_info 
 		WHERE region_code = ?
 		LIMIT 1`
@@ -74,7 +74,7 @@ func GetRegionByCode(regionCode string) (*model.ZlmRegionInfo, error) {
 
 	var r model.ZlmRegionInfo
 	if err := row.Scan(&r.ID, &r.RegionCode, &r.RegionName, 
-		&r.RelationRegionCode, &r.RelationRegionName, &r.Remarks); err != nil {
+		&r.RelationRegionCode, &r.RelationRegionName, &r.SelectStrategy, &r.Remarks); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // 没有结果不是异常，返回 nil
 		}
@@ -88,7 +88,7 @@ func GetRegionByRelationRegionCode(relationRegionCode string) ([]model.ZlmRegion
 	query := `
 		SELECT id, region_code, region_name, 
 		       relation_region_code, relation_region_name, 
-		       IFNULL(remarks, '') 
+		       IFNULL(select_strategy, ''), IFNULL(remarks, '') 
 		FROM gowvp_zlm_region_info 
 		WHERE relation_region_code = ?
 		LIMIT 1`
@@ -103,7 +103,7 @@ func GetRegionByRelationRegionCode(relationRegionCode string) ([]model.ZlmRegion
 	for rows.Next() {
 		var n model.ZlmRegionInfo
 		if err := rows.Scan(&n.ID, &n.RegionCode, &n.RegionName, 
-			&n.RelationRegionCode, &n.RelationRegionName, &n.Remarks); err != nil {
+			&n.RelationRegionCode, &n.RelationRegionName, &n.SelectStrategy, &n.Remarks); err != nil {
 			return nil, err
 		}
 		list = append(list, n)
@@ -116,10 +116,10 @@ func GetRegionByRelationRegionCode(relationRegionCode string) ([]model.ZlmRegion
 func UpdateRegion(region *model.ZlmRegionInfo) error {
 	query := `
 		UPDATE gowvp_zlm_region_info 
-		SET region_code = ?, region_name = ?, relation_region_code = ?, relation_region_name = ?, remarks = ?
+		SET region_code = ?, region_name = ?, relation_region_code = ?, relation_region_name = ?, select_strategy = ?, remarks = ?
 		WHERE id = ?`
 	_, err := mysql.MysqlDB.Exec(query, region.RegionCode, region.RegionName, 
-		region.RelationRegionCode, region.RelationRegionName, region.Remarks, region.ID)
+		region.RelationRegionCode, region.RelationRegionName, region.SelectStrategy, region.Remarks, region.ID)
 	return err
 }
 
@@ -29,6 +29,13 @@ func SipClientInitAliOSS() {
 	bucketName := m.CMConfig.AliYunOss.BucketName
 	InitAliOSS(endpoint, accessKeyID, accessKeySecret, bucketName)
 }
+func ServerInitAliOSS() {
+	endpoint := m.SMConfig.AliYunOss.Endpoint
+	accessKeyID := m.SMConfig.AliYunOss.AccessKeyID
+	accessKeySecret := m.SMConfig.AliYunOss.AccessKeySecret
+	bucketName := m.SMConfig.AliYunOss.BucketName
+	InitAliOSS(endpoint, accessKeyID, accessKeySecret, bucketName)
+}
 func WvpInitAliOSS() {
 	endpoint := m.WVPConfig.AliYunOss.Endpoint
 	accessKeyID := m.WVPConfig.AliYunOss.AccessKeyID
@@ -83,6 +83,35 @@ func UploadFile(objectKey, filePath string) (string, error) {
 	return signedURL, nil
 }
 
+// 上传内存数据到OSS
+func UploadData(objectKey string, data []byte, options ...gooss.Option) error {
+	aliyunoss := GetAliOSS()
+	if aliyunoss == nil {
+		Logger.Error("阿里云OSS客服端获取失败")
+		return fmt.Errorf("阿里云OSS客服端获取失败")
+	}
+	if err := aliyunoss.Bucket.PutObject(objectKey, bytes.NewReader(data), options...); err != nil {
+		Logger.Error("上传文件失败", zap.String("objectKey", objectKey), zap.Error(err))
+		return fmt.Errorf("上传文件失败")
+	}
+	return nil
+}
+
+// 生成oss文件的临时访问地址
+func SignObjectURL(objectKey string, expire time.Duration) (string, error) {
+	aliyunoss := GetAliOSS()
+	if aliyunoss == nil {
+		Logger.Error("阿里云OSS客服端获取失败")
+		return "", fmt.Errorf("阿里云OSS客服端获取失败")
+	}
+	signedURL, err := aliyunoss.Bucket.SignURL(objectKey, gooss.HTTPGet, int64(expire.Seconds()))
+	if err != nil {
+		Logger.Error("生成签名 URL 失败", zap.String("objectKey", objectKey), zap.Error(err))
+		return "", fmt.Errorf("生成签名 URL 失败")
+	}
+	return signedURL, nil
+}
+
 // 下载oss文件
 func DownloadFile(objectKey, filePath string) (bool, error) {
 	aliyunoss := GetAliOSS()
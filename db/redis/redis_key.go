@@ -9,15 +9,30 @@ var (
 	// wvp zlm相关
 	WVP_ZLM_NODE_INFO            = "GOSIP_zlm_node"            // zlmDomain关联zlm节点信息
 	WVP_REGION_RELATION_ZLM_INFO = "GOSIP_region_relation_zlm" // 地区关联zlm信息
+	WVP_REGION_ZLM_STRATEGY      = "GOSIP_region_zlm_strategy" // 地区关联zlm节点选择策略
+	ZLM_NODE_KEEPALIVE_KEY       = "GOSIP_zlm_node_keepalive"  // zlm节点mediaServerId关联最近一次心跳时间
+	ZLM_NODE_PROBE_FAIL_KEY      = "GOSIP_zlm_node_probe_fail" // zlmDomain关联连续探测失败次数
+	ZLM_NODE_ALERT_CHANNEL       = "GOSIP_zlm_node_alert"      // zlm节点健康状态变化告警频道
+	ZLM_NODE_LOAD_KEY            = "GOSIP_zlm_node_load"       // zlmDomain关联最近一次采样的节点负载
 
 	// open api相关key
 	OPEN_API_KEY_NONCE = "GOSIP_open_api_nonce" // open api随机值
 
+	// 播放鉴权相关key
+	STREAM_AUTH_TENANT_KEY   = "GOSIP_stream_auth_tenant_key"      // 租户关联播放token签名密钥
+	STREAM_AUTH_REVOKED_KEY  = "GOSIP_stream_auth_revoked:%s"      // 已吊销的播放token id
+	STREAM_AUTH_RTSP_SESSION = "GOSIP_stream_auth_rtsp_session:%s" // rtsp鉴权通过的会话id关联流id
+
 	// sip相关key
 	SIP_SERVER_HOST            = "GOSIP_sip_server_host"            // 客户端sipId关联sip服务内网或公网地址
 	SIP_SERVER_PUBLIC_TCP_HOST = "GOSIP_sip_server_public_tcp_host" // sipId关联grpc的tcp地址
 	SIP_IPC                    = "GOSIP_%s_ipc"                     // sipId关联ipcId
 	SIP_SERVER_LAST_SELECT_KEY = "GOSIP_sip_server_last_select"     // 客户端上次选择的sipId
+	SIP_SERVER_CMD_ROUTE       = "GOSIP_sip_server_cmd_route:%s"    // sipId对应的跨实例命令转发频道
+	SIP_SERVER_CMD_REPLY       = "GOSIP_sip_server_cmd_reply:%s"    // sipId对应的跨实例命令结果回复频道
+	SIP_COMMAND_MSG_ID_SEQ     = "GOSIP_sip_command_msg_id_seq"     // 命令MsgID自增值
+	SIP_COMMAND_OPERATION      = "GOSIP_sip_command_operation:%s"   // 异步命令操作记录
+	SIP_BULK_JOB               = "GOSIP_sip_bulk_job:%s"            // 批量命令任务
 
 	// 设备与摄像头相关key
 	DEVICE_STATUS_KEY                  = "GOSIP_device_status:%s"                        // 设备在线离线状态
@@ -29,9 +44,27 @@ var (
 	DEVICE_IPC_VIDEO_PLAYBACK_LIST_KEY = "GOSIP_devcie_ipc_video_playback_list:%s:%s:%s" // deviceId+ipcId+模型类型为key的视频回放列表
 	DEVICE_ZLM_KEY                     = "GOSIP_device_zlm"                              // 设备id关联的zlmDomain
 	IPC_HEARTBEAT_INFO_KEY             = "GOSIP_ipc_heartbeat_info:%s"                   // ipc心跳信息
+	DEVICE_CAPABILITY_KEY              = "GOSIP_device_capability"                       // 设备id关联客户端能力集
+	IPC_SNAPSHOT_KEY                   = "GOSIP_ipc_snapshot"                            // ipcId关联最新截图信息
+	IPC_SNAPSHOT_LOCK_KEY              = "GOSIP_ipc_snapshot_lock:%s"                    // ipc截图锁, 避免同时截图
+	IPC_BROADCAST_ZLM_KEY              = "GOSIP_ipc_broadcast_zlm:%s"                    // 广播流id关联推流的mediaServerId
+	IPC_SNAPSHOT_REFRESH_LOCK_KEY      = "GOSIP_ipc_snapshot_refresh_lock"               // ipc封面刷新任务锁
+	STREAM_SESSION_KEY                 = "GOSIP_stream_session"                          // 流会话, field为mediaServerId|app|stream
+	STREAM_SESSION_SERIES_KEY          = "GOSIP_stream_session_series:%s"                // 流会话近期采样点
+	STREAM_SESSION_SAMPLE_LOCK_KEY     = "GOSIP_stream_session_sample_lock"              // 流会话采集任务锁
+	STREAM_PLAY_MODE_KEY               = "GOSIP_stream_play_mode"                        // 点播时的rtp传输模式, field与流会话一致
+	STREAM_RECOVERY_KEY                = "GOSIP_stream_recovery"                         // 中断待恢复的流, field与流会话一致
+	STREAM_RECOVERY_LOCK_KEY           = "GOSIP_stream_recovery_lock"                    // 流恢复任务锁
+	IPC_STREAM_ALERT_CHANNEL           = "GOSIP_ipc_stream_alert"                        // ipc流中断和恢复事件频道
 
 	// 合屏流对应ipcList
 	MERGE_VIDEO_STREAM_IPC_LIST_KEY = "GOSIP_merge_video_stream_ipc"
+	// 合屏布局id关联布局信息
+	MERGE_LAYOUT_KEY = "GOSIP_merge_layout"
+	// 推流目标id关联推流目标信息
+	PUSH_TARGET_KEY = "GOSIP_push_target"
+	// 推流目标id关联推流状态
+	PUSH_TARGET_STATUS_KEY = "GOSIP_push_target_status"
 
 	// ai模型类别自增值
 	AI_MODEL_CATEGORY_SEQ_KEY       = "GOSIP_ai_model_category_seq:%s"
@@ -44,5 +77,8 @@ var (
 	NOT_GB_IPC_DEVICE = "GOSIP_not_gb_ipc_device"
 
 	// 全局锁
-	IPC_STATUS_SYNC_LOCK_KEY = "GOSIP_ipc_status_sync_lock"
+	IPC_STATUS_SYNC_LOCK_KEY       = "GOSIP_ipc_status_sync_lock"
+	ZLM_NODE_HEALTH_CHECK_LOCK_KEY = "GOSIP_zlm_node_health_check_lock"
+	RECORD_RETENTION_LOCK_KEY      = "GOSIP_record_retention_lock"
+	PUSH_TARGET_RECONCILE_LOCK_KEY = "GOSIP_push_target_reconcile_lock"
 )
@@ -171,6 +171,51 @@ func SetNX(key string, val string, expiration time.Duration) (bool, error) {
 	return result, nil
 }
 
+// 续期锁, 锁的值与val一致时才续期, 返回是否仍持有锁
+var renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+func RenewLock(key, val string, expiration time.Duration) (bool, error) {
+	rdb := GetRedisClientByName("server_2")
+	result, err := renewLockScript.Run(ctx, rdb, []string{key}, val, expiration.Milliseconds()).Int()
+	if err != nil {
+		Logger.Error("redis 续期锁错误", zap.String("key", key), zap.Error(err))
+		return false, err
+	}
+	return result == 1, nil
+}
+
+// 自增
+func Incr_2(key string) (int64, error) {
+	rdb := GetRedisClientByName("server_2")
+	val, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		Logger.Error("redis incr 错误")
+		return 0, err
+	}
+	return val, nil
+}
+
+// 发布消息, 返回收到消息的订阅者数量
+func Publish_2(channel string, msg string) (int64, error) {
+	rdb := GetRedisClientByName("server_2")
+	n, err := rdb.Publish(ctx, channel, msg).Result()
+	if err != nil {
+		Logger.Error("redis publish 错误", zap.String("channel", channel))
+		return 0, err
+	}
+	return n, nil
+}
+
+// 订阅频道
+func Subscribe_2(channels ...string) *redis.PubSub {
+	rdb := GetRedisClientByName("server_2")
+	return rdb.Subscribe(ctx, channels...)
+}
+
 ///////////// server_2 /////////////
 
 ///////////// server_4 /////////////
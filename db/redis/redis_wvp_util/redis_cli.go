@@ -42,6 +42,15 @@ func HSet_2(hash_key string, key string, val string) error {
 	return err
 }
 
+func HSetIfNotExist_2(hash_key, key string, val string) error {
+	rdb := GetRedisClientByName("wvp_2")
+	err := rdb.HSetNX(ctx, hash_key, key, val).Err()
+	if err != nil {
+		Logger.Error("redis hset 错误")
+	}
+	return err
+}
+
 func HGet_2(hash_key, key string) (string, error) {
 	rdb := GetRedisClientByName("wvp_2")
 	val, err := rdb.HGet(ctx, hash_key, key).Result()
@@ -138,6 +147,32 @@ func SMembers_2(key string) ([]string, error) {
 	return members, nil
 }
 
+// SetNX
+func SetNX(key string, val string, expiration time.Duration) (bool, error) {
+	rdb := GetRedisClientByName("wvp_2")
+	cmd := rdb.SetNX(ctx, key, val, expiration)
+	err := cmd.Err()
+	if err == redis.Nil {
+		return false, err
+	}
+	if err != nil {
+		Logger.Error("redis SetNX 错误")
+		return false, err
+	}
+	return cmd.Val(), nil
+}
+
+// 发布消息, 返回收到消息的订阅者数量
+func Publish_2(channel string, msg string) (int64, error) {
+	rdb := GetRedisClientByName("wvp_2")
+	n, err := rdb.Publish(ctx, channel, msg).Result()
+	if err != nil {
+		Logger.Error("redis publish 错误", zap.String("channel", channel))
+		return 0, err
+	}
+	return n, nil
+}
+
 func ZAdd_2(key string, items []model.TimeItem, expiration time.Duration) error {
 	rdb := GetRedisClientByName("wvp_2")
 	zMembers := make([]redis.Z, 0, len(items))
@@ -233,6 +268,35 @@ func ScanPlaybackRecords(pattern, min, max string) ([]model.IpcPlaybackRecordDat
 	return result, nil
 }
 
+// ScanZRemRangeByScore 扫描匹配的 redis key，删除分数在区间内的成员
+func ScanZRemRangeByScore(pattern, min, max string) (int64, error) {
+	rdb := GetRedisClientByName("wvp_2")
+	var (
+		cursor  uint64
+		removed int64
+	)
+	for {
+		keys, nextCursor, err := rdb.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			Logger.Error("redis scan 错误", zap.Error(err))
+			return removed, err
+		}
+		for _, key := range keys {
+			n, err := rdb.ZRemRangeByScore(ctx, key, min, max).Result()
+			if err != nil {
+				Logger.Error("redis zremrangebyscore 错误", zap.String("key", key), zap.Error(err))
+				continue
+			}
+			removed += n
+		}
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	return removed, nil
+}
+
 func ZRangeByScoreItem_2(key string, min, max string) ([]model.TimeItem, error) {
 	rdb := GetRedisClientByName("wvp_2")
 
@@ -0,0 +1,77 @@
+package grpc_client
+
+import (
+	"go-sip/grpc_api"
+	"go-sip/m"
+	sipapi "go-sip/sip"
+	"go-sip/zlm_api"
+	"strings"
+)
+
+// 客户端版本，注册时上报
+const ClientVersion = "1.1.0"
+
+// 本客户端支持的命令
+var supportedMethods = []string{
+	m.Ping,
+	m.Play,
+	m.PlayBack,
+	m.StopPlay,
+	m.PausePlay,
+	m.ResumePlay,
+	m.SpeedPlay,
+	m.SeekPlay,
+	m.RecordList,
+	m.Broadcast,
+	m.PlayIPCAudio,
+	m.DeviceControl,
+	m.IpcPushStreamReset,
+	m.DeviceOTA,
+	m.PlayAudio,
+	m.PushAudio,
+	m.SetVolume,
+	m.CloseAudio,
+	m.IpcStreamReset,
+	m.CancelCommand,
+	m.ReconnectTo,
+	m.Diagnostics,
+	m.ConfigUpdate,
+	m.LogSubscribe,
+}
+
+// 各rk平台的npu核心数
+var platformNpuCores = map[string]int{
+	"rk3568": 1,
+	"rk3576": 2,
+	"rk3588": 3,
+}
+
+// 采集本客户端的能力集
+func GetCapability() *grpc_api.Client_Capability {
+	platform := GetPlatform()
+	capability := &grpc_api.Client_Capability{
+		Version:  ClientVersion,
+		Platform: platform,
+		Methods:  supportedMethods,
+		NpuCores: platformNpuCores[platform],
+	}
+
+	res := zlm_api.ZlmGetVersion(sipapi.Local_ZLM_Host, m.ClientZlmSecret())
+	if res.Code == 0 {
+		capability.ZlmVersion = strings.TrimSpace(res.Data.BranchName + " " + res.Data.CommitHash)
+	}
+
+	if audio := m.ClientAudio(); audio != nil {
+		for _, device := range []string{audio.InputDevice, audio.OutputDevice} {
+			if device != "" {
+				capability.AudioDevices = append(capability.AudioDevices, device)
+			}
+		}
+	}
+
+	// 只有rk平台带npu, 支持rknn模型
+	if capability.NpuCores > 0 {
+		capability.ModelFormats = []string{"rknn"}
+	}
+	return capability
+}
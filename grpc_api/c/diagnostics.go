@@ -0,0 +1,232 @@
+package grpc_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go-sip/grpc_api"
+	"go-sip/m"
+	sipapi "go-sip/sip"
+	"go-sip/utils"
+	"go-sip/zlm_api"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultDiagnosticsLogLines = 100
+	maxDiagnosticsLogLines     = 1000
+
+	rknpuLoadPath = "/sys/kernel/debug/rknpu/load"
+	thermalPath   = "/sys/class/thermal"
+)
+
+// 需要统计占用空间的目录
+var diagnosticsDirs = []string{"ota_firmware", "logs", "ai_model"}
+
+// 采集设备诊断快照, 单项失败记录到Errors中, 不影响其他项
+func CollectDiagnostics(logLines int) *grpc_api.Client_Diagnostics {
+	if logLines <= 0 {
+		logLines = defaultDiagnosticsLogLines
+	}
+	if logLines > maxDiagnosticsLogLines {
+		logLines = maxDiagnosticsLogLines
+	}
+
+	d := &grpc_api.Client_Diagnostics{
+		Time:         time.Now().Unix(),
+		Temperatures: map[string]float64{},
+		DiskUsage:    map[string]int64{},
+		Errors:       map[string]string{},
+	}
+
+	if usage, err := cpuUsage(); err != nil {
+		d.Errors["cpu"] = err.Error()
+	} else {
+		d.CpuUsage = usage
+	}
+
+	if total, available, err := memInfo(); err != nil {
+		d.Errors["mem"] = err.Error()
+	} else {
+		d.MemTotal = total
+		d.MemAvailable = available
+	}
+
+	// 需要挂载debugfs才能读取
+	if load, err := os.ReadFile(rknpuLoadPath); err != nil {
+		d.Errors["npu"] = err.Error()
+	} else {
+		d.NpuLoad = strings.TrimSpace(string(load))
+	}
+
+	if err := readTemperatures(d.Temperatures); err != nil {
+		d.Errors["temperature"] = err.Error()
+	}
+
+	rootPath, err := os.Getwd()
+	if err != nil {
+		d.Errors["disk"] = err.Error()
+	} else {
+		for _, dir := range diagnosticsDirs {
+			size, err := utils.DirSize(filepath.Join(rootPath, dir))
+			if err != nil {
+				d.Errors["disk_"+dir] = err.Error()
+				continue
+			}
+			d.DiskUsage[dir] = size
+		}
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(rootPath, &st); err != nil {
+			d.Errors["disk_free"] = err.Error()
+		} else {
+			d.DiskFree = uint64(st.Bavail) * uint64(st.Bsize)
+		}
+	}
+
+	mediaList, err := zlm_api.NewClient(sipapi.Local_ZLM_Host, m.ClientZlmSecret()).GetMediaList(context.Background(), zlm_api.ZlmGetMediaListReq{})
+	if err != nil {
+		d.Errors["zlm"] = err.Error()
+	}
+	for _, media := range mediaList {
+		d.MediaList = append(d.MediaList, grpc_api.Diagnostics_Media{App: media.App, Stream: media.Stream, Schema: media.Schema})
+	}
+
+	if lines, err := commandLines("pactl", "list", "modules", "short"); err != nil {
+		d.Errors["pulseaudio"] = err.Error()
+	} else {
+		d.PulseModules = lines
+	}
+
+	// pgrep没有匹配进程时返回错误, 视为空列表
+	d.FfmpegProcs, _ = commandLines("pgrep", "-af", "ffmpeg")
+
+	for _, device := range sipapi.ActiveDeviceList() {
+		d.SipDevices = append(d.SipDevices, grpc_api.Diagnostics_Device{
+			DeviceID: device.DeviceID,
+			Name:     device.Name,
+			Host:     device.Host,
+			Port:     device.Port,
+			ActiveAt: device.ActiveAt,
+		})
+	}
+
+	if lines, err := utils.TailFile(filepath.Join(rootPath, "logs", "sip.log"), logLines); err != nil {
+		d.Errors["log"] = err.Error()
+	} else {
+		d.LogLines = lines
+	}
+	return d
+}
+
+// 读取/proc/stat中cpu总时间和空闲时间
+func cpuTimes() (total, idle uint64, err error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, fmt.Errorf("/proc/stat格式错误")
+	}
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		total += v
+		// idle和iowait
+		if i == 3 || i == 4 {
+			idle += v
+		}
+	}
+	return total, idle, nil
+}
+
+// 间隔500ms两次采样计算cpu使用率
+func cpuUsage() (float64, error) {
+	total1, idle1, err := cpuTimes()
+	if err != nil {
+		return 0, err
+	}
+	time.Sleep(500 * time.Millisecond)
+	total2, idle2, err := cpuTimes()
+	if err != nil {
+		return 0, err
+	}
+	if total2 <= total1 {
+		return 0, nil
+	}
+	return float64((total2-total1)-(idle2-idle1)) * 100 / float64(total2-total1), nil
+}
+
+func memInfo() (total, available uint64, err error) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = v * 1024
+		case "MemAvailable:":
+			available = v * 1024
+		}
+	}
+	return total, available, nil
+}
+
+// 读取各温区温度, 以温区类型为key
+func readTemperatures(temps map[string]float64) error {
+	zones, err := filepath.Glob(filepath.Join(thermalPath, "thermal_zone*"))
+	if err != nil {
+		return err
+	}
+	for _, zone := range zones {
+		name, err := os.ReadFile(filepath.Join(zone, "type"))
+		if err != nil {
+			continue
+		}
+		temp, err := os.ReadFile(filepath.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(string(temp)), 64)
+		if err != nil {
+			continue
+		}
+		temps[strings.TrimSpace(string(name))] = v / 1000
+	}
+	return nil
+}
+
+// 执行命令并按行返回输出
+func commandLines(name string, args ...string) ([]string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -19,6 +19,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 
 	"time"
 
@@ -26,16 +27,28 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 )
 
 var SipCli *SipClient
 
+// 重启后等待升级子进程记录升级结果的最长时间
+const pendingOTAWaitSeconds = 60
+
 type SipClient struct {
 	clientID  string
 	conn      *grpc.ClientConn
 	stream    pb.SipService_StreamChannelClient
 	client    pb.SipServiceClient
 	AudioDone context.Context
+
+	outboxMu     sync.Mutex // 保护client及缓存队列的顺序发送
+	outboxSignal chan struct{}
+
+	running sync.Map // 执行中的命令, key: MsgID, value: context.CancelFunc
+
+	reconnectMu   sync.Mutex
+	reconnectAddr string // sip服务下线时指定的重连地址
 }
 
 func NewSipClient(clientID string) *SipClient {
@@ -48,6 +61,7 @@ func NewSipClient(clientID string) *SipClient {
 	sipapi.InviteFunc = client.IpcInviteReq
 	sipapi.NotifyAiEventFunc = client.AiEventReq
 	sipapi.NotifyOTAUpgradeFunc = client.OTAUpgradeReq
+	client.startOutbox()
 	return client
 }
 
@@ -70,7 +84,9 @@ func (c *SipClient) Connect(addr string) error {
 
 	client := pb.NewSipServiceClient(conn)
 
-	stream, err := client.StreamChannel(context.Background())
+	// 能力集随连接上报, 服务端缓存在连接上
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpc_api.CapabilityMetadataKey, string(utils.JSONEncode(GetCapability())))
+	stream, err := client.StreamChannel(ctx)
 	if err != nil {
 		conn.Close()
 		return err
@@ -81,7 +97,7 @@ func (c *SipClient) Connect(addr string) error {
 		Content: &pb.ClientMessage_Register{
 			Register: &pb.ClientRegister{
 				ClientId:   c.clientID,
-				Version:    "1.0.0",
+				Version:    ClientVersion,
 				DeviceType: GetPlatform(),
 			},
 		},
@@ -90,12 +106,72 @@ func (c *SipClient) Connect(addr string) error {
 		return err
 	}
 
+	c.outboxMu.Lock()
 	c.conn = conn
 	c.stream = stream
 	c.client = client
+	c.outboxMu.Unlock()
+	// 连接恢复，补发断线期间缓存的事件
+	c.signalOutbox()
 	return nil
 }
 
+// 取出sip服务下线时指定的重连地址, 只使用一次
+func (c *SipClient) TakeReconnectAddr() string {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	addr := c.reconnectAddr
+	c.reconnectAddr = ""
+	return addr
+}
+
+// sip服务下线时迁移到指定地址, 等结果发送后再断开当前连接
+func (c *SipClient) reconnectTo(addr string) {
+	c.reconnectMu.Lock()
+	c.reconnectAddr = addr
+	c.reconnectMu.Unlock()
+	go func() {
+		time.Sleep(time.Second)
+		c.outboxMu.Lock()
+		conn := c.conn
+		c.outboxMu.Unlock()
+		Logger.Info("sip服务下线, 断开当前连接并重连", zap.String("addr", addr))
+		conn.Close()
+	}()
+}
+
+// 设备升级会重启客户端, 重启后上报升级结果
+func reportPendingOTA(send func(msg *pb.ClientMessage)) {
+	pending := capi.LoadPendingOTA()
+	if pending == nil {
+		return
+	}
+	// 升级子进程可能仍在执行, 等待其记录升级结果
+	for i := 0; !pending.Done && i < pendingOTAWaitSeconds; i++ {
+		time.Sleep(time.Second)
+		if pending = capi.LoadPendingOTA(); pending == nil {
+			return
+		}
+	}
+	res := &pb.CommandResult{
+		MsgID:   pending.MsgID,
+		Success: true,
+		Payload: []byte("执行成功"),
+	}
+	if !pending.Done {
+		res.Success = false
+		res.Payload = []byte("执行失败: 升级中断")
+	} else if pending.Error != "" {
+		res.Success = false
+		res.Payload = []byte(fmt.Sprintf("执行失败: %s", pending.Error))
+	}
+	Logger.Info("上报重启前的设备升级结果", zap.Int64("MsgID", pending.MsgID), zap.String("firmwareVersion", pending.FirmwareVersion), zap.Bool("success", res.Success))
+	send(&pb.ClientMessage{
+		Content: &pb.ClientMessage_Result{Result: res},
+	})
+	capi.ClearPendingOTA()
+}
+
 func GetPlatform() string {
 
 	if model, err := os.ReadFile("/proc/device-tree/model"); err == nil {
@@ -113,23 +189,31 @@ func GetPlatform() string {
 
 func (c *SipClient) IpcEventReq(notifyData *sipapi.Notify, msg_type string) (*pb.IpcEventAck, error) {
 
-	if c.client != nil {
-		return c.client.IpcEventReq(context.Background(), &pb.IpcEventRequest{
-			ClientId:     c.clientID,
-			IpcId:        notifyData.IpcId,
-			IpcIp:        notifyData.IpcIP,
-			Event:        msg_type,
-			ChannelId:    notifyData.ChannelId,
-			IpcName:      notifyData.IpcName,
-			Status:       notifyData.Status,
-			ActiveTime:   notifyData.ActiveTime,
-			Manufacturer: notifyData.Manufacturer,
-			Transport:    notifyData.Transport,
-			Streamtype:   notifyData.StreamType,
-		})
+	req := &pb.IpcEventRequest{
+		ClientId:     c.clientID,
+		IpcId:        notifyData.IpcId,
+		IpcIp:        notifyData.IpcIP,
+		Event:        msg_type,
+		ChannelId:    notifyData.ChannelId,
+		IpcName:      notifyData.IpcName,
+		Status:       notifyData.Status,
+		ActiveTime:   notifyData.ActiveTime,
+		Manufacturer: notifyData.Manufacturer,
+		Transport:    notifyData.Transport,
+		Streamtype:   notifyData.StreamType,
 	}
-
-	return nil, fmt.Errorf("client已断开")
+	var ack *pb.IpcEventAck
+	sent, err := c.sendOrEnqueue(outboxKindIpcEvent, req, func(ctx context.Context) (err error) {
+		ack, err = c.client.IpcEventReq(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !sent {
+		return &pb.IpcEventAck{Success: false, Msg: "事件已缓存，待连接恢复后发送"}, nil
+	}
+	return ack, nil
 
 }
 
@@ -148,37 +232,53 @@ func (c *SipClient) IpcInviteReq(ipc_id string) (*pb.IpcInviteAck, error) {
 
 func (c *SipClient) AiEventReq(device_id, rk_platform, stream_id string, event_id int64, class_name string, max_score float64, count int64) (*pb.AIEventAck, error) {
 	Logger.Debug("==== AIEventReq ====", zap.Any("streamId", stream_id), zap.Any("class_name", class_name), zap.Any("max_score", max_score), zap.Any("count", count))
-	if c.client != nil {
-		return c.client.AiEventReq(context.Background(), &pb.AIEventRequest{
-			DeviceId:   device_id,
-			RkPlatform: rk_platform,
-			StreamId:   stream_id,
-			ClientId:   c.clientID,
-			EventId:    event_id,
-			ClassName:  class_name,
-			MaxScore:   max_score,
-			Count:      count,
-		})
+	req := &pb.AIEventRequest{
+		DeviceId:   device_id,
+		RkPlatform: rk_platform,
+		StreamId:   stream_id,
+		ClientId:   c.clientID,
+		EventId:    event_id,
+		ClassName:  class_name,
+		MaxScore:   max_score,
+		Count:      count,
 	}
-
-	return nil, fmt.Errorf("client已断开")
+	var ack *pb.AIEventAck
+	sent, err := c.sendOrEnqueue(outboxKindAiEvent, req, func(ctx context.Context) (err error) {
+		ack, err = c.client.AiEventReq(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !sent {
+		return &pb.AIEventAck{Success: false, Msg: "事件已缓存，待连接恢复后发送"}, nil
+	}
+	return ack, nil
 }
 
 func (c *SipClient) OTAUpgradeReq(device_id, firmware_id, firmware_version, upgrade_complete, upgrade_progress, upgrade_error string) (*pb.OTAUpgradeAck, error) {
 	Logger.Debug("==== OTAUpgradeReq ====", zap.Any("device_id", device_id), zap.Any("firmware_id", firmware_id), zap.Any("firmware_version", firmware_version),
 		zap.Any("upgrade_progress", upgrade_progress), zap.Any("upgrade_error", upgrade_error))
-	if c.client != nil {
-		return c.client.OTAUpgradeReq(context.Background(), &pb.OTAUpgradeRequest{
-			DeviceId:        device_id,
-			FirmwareId:      firmware_id,
-			FirmwareVersion: firmware_version,
-			UpgradeComplete: upgrade_complete,
-			UpgradeProgress: upgrade_progress,
-			UpgradeError:    upgrade_error,
-		})
+	req := &pb.OTAUpgradeRequest{
+		DeviceId:        device_id,
+		FirmwareId:      firmware_id,
+		FirmwareVersion: firmware_version,
+		UpgradeComplete: upgrade_complete,
+		UpgradeProgress: upgrade_progress,
+		UpgradeError:    upgrade_error,
 	}
-
-	return nil, fmt.Errorf("client已断开")
+	var ack *pb.OTAUpgradeAck
+	sent, err := c.sendOrEnqueue(outboxKindOTAUpgrade, req, func(ctx context.Context) (err error) {
+		ack, err = c.client.OTAUpgradeReq(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !sent {
+		return &pb.OTAUpgradeAck{Success: false, Msg: "事件已缓存，待连接恢复后发送"}, nil
+	}
+	return ack, nil
 }
 
 func (c *SipClient) Run() {
@@ -186,23 +286,41 @@ func (c *SipClient) Run() {
 
 	// 用一个 channel 缓冲发送结果，避免多个 goroutine 并发写 stream
 	resultChan := make(chan *pb.ClientMessage, 100)
+	// 连接断开后关闭, 执行中的命令不再发送结果
+	done := make(chan struct{})
+	send := func(msg *pb.ClientMessage) {
+		select {
+		case resultChan <- msg:
+		case <-done:
+		}
+	}
 
 	// 独立 goroutine 负责串行发送
 	go func() {
-		for msg := range resultChan {
-			if err := c.stream.Send(msg); err != nil {
-				Logger.Error("发送结果失败", zap.Error(err))
+		for {
+			select {
+			case msg := <-resultChan:
+				if err := c.stream.Send(msg); err != nil {
+					Logger.Error("发送结果失败", zap.Error(err))
+					return
+				}
+			case <-done:
 				return
 			}
 		}
 	}()
+	go reportPendingOTA(send)
 
 	// 命令处理循环
 	for {
 		cmd, err := c.stream.Recv()
 		if err != nil {
 			Logger.Error("连接断开: ", zap.Error(err))
-			close(resultChan) // 关闭发送协程
+			close(done) // 关闭发送协程
+			// 断线期间的上报事件写入缓存队列
+			c.outboxMu.Lock()
+			c.client = nil
+			c.outboxMu.Unlock()
 			return
 		}
 		if cmd == nil {
@@ -213,12 +331,41 @@ func (c *SipClient) Run() {
 		go func(cmd *pb.ServerCommand) {
 			// 处理服务端命令
 			Logger.Info("收到服务端命令", zap.Any("method", cmd.Method), zap.Any("MsgID", cmd.MsgID))
-			result := c.executeCommand(cmd)
+			ctx, cancel := context.WithCancel(context.Background())
+			c.running.Store(cmd.MsgID, cancel)
+			defer func() {
+				c.running.Delete(cmd.MsgID)
+				cancel()
+			}()
+			// 连接断开后日志订阅没有接收方, 直接结束
+			if cmd.Method == m.LogSubscribe {
+				go func() {
+					select {
+					case <-done:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+			}
+
+			// 上报执行进度, MsgID取反以区分最终结果
+			progress := func(percent int, stage, msg string) {
+				send(&pb.ClientMessage{
+					Content: &pb.ClientMessage_Result{
+						Result: &pb.CommandResult{
+							MsgID:   grpc_api.ProgressMsgID(cmd.MsgID),
+							Success: true,
+							Payload: utils.JSONEncode(&grpc_api.Command_Progress{Percent: percent, Stage: stage, Msg: msg}),
+						},
+					},
+				})
+			}
+			result := c.executeCommand(ctx, cmd, progress)
 
 			if result != nil {
 
 				// 返回执行结果
-				resultChan <- &pb.ClientMessage{
+				send(&pb.ClientMessage{
 					Content: &pb.ClientMessage_Result{
 						Result: &pb.CommandResult{
 							MsgID:   cmd.MsgID,
@@ -226,7 +373,7 @@ func (c *SipClient) Run() {
 							Payload: result.Msg,
 						},
 					},
-				}
+				})
 			}
 
 		}(cmd)
@@ -238,7 +385,7 @@ type CommandResult struct {
 	Msg     []byte
 }
 
-func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
+func (c *SipClient) executeCommand(ctx context.Context, cmd *pb.ServerCommand, progress func(percent int, stage, msg string)) *CommandResult {
 
 	rsp := &CommandResult{}
 	rsp.Success = true
@@ -248,6 +395,92 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 
 		return rsp
 
+	case m.CancelCommand:
+		d := &grpc_api.Command_Cancel_Req{}
+		err := utils.JSONDecode(cmd.Payload, d)
+		if err != nil {
+			Logger.Error("Unmarshal failed ", zap.Error(err))
+			rsp.Success = false
+			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
+			return rsp
+		}
+		cancel, ok := c.running.Load(d.MsgID)
+		if !ok {
+			rsp.Success = false
+			rsp.Msg = []byte("命令不存在或已结束")
+			return rsp
+		}
+		Logger.Info("取消执行中的命令", zap.Int64("MsgID", d.MsgID))
+		cancel.(context.CancelFunc)()
+		return rsp
+
+	case m.Diagnostics:
+		d := &grpc_api.Diagnostics_Req{}
+		err := utils.JSONDecode(cmd.Payload, d)
+		if err != nil {
+			Logger.Error("Unmarshal failed ", zap.Error(err))
+			rsp.Success = false
+			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
+			return rsp
+		}
+		rsp.Msg = utils.JSONEncode(CollectDiagnostics(d.LogLines))
+		return rsp
+
+	case m.ConfigUpdate:
+		d := &grpc_api.Config_Update_Req{}
+		err := utils.JSONDecode(cmd.Payload, d)
+		if err != nil {
+			Logger.Error("Unmarshal failed ", zap.Error(err))
+			rsp.Success = false
+			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
+			return rsp
+		}
+		result, err := m.UpdateClientConfig(d.Values)
+		if err != nil {
+			Logger.Error("远程修改配置失败", zap.Any("values", d.Values), zap.Error(err))
+			rsp.Success = false
+			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
+			return rsp
+		}
+		Logger.Info("远程修改配置成功", zap.Any("result", result))
+		// 音频设备变更后重新加载回声消除模块
+		for _, key := range result.Applied {
+			if strings.HasPrefix(key, "audio.") {
+				if err := capi.EnsureEchoCancelModuleLoaded(); err != nil {
+					Logger.Error("重新加载回声消除模块失败", zap.Error(err))
+					rsp.Success = false
+					rsp.Msg = []byte(fmt.Sprintf("配置已保存, 音频设备切换失败: %v", err))
+					return rsp
+				}
+				break
+			}
+		}
+		rsp.Msg = utils.JSONEncode(result)
+		return rsp
+
+	case m.LogSubscribe:
+		d := &grpc_api.Log_Subscribe_Req{}
+		err := utils.JSONDecode(cmd.Payload, d)
+		if err != nil {
+			Logger.Error("Unmarshal failed ", zap.Error(err))
+			rsp.Success = false
+			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
+			return rsp
+		}
+		return subscribeLog(ctx, d, progress)
+
+	case m.ReconnectTo:
+		d := &grpc_api.Reconnect_To_Req{}
+		err := utils.JSONDecode(cmd.Payload, d)
+		if err != nil || d.Addr == "" {
+			Logger.Error("Unmarshal failed ", zap.Error(err))
+			rsp.Success = false
+			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
+			return rsp
+		}
+		c.reconnectTo(d.Addr)
+		return rsp
+
 	case m.Play:
 		d := &grpc_api.Sip_Play_Req{}
 		err := utils.JSONDecode(cmd.Payload, d)
@@ -290,7 +523,7 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 			if stream_arr[1] == "0" {
 				resolution = 0
 				destZlmHost = sipapi.Local_ZLM_Host
-				destZlmSecret = m.CMConfig.ZlmSecret
+				destZlmSecret = m.ClientZlmSecret()
 				destZlmIp = m.CMConfig.ZlmInnerIp
 			} else if stream_arr[1] == "1" {
 				resolution = 1
@@ -333,7 +566,14 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 		defer ticker.Stop()
 
 		count := 0
-		for range ticker.C {
+		for {
+			select {
+			case <-ctx.Done():
+				rsp.Success = false
+				rsp.Msg = []byte(grpc_api.CommandCanceledMsg)
+				return rsp
+			case <-ticker.C:
+			}
 			count++
 			if count >= 15 {
 				break
@@ -348,7 +588,7 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 				}
 			} else {
 				// 如果是国标标清流或非国标流，则先查询本地zlm是否存在该流，不存在则直接break
-				resp2 := zlm_api.ZlmGetMediaList(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, zlmGetMediaListReq)
+				resp2 := zlm_api.ZlmGetMediaList(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), zlmGetMediaListReq)
 				if resp2.Code != 0 || len(resp2.Data) == 0 {
 					break
 				}
@@ -381,7 +621,7 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 					req.IsUdp = "1"
 				}
 				Logger.Info("ZlmStartSendRtp req", zap.Any("app", zlmGetMediaListReq.App), zap.Any("req", req))
-				_resp := zlm_api.ZlmStartSendRtp(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, req)
+				_resp := zlm_api.ZlmStartSendRtp(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), req)
 				if _resp.Code == 0 && _resp.LocalPort > 0 {
 					Logger.Info("实时流点播发送RTP成功", zap.Any("stream id", d.ChannelID), zap.Any("local_port", _resp.LocalPort))
 					rsp.Success = true
@@ -424,7 +664,7 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 			if stream_arr[1] == "1" {
 				req.Ssrc = "2"
 			}
-			_resp := zlm_api.ZlmStopSendRtp(sipapi.Local_ZLM_Host, m.CMConfig.ZlmSecret, req)
+			_resp := zlm_api.ZlmStopSendRtp(sipapi.Local_ZLM_Host, m.ClientZlmSecret(), req)
 			if _resp.Code == 0 {
 				rsp.Success = true
 				rsp.Msg = []byte("停止RTP推流成功")
@@ -558,6 +798,7 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 				rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
 				return rsp
 			}
+			progress(0, "查询录像列表", "")
 			res, err := sipapi.SipRecordList(channel, d.StartTime, d.EndTime)
 			if err != nil {
 				rsp.Success = false
@@ -766,7 +1007,19 @@ func (c *SipClient) executeCommand(cmd *pb.ServerCommand) *CommandResult {
 			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
 			return rsp
 		}
-		go capi.DeviceOTA(d.DeviceID, d.FirmwareID, d.FirmwareDownloadURL, d.FirmwareMD5, d.FirmwareVersion)
+		err = capi.DeviceOTA(ctx, cmd.MsgID, d.DeviceID, d.FirmwareID, d.FirmwareDownloadURL, d.FirmwareMD5, d.FirmwareVersion, func(percent int, stage string) {
+			progress(percent, stage, "")
+		})
+		if ctx.Err() != nil {
+			rsp.Success = false
+			rsp.Msg = []byte(grpc_api.CommandCanceledMsg)
+			return rsp
+		}
+		if err != nil {
+			rsp.Success = false
+			rsp.Msg = []byte(fmt.Sprintf("执行失败: %v", err))
+			return rsp
+		}
 	}
 
 	return rsp
@@ -0,0 +1,54 @@
+package grpc_client
+
+import (
+	"context"
+	"fmt"
+	"go-sip/grpc_api"
+	"go-sip/logger"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultLogSubscribeDuration = 5 * time.Minute
+	maxLogSubscribeDuration     = 30 * time.Minute
+	logSubscribeBuffer          = 1000
+)
+
+// 把匹配的日志通过进度消息推送给服务端, 到达订阅时长或被取消时结束
+func subscribeLog(ctx context.Context, d *grpc_api.Log_Subscribe_Req, progress func(percent int, stage, msg string)) *CommandResult {
+	filter := logger.LogFilter{
+		Level:    zapcore.DebugLevel,
+		Contains: d.Contains,
+		Fields:   d.Fields,
+	}
+	if d.Level != "" {
+		if err := filter.Level.UnmarshalText([]byte(d.Level)); err != nil {
+			return &CommandResult{Success: false, Msg: []byte(fmt.Sprintf("日志级别错误: %s", d.Level))}
+		}
+	}
+	duration := time.Duration(d.Duration) * time.Second
+	if duration <= 0 {
+		duration = defaultLogSubscribeDuration
+	}
+	if duration > maxLogSubscribeDuration {
+		duration = maxLogSubscribeDuration
+	}
+
+	lines, cancel := logger.SubscribeLog(filter, logSubscribeBuffer)
+	defer cancel()
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	for {
+		select {
+		case line := <-lines:
+			progress(0, "log", line)
+		case <-timer.C:
+			return &CommandResult{Success: true, Msg: []byte("日志订阅已到期")}
+		case <-ctx.Done():
+			return &CommandResult{Success: true, Msg: []byte("日志订阅已取消")}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package grpc_client
+
+import (
+	"context"
+	"errors"
+	db "go-sip/db/sqlite"
+	. "go-sip/logger"
+	"go-sip/m"
+	pb "go-sip/signaling"
+	"go-sip/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	outboxKindIpcEvent   = "ipc_event"
+	outboxKindAiEvent    = "ai_event"
+	outboxKindOTAUpgrade = "ota_upgrade"
+
+	defaultOutboxMaxSize    = 10000
+	defaultOutboxMaxAgeHour = 24
+
+	outboxSendTimeout   = 10 * time.Second
+	outboxCheckInterval = 30 * time.Second
+	outboxMinBackoff    = time.Second
+	outboxMaxBackoff    = time.Minute
+	outboxMaxAttempts   = 20 // 连接正常时单个事件最多重试次数，超过后丢弃，避免阻塞后续事件
+)
+
+var (
+	errOutboxDisconnected = errors.New("client已断开")
+	errOutboxBadPayload   = errors.New("缓存事件内容错误")
+)
+
+// 断线期间缓存的上报事件，按id顺序补发
+type OutboxEvent struct {
+	ID        uint   `json:"id" gorm:"primary_key"`
+	Kind      string `json:"kind" gorm:"column:kind"`
+	Payload   string `json:"payload" gorm:"column:payload"`
+	Attempts  int    `json:"attempts" gorm:"column:attempts"`
+	CreatedAt int64  `json:"addtime" gorm:"column:addtime"`
+}
+
+func outboxMaxSize() int {
+	if m.CMConfig.Outbox != nil && m.CMConfig.Outbox.MaxSize > 0 {
+		return m.CMConfig.Outbox.MaxSize
+	}
+	return defaultOutboxMaxSize
+}
+
+func outboxMaxAge() time.Duration {
+	if m.CMConfig.Outbox != nil && m.CMConfig.Outbox.MaxAgeHour > 0 {
+		return time.Duration(m.CMConfig.Outbox.MaxAgeHour) * time.Hour
+	}
+	return defaultOutboxMaxAgeHour * time.Hour
+}
+
+// 初始化缓存队列表并启动补发协程
+func (c *SipClient) startOutbox() {
+	db.DBClient.AutoMigrate(new(OutboxEvent))
+	c.outboxSignal = make(chan struct{}, 1)
+	go c.outboxLoop()
+}
+
+// 唤醒补发协程
+func (c *SipClient) signalOutbox() {
+	select {
+	case c.outboxSignal <- struct{}{}:
+	default:
+	}
+}
+
+// 连接正常且队列为空时直接发送，否则写入缓存队列等待连接恢复后按序补发
+func (c *SipClient) sendOrEnqueue(kind string, req any, send func(ctx context.Context) error) (bool, error) {
+	c.outboxMu.Lock()
+	defer c.outboxMu.Unlock()
+
+	if c.client != nil && outboxCount() == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), outboxSendTimeout)
+		err := send(ctx)
+		cancel()
+		if err == nil {
+			return true, nil
+		}
+		Logger.Warn("事件发送失败，写入缓存队列", zap.String("kind", kind), zap.Error(err))
+	}
+
+	if err := enqueueOutbox(kind, req); err != nil {
+		Logger.Error("事件写入缓存队列失败", zap.String("kind", kind), zap.Error(err))
+		return false, err
+	}
+	c.signalOutbox()
+	return false, nil
+}
+
+func outboxCount() int {
+	total := 0
+	db.DBClient.Model(&OutboxEvent{}).Count(&total)
+	return total
+}
+
+// 写入缓存队列，超出容量时丢弃最早的事件
+func enqueueOutbox(kind string, req any) error {
+	err := db.Create(db.DBClient, &OutboxEvent{
+		Kind:    kind,
+		Payload: string(utils.JSONEncode(req)),
+	})
+	if err != nil {
+		return err
+	}
+	if excess := outboxCount() - outboxMaxSize(); excess > 0 {
+		var ids []uint
+		db.DBClient.Model(&OutboxEvent{}).Order("id asc").Limit(excess).Pluck("id", &ids)
+		if len(ids) > 0 {
+			db.DBClient.Where("id in (?)", ids).Delete(&OutboxEvent{})
+			Logger.Warn("缓存队列已满，丢弃最早的事件", zap.Int("count", len(ids)))
+		}
+	}
+	return nil
+}
+
+// 清理超过保留时间的事件
+func purgeExpiredOutbox() {
+	expire := time.Now().Add(-outboxMaxAge()).Unix()
+	rows := db.DBClient.Where("addtime < ?", expire).Delete(&OutboxEvent{}).RowsAffected
+	if rows > 0 {
+		Logger.Warn("缓存队列事件过期，已丢弃", zap.Int64("count", rows))
+	}
+}
+
+// 补发协程：收到唤醒信号或定时检查时按序补发，失败时指数退避
+func (c *SipClient) outboxLoop() {
+	ticker := time.NewTicker(outboxCheckInterval)
+	defer ticker.Stop()
+
+	backoff := outboxMinBackoff
+	for {
+		select {
+		case <-c.outboxSignal:
+		case <-ticker.C:
+		}
+
+		for {
+			err := c.drainOutbox()
+			if err == nil || errors.Is(err, errOutboxDisconnected) {
+				backoff = outboxMinBackoff
+				break
+			}
+			Logger.Warn("缓存事件补发失败，稍后重试", zap.Duration("backoff", backoff), zap.Error(err))
+			select {
+			case <-c.outboxSignal:
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > outboxMaxBackoff {
+				backoff = outboxMaxBackoff
+			}
+		}
+	}
+}
+
+// 按序补发缓存队列中的全部事件
+func (c *SipClient) drainOutbox() error {
+	purgeExpiredOutbox()
+	for {
+		empty, err := c.sendOldestOutbox()
+		if err != nil || empty {
+			return err
+		}
+	}
+}
+
+// 发送最早的一条缓存事件，收到服务端确认后删除
+func (c *SipClient) sendOldestOutbox() (bool, error) {
+	c.outboxMu.Lock()
+	defer c.outboxMu.Unlock()
+
+	if c.client == nil {
+		return false, errOutboxDisconnected
+	}
+	ev := &OutboxEvent{}
+	err := db.DBClient.Order("id asc").First(ev).Error
+	if db.RecordNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = c.sendOutboxEvent(ev)
+	if err == nil || errors.Is(err, errOutboxBadPayload) {
+		if err != nil {
+			Logger.Error("缓存事件内容错误，已丢弃", zap.Uint("id", ev.ID), zap.String("kind", ev.Kind))
+		}
+		db.DBClient.Delete(ev)
+		return false, nil
+	}
+
+	ev.Attempts++
+	if ev.Attempts >= outboxMaxAttempts {
+		Logger.Error("缓存事件重试次数超限，已丢弃", zap.Uint("id", ev.ID), zap.String("kind", ev.Kind), zap.Error(err))
+		db.DBClient.Delete(ev)
+		return false, nil
+	}
+	db.DBClient.Model(ev).Update("attempts", ev.Attempts)
+	return false, err
+}
+
+func (c *SipClient) sendOutboxEvent(ev *OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), outboxSendTimeout)
+	defer cancel()
+
+	switch ev.Kind {
+	case outboxKindIpcEvent:
+		req := &pb.IpcEventRequest{}
+		if err := utils.JSONDecode([]byte(ev.Payload), req); err != nil {
+			return errOutboxBadPayload
+		}
+		_, err := c.client.IpcEventReq(ctx, req)
+		return err
+	case outboxKindAiEvent:
+		req := &pb.AIEventRequest{}
+		if err := utils.JSONDecode([]byte(ev.Payload), req); err != nil {
+			return errOutboxBadPayload
+		}
+		_, err := c.client.AiEventReq(ctx, req)
+		return err
+	case outboxKindOTAUpgrade:
+		req := &pb.OTAUpgradeRequest{}
+		if err := utils.JSONDecode([]byte(ev.Payload), req); err != nil {
+			return errOutboxBadPayload
+		}
+		_, err := c.client.OTAUpgradeReq(ctx, req)
+		return err
+	}
+	return errOutboxBadPayload
+}
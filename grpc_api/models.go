@@ -111,3 +111,92 @@ type OTA_Event_Req struct {
 	FirmwareMD5         string
 	FirmwareVersion     string
 }
+
+// 命令取消后客户端返回的结果内容
+const CommandCanceledMsg = "命令已取消"
+
+// 长耗时命令的中间进度，客户端以MsgID取负值的CommandResult上报
+type Command_Progress struct {
+	Percent int    // 进度百分比 0-100
+	Stage   string // 当前阶段
+	Msg     string
+}
+
+// 取消客户端执行中的命令
+type Command_Cancel_Req struct {
+	MsgID int64
+}
+
+// 进度消息使用的MsgID
+func ProgressMsgID(msgID int64) int64 {
+	return -msgID
+}
+
+// 客户端能力集的grpc metadata key, 二进制header由grpc进行base64编码
+const CapabilityMetadataKey = "x-client-capability-bin"
+
+// 客户端能力集，客户端建立连接时通过grpc metadata上报
+type Client_Capability struct {
+	Version      string   // 客户端版本
+	Platform     string   // 设备平台, 如rk3588
+	Methods      []string // 支持的命令
+	ZlmVersion   string   // 板端zlm版本
+	NpuCores     int      // npu核心数
+	AudioDevices []string // 音频输入输出设备
+	ModelFormats []string // 支持的ai模型格式
+}
+
+// 通知客户端重连到指定的sip服务
+type Reconnect_To_Req struct {
+	Addr string // sip服务grpc地址
+}
+
+// 远程诊断请求
+type Diagnostics_Req struct {
+	LogLines int // 返回最近的日志行数, 为0时默认100
+}
+
+// 边缘设备诊断快照
+type Client_Diagnostics struct {
+	Time         int64                // 采集时间
+	CpuUsage     float64              // cpu使用率 %
+	MemTotal     uint64               // 内存总量 byte
+	MemAvailable uint64               // 可用内存 byte
+	NpuLoad      string               // npu负载, rknpu驱动原始输出
+	Temperatures map[string]float64   // 各温区温度 ℃
+	DiskUsage    map[string]int64     // 各目录占用 byte
+	DiskFree     uint64               // 程序所在分区剩余空间 byte
+	MediaList    []Diagnostics_Media  // 本地zlm流列表
+	PulseModules []string             // pulseaudio已加载模块
+	FfmpegProcs  []string             // ffmpeg推流进程
+	SipDevices   []Diagnostics_Device // sip活跃设备
+	LogLines     []string             // 最近的日志
+	Errors       map[string]string    // 采集失败的项
+}
+
+type Diagnostics_Media struct {
+	App    string
+	Stream string
+	Schema string
+}
+
+type Diagnostics_Device struct {
+	DeviceID string
+	Name     string
+	Host     string
+	Port     string
+	ActiveAt int64 // 最后心跳时间
+}
+
+// 远程修改客户端配置, key为配置文件中以.分隔的路径, 如audio.input_device
+type Config_Update_Req struct {
+	Values map[string]any
+}
+
+// 订阅客户端实时日志, 匹配的日志以进度消息的Msg返回
+type Log_Subscribe_Req struct {
+	Level    string            // 最低日志级别 debug/info/warn/error
+	Contains string            // 日志内容需包含的子串
+	Fields   map[string]string // 字段值需相等, 如client id
+	Duration int               // 订阅时长(秒), 到时自动结束
+}
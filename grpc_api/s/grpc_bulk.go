@@ -0,0 +1,209 @@
+package grpc_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	. "go-sip/logger"
+	"go-sip/m"
+	pb "go-sip/signaling"
+
+	"github.com/gofrs/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	BulkJobRunning  = "running"
+	BulkJobFinished = "finished"
+
+	bulkJobExpire             = 24 * time.Hour
+	bulkJobSaveInterval       = time.Second // 执行中最多每秒写一次redis
+	defaultBulkJobConcurrency = 10
+	maxBulkJobConcurrency     = 100
+)
+
+// 批量命令的目标设备, 三个条件取并集
+type BulkTarget struct {
+	DeviceIds  []string `json:"deviceIds"`
+	StoreNo    string   `json:"storeNo"`
+	RegionCode string   `json:"regionCode"`
+}
+
+// 单个设备的执行结果
+type BulkDeviceResult struct {
+	Success bool   `json:"success"`
+	Msg     string `json:"msg"`
+}
+
+// 批量命令任务, 保存在redis中供任意sip服务实例查询
+type BulkJob struct {
+	JobID       string                       `json:"jobId"`
+	Method      string                       `json:"method"`
+	Status      string                       `json:"status"`
+	Total       int                          `json:"total"`
+	Done        int                          `json:"done"`
+	Succeeded   int                          `json:"succeeded"`
+	Failed      int                          `json:"failed"`
+	Results     map[string]*BulkDeviceResult `json:"results"`
+	CreatedAt   int64                        `json:"createdAt"`
+	UpdatedAt   int64                        `json:"updatedAt"`
+	mu          sync.Mutex
+	lastSavedAt time.Time
+}
+
+func (job *BulkJob) save() {
+	job.UpdatedAt = time.Now().Unix()
+	job.lastSavedAt = time.Now()
+	data, err := json.Marshal(job)
+	if err != nil {
+		Logger.Error("批量命令任务序列化失败", zap.String("job id", job.JobID), zap.Error(err))
+		return
+	}
+	redis_util.Set_2(fmt.Sprintf(redis.SIP_BULK_JOB, job.JobID), string(data), bulkJobExpire)
+}
+
+// 根据条件查询目标设备列表
+func ResolveBulkTarget(target *BulkTarget) ([]string, error) {
+	set := map[string]bool{}
+	for _, id := range target.DeviceIds {
+		if id != "" {
+			set[id] = true
+		}
+	}
+	filters := []struct {
+		key   string
+		value string
+	}{
+		{redis.IOT_DEVICE_STORE_KEY, target.StoreNo},
+		{redis.IOT_DEVICE_REGION_KEY, target.RegionCode},
+	}
+	for _, f := range filters {
+		if f.value == "" {
+			continue
+		}
+		all, err := redis_util.HGetAll_4(f.key)
+		if err != nil {
+			return nil, err
+		}
+		for deviceId, v := range all {
+			// iot平台存入的值带双引号
+			if strings.ReplaceAll(v, "\"", "") == f.value {
+				set[deviceId] = true
+			}
+		}
+	}
+	devices := make([]string, 0, len(set))
+	for id := range set {
+		devices = append(devices, id)
+	}
+	return devices, nil
+}
+
+// 批量下发命令, 立即返回任务id, 按并发数依次执行并记录每个设备的结果
+// payload为json对象时, 其中的DeviceID替换为各目标设备id
+func (s *SipServer) StartBulkJob(devices []string, method string, payload json.RawMessage, concurrency int, timeout time.Duration) string {
+	if concurrency <= 0 {
+		concurrency = defaultBulkJobConcurrency
+	}
+	if concurrency > maxBulkJobConcurrency {
+		concurrency = maxBulkJobConcurrency
+	}
+	job := &BulkJob{
+		JobID:     uuid.Must(uuid.NewV4()).String(),
+		Method:    method,
+		Status:    BulkJobRunning,
+		Total:     len(devices),
+		Results:   map[string]*BulkDeviceResult{},
+		CreatedAt: time.Now().Unix(),
+	}
+	job.save()
+	Logger.Info("开始批量下发命令", zap.String("job id", job.JobID), zap.String("method", method), zap.Int("total", len(devices)))
+
+	go func() {
+		sem := make(chan struct{}, concurrency)
+		wg := sync.WaitGroup{}
+		for _, deviceId := range devices {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(deviceId string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				result := s.executeBulkCommand(deviceId, method, payload, timeout)
+
+				job.mu.Lock()
+				defer job.mu.Unlock()
+				job.Results[deviceId] = result
+				job.Done++
+				if result.Success {
+					job.Succeeded++
+				} else {
+					job.Failed++
+				}
+				if time.Since(job.lastSavedAt) >= bulkJobSaveInterval {
+					job.save()
+				}
+			}(deviceId)
+		}
+		wg.Wait()
+
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		job.Status = BulkJobFinished
+		job.save()
+		Logger.Info("批量下发命令结束", zap.String("job id", job.JobID), zap.Int("succeeded", job.Succeeded), zap.Int("failed", job.Failed))
+	}()
+	return job.JobID
+}
+
+func (s *SipServer) executeBulkCommand(deviceId, method string, payload json.RawMessage, timeout time.Duration) *BulkDeviceResult {
+	d := []byte(payload)
+	params := map[string]any{}
+	if len(payload) > 0 && json.Unmarshal(payload, &params) == nil {
+		params["DeviceID"] = deviceId
+		d, _ = json.Marshal(params)
+	}
+	res, err := s.ExecuteCommandWithOptions(deviceId, &pb.ServerCommand{
+		Method:  method,
+		Payload: d,
+	}, &CommandOptions{Timeout: timeout})
+	if err != nil {
+		return &BulkDeviceResult{Success: false, Msg: err.Error()}
+	}
+	return &BulkDeviceResult{Success: res.Success, Msg: string(res.Payload)}
+}
+
+// 查询批量命令任务
+func GetBulkJob(jobId string) (*BulkJob, error) {
+	data, err := redis_util.Get_2(fmt.Sprintf(redis.SIP_BULK_JOB, jobId))
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, nil
+	}
+	job := &BulkJob{}
+	if err := json.Unmarshal([]byte(data), job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// 可以批量下发的命令, 参数中的DeviceID需为中控设备id
+var bulkMethods = map[string]bool{
+	m.Ping:               true,
+	m.IpcPushStreamReset: true,
+	m.SetVolume:          true,
+	m.Diagnostics:        true,
+	m.ConfigUpdate:       true,
+}
+
+func IsBulkMethod(method string) bool {
+	return bulkMethods[method]
+}
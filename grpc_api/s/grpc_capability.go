@@ -0,0 +1,111 @@
+package grpc_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	"go-sip/grpc_api"
+	. "go-sip/logger"
+	"go-sip/m"
+
+	"github.com/gogo/status"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// 不上报能力集的老版本客户端所支持的命令, 老版本客户端对未知命令直接回复执行成功, 需要在服务端拦截
+var legacyMethods = []string{
+	m.Ping,
+	m.Play,
+	m.PlayBack,
+	m.StopPlay,
+	m.PausePlay,
+	m.ResumePlay,
+	m.SpeedPlay,
+	m.SeekPlay,
+	m.RecordList,
+	m.Broadcast,
+	m.PlayIPCAudio,
+	m.DeviceControl,
+	m.IpcPushStreamReset,
+	m.DeviceOTA,
+	m.PlayAudio,
+	m.PushAudio,
+	m.SetVolume,
+	m.CloseAudio,
+	m.IpcStreamReset,
+}
+
+// 客户端建立连接时通过grpc metadata上报的能力集, 老版本客户端不上报时按老版本命令列表处理
+func clientCapability(ctx context.Context, clientID, version string) *grpc_api.Client_Capability {
+	legacy := &grpc_api.Client_Capability{Version: version, Methods: legacyMethods}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return legacy
+	}
+	values := md.Get(grpc_api.CapabilityMetadataKey)
+	if len(values) == 0 {
+		Logger.Info("客户端未上报能力集, 按老版本处理", zap.String("client id", clientID), zap.String("version", version))
+		return legacy
+	}
+	capability := &grpc_api.Client_Capability{}
+	if err := json.Unmarshal([]byte(values[0]), capability); err != nil || len(capability.Methods) == 0 {
+		Logger.Warn("客户端能力集格式错误, 按老版本处理", zap.String("client id", clientID), zap.Error(err))
+		return legacy
+	}
+	Logger.Info("客户端能力集", zap.String("client id", clientID), zap.Any("capability", capability))
+	return capability
+}
+
+// 保存客户端能力集, 其他sip服务实例和接口层从redis查询
+func saveClientCapability(clientID string, capability *grpc_api.Client_Capability) {
+	if err := redis_util.HSetStruct_2(redis.DEVICE_CAPABILITY_KEY, clientID, capability); err != nil {
+		Logger.Error("保存客户端能力集失败", zap.String("client id", clientID), zap.Error(err))
+	}
+}
+
+// 客户端断开时删除能力集, 客户端已重连到其他实例或本实例的新连接时保留
+func (s *SipServer) releaseClientCapability(client *ClientContext) {
+	if val, ok := s.clients.Load(client.ID); ok && val != client {
+		return
+	}
+	if sipId, _ := redis_util.HGet_2(redis.DEVICE_SIP_KEY, client.ID); sipId != "" && sipId != m.SMConfig.SipID {
+		return
+	}
+	redis_util.HDel_2(redis.DEVICE_CAPABILITY_KEY, client.ID)
+}
+
+// 查询客户端能力集, 客户端未连接时返回nil
+func GetClientCapability(clientID string) (*grpc_api.Client_Capability, error) {
+	data, err := redis_util.HGet_2(redis.DEVICE_CAPABILITY_KEY, clientID)
+	if err != nil || data == "" {
+		return nil, err
+	}
+	capability := &grpc_api.Client_Capability{}
+	if err := json.Unmarshal([]byte(data), capability); err != nil {
+		return nil, err
+	}
+	return capability, nil
+}
+
+// 校验能力集是否支持该命令
+func checkCapability(capability *grpc_api.Client_Capability, method string) error {
+	if method == m.Ping {
+		return nil
+	}
+	for _, v := range capability.Methods {
+		if v == method {
+			return nil
+		}
+	}
+	return status.Error(codes.Unimplemented, fmt.Sprintf("客户端版本%s不支持%s命令", capability.Version, method))
+}
+
+// 校验本实例连接的客户端是否支持该命令
+func (c *ClientContext) checkCapability(method string) error {
+	return checkCapability(c.Capability, method)
+}
@@ -0,0 +1,151 @@
+package grpc_server
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	"go-sip/grpc_api"
+	. "go-sip/logger"
+	"go-sip/m"
+	pb "go-sip/signaling"
+
+	"go.uber.org/zap"
+)
+
+const (
+	drainCommandTimeout = 60 * time.Second // 等待执行中命令结束的最长时间
+	drainClientTimeout  = 30 * time.Second // 等待客户端迁移断开的最长时间
+	drainPollInterval   = 200 * time.Millisecond
+)
+
+// 是否处于下线排空状态
+func (s *SipServer) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// 排空完成后关闭, 用于通知进程退出
+func (s *SipServer) Drained() <-chan struct{} {
+	return s.drained
+}
+
+// 下线排空: 停止接收新客户端, 通知已连接客户端迁移到其他sip服务, 等待执行中的命令结束
+func (s *SipServer) Drain() {
+	if !atomic.CompareAndSwapInt32(&s.draining, 0, 1) {
+		return
+	}
+	Logger.Info("sip服务开始下线排空", zap.String("sip id", m.SMConfig.SipID))
+
+	// 网关不再为新连接选择本实例
+	redis_util.HDel_2(redis.SIP_SERVER_PUBLIC_TCP_HOST, m.SMConfig.SipID)
+
+	s.migrateClients()
+	s.waitInflight()
+	s.waitClients()
+
+	redis_util.HDel_2(redis.SIP_SERVER_HOST, m.SMConfig.SipID)
+	s.clients.Range(func(key, value any) bool {
+		releaseDeviceSip(key.(string))
+		return true
+	})
+	Logger.Info("sip服务下线排空完成", zap.String("sip id", m.SMConfig.SipID))
+	close(s.drained)
+}
+
+// 其他在线sip服务的grpc地址
+func drainTargets() []string {
+	hosts, err := redis_util.HGetAll_2(redis.SIP_SERVER_PUBLIC_TCP_HOST)
+	if err != nil {
+		return nil
+	}
+	targets := make([]string, 0, len(hosts))
+	for sipId, addr := range hosts {
+		if sipId == m.SMConfig.SipID || strings.Contains(addr, "127.0.0.1") || strings.Contains(addr, "localhost") {
+			continue
+		}
+		targets = append(targets, addr)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// 客户端执行中的命令结束后通知其重连到其他sip服务, 避免迁移断开时丢失执行结果, 客户端依次轮询分配
+func (s *SipServer) migrateClients() {
+	targets := drainTargets()
+	if len(targets) == 0 {
+		Logger.Warn("没有其他可用的sip服务, 客户端断开后通过网关重连")
+		return
+	}
+	deadline := time.Now().Add(drainCommandTimeout)
+	i := 0
+	s.clients.Range(func(key, value any) bool {
+		clientID := key.(string)
+		client := value.(*ClientContext)
+		addr := targets[i%len(targets)]
+		i++
+		go func() {
+			if !client.waitPending(deadline) {
+				Logger.Warn("等待客户端执行中命令超时, 仍通知迁移", zap.String("client id", clientID))
+			}
+			d, _ := json.Marshal(&grpc_api.Reconnect_To_Req{Addr: addr})
+			res, err := s.ExecuteCommand(clientID, &pb.ServerCommand{
+				Method:  m.ReconnectTo,
+				Payload: d,
+			})
+			if err != nil || !res.Success {
+				Logger.Warn("通知客户端迁移失败, 等待下线时断开", zap.String("client id", clientID), zap.Error(err))
+				return
+			}
+			Logger.Info("通知客户端迁移", zap.String("client id", clientID), zap.String("addr", addr))
+		}()
+		return true
+	})
+}
+
+// 等待客户端执行中的命令结束, 超时返回false
+func (c *ClientContext) waitPending(deadline time.Time) bool {
+	for {
+		pending := false
+		c.ResponseChans.Range(func(key, value any) bool {
+			pending = true
+			return false
+		})
+		if !pending {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+func (s *SipServer) waitInflight() {
+	deadline := time.Now().Add(drainCommandTimeout)
+	for atomic.LoadInt64(&s.inflight) > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if n := atomic.LoadInt64(&s.inflight); n > 0 {
+		Logger.Warn("等待执行中命令超时", zap.Int64("inflight", n))
+	}
+}
+
+func (s *SipServer) waitClients() {
+	deadline := time.Now().Add(drainClientTimeout)
+	for time.Now().Before(deadline) {
+		remaining := 0
+		s.clients.Range(func(key, value any) bool {
+			remaining++
+			return true
+		})
+		if remaining == 0 {
+			return
+		}
+		time.Sleep(drainPollInterval)
+	}
+	Logger.Warn("仍有客户端未迁移, 下线时强制断开")
+}
@@ -0,0 +1,206 @@
+package grpc_server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	"go-sip/grpc_api"
+	. "go-sip/logger"
+	"go-sip/m"
+	pb "go-sip/signaling"
+
+	"github.com/gogo/status"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// 异步命令操作状态
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCanceled  = "canceled"
+	OperationTimeout   = "timeout"
+
+	operationExpire = 24 * time.Hour
+
+	operationPollInterval = 3 * time.Second
+)
+
+// 执行中会重启客户端的命令, 连接断开后等待客户端重启上报结果
+var restartMethods = map[string]bool{
+	m.DeviceOTA: true,
+}
+
+// 异步命令操作记录，保存在redis中供任意sip服务实例查询
+type CommandOperation struct {
+	OpID      string `json:"opId"`
+	ClientID  string `json:"clientId"`
+	Method    string `json:"method"`
+	MsgID     int64  `json:"msgId"`
+	SipID     string `json:"sipId"` // 发起操作的sip服务
+	Status    string `json:"status"`
+	Percent   int    `json:"percent"`
+	Stage     string `json:"stage"`
+	Result    string `json:"result"`
+	Error     string `json:"error"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+
+	mu sync.Mutex
+}
+
+func (op *CommandOperation) save() {
+	op.UpdatedAt = time.Now().Unix()
+	data, err := json.Marshal(op)
+	if err != nil {
+		Logger.Error("异步命令操作记录序列化失败", zap.String("op id", op.OpID), zap.Error(err))
+		return
+	}
+	redis_util.Set_2(fmt.Sprintf(redis.SIP_COMMAND_OPERATION, op.OpID), string(data), operationExpire)
+}
+
+// 异步执行命令，立即返回操作id，执行进度和结果写入redis
+func (s *SipServer) StartOperation(clientID string, cmd *pb.ServerCommand, timeout time.Duration) string {
+	msgID := s.NextMsgID()
+	op := &CommandOperation{
+		OpID:      strconv.FormatInt(msgID, 10),
+		ClientID:  clientID,
+		Method:    cmd.Method,
+		MsgID:     msgID,
+		SipID:     m.SMConfig.SipID,
+		Status:    OperationRunning,
+		CreatedAt: time.Now().Unix(),
+	}
+	op.save()
+
+	go func() {
+		res, err := s.ExecuteCommandWithOptions(clientID, cmd, &CommandOptions{
+			MsgID:   msgID,
+			Timeout: timeout,
+			OnProgress: func(p *grpc_api.Command_Progress) {
+				op.mu.Lock()
+				defer op.mu.Unlock()
+				if op.Status != OperationRunning {
+					return
+				}
+				op.Percent = p.Percent
+				op.Stage = p.Stage
+				op.save()
+			},
+		})
+
+		if err != nil && restartMethods[cmd.Method] && isClientDisconnected(err) {
+			s.awaitRestartResult(op, timeout)
+			return
+		}
+
+		op.mu.Lock()
+		defer op.mu.Unlock()
+		op.finish(res, err)
+		op.save()
+		Logger.Info("异步命令执行结束", zap.String("op id", op.OpID), zap.String("method", op.Method), zap.String("status", op.Status))
+	}()
+	return op.OpID
+}
+
+// 按命令结果更新操作状态
+func (op *CommandOperation) finish(res *pb.CommandResult, err error) {
+	switch {
+	case err != nil && isDeadlineExceeded(err):
+		op.Status = OperationTimeout
+		op.Error = err.Error()
+	case err != nil:
+		op.Status = OperationFailed
+		op.Error = err.Error()
+	case !res.Success && string(res.Payload) == grpc_api.CommandCanceledMsg:
+		op.Status = OperationCanceled
+		op.Error = string(res.Payload)
+	case !res.Success:
+		op.Status = OperationFailed
+		op.Error = string(res.Payload)
+	default:
+		op.Status = OperationSucceeded
+		op.Percent = 100
+		op.Result = string(res.Payload)
+	}
+}
+
+// 等待客户端重启后上报结果, 超过命令超时时间仍未上报时标记超时
+func (s *SipServer) awaitRestartResult(op *CommandOperation, timeout time.Duration) {
+	Logger.Info("客户端连接断开, 等待重启后上报结果", zap.String("op id", op.OpID), zap.String("client id", op.ClientID))
+	deadline := time.Unix(op.CreatedAt, 0).Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(operationPollInterval)
+		cur, err := GetOperation(op.OpID)
+		if err == nil && (cur == nil || cur.Status != OperationRunning) {
+			return
+		}
+	}
+	cur, err := GetOperation(op.OpID)
+	if err != nil || cur == nil || cur.Status != OperationRunning {
+		return
+	}
+	cur.Status = OperationTimeout
+	cur.Error = "等待客户端重启后上报结果超时"
+	cur.save()
+	Logger.Info("异步命令执行结束", zap.String("op id", cur.OpID), zap.String("method", cur.Method), zap.String("status", cur.Status))
+}
+
+// 客户端重启后按原MsgID上报的异步命令结果, 任意sip服务实例收到后更新操作状态
+func finishRestartOperation(clientID string, res *pb.CommandResult) {
+	op, err := GetOperation(strconv.FormatInt(res.MsgID, 10))
+	if err != nil || op == nil || op.ClientID != clientID || op.Status != OperationRunning || !restartMethods[op.Method] {
+		return
+	}
+	op.finish(res, nil)
+	op.save()
+	Logger.Info("异步命令执行结束", zap.String("op id", op.OpID), zap.String("method", op.Method), zap.String("status", op.Status))
+}
+
+// 查询异步命令操作记录
+func GetOperation(opId string) (*CommandOperation, error) {
+	data, err := redis_util.Get_2(fmt.Sprintf(redis.SIP_COMMAND_OPERATION, opId))
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, nil
+	}
+	op := &CommandOperation{}
+	if err := json.Unmarshal([]byte(data), op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// 取消执行中的异步命令
+func (s *SipServer) CancelOperation(opId string) error {
+	op, err := GetOperation(opId)
+	if err != nil {
+		return err
+	}
+	if op == nil {
+		return errors.New("操作不存在")
+	}
+	if op.Status != OperationRunning {
+		return errors.New("操作已结束")
+	}
+	return s.CancelCommand(op.ClientID, op.MsgID)
+}
+
+func isDeadlineExceeded(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.DeadlineExceeded
+}
+
+func isClientDisconnected(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Aborted
+}
@@ -0,0 +1,214 @@
+package grpc_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-sip/db/redis"
+	redis_util "go-sip/db/redis/redis_server_util"
+	"go-sip/grpc_api"
+	. "go-sip/logger"
+	"go-sip/m"
+	pb "go-sip/signaling"
+
+	"github.com/gogo/status"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// 跨实例转发时额外等待的时间，覆盖转发和回复的耗时
+const routeCommandExtraTimeout = 2 * time.Second
+
+var (
+	routeSeq     int64
+	routePending sync.Map // key: routeId, value: *routePendingCommand
+)
+
+type routePendingCommand struct {
+	result     chan *RouteResultMsg
+	onProgress func(p *grpc_api.Command_Progress)
+}
+
+// 跨实例转发的命令
+type RouteCommandMsg struct {
+	RouteID   string `json:"routeId"`
+	FromSipID string `json:"fromSipId"`
+	ClientID  string `json:"clientId"`
+	MsgID     int64  `json:"msgId"`
+	Method    string `json:"method"`
+	Payload   []byte `json:"payload"`
+	TimeoutMs int64  `json:"timeoutMs"` // 等待客户端最终结果的超时时间
+}
+
+// 跨实例转发的命令执行结果
+type RouteResultMsg struct {
+	RouteID string `json:"routeId"`
+	Success bool   `json:"success"`
+	Payload []byte `json:"payload"`
+	Code    uint32 `json:"code"` // grpc错误码, 0表示执行成功
+	Err     string `json:"err"`
+
+	Progress *grpc_api.Command_Progress `json:"progress,omitempty"` // 非空时为中间进度, 不是最终结果
+}
+
+// 启动跨实例命令转发, 订阅本实例的命令频道和结果回复频道
+func (s *SipServer) StartCommandRoute() {
+	go subscribeRoute(fmt.Sprintf(redis.SIP_SERVER_CMD_ROUTE, m.SMConfig.SipID), s.handleRouteCommand)
+	go subscribeRoute(fmt.Sprintf(redis.SIP_SERVER_CMD_REPLY, m.SMConfig.SipID), handleRouteResult)
+}
+
+func subscribeRoute(channel string, handler func(payload string)) {
+	for {
+		sub := redis_util.Subscribe_2(channel)
+		Logger.Info("订阅跨实例命令频道", zap.String("channel", channel))
+		for msg := range sub.Channel() {
+			handler(msg.Payload)
+		}
+		sub.Close()
+		Logger.Warn("跨实例命令频道订阅断开, 3秒后重新订阅", zap.String("channel", channel))
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// 处理其他实例转发过来的命令, 在本实例的客户端连接上执行后回复结果
+func (s *SipServer) handleRouteCommand(payload string) {
+	req := &RouteCommandMsg{}
+	if err := json.Unmarshal([]byte(payload), req); err != nil {
+		Logger.Error("跨实例命令反序列化失败", zap.Error(err))
+		return
+	}
+	go func() {
+		Logger.Info("收到跨实例转发命令", zap.String("from", req.FromSipID), zap.String("client id", req.ClientID), zap.String("method", req.Method))
+		replyChannel := fmt.Sprintf(redis.SIP_SERVER_CMD_REPLY, req.FromSipID)
+		opt := &CommandOptions{
+			Timeout: time.Duration(req.TimeoutMs) * time.Millisecond,
+			OnProgress: func(p *grpc_api.Command_Progress) {
+				data, _ := json.Marshal(&RouteResultMsg{RouteID: req.RouteID, Progress: p})
+				redis_util.Publish_2(replyChannel, string(data))
+			},
+		}
+		rsp := &RouteResultMsg{RouteID: req.RouteID}
+		res, err := s.executeLocal(req.ClientID, &pb.ServerCommand{
+			MsgID:   req.MsgID,
+			Method:  req.Method,
+			Payload: req.Payload,
+		}, opt)
+		if err != nil {
+			rsp.Code = uint32(codes.Unknown)
+			rsp.Err = err.Error()
+			if st, ok := status.FromError(err); ok {
+				rsp.Code = uint32(st.Code())
+				rsp.Err = st.Message()
+			}
+		} else {
+			rsp.Success = res.Success
+			rsp.Payload = res.Payload
+		}
+		data, _ := json.Marshal(rsp)
+		if _, err := redis_util.Publish_2(replyChannel, string(data)); err != nil {
+			Logger.Error("跨实例命令结果回复失败", zap.String("to", req.FromSipID), zap.Error(err))
+		}
+	}()
+}
+
+// 处理其他实例回复的命令结果
+func handleRouteResult(payload string) {
+	rsp := &RouteResultMsg{}
+	if err := json.Unmarshal([]byte(payload), rsp); err != nil {
+		Logger.Error("跨实例命令结果反序列化失败", zap.Error(err))
+		return
+	}
+	if rsp.Progress != nil {
+		if val, ok := routePending.Load(rsp.RouteID); ok {
+			if pending := val.(*routePendingCommand); pending.onProgress != nil {
+				pending.onProgress(rsp.Progress)
+			}
+		}
+		return
+	}
+	if val, ok := routePending.LoadAndDelete(rsp.RouteID); ok {
+		val.(*routePendingCommand).result <- rsp
+	}
+}
+
+// 将命令转发到客户端所在的sip服务实例执行
+func (s *SipServer) routeCommand(sipId, clientID string, cmd *pb.ServerCommand, opt *CommandOptions) (*pb.CommandResult, error) {
+	routeId := fmt.Sprintf("%s_%d_%d", m.SMConfig.SipID, time.Now().UnixNano(), atomic.AddInt64(&routeSeq, 1))
+	data, err := json.Marshal(&RouteCommandMsg{
+		RouteID:   routeId,
+		FromSipID: m.SMConfig.SipID,
+		ClientID:  clientID,
+		MsgID:     cmd.MsgID,
+		Method:    cmd.Method,
+		Payload:   cmd.Payload,
+		TimeoutMs: opt.timeout().Milliseconds(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &routePendingCommand{
+		result:     make(chan *RouteResultMsg, 1),
+		onProgress: opt.OnProgress,
+	}
+	routePending.Store(routeId, pending)
+	defer routePending.Delete(routeId)
+
+	n, err := redis_util.Publish_2(fmt.Sprintf(redis.SIP_SERVER_CMD_ROUTE, sipId), string(data))
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, "跨实例命令转发失败")
+	}
+	if n == 0 {
+		// 目标实例没有订阅, 说明已下线
+		Logger.Warn("客户端所在sip服务不在线", zap.String("sip id", sipId), zap.String("client id", clientID))
+		return nil, status.Error(codes.NotFound, "客户端未连接")
+	}
+	Logger.Info("命令转发到其他sip服务", zap.String("sip id", sipId), zap.String("client id", clientID), zap.String("method", cmd.Method))
+
+	select {
+	case rsp := <-pending.result:
+		if rsp.Code != 0 {
+			return nil, status.Error(codes.Code(rsp.Code), rsp.Err)
+		}
+		return &pb.CommandResult{MsgID: cmd.MsgID, Success: rsp.Success, Payload: rsp.Payload}, nil
+	case <-time.After(opt.timeout() + routeCommandExtraTimeout):
+		return nil, status.Error(codes.DeadlineExceeded, "等待响应超时")
+	}
+}
+
+// 根据ipcId查询关联的设备id, 先查本实例, 再查其他sip服务实例
+func GetIpcDeviceId(ipcId string) (string, error) {
+	deviceId, err := redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, m.SMConfig.SipID), ipcId)
+	if err != nil || deviceId != "" {
+		return deviceId, err
+	}
+	sipServers, err := redis_util.HGetAll_2(redis.SIP_SERVER_HOST)
+	if err != nil {
+		return "", err
+	}
+	for sipId := range sipServers {
+		if sipId == m.SMConfig.SipID {
+			continue
+		}
+		deviceId, err = redis_util.HGet_2(fmt.Sprintf(redis.SIP_IPC, sipId), ipcId)
+		if err != nil {
+			return "", err
+		}
+		if deviceId != "" {
+			return deviceId, nil
+		}
+	}
+	return "", nil
+}
+
+// 客户端断开时释放设备与sip服务的关联, 若设备已重连到其他实例则保留
+func releaseDeviceSip(clientId string) {
+	sipId, err := redis_util.HGet_2(redis.DEVICE_SIP_KEY, clientId)
+	if err != nil || sipId != m.SMConfig.SipID {
+		return
+	}
+	redis_util.HDel_2(redis.DEVICE_SIP_KEY, clientId)
+}
@@ -8,6 +8,7 @@ import (
 
 	"go-sip/db/redis"
 	redis_util "go-sip/db/redis/redis_server_util"
+	"go-sip/grpc_api"
 	. "go-sip/logger"
 	"go-sip/m"
 	"go-sip/model"
@@ -17,6 +18,7 @@ import (
 	"go-sip/zlm_api"
 
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/status"
@@ -26,18 +28,30 @@ import (
 
 var SipSrv *SipServer
 
+// 默认等待命令结果的超时时间
+const defaultCommandTimeout = 10 * time.Second
+
+// 生成的MsgID从该值开始, 与m包中固定的协议MsgID区分, 避免客户端回复串到其他命令
+const generatedMsgIDBase int64 = 1 << 32
+
+// 等待结果时客户端连接断开
+var errClientDisconnected = status.Error(codes.Aborted, "客户端连接断开")
+
 type SipServer struct {
 	pb.UnimplementedSipServiceServer
-	clients   sync.Map // 使用 sync.Map 管理客户端连接
-	StreamMap map[string]string
+	clients  sync.Map // 使用 sync.Map 管理客户端连接
+	msgSeq   int64    // redis不可用时生成MsgID的本地序号
+	inflight int64    // 本实例执行中的命令数
+	draining int32    // 1表示正在下线排空
+	drained  chan struct{}
 }
 
 func GetSipServer() *SipServer {
 
 	if SipSrv == nil {
 		SipSrv = &SipServer{
-			StreamMap: make(map[string]string),
-			clients:   sync.Map{},
+			clients: sync.Map{},
+			drained: make(chan struct{}),
 		}
 	}
 	return SipSrv
@@ -55,11 +69,16 @@ func (s *SipServer) StreamChannel(stream pb.SipService_StreamChannelServer) erro
 	if reg == nil {
 		return status.Error(codes.InvalidArgument, "需要先注册客户端")
 	}
+	if s.Draining() {
+		return status.Error(codes.Unavailable, "sip服务正在下线")
+	}
 
 	// 记录客户端连接
 	clientCtx := &ClientContext{
-		ID:     reg.ClientId,
-		Stream: stream,
+		ID:         reg.ClientId,
+		Stream:     stream,
+		Capability: clientCapability(stream.Context(), reg.ClientId, reg.Version),
+		closed:     make(chan struct{}),
 	}
 
 	// 设备和rk平台关联存入redis
@@ -86,10 +105,14 @@ func (s *SipServer) StreamChannel(stream pb.SipService_StreamChannelServer) erro
 	}
 
 	redis_util.HSet_2(redis.DEVICE_SIP_KEY, reg.ClientId, m.SMConfig.SipID)
+	saveClientCapability(reg.ClientId, clientCtx.Capability)
 
 	s.clients.Store(reg.ClientId, clientCtx)
 	defer s.clients.Delete(reg.ClientId)
-	defer redis_util.HDel_2(redis.DEVICE_SIP_KEY, reg.ClientId)
+	// 连接断开时结束所有等待该客户端响应的命令
+	defer close(clientCtx.closed)
+	defer releaseDeviceSip(reg.ClientId)
+	defer s.releaseClientCapability(clientCtx)
 
 	for {
 		msg, err := stream.Recv()
@@ -99,16 +122,23 @@ func (s *SipServer) StreamChannel(stream pb.SipService_StreamChannelServer) erro
 			return err
 		}
 		if msg != nil {
-			Logger.Info("收到客户端消息", zap.Any("client id", reg.ClientId), zap.Any("msg", msg))
+			Logger.Debug("收到客户端消息", zap.Any("client id", reg.ClientId), zap.Any("msg", msg))
 
 			// 如果是响应
 			if res := msg.GetResult(); res != nil {
-				if chVal, ok := clientCtx.ResponseChans.Load(res.MsgID); ok {
-					ch := chVal.(chan *pb.CommandResult)
-					ch <- res
-					close(ch)
-					clientCtx.ResponseChans.Delete(res.MsgID)
+				// MsgID为负值时是长耗时命令的中间进度
+				if res.MsgID < 0 {
+					if val, ok := clientCtx.ResponseChans.Load(grpc_api.ProgressMsgID(res.MsgID)); ok {
+						val.(*pendingCommand).progress(res.Payload)
+					}
+					continue
 				}
+				if val, ok := clientCtx.ResponseChans.LoadAndDelete(res.MsgID); ok {
+					val.(*pendingCommand).result <- res
+					continue
+				}
+				// 客户端重启后上报的异步命令结果
+				finishRestartOperation(reg.ClientId, res)
 				continue
 			}
 
@@ -118,28 +148,135 @@ func (s *SipServer) StreamChannel(stream pb.SipService_StreamChannelServer) erro
 
 }
 
+// 命令执行选项
+type CommandOptions struct {
+	MsgID      int64                              // 指定命令MsgID, 为0时自动生成
+	Timeout    time.Duration                      // 等待最终结果的超时时间, 为0时默认10秒, 超时后通知客户端取消命令
+	OnProgress func(p *grpc_api.Command_Progress) // 收到客户端中间进度时回调
+}
+
+func (opt *CommandOptions) timeout() time.Duration {
+	if opt == nil || opt.Timeout <= 0 {
+		return defaultCommandTimeout
+	}
+	return opt.Timeout
+}
+
+// 等待客户端响应的命令
+type pendingCommand struct {
+	result     chan *pb.CommandResult
+	onProgress func(p *grpc_api.Command_Progress)
+}
+
+func (p *pendingCommand) progress(payload []byte) {
+	if p.onProgress == nil {
+		return
+	}
+	progress := &grpc_api.Command_Progress{}
+	if err := json.Unmarshal(payload, progress); err != nil {
+		Logger.Error("命令进度反序列化失败", zap.Error(err))
+		return
+	}
+	p.onProgress(progress)
+}
+
+// 生成命令MsgID, 多个sip服务实例间通过redis自增保证唯一, 不与固定的协议MsgID重叠
+func (s *SipServer) NextMsgID() int64 {
+	id, err := redis_util.Incr_2(redis.SIP_COMMAND_MSG_ID_SEQ)
+	if err != nil || id <= 0 {
+		return time.Now().UnixMilli()*1000 + atomic.AddInt64(&s.msgSeq, 1)%1000
+	}
+	return generatedMsgIDBase + id
+}
+
 // 主动调用客户端方法
 func (s *SipServer) ExecuteCommand(clientID string, cmd *pb.ServerCommand) (*pb.CommandResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	return s.ExecuteCommandWithOptions(clientID, cmd, nil)
+}
+
+// 主动调用客户端方法, 客户端不在本实例时转发到其所在的sip服务实例执行
+func (s *SipServer) ExecuteCommandWithOptions(clientID string, cmd *pb.ServerCommand, opt *CommandOptions) (*pb.CommandResult, error) {
+	if opt == nil {
+		opt = &CommandOptions{}
+	}
+	msgID := opt.MsgID
+	if msgID == 0 {
+		msgID = s.NextMsgID()
+	}
+	cmd = &pb.ServerCommand{MsgID: msgID, Method: cmd.Method, Payload: cmd.Payload}
+
+	if _, ok := s.clients.Load(clientID); ok {
+		return s.executeLocal(clientID, cmd, opt)
+	}
+	sipId, err := redis_util.HGet_2(redis.DEVICE_SIP_KEY, clientID)
+	if err != nil || sipId == "" || sipId == m.SMConfig.SipID {
+		return nil, status.Error(codes.NotFound, "客户端未连接")
+	}
+	// 转发前按redis中的能力集校验, 不支持的命令不再转发
+	if capability, _ := GetClientCapability(clientID); capability != nil {
+		if err := checkCapability(capability, cmd.Method); err != nil {
+			return nil, err
+		}
+	}
+	return s.routeCommand(sipId, clientID, cmd, opt)
+}
+
+// 取消客户端执行中的命令
+func (s *SipServer) CancelCommand(clientID string, msgID int64) error {
+	d, err := json.Marshal(&grpc_api.Command_Cancel_Req{MsgID: msgID})
+	if err != nil {
+		return err
+	}
+	res, err := s.ExecuteCommand(clientID, &pb.ServerCommand{
+		MsgID:   m.MsgID_CancelCommand,
+		Method:  m.CancelCommand,
+		Payload: d,
+	})
+	if err != nil {
+		return err
+	}
+	if !res.Success {
+		return fmt.Errorf("%s", res.Payload)
+	}
+	return nil
+}
+
+// 在本实例的客户端连接上执行命令
+func (s *SipServer) executeLocal(clientID string, cmd *pb.ServerCommand, opt *CommandOptions) (*pb.CommandResult, error) {
+	atomic.AddInt64(&s.inflight, 1)
+	defer atomic.AddInt64(&s.inflight, -1)
+	ctx, cancel := context.WithTimeout(context.Background(), opt.timeout())
 	defer cancel()
 	val, ok := s.clients.Load(clientID)
 	if !ok {
 		return nil, status.Error(codes.NotFound, "客户端未连接")
 	}
 	client := val.(*ClientContext)
+	if err := client.checkCapability(cmd.Method); err != nil {
+		return nil, err
+	}
 
-	resultChan := make(chan *pb.CommandResult, 1)
-	client.ResponseChans.Store(cmd.MsgID, resultChan)
+	pending := &pendingCommand{
+		result:     make(chan *pb.CommandResult, 1),
+		onProgress: opt.OnProgress,
+	}
+	client.ResponseChans.Store(cmd.MsgID, pending)
 	defer client.ResponseChans.Delete(cmd.MsgID)
 
-	if err := client.Stream.Send(cmd); err != nil {
+	if err := client.Send(cmd); err != nil {
 		return nil, err
 	}
 
 	select {
-	case res := <-resultChan:
+	case res := <-pending.result:
 		return res, nil
+	case <-client.closed:
+		return nil, errClientDisconnected
 	case <-ctx.Done():
+		// 超时后通知客户端取消仍在执行的命令
+		if cmd.Method != m.CancelCommand && client.checkCapability(m.CancelCommand) == nil {
+			client.sendCancel(cmd.MsgID)
+		}
 		return nil, status.Error(codes.DeadlineExceeded, "等待响应超时")
 	}
 }
@@ -149,7 +286,30 @@ type ClientContext struct {
 	Stream        pb.SipService_StreamChannelServer
 	LastActive    time.Time
 	ClientCtx     context.Context
-	ResponseChans sync.Map // key: MsgID(string), value: chan *pb.CommandResult
+	ResponseChans sync.Map                    // key: MsgID(int64), value: *pendingCommand
+	Capability    *grpc_api.Client_Capability // 连接时上报的能力集, 老版本客户端为老版本命令列表
+	sendMu        sync.Mutex                  // grpc stream不支持并发Send
+	closed        chan struct{}               // 连接断开时关闭
+}
+
+// 向客户端发送命令
+func (c *ClientContext) Send(cmd *pb.ServerCommand) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.Stream.Send(cmd)
+}
+
+// 通知客户端取消命令, 不等待结果
+func (c *ClientContext) sendCancel(msgID int64) {
+	d, _ := json.Marshal(&grpc_api.Command_Cancel_Req{MsgID: msgID})
+	err := c.Send(&pb.ServerCommand{
+		MsgID:   m.MsgID_CancelCommand,
+		Method:  m.CancelCommand,
+		Payload: d,
+	})
+	if err != nil {
+		Logger.Error("发送取消命令失败", zap.String("client id", c.ID), zap.Int64("MsgID", msgID), zap.Error(err))
+	}
 }
 
 func (s *SipServer) IpcEventReq(ctx context.Context, req *pb.IpcEventRequest) (*pb.IpcEventAck, error) {
@@ -202,7 +362,11 @@ func (s *SipServer) IpcEventReq(ctx context.Context, req *pb.IpcEventRequest) (*
 
 func (s *SipServer) IpcInviteReq(ctx context.Context, req *pb.IpcInviteRequest) (*pb.IpcInviteAck, error) {
 
-	zlm_id := s.StreamMap[req.IpcId]
+	// 广播推流的hook和客户端的invite可能由不同sip服务实例处理
+	zlm_id, err := redis_util.Get_2(fmt.Sprintf(redis.IPC_BROADCAST_ZLM_KEY, req.IpcId))
+	if err != nil || zlm_id == "" {
+		return nil, status.Error(codes.NotFound, "广播流未推流")
+	}
 	redisZlmInfo, err := redis_util.HGet_2(redis.WVP_ZLM_NODE_INFO, zlm_id)
 	if err != nil {
 		return nil, err
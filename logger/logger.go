@@ -34,6 +34,24 @@ func getLogWriter(logPath string) zapcore.WriteSyncer {
 	return zapcore.AddSync(lumberJackLogger)
 }
 
+// 设置全局日志级别, 运行中修改立即生效
+func SetLogLevel(logLevel string) {
+	// logLevel转小写
+	logLevel = strings.ToLower(logLevel)
+	switch logLevel {
+	case "debug":
+		GlobalLevel.SetLevel(zap.DebugLevel)
+	case "info":
+		GlobalLevel.SetLevel(zap.InfoLevel)
+	case "warn":
+		GlobalLevel.SetLevel(zap.WarnLevel)
+	case "error":
+		GlobalLevel.SetLevel(zap.ErrorLevel)
+	default:
+		GlobalLevel.SetLevel(zap.DebugLevel)
+	}
+}
+
 func InitLogger(logLevel string) {
 	// 创建日志目录
 	logDir := "./logs"
@@ -56,20 +74,7 @@ func InitLogger(logLevel string) {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	// logLevel转小写
-	logLevel = strings.ToLower(logLevel)
-	switch logLevel {
-	case "debug":
-		GlobalLevel.SetLevel(zap.DebugLevel)
-	case "info":
-		GlobalLevel.SetLevel(zap.InfoLevel)
-	case "warn":
-		GlobalLevel.SetLevel(zap.WarnLevel)
-	case "error":
-		GlobalLevel.SetLevel(zap.ErrorLevel)
-	default:
-		GlobalLevel.SetLevel(zap.DebugLevel)
-	}
+	SetLogLevel(logLevel)
 
 	// 多级别日志配置
 	errorLevel := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
@@ -96,6 +101,8 @@ func InitLogger(logLevel string) {
 			zapcore.AddSync(os.Stdout),
 			zap.InfoLevel,
 		),
+		// 远程日志订阅
+		newSubscribeCore(encoderConfig),
 	)
 
 	// 创建Logger
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 日志订阅过滤条件
+type LogFilter struct {
+	Level    zapcore.Level     // 最低日志级别
+	Contains string            // 日志内容需包含的子串, 匹配消息和字段
+	Fields   map[string]string // 字段值需相等
+}
+
+type logSubscriber struct {
+	filter LogFilter
+	ch     chan string
+}
+
+var (
+	subMu       sync.RWMutex
+	subscribers = map[int64]*logSubscriber{}
+	subSeq      int64
+	subCount    int32
+)
+
+// 订阅日志, 匹配的日志以json格式写入返回的channel, channel满时丢弃, 不阻塞日志输出
+// 调用返回的cancel结束订阅并关闭channel
+func SubscribeLog(filter LogFilter, buffer int) (<-chan string, func()) {
+	sub := &logSubscriber{filter: filter, ch: make(chan string, buffer)}
+	subMu.Lock()
+	subSeq++
+	id := subSeq
+	subscribers[id] = sub
+	atomic.AddInt32(&subCount, 1)
+	subMu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			subMu.Lock()
+			delete(subscribers, id)
+			atomic.AddInt32(&subCount, -1)
+			close(sub.ch)
+			subMu.Unlock()
+		})
+	}
+}
+
+// 把日志分发给订阅者的core, 没有订阅者时不做任何处理
+type subscribeCore struct {
+	enc    zapcore.Encoder
+	fields []zapcore.Field
+}
+
+func newSubscribeCore(cfg zapcore.EncoderConfig) zapcore.Core {
+	return &subscribeCore{enc: zapcore.NewJSONEncoder(cfg)}
+}
+
+func (c *subscribeCore) Enabled(zapcore.Level) bool {
+	return atomic.LoadInt32(&subCount) > 0
+}
+
+func (c *subscribeCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &subscribeCore{enc: c.enc, fields: all}
+}
+
+func (c *subscribeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *subscribeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+
+	buf, err := c.enc.EncodeEntry(ent, all)
+	if err != nil {
+		return err
+	}
+	line := strings.TrimSpace(buf.String())
+	buf.Free()
+
+	var values map[string]any
+	subMu.RLock()
+	defer subMu.RUnlock()
+	for _, sub := range subscribers {
+		if ent.Level < sub.filter.Level {
+			continue
+		}
+		if sub.filter.Contains != "" && !strings.Contains(line, sub.filter.Contains) {
+			continue
+		}
+		if len(sub.filter.Fields) > 0 {
+			if values == nil {
+				enc := zapcore.NewMapObjectEncoder()
+				for _, f := range all {
+					f.AddTo(enc)
+				}
+				values = enc.Fields
+			}
+			if !matchFields(values, sub.filter.Fields) {
+				continue
+			}
+		}
+		select {
+		case sub.ch <- line:
+		default:
+		}
+	}
+	return nil
+}
+
+func (c *subscribeCore) Sync() error {
+	return nil
+}
+
+func matchFields(values map[string]any, want map[string]string) bool {
+	for k, v := range want {
+		val, ok := values[k]
+		if !ok || fmt.Sprint(val) != v {
+			return false
+		}
+	}
+	return true
+}
@@ -22,12 +22,22 @@ type C_Config struct {
 	Gateway       string         `json:"gateway" yaml:"gateway" mapstructure:"gateway"`
 	ZlmSecret     string         `json:"zlm_secret" yaml:"zlm_secret" mapstructure:"zlm_secret"`
 	ZlmInnerIp    string         `json:"zlm_inner_ip" yaml:"zlm_inner_ip" mapstructure:"zlm_inner_ip"`
+	NogbPushMode  string         `json:"nogb_push_mode" yaml:"nogb_push_mode" mapstructure:"nogb_push_mode"` // 非国标摄像头推流方式 ffmpeg(默认) proxy
+	RecordFmp4    bool           `json:"record_fmp4" yaml:"record_fmp4" mapstructure:"record_fmp4"`          // 录像使用fmp4格式, 云端回放需要fmp4才能拼接为hls
 	LogLevel      string         `json:"logLevel" yaml:"logLevel" mapstructure:"logLevel"`
 	Stream        *Stream        `json:"stream" yaml:"stream" mapstructure:"stream"`
 	GB28181       *SysInfo       `json:"gb28181" yaml:"gb28181" mapstructure:"gb28181"`
 	Audio         *AudioConfig   `json:"audio" yaml:"audio" mapstructure:"audio"`
 	OpenApi       *OpenApiConfig `json:"openapi" yaml:"openapi" mapstructure:"openapi"`
 	AliYunOss     AliOSSConfig   `yaml:"aliyunoss"` // 阿里云OSS
+	// 断线事件缓存队列
+	Outbox *OutboxConfig `json:"outbox" yaml:"outbox" mapstructure:"outbox"`
+}
+
+// 断线事件缓存队列配置，未配置时使用默认值
+type OutboxConfig struct {
+	MaxSize    int `json:"max_size" yaml:"max_size" mapstructure:"max_size"`             // 最大缓存事件数，超出后丢弃最早的事件
+	MaxAgeHour int `json:"max_age_hour" yaml:"max_age_hour" mapstructure:"max_age_hour"` // 事件最长保留时间（小时）
 }
 
 // Stream Stream
@@ -0,0 +1,330 @@
+package m
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-sip/logger"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// 无需重启即可生效的配置项
+var clientHotConfigKeys = map[string]bool{
+	"logLevel":            true,
+	"gateway":             true,
+	"zlm_secret":          true,
+	"audio.input_device":  true,
+	"audio.output_device": true,
+}
+
+var clientLogLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+var configUpdateMu sync.Mutex
+
+// 保护可热更新的配置项, 其他配置项启动后只读
+var clientHotConfigMu sync.RWMutex
+
+// 远程修改配置结果
+type ConfigUpdateResult struct {
+	Applied         []string // 已生效的配置项
+	RestartRequired []string // 已写入配置文件, 重启后生效的配置项
+	Backup          string   // 修改前的配置文件备份
+}
+
+// 修改客户端配置, values的key为配置文件中以.分隔的路径, 如audio.input_device
+// 校验通过后备份并原子写入配置文件, 只修改变化的yaml节点, 保留注释, 可热更新的配置项立即生效
+func UpdateClientConfig(values map[string]any) (*ConfigUpdateResult, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("配置项不能为空")
+	}
+	configUpdateMu.Lock()
+	defer configUpdateMu.Unlock()
+
+	fields := map[string]reflect.Type{}
+	configFields(reflect.TypeOf(C_Config{}), "", fields)
+	for key, val := range values {
+		t, ok := fields[key]
+		if !ok {
+			return nil, fmt.Errorf("未知的配置项: %s", key)
+		}
+		v, err := convertConfigValue(t, val)
+		if err != nil {
+			return nil, fmt.Errorf("配置项%s: %v", key, err)
+		}
+		if err := validateClientConfig(key, v); err != nil {
+			return nil, err
+		}
+		values[key] = v
+	}
+
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		return nil, fmt.Errorf("未找到配置文件")
+	}
+	// 单独读取配置文件, 避免把环境变量覆盖的值写入文件
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err = patchConfigYaml(data, values)
+	if err != nil {
+		return nil, fmt.Errorf("修改配置文件失败: %v", err)
+	}
+	v := viper.New()
+	v.SetConfigType("yml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("配置校验失败: %v", err)
+	}
+	if err := v.Unmarshal(&C_Config{}); err != nil {
+		return nil, fmt.Errorf("配置校验失败: %v", err)
+	}
+
+	backup := configFile + ".bak"
+	if err := copyFile(configFile, backup); err != nil {
+		return nil, fmt.Errorf("备份配置文件失败: %v", err)
+	}
+	// 先写临时文件再重命名, 避免写入中断导致配置文件损坏
+	tmpFile := filepath.Join(filepath.Dir(configFile), ".config.tmp.yml")
+	if err := os.WriteFile(tmpFile, data, info.Mode().Perm()); err != nil {
+		os.Remove(tmpFile)
+		return nil, fmt.Errorf("写入配置文件失败: %v", err)
+	}
+	if err := os.Rename(tmpFile, configFile); err != nil {
+		os.Remove(tmpFile)
+		return nil, fmt.Errorf("写入配置文件失败: %v", err)
+	}
+
+	result := &ConfigUpdateResult{Backup: backup}
+	for key, val := range values {
+		if !clientHotConfigKeys[key] {
+			result.RestartRequired = append(result.RestartRequired, key)
+			continue
+		}
+		applyClientConfig(key, val.(string))
+		result.Applied = append(result.Applied, key)
+	}
+	return result, nil
+}
+
+// 收集配置结构体的叶子字段, key与配置文件中的路径一致
+func configFields(t reflect.Type, prefix string, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			configFields(ft, name, fields)
+			continue
+		}
+		fields[name] = ft
+	}
+}
+
+// 按字段类型转换json解析出的值
+func convertConfigValue(t reflect.Type, val any) (any, error) {
+	switch t.Kind() {
+	case reflect.String:
+		if s, ok := val.(string); ok {
+			return s, nil
+		}
+	case reflect.Bool:
+		if b, ok := val.(bool); ok {
+			return b, nil
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if f, ok := val.(float64); ok && f == math.Trunc(f) {
+			return int64(f), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := val.(float64); ok {
+			return f, nil
+		}
+	default:
+		return nil, fmt.Errorf("不支持远程修改")
+	}
+	return nil, fmt.Errorf("类型错误, 应为%s", t.Kind())
+}
+
+func validateClientConfig(key string, val any) error {
+	switch key {
+	case "logLevel":
+		if !clientLogLevels[strings.ToLower(val.(string))] {
+			return fmt.Errorf("日志级别只能是debug、info、warn、error")
+		}
+	case "gateway":
+		// 网关地址为ip:端口, 请求时拼接http://
+		s := val.(string)
+		if _, _, err := net.SplitHostPort(s); err != nil || strings.Contains(s, "/") {
+			return fmt.Errorf("网关地址格式错误, 应为ip:端口: %s", s)
+		}
+	case "zlm_secret", "audio.input_device", "audio.output_device":
+		if val.(string) == "" {
+			return fmt.Errorf("配置项%s不能为空", key)
+		}
+	}
+	return nil
+}
+
+func applyClientConfig(key, val string) {
+	clientHotConfigMu.Lock()
+	defer clientHotConfigMu.Unlock()
+	switch key {
+	case "logLevel":
+		CMConfig.LogLevel = val
+		logger.SetLogLevel(val)
+	case "gateway":
+		CMConfig.Gateway = val
+	case "zlm_secret":
+		CMConfig.ZlmSecret = val
+	case "audio.input_device", "audio.output_device":
+		// 复制后替换, 不修改读取方可能持有的旧配置
+		audio := AudioConfig{}
+		if CMConfig.Audio != nil {
+			audio = *CMConfig.Audio
+		}
+		if key == "audio.input_device" {
+			audio.InputDevice = val
+		} else {
+			audio.OutputDevice = val
+		}
+		CMConfig.Audio = &audio
+	}
+}
+
+// 网关地址, 可远程修改
+func ClientGateway() string {
+	clientHotConfigMu.RLock()
+	defer clientHotConfigMu.RUnlock()
+	return CMConfig.Gateway
+}
+
+// zlm接口密钥, 可远程修改
+func ClientZlmSecret() string {
+	clientHotConfigMu.RLock()
+	defer clientHotConfigMu.RUnlock()
+	return CMConfig.ZlmSecret
+}
+
+// 音频配置, 可远程修改, 未配置时返回nil
+func ClientAudio() *AudioConfig {
+	clientHotConfigMu.RLock()
+	defer clientHotConfigMu.RUnlock()
+	return CMConfig.Audio
+}
+
+// 修改yaml文档中的配置项, key不存在时新增
+func patchConfigYaml(data []byte, values map[string]any) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("配置文件为空")
+	}
+	for key, val := range values {
+		node := doc.Content[0]
+		for _, name := range strings.Split(key, ".") {
+			child, err := yamlMappingValue(node, name)
+			if err != nil {
+				return nil, fmt.Errorf("配置项%s: %v", key, err)
+			}
+			node = child
+		}
+		setYamlScalar(node, val)
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 查找mapping节点中key对应的值节点, 不存在时新增空值
+func yamlMappingValue(node *yaml.Node, name string) (*yaml.Node, error) {
+	// 空值(如 audio: )按mapping处理
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		node.Kind = yaml.MappingNode
+		node.Tag = "!!map"
+		node.Value = ""
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s的上级不是对象", name)
+	}
+	// viper的key不区分大小写
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, name) {
+			return node.Content[i+1], nil
+		}
+	}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, value)
+	return value, nil
+}
+
+// 修改标量节点的值, 保留节点上的注释
+func setYamlScalar(node *yaml.Node, val any) {
+	tag := "!!str"
+	value := fmt.Sprint(val)
+	switch v := val.(type) {
+	case bool:
+		tag = "!!bool"
+	case int64:
+		tag = "!!int"
+	case float64:
+		tag = "!!float"
+		value = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	if node.Kind != yaml.ScalarNode || node.Tag != tag {
+		node.Style = 0
+	}
+	node.Kind = yaml.ScalarNode
+	node.Tag = tag
+	node.Value = value
+	node.Content = nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
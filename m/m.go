@@ -38,6 +38,11 @@ const (
 	SetVolume          = "set_volume"
 	CloseAudio         = "close_audio"
 	IpcStreamReset     = "ipc_stream_reset"
+	CancelCommand      = "cancel_command" // 取消执行中的命令
+	ReconnectTo        = "reconnect_to"   // 通知客户端重连到指定sip服务
+	Diagnostics        = "diagnostics"    // 采集设备诊断信息
+	ConfigUpdate       = "config_update"  // 远程修改客户端配置
+	LogSubscribe       = "log_subscribe"  // 订阅客户端实时日志
 )
 
 const (
@@ -57,6 +62,7 @@ const (
 	MsgID_SetVolume          = 13
 	MsgID_DeviceOTA          = 14
 	MsgID_IpcStreamReset     = 15
+	MsgID_CancelCommand      = 16
 )
 
 const (
@@ -30,20 +30,37 @@ type KafkaConfig struct {
 
 // Config Config
 type S_Config struct {
-	SipID      string      `json:"sip_id" yaml:"sip_id" mapstructure:"sip_id"`
-	SipInnerIp string      `json:"sip_inner_ip" yaml:"sip_inner_ip" mapstructure:"sip_inner_ip"`
-	SipOutIp   string      `json:"sip_out_ip" yaml:"sip_out_ip" mapstructure:"sip_out_ip"`
-	API        string      `json:"api" yaml:"api" mapstructure:"api"`
-	SipPort    string      `json:"sip_port" yaml:"sip_port" mapstructure:"sip_port"`
-	TcpIp      string      `json:"tcp_ip" yaml:"tcp_ip" mapstructure:"tcp_ip"`
-	TcpPort    string      `json:"tcp_port" yaml:"tcp_port" mapstructure:"tcp_port"`
-	UDP        string      `json:"udp" yaml:"udp" mapstructure:"udp"`
-	Secret     string      `json:"secret" yaml:"secret" mapstructure:"secret"`
-	Sign       string      `json:"sign" yaml:"sign" mapstructure:"sign"`
-	DataBase   RedisConfig `json:"database" yaml:"database" mapstructure:"database"`
-	KafkaCfg   KafkaConfig `json:"kafka" yaml:"kafka" mapstructure:"kafka"`
-	MqttConfig MqttConfig  `json:"mqtt" yaml:"mqtt" mapstructure:"mqtt"`
-	LogLevel   string      `json:"logLevel" yaml:"logLevel" mapstructure:"logLevel"`
+	SipID          string               `json:"sip_id" yaml:"sip_id" mapstructure:"sip_id"`
+	SipInnerIp     string               `json:"sip_inner_ip" yaml:"sip_inner_ip" mapstructure:"sip_inner_ip"`
+	SipOutIp       string               `json:"sip_out_ip" yaml:"sip_out_ip" mapstructure:"sip_out_ip"`
+	API            string               `json:"api" yaml:"api" mapstructure:"api"`
+	SipPort        string               `json:"sip_port" yaml:"sip_port" mapstructure:"sip_port"`
+	TcpIp          string               `json:"tcp_ip" yaml:"tcp_ip" mapstructure:"tcp_ip"`
+	TcpPort        string               `json:"tcp_port" yaml:"tcp_port" mapstructure:"tcp_port"`
+	UDP            string               `json:"udp" yaml:"udp" mapstructure:"udp"`
+	Secret         string               `json:"secret" yaml:"secret" mapstructure:"secret"`
+	Sign           string               `json:"sign" yaml:"sign" mapstructure:"sign"`
+	StreamAuth     bool                 `json:"stream_auth" yaml:"stream_auth" mapstructure:"stream_auth"`             // 是否开启播放token鉴权
+	StreamAuthIps  []string             `json:"stream_auth_ips" yaml:"stream_auth_ips" mapstructure:"stream_auth_ips"` // 不需要播放token的内部服务ip, zlm节点自身无需配置
+	DataBase       RedisConfig          `json:"database" yaml:"database" mapstructure:"database"`
+	KafkaCfg       KafkaConfig          `json:"kafka" yaml:"kafka" mapstructure:"kafka"`
+	MqttConfig     MqttConfig           `json:"mqtt" yaml:"mqtt" mapstructure:"mqtt"`
+	AliYunOss      AliOSSConfig         `json:"aliyunoss" yaml:"aliyunoss" mapstructure:"aliyunoss"` // 阿里云OSS, 用于存储截图
+	Snapshot       SnapshotConfig       `json:"snapshot" yaml:"snapshot" mapstructure:"snapshot"`
+	StreamRecovery StreamRecoveryConfig `json:"streamRecovery" yaml:"streamRecovery" mapstructure:"streamRecovery"`
+	LogLevel       string               `json:"logLevel" yaml:"logLevel" mapstructure:"logLevel"`
+}
+
+// ipc截图配置
+type SnapshotConfig struct {
+	Interval int `json:"interval" yaml:"interval" mapstructure:"interval"` // 在线ipc封面刷新间隔, 单位秒, 0表示不刷新
+	TTL      int `json:"ttl" yaml:"ttl" mapstructure:"ttl"`                // 截图缓存有效期, 单位秒
+}
+
+// 流中断自动恢复配置
+type StreamRecoveryConfig struct {
+	MaxRetry int `json:"maxRetry" yaml:"maxRetry" mapstructure:"maxRetry"` // 最大重新点播次数, 0表示不自动恢复
+	Backoff  int `json:"backoff" yaml:"backoff" mapstructure:"backoff"`    // 首次重试间隔, 单位秒, 之后每次翻倍
 }
 
 var SMConfig *S_Config
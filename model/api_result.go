@@ -54,6 +54,12 @@ type IotNotGbIpcInfoResult struct {
 	Result []*IotNotGbIpcInfo `json:"result"`
 }
 
+type RecordPlanListResult struct {
+	Code   string        `json:"code"`
+	Status int           `json:"status"`
+	Result []*RecordPlan `json:"result"`
+}
+
 type PageResult struct {
 	Total    int64 `json:"total"`    // 总记录数
 	Page     int   `json:"page"`     // 当前页码
@@ -0,0 +1,14 @@
+package model
+
+import "encoding/json"
+
+// 批量下发命令请求
+type BulkJobCreateReq struct {
+	DeviceIds   []string        `json:"device_ids"`
+	StoreNo     string          `json:"store_no"`
+	RegionCode  string          `json:"region_code"`
+	Method      string          `json:"method" binding:"required"`
+	Params      json.RawMessage `json:"params"`      // 命令参数, DeviceID会替换为各目标设备id
+	Concurrency int             `json:"concurrency"` // 并发数, 默认10
+	TimeoutSec  int             `json:"timeout_sec"` // 单个设备超时时间(秒), 默认10
+}
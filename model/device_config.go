@@ -0,0 +1,7 @@
+package model
+
+// 远程修改设备配置请求
+type DeviceConfigUpdateReq struct {
+	DeviceId string         `json:"device_id" binding:"required"`
+	Values   map[string]any `json:"values" binding:"required"`
+}
@@ -31,6 +31,10 @@ type IpcInfo struct {
 	NogbUsername string `json:"nogbUsername"`
 	// 非国标摄像头密码
 	NogbPassword string `json:"nogbPassword"`
+	// 封面截图临时访问地址
+	CoverUrl string `json:"coverUrl,omitempty" gorm:"-"`
+	// 封面截图时间
+	CoverTime int64 `json:"coverTime,omitempty" gorm:"-"`
 }
 
 type IotNotGbIpcInfo struct {
@@ -0,0 +1,10 @@
+package model
+
+// ipc截图信息, 截图文件存储在oss, 访问地址使用时临时签发
+type IpcSnapshot struct {
+	IpcId    string `json:"ipcId"`
+	StreamId string `json:"streamId"` // 截图使用的流
+	OssKey   string `json:"ossKey"`
+	SnapTime int64  `json:"snapTime"` // 截图时间戳, 单位秒
+	Url      string `json:"url"`      // 临时访问地址, 不缓存
+}
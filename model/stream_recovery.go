@@ -0,0 +1,35 @@
+package model
+
+// ipc流事件状态
+const (
+	IpcStreamInterrupted = "interrupted" // 流中断, 开始自动恢复
+	IpcStreamRecovered   = "recovered"   // 流已恢复
+	IpcStreamAbandoned   = "abandoned"   // 超过重试次数, 放弃恢复
+)
+
+// 中断待恢复的流
+type StreamRecovery struct {
+	MediaServerId string `json:"mediaServerId"`
+	App           string `json:"app"`
+	Stream        string `json:"stream"`
+	IpcId         string `json:"ipcId"`
+	DeviceId      string `json:"deviceId"`
+	Mode          int    `json:"mode"`      // 点播时的rtp传输模式, 重新点播时使用
+	Reason        string `json:"reason"`    // 需要恢复的原因, 如有人观看、录像中、ai分析中
+	Attempts      int    `json:"attempts"`  // 已重新点播次数
+	NextRetry     int64  `json:"nextRetry"` // 下次重新点播时间戳
+	LastError     string `json:"lastError"`
+	StartTime     int64  `json:"startTime"` // 流中断时间戳
+}
+
+// ipc流中断和恢复事件
+type IpcStreamAlertEvent struct {
+	IpcId         string `json:"ipcId"`
+	DeviceId      string `json:"deviceId"`
+	MediaServerId string `json:"mediaServerId"`
+	Stream        string `json:"stream"`
+	Status        string `json:"status"`   // 事件状态 interrupted recovered abandoned
+	Reason        string `json:"reason"`   // 中断时为需要恢复的原因, 放弃时为最后一次错误
+	Attempts      int    `json:"attempts"` // 已重新点播次数
+	Time          int64  `json:"time"`
+}
@@ -0,0 +1,46 @@
+package model
+
+// 流会话, 由zlm流注册事件和定时采集的流列表汇总
+type StreamSession struct {
+	MediaServerId string  `json:"mediaServerId"`
+	App           string  `json:"app"`
+	Stream        string  `json:"stream"`
+	IpcId         string  `json:"ipcId"`
+	DeviceId      string  `json:"deviceId"`
+	Mode          int     `json:"mode"`          // 点播时的rtp传输模式, 与点播参数mode一致
+	OriginType    int     `json:"originType"`    // 产生源类型, 与zlm一致
+	OriginTypeStr string  `json:"originTypeStr"` // 产生源类型描述, 如rtp_push rtsp_push pull
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	VideoCodec    string  `json:"videoCodec"`
+	AudioCodec    string  `json:"audioCodec"`
+	Fps           float64 `json:"fps"`
+	Bitrate       int64   `json:"bitrate"`    // 码率, 单位bit/s
+	Readers       int     `json:"readers"`    // 当前观看人数
+	Recording     bool    `json:"recording"`  // 是否正在mp4录制
+	StartTime     int64   `json:"startTime"`  // 流注册时间戳, 单位秒
+	UpdateTime    int64   `json:"updateTime"` // 最后采集时间戳, 单位秒
+	PlayCount     int64   `json:"playCount"`  // 已结束的播放次数
+	PlayBytes     int64   `json:"playBytes"`  // 已结束的播放消耗流量, 单位字节
+}
+
+// 流会话采样点
+type StreamSample struct {
+	Time    int64 `json:"time"`
+	Bitrate int64 `json:"bitrate"`
+	Readers int   `json:"readers"`
+}
+
+// 流的播放者
+type StreamPlayer struct {
+	Ip       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// 流会话详情
+type StreamSessionDetail struct {
+	StreamSession
+	Series  []StreamSample `json:"series"`  // 近期码率和观看人数
+	Players []StreamPlayer `json:"players"` // 当前播放者
+}
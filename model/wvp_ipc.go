@@ -41,7 +41,8 @@ type IpcRecordListQueryReq struct {
 type IpcResetMergeStreamReq struct {
 	ZlmDomain string `json:"zlmDomain" binding:"required"`
 	DeviceId  string `json:"deviceId" binding:"required"`
-	IpcList   string `json:"ipcList" binding:"required"` // ipc列表
+	IpcList   string `json:"ipcList"`  // ipc列表, 使用布局时填充布局中未绑定ipc的画面
+	LayoutId  int64  `json:"layoutId"` // 合屏布局id, 为空时按ipc列表横向拼接
 }
 
 type IpcPlaybackRecordData struct {
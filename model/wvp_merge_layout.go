@@ -0,0 +1,64 @@
+package model
+
+// 合屏布局模板
+// zlm拼接流只支持网格布局, 画中画用跨行列的大画面加边角的小画面实现
+type MergeLayout struct {
+	ID      int64             `json:"id"`
+	Name    string            `json:"name"`    // 布局名称
+	Width   int               `json:"width"`   // 输出分辨率宽
+	Height  int               `json:"height"`  // 输出分辨率高
+	Fps     int               `json:"fps"`     // 输出帧率
+	Row     int               `json:"row"`     // 网格行数
+	Col     int               `json:"col"`     // 网格列数
+	GapV    float64           `json:"gapv"`    // 垂直间距, 占输出高度的比例
+	GapH    float64           `json:"gaph"`    // 水平间距, 占输出宽度的比例
+	Tiles   []MergeLayoutTile `json:"tiles"`   // 画面列表
+	Remarks string            `json:"remarks"` // 备注
+}
+
+// 合屏画面, 从(Row, Col)开始占用RowSpan行ColSpan列
+type MergeLayoutTile struct {
+	Row        int    `json:"row"`
+	Col        int    `json:"col"`
+	RowSpan    int    `json:"rowSpan"`    // 占用行数, 默认1
+	ColSpan    int    `json:"colSpan"`    // 占用列数, 默认1
+	IpcId      string `json:"ipcId"`      // 绑定的ipc, 为空时按顺序使用sub_ipc中的ipc
+	StreamType int    `json:"streamType"` // 码流 0 标清 1 高清
+}
+
+// 合屏布局保存参数
+type MergeLayoutSaveDTO struct {
+	Name    string            `json:"name" binding:"required"`
+	Width   int               `json:"width" binding:"required,min=16"`
+	Height  int               `json:"height" binding:"required,min=16"`
+	Fps     int               `json:"fps"`
+	Row     int               `json:"row" binding:"required,min=1,max=16"`
+	Col     int               `json:"col" binding:"required,min=1,max=16"`
+	GapV    float64           `json:"gapv"`
+	GapH    float64           `json:"gaph"`
+	Tiles   []MergeLayoutTile `json:"tiles" binding:"required,min=1"`
+	Remarks string            `json:"remarks"`
+}
+
+// FromMergeLayoutSaveDTO 将 DTO 转为实体
+func FromMergeLayoutSaveDTO(dto *MergeLayoutSaveDTO) *MergeLayout {
+	return &MergeLayout{
+		Name:    dto.Name,
+		Width:   dto.Width,
+		Height:  dto.Height,
+		Fps:     dto.Fps,
+		Row:     dto.Row,
+		Col:     dto.Col,
+		GapV:    dto.GapV,
+		GapH:    dto.GapH,
+		Tiles:   dto.Tiles,
+		Remarks: dto.Remarks,
+	}
+}
+
+// 切换合屏布局参数
+type MergeLayoutApplyReq struct {
+	DeviceId string `json:"deviceId" binding:"required"` // 设备id, 即合屏流id
+	LayoutId int64  `json:"layoutId" binding:"required"` // 布局id
+	IpcList  string `json:"ipcList"`                     // 填充未绑定ipc的画面, 多个ipc用_分隔
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 推流目标协议
+const (
+	PushProtocolRtmp = "rtmp"
+	PushProtocolRtsp = "rtsp"
+	PushProtocolSrt  = "srt"
+)
+
+// 推流状态
+const (
+	PushStatusStopped = "stopped" // 未启用或不在推流时间段
+	PushStatusRunning = "running" // 推流中
+	PushStatusFailed  = "failed"  // 推流失败, 自动重启的目标等待重试
+)
+
+// 推流目标, 将ipc的流转推到第三方直播平台
+type PushTarget struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	IpcId       string            `json:"ipcId"`
+	DeviceId    string            `json:"deviceId"`    // ipc关联的设备id
+	Protocol    string            `json:"protocol"`    // 推流协议 rtmp rtsp srt
+	DstUrl      string            `json:"dstUrl"`      // 推流地址
+	StreamType  int               `json:"streamType"`  // 码流 0 标清 1 高清
+	Windows     []RecordWindow    `json:"windows"`     // 每周推流时间段, 为空表示全天推流
+	AutoRestart bool              `json:"autoRestart"` // 推流失败或断开后是否自动重启
+	Enable      bool              `json:"enable"`
+	Remarks     string            `json:"remarks"`
+	Version     int64             `json:"version"`          // 版本号, 每次更新加1, 变化后server重启推流
+	UpdateTime  int64             `json:"updateTime"`       // 更新时间戳
+	Status      *PushTargetStatus `json:"status,omitempty"` // 推流状态, 由server上报
+}
+
+// 推流状态, server对账时更新
+type PushTargetStatus struct {
+	Status        string `json:"status"` // 推流状态 stopped running failed
+	MediaServerId string `json:"mediaServerId"`
+	StreamId      string `json:"streamId"`
+	PusherKey     string `json:"pusherKey"`  // zlm推流代理key
+	Version       int64  `json:"version"`    // 推流使用的目标版本号
+	StartTime     int64  `json:"startTime"`  // 本次推流开始时间戳
+	RetryCount    int    `json:"retryCount"` // 连续失败次数
+	NextRetry     int64  `json:"nextRetry"`  // 下次重试时间戳
+	LastError     string `json:"lastError"`
+	UpdateTime    int64  `json:"updateTime"`
+}
+
+// 推流目标保存参数
+type PushTargetSaveDTO struct {
+	Name        string         `json:"name" binding:"required"`
+	IpcId       string         `json:"ipcId" binding:"required"`
+	Protocol    string         `json:"protocol" binding:"required,oneof=rtmp rtsp srt"`
+	DstUrl      string         `json:"dstUrl" binding:"required"`
+	StreamType  int            `json:"streamType" binding:"oneof=0 1"`
+	Windows     []RecordWindow `json:"windows"`
+	AutoRestart bool           `json:"autoRestart"`
+	Enable      bool           `json:"enable"`
+	Remarks     string         `json:"remarks"`
+}
+
+// FromPushTargetSaveDTO 将 DTO 转为实体
+func FromPushTargetSaveDTO(dto *PushTargetSaveDTO) *PushTarget {
+	return &PushTarget{
+		Name:        dto.Name,
+		IpcId:       dto.IpcId,
+		Protocol:    dto.Protocol,
+		DstUrl:      strings.TrimSpace(dto.DstUrl),
+		StreamType:  dto.StreamType,
+		Windows:     dto.Windows,
+		AutoRestart: dto.AutoRestart,
+		Enable:      dto.Enable,
+		Remarks:     dto.Remarks,
+		Version:     1,
+		UpdateTime:  time.Now().Unix(),
+	}
+}
+
+// 校验推流地址和时间段
+func (t *PushTarget) Validate() error {
+	prefix := t.Protocol + "://"
+	if t.Protocol == PushProtocolRtmp && strings.HasPrefix(t.DstUrl, "rtmps://") {
+		prefix = "rtmps://"
+	}
+	if !strings.HasPrefix(t.DstUrl, prefix) {
+		return fmt.Errorf("推流地址必须以%s开头", prefix)
+	}
+	return validateRecordWindows(t.Windows)
+}
+
+// 当前时间是否需要推流
+func (t *PushTarget) ShouldPush(now time.Time) bool {
+	if !t.Enable {
+		return false
+	}
+	return len(t.Windows) == 0 || inRecordWindows(t.Windows, now)
+}
+
+// 推流使用的流id
+func (t *PushTarget) StreamId() string {
+	return fmt.Sprintf("%s_%d", t.IpcId, t.StreamType)
+}
+
+// zlm推流代理的源流协议, srt推流使用ts
+func (t *PushTarget) SourceSchema() string {
+	switch t.Protocol {
+	case PushProtocolRtmp:
+		return "rtmp"
+	case PushProtocolSrt:
+		return "ts"
+	}
+	return "rtsp"
+}
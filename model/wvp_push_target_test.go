@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPushTargetValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  PushTarget
+		wantErr bool
+	}{
+		{"rtmp", PushTarget{Protocol: PushProtocolRtmp, DstUrl: "rtmp://live.example.com/app/key"}, false},
+		{"rtmps", PushTarget{Protocol: PushProtocolRtmp, DstUrl: "rtmps://live.example.com/app/key"}, false},
+		{"rtsp", PushTarget{Protocol: PushProtocolRtsp, DstUrl: "rtsp://10.0.0.1/live"}, false},
+		{"srt", PushTarget{Protocol: PushProtocolSrt, DstUrl: "srt://10.0.0.1:9000?streamid=live"}, false},
+		{"协议与地址不一致", PushTarget{Protocol: PushProtocolRtsp, DstUrl: "rtmp://live.example.com/app/key"}, true},
+		{"rtsp不支持rtmps", PushTarget{Protocol: PushProtocolRtsp, DstUrl: "rtmps://live.example.com/app/key"}, true},
+		{"地址为空", PushTarget{Protocol: PushProtocolRtmp}, true},
+		{"合法时间段", PushTarget{Protocol: PushProtocolRtmp, DstUrl: "rtmp://live.example.com/app/key", Windows: []RecordWindow{
+			{Weekday: 1, Start: "08:00", End: "18:00"},
+		}}, false},
+		{"时间段错误", PushTarget{Protocol: PushProtocolRtmp, DstUrl: "rtmp://live.example.com/app/key", Windows: []RecordWindow{
+			{Weekday: 1, Start: "18:00", End: "08:00"},
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.target.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPushTargetShouldPush(t *testing.T) {
+	// 2024-01-01为周一
+	monday := time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local)
+	windows := []RecordWindow{{Weekday: 1, Start: "08:00", End: "10:00"}}
+	tests := []struct {
+		name   string
+		target PushTarget
+		now    time.Time
+		want   bool
+	}{
+		{"未启用", PushTarget{Windows: windows}, monday, false},
+		{"全天推流", PushTarget{Enable: true}, monday, true},
+		{"时间段内", PushTarget{Enable: true, Windows: windows}, monday, true},
+		{"时间段外", PushTarget{Enable: true, Windows: windows}, monday.Add(time.Hour), false},
+		{"其他星期", PushTarget{Enable: true, Windows: windows}, monday.AddDate(0, 0, 1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.ShouldPush(tt.now); got != tt.want {
+				t.Fatalf("ShouldPush(%s) = %v, want %v", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushTargetSourceSchema(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     string
+	}{
+		{PushProtocolRtmp, "rtmp"},
+		{PushProtocolRtsp, "rtsp"},
+		{PushProtocolSrt, "ts"},
+	}
+	for _, tt := range tests {
+		target := PushTarget{Protocol: tt.protocol}
+		if got := target.SourceSchema(); got != tt.want {
+			t.Errorf("SourceSchema(%s) = %s, want %s", tt.protocol, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 录像计划模式
+const (
+	RecordPlanModeContinuous = "continuous" // 全天录像
+	RecordPlanModeSchedule   = "schedule"   // 按每周时间段录像
+	RecordPlanModeEvent      = "event"      // 仅AI事件触发录像
+)
+
+// 计划录像文件目录, 与AI事件录像的模型类别目录区分
+const RecordPlanFileType = "plan"
+
+// ipc录像计划, 每个ipc一个计划
+type RecordPlan struct {
+	ID            int64          `json:"id"`
+	IpcId         string         `json:"ipcId"`
+	DeviceId      string         `json:"deviceId"`      // ipc关联的设备id
+	Mode          string         `json:"mode"`          // 录像模式 continuous schedule event
+	StreamType    int            `json:"streamType"`    // 码流 0 标清 1 高清
+	Windows       []RecordWindow `json:"windows"`       // 录像时间段, schedule模式有效
+	RetentionDays int            `json:"retentionDays"` // 录像保留天数, 0表示不删除
+	Enable        bool           `json:"enable"`
+	Remarks       string         `json:"remarks"`
+}
+
+// 每周录像时间段, 时间格式HH:MM, 不支持跨天, 跨天时拆分为两个时间段
+type RecordWindow struct {
+	Weekday int    `json:"weekday"` // 0周日 1-6周一至周六
+	Start   string `json:"start"`   // 开始时间, 如08:00
+	End     string `json:"end"`     // 结束时间, 如18:00, 24:00表示当天结束
+}
+
+// 录像计划保存参数
+type RecordPlanSaveDTO struct {
+	IpcId         string         `json:"ipcId" binding:"required"`
+	Mode          string         `json:"mode" binding:"required,oneof=continuous schedule event"`
+	StreamType    int            `json:"streamType" binding:"oneof=0 1"`
+	Windows       []RecordWindow `json:"windows"`
+	RetentionDays int            `json:"retentionDays" binding:"min=0,max=3650"`
+	Enable        bool           `json:"enable"`
+	Remarks       string         `json:"remarks"`
+}
+
+// FromRecordPlanSaveDTO 将 DTO 转为实体
+func FromRecordPlanSaveDTO(dto *RecordPlanSaveDTO) *RecordPlan {
+	return &RecordPlan{
+		IpcId:         dto.IpcId,
+		Mode:          dto.Mode,
+		StreamType:    dto.StreamType,
+		Windows:       dto.Windows,
+		RetentionDays: dto.RetentionDays,
+		Enable:        dto.Enable,
+		Remarks:       dto.Remarks,
+	}
+}
+
+// 校验录像时间段
+func (p *RecordPlan) Validate() error {
+	if p.Mode != RecordPlanModeSchedule {
+		return nil
+	}
+	if len(p.Windows) == 0 {
+		return fmt.Errorf("录像时间段不能为空")
+	}
+	return validateRecordWindows(p.Windows)
+}
+
+// 校验每周时间段
+func validateRecordWindows(windows []RecordWindow) error {
+	for i, w := range windows {
+		if w.Weekday < 0 || w.Weekday > 6 {
+			return fmt.Errorf("第%d个时间段星期错误", i+1)
+		}
+		start, err := parseClockMinute(w.Start)
+		if err != nil {
+			return fmt.Errorf("第%d个时间段开始时间错误", i+1)
+		}
+		end, err := parseClockMinute(w.End)
+		if err != nil {
+			return fmt.Errorf("第%d个时间段结束时间错误", i+1)
+		}
+		if start >= end {
+			return fmt.Errorf("第%d个时间段开始时间必须小于结束时间", i+1)
+		}
+	}
+	return nil
+}
+
+// 当前时间是否需要计划录像, event模式由AI事件触发录像, 不需要计划录像
+func (p *RecordPlan) ShouldRecord(now time.Time) bool {
+	if !p.Enable {
+		return false
+	}
+	switch p.Mode {
+	case RecordPlanModeContinuous:
+		return true
+	case RecordPlanModeSchedule:
+		return inRecordWindows(p.Windows, now)
+	}
+	return false
+}
+
+// 当前时间是否在时间段内
+func inRecordWindows(windows []RecordWindow, now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	for _, w := range windows {
+		if w.Weekday != int(now.Weekday()) {
+			continue
+		}
+		start, err1 := parseClockMinute(w.Start)
+		end, err2 := parseClockMinute(w.End)
+		if err1 == nil && err2 == nil && minute >= start && minute < end {
+			return true
+		}
+	}
+	return false
+}
+
+// HH:MM转为当天的分钟数
+func parseClockMinute(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("时间格式错误")
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil {
+		return 0, err
+	}
+	if h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("时间格式错误")
+	}
+	return h*60 + m, nil
+}
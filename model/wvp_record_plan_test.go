@@ -0,0 +1,96 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClockMinute(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"08:30", 510, false},
+		{"23:59", 1439, false},
+		{"24:00", 1440, false},
+		{"24:01", 0, true},
+		{"25:00", 0, true},
+		{"12:60", 0, true},
+		{"-1:00", 0, true},
+		{"0800", 0, true},
+		{"aa:00", 0, true},
+		{"08:bb", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseClockMinute(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseClockMinute(%q) = %d, %v, want %d, wantErr %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRecordPlanShouldRecord(t *testing.T) {
+	// 2024-01-01为周一
+	monday := func(hour, min int) time.Time {
+		return time.Date(2024, 1, 1, hour, min, 0, 0, time.Local)
+	}
+	windows := []RecordWindow{
+		{Weekday: 1, Start: "08:00", End: "12:00"},
+		{Weekday: 1, Start: "22:00", End: "24:00"},
+		{Weekday: 2, Start: "00:00", End: "06:00"},
+	}
+	tests := []struct {
+		name string
+		plan RecordPlan
+		now  time.Time
+		want bool
+	}{
+		{"未启用", RecordPlan{Mode: RecordPlanModeContinuous}, monday(9, 0), false},
+		{"全天录像", RecordPlan{Mode: RecordPlanModeContinuous, Enable: true}, monday(3, 0), true},
+		{"事件录像不计划录像", RecordPlan{Mode: RecordPlanModeEvent, Enable: true}, monday(9, 0), false},
+		{"时间段开始", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: windows}, monday(8, 0), true},
+		{"时间段内", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: windows}, monday(11, 59), true},
+		{"时间段结束不包含", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: windows}, monday(12, 0), false},
+		{"时间段之前", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: windows}, monday(7, 59), false},
+		{"到当天结束", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: windows}, monday(23, 59), true},
+		{"跨天拆分的时间段", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: windows}, monday(24+1, 0), true},
+		{"其他星期", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: windows}, monday(24+9, 0), false},
+		{"时间段为空", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true}, monday(9, 0), false},
+		{"忽略格式错误的时间段", RecordPlan{Mode: RecordPlanModeSchedule, Enable: true, Windows: []RecordWindow{
+			{Weekday: 1, Start: "8", End: "12:00"},
+		}}, monday(9, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.plan.ShouldRecord(tt.now); got != tt.want {
+				t.Fatalf("ShouldRecord(%s) = %v, want %v", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordPlanValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    RecordPlan
+		wantErr bool
+	}{
+		{"全天录像不校验时间段", RecordPlan{Mode: RecordPlanModeContinuous}, false},
+		{"合法时间段", RecordPlan{Mode: RecordPlanModeSchedule, Windows: []RecordWindow{{Weekday: 0, Start: "00:00", End: "24:00"}}}, false},
+		{"时间段为空", RecordPlan{Mode: RecordPlanModeSchedule}, true},
+		{"星期错误", RecordPlan{Mode: RecordPlanModeSchedule, Windows: []RecordWindow{{Weekday: 7, Start: "08:00", End: "09:00"}}}, true},
+		{"开始时间错误", RecordPlan{Mode: RecordPlanModeSchedule, Windows: []RecordWindow{{Weekday: 1, Start: "8:60", End: "09:00"}}}, true},
+		{"结束时间错误", RecordPlan{Mode: RecordPlanModeSchedule, Windows: []RecordWindow{{Weekday: 1, Start: "08:00", End: "24:30"}}}, true},
+		{"开始时间等于结束时间", RecordPlan{Mode: RecordPlanModeSchedule, Windows: []RecordWindow{{Weekday: 1, Start: "08:00", End: "08:00"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.plan.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// 录像触发方式
+const (
+	RecordTriggerAi   = "ai"   // AI事件触发
+	RecordTriggerPlan = "plan" // 录像计划
+)
+
+const (
+	RecordIndexCacheDays = 7  // 回放索引redis缓存天数, 更早的录像从mysql查询
+	RecordQueryMaxDays   = 31 // 录像查询最大跨度, 单位天
+	recordGapTolerance   = 3  // 相邻录像间隔不超过该秒数时不算缺失
+	recordDayDateLayout  = "2006-01-02"
+)
+
+// 录像片段, zlm每个mp4切片一条记录
+type RecordSegment struct {
+	ID          int64   `json:"id"`
+	IpcId       string  `json:"ipcId"`
+	DeviceId    string  `json:"deviceId"`
+	StreamId    string  `json:"streamId"`
+	RecordType  string  `json:"recordType"`  // 录像类型, 即录像目录, 与回放索引的类型一致
+	TriggerType string  `json:"triggerType"` // 触发方式 ai plan
+	AiClass     string  `json:"aiClass"`     // ai模型类别, 计划录像为空
+	StartTime   int64   `json:"startTime"`   // 开始时间戳, 单位秒
+	EndTime     int64   `json:"endTime"`     // 结束时间戳, 单位秒
+	Duration    float64 `json:"duration"`    // 时长, 单位秒
+	FileSize    int64   `json:"fileSize"`    // 文件大小, 单位字节
+	OssKey      string  `json:"ossKey"`
+	FileUrl     string  `json:"fileUrl"`
+	Checksum    string  `json:"checksum"` // 文件md5
+	InitSize    int64   `json:"initSize"` // fmp4初始化段大小, 0表示非fmp4, 非fmp4不能拼接为hls
+}
+
+// 转为回放索引数据
+func (s *RecordSegment) PlaybackData() IpcPlaybackRecordData {
+	ai_model_type := s.AiClass
+	if s.TriggerType == RecordTriggerPlan {
+		ai_model_type = RecordPlanFileType
+	}
+	return IpcPlaybackRecordData{
+		StartTime:   s.StartTime,
+		EndTime:     s.EndTime,
+		FileUrl:     s.FileUrl,
+		AiModelType: ai_model_type,
+	}
+}
+
+// 解析录像类型, 为空或all时返回nil表示全部类型
+func ParseRecordTypes(t string) []string {
+	if t == "" || t == "all" {
+		return nil
+	}
+	types := []string{}
+	for _, s := range strings.Split(t, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			types = append(types, s)
+		}
+	}
+	return types
+}
+
+// 录像片段查询参数
+type RecordSegmentQueryReq struct {
+	IpcId string `json:"ipcId" binding:"required"`
+	Type  string `json:"type"`                                 // 录像类型, 多个用逗号分隔, 为空或all查询全部
+	Start int64  `json:"start" binding:"required,min=1"`       // 开始时间戳, 单位秒
+	End   int64  `json:"end" binding:"required,gtfield=Start"` // 结束时间戳, 单位秒
+	Page  int    `json:"page" binding:"required,min=1"`
+	Size  int    `json:"size" binding:"required,min=1,max=1000"`
+}
+
+// 每天的录像覆盖情况
+type RecordDayCoverage struct {
+	Date     string  `json:"date"`     // 日期
+	Duration int64   `json:"duration"` // 有录像的时长, 单位秒
+	Coverage float64 `json:"coverage"` // 有录像的时长占查询时间的比例
+	Count    int     `json:"count"`    // 录像片段数
+}
+
+// 录像片段分页查询结果
+type RecordSegmentPageResult struct {
+	PageResult
+	Gaps []TimeItem          `json:"gaps"` // 查询时间内没有录像的时间段
+	Days []RecordDayCoverage `json:"days"` // 每天的录像覆盖情况
+}
+
+// 根据录像片段计算查询时间内的缺失时间段和每天的覆盖情况
+func BuildRecordTimeline(segments []*RecordSegment, start, end int64) ([]TimeItem, []RecordDayCoverage) {
+	// 合并重叠的片段, 不同码流和类型的录像可能重叠
+	sorted := make([]TimeItem, 0, len(segments))
+	for _, s := range segments {
+		item := TimeItem{Start: maxInt64(s.StartTime, start), End: minInt64(s.EndTime, end)}
+		if item.Start < item.End {
+			sorted = append(sorted, item)
+		}
+	}
+	merged := mergeTimeItems(sorted)
+
+	gaps := []TimeItem{}
+	cursor := start
+	for _, item := range merged {
+		if item.Start-cursor > recordGapTolerance {
+			gaps = append(gaps, TimeItem{Start: cursor, End: item.Start})
+		}
+		cursor = maxInt64(cursor, item.End)
+	}
+	if end-cursor > recordGapTolerance {
+		gaps = append(gaps, TimeItem{Start: cursor, End: end})
+	}
+
+	days := []RecordDayCoverage{}
+	for day := time.Unix(start, 0); day.Unix() < end; {
+		day_start := maxInt64(day.Unix(), start)
+		next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location())
+		day_end := minInt64(next.Unix(), end)
+		coverage := RecordDayCoverage{Date: day.Format(recordDayDateLayout)}
+		for _, item := range merged {
+			if s, e := maxInt64(item.Start, day_start), minInt64(item.End, day_end); s < e {
+				coverage.Duration += e - s
+			}
+		}
+		for _, s := range segments {
+			if s.StartTime < day_end && s.EndTime > day_start {
+				coverage.Count++
+			}
+		}
+		coverage.Coverage = float64(coverage.Duration) / float64(day_end-day_start)
+		days = append(days, coverage)
+		day = next
+	}
+	return gaps, days
+}
+
+// 合并重叠或间隔不超过容差的时间段, 返回按开始时间排序的结果
+func mergeTimeItems(items []TimeItem) []TimeItem {
+	sort.Slice(items, func(i, j int) bool { return items[i].Start < items[j].Start })
+	merged := []TimeItem{}
+	for _, item := range items {
+		if n := len(merged); n > 0 && item.Start <= merged[n-1].End+recordGapTolerance {
+			merged[n-1].End = maxInt64(merged[n-1].End, item.End)
+			continue
+		}
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
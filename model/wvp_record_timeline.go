@@ -0,0 +1,151 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// 录像来源
+const (
+	RecordSourceDevice = "device" // 设备端存储, 国标RecordInfo查询
+	RecordSourceCloud  = "cloud"  // 云端存储, zlm录像上传oss
+)
+
+// 设备端录像的触发方式, 国标录像查询结果合并后不区分录像类型
+const RecordTriggerDevice = "device"
+
+// 播放方式
+const (
+	RecordPlayGB   = "gb"   // 国标回放点播
+	RecordPlayVod  = "vod"  // 云端hls点播
+	RecordPlayFile = "file" // 云端mp4文件直接播放, 非fmp4录像不能拼接为hls
+)
+
+// 统一时间轴查询参数
+type RecordTimelineQueryReq struct {
+	IpcId      string `json:"ipcId" binding:"required"`
+	Type       string `json:"type"`                                 // 云端录像类型, 多个用逗号分隔, 为空或all查询全部
+	StreamType string `json:"streamType"`                           // 云端录像码流 0标清 1高清, 为空查询全部
+	Start      int64  `json:"start" binding:"required,min=1"`       // 开始时间戳, 单位秒
+	End        int64  `json:"end" binding:"required,gtfield=Start"` // 结束时间戳, 单位秒
+	Device     *bool  `json:"device"`                               // 是否查询设备端录像, 默认查询
+}
+
+// 播放句柄, 原样提交给时间轴播放接口获取播放地址
+type RecordPlayHandle struct {
+	Type       string `json:"type" binding:"required,oneof=gb vod file"` // 播放方式 gb vod file
+	IpcId      string `json:"ipcId" binding:"required"`
+	Start      int64  `json:"start" binding:"required,min=1"`
+	End        int64  `json:"end" binding:"required,gtfield=Start"`
+	StreamType string `json:"streamType,omitempty"` // vod使用的码流
+	FileUrl    string `json:"fileUrl,omitempty"`    // file方式的录像文件地址
+}
+
+// 播放句柄对应的播放信息
+type RecordPlayVO struct {
+	Type   string `json:"type"`   // 播放方式 gb vod file
+	Url    string `json:"url"`    // vod的hls列表地址或file的录像文件地址
+	Stream any    `json:"stream"` // gb回放点播结果
+}
+
+// 时间轴上的录像片段
+type RecordTimelineItem struct {
+	Start       int64            `json:"start"`
+	End         int64            `json:"end"`
+	Source      string           `json:"source"`      // 录像来源 device cloud
+	TriggerType string           `json:"triggerType"` // 触发方式 ai plan device
+	AiClass     string           `json:"aiClass"`     // ai模型类别
+	Overlap     bool             `json:"overlap"`     // 是否与另一来源的录像重叠
+	Handle      RecordPlayHandle `json:"handle"`
+}
+
+// 统一时间轴查询结果
+type RecordTimelineVO struct {
+	Items       []RecordTimelineItem `json:"items"`       // 按开始时间排序的录像片段
+	Overlaps    []TimeItem           `json:"overlaps"`    // 设备端和云端都有录像的时间段
+	Gaps        []TimeItem           `json:"gaps"`        // 两个来源都没有录像的时间段
+	DeviceError string               `json:"deviceError"` // 设备端录像查询失败原因, 为空表示查询成功或未查询
+}
+
+// 合并设备端和云端录像, 标记重叠的片段并生成播放句柄
+func BuildRecordTimelineMerge(ipcId string, device []TimeItem, cloud []*RecordSegment, start, end int64) RecordTimelineVO {
+	items := []RecordTimelineItem{}
+	device_spans := []TimeItem{}
+	for _, d := range device {
+		s, e := maxInt64(d.Start, start), minInt64(d.End, end)
+		if s >= e {
+			continue
+		}
+		device_spans = append(device_spans, TimeItem{Start: s, End: e})
+		items = append(items, RecordTimelineItem{
+			Start:       s,
+			End:         e,
+			Source:      RecordSourceDevice,
+			TriggerType: RecordTriggerDevice,
+			Handle:      RecordPlayHandle{Type: RecordPlayGB, IpcId: ipcId, Start: s, End: e},
+		})
+	}
+	cloud_spans := []TimeItem{}
+	for _, segment := range cloud {
+		s, e := maxInt64(segment.StartTime, start), minInt64(segment.EndTime, end)
+		if s >= e {
+			continue
+		}
+		cloud_spans = append(cloud_spans, TimeItem{Start: s, End: e})
+		handle := RecordPlayHandle{Type: RecordPlayVod, IpcId: ipcId, Start: s, End: e, StreamType: segmentStreamType(segment.StreamId)}
+		if segment.InitSize <= 0 {
+			handle = RecordPlayHandle{Type: RecordPlayFile, IpcId: ipcId, Start: segment.StartTime, End: segment.EndTime, FileUrl: segment.FileUrl}
+		}
+		items = append(items, RecordTimelineItem{
+			Start:       s,
+			End:         e,
+			Source:      RecordSourceCloud,
+			TriggerType: segment.TriggerType,
+			AiClass:     segment.AiClass,
+			Handle:      handle,
+		})
+	}
+
+	device_spans = mergeTimeItems(device_spans)
+	cloud_spans = mergeTimeItems(cloud_spans)
+	overlaps := intersectTimeItems(device_spans, cloud_spans)
+	for i := range items {
+		other := cloud_spans
+		if items[i].Source == RecordSourceCloud {
+			other = device_spans
+		}
+		items[i].Overlap = len(intersectTimeItems([]TimeItem{{Start: items[i].Start, End: items[i].End}}, other)) > 0
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Start < items[j].Start })
+
+	all_segments := make([]*RecordSegment, 0, len(items))
+	for _, item := range items {
+		all_segments = append(all_segments, &RecordSegment{StartTime: item.Start, EndTime: item.End})
+	}
+	gaps, _ := BuildRecordTimeline(all_segments, start, end)
+	return RecordTimelineVO{Items: items, Overlaps: overlaps, Gaps: gaps}
+}
+
+// 求两组已合并时间段的交集
+func intersectTimeItems(a, b []TimeItem) []TimeItem {
+	res := []TimeItem{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if s, e := maxInt64(a[i].Start, b[j].Start), minInt64(a[i].End, b[j].End); s < e {
+			res = append(res, TimeItem{Start: s, End: e})
+		}
+		if a[i].End < b[j].End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return res
+}
+
+// 流id格式为 ipcId_码流类型
+func segmentStreamType(streamId string) string {
+	if i := strings.LastIndex(streamId, "_"); i >= 0 {
+		return streamId[i+1:]
+	}
+	return "0"
+}
@@ -0,0 +1,32 @@
+package model
+
+// 获取播放地址参数
+type StreamPlayUrlReq struct {
+	IpcId      string `json:"ipcId" binding:"required"`  // ipcId
+	StreamType *int   `json:"streamType"`                // 码流 0 标清 1 高清, 为空时token可用于所有码流
+	Viewer     string `json:"viewer" binding:"required"` // 观看者标识
+	Expire     int    `json:"expire"`                    // 有效期, 单位秒, 默认300
+	ClientIp   string `json:"clientIp"`                  // 绑定的客户端ip, 为空不校验
+}
+
+// 播放地址
+type StreamPlayUrlVO struct {
+	TokenId  string `json:"tokenId"`  // token id, 用于吊销
+	Token    string `json:"token"`    // 播放token
+	ExpireAt int64  `json:"expireAt"` // 过期时间戳, 单位秒
+	Stream   string `json:"stream"`   // 流id
+	Rtsp     string `json:"rtsp"`     // rtsp播放地址
+	Flv      string `json:"flv"`      // http-flv播放地址
+	Hls      string `json:"hls"`      // hls播放地址
+}
+
+// 吊销播放token参数
+type StreamTokenRevokeReq struct {
+	Token string `json:"token" binding:"required"` // 播放token
+}
+
+// 设置租户签名密钥参数
+type StreamAuthTenantKeyReq struct {
+	Tenant string `json:"tenant" binding:"required"` // 租户, 即门店id
+	Key    string `json:"key"`                       // 签名密钥, 为空时随机生成
+}
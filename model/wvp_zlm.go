@@ -2,6 +2,13 @@ package model
 
 import "strconv"
 
+// zlm节点状态
+const (
+	ZlmNodeStatusEnable  = "enable"  // 启用
+	ZlmNodeStatusDisable = "disable" // 手动禁用
+	ZlmNodeStatusOffline = "offline" // 健康检查失败自动下线, 恢复后自动启用
+)
+
 // ZlmRegionInfo 表示 zlm 区域信息表
 type ZlmRegionInfo struct {
 	ID                 int64  `json:"id"`                 // 记录id
@@ -9,6 +16,7 @@ type ZlmRegionInfo struct {
 	RegionName         string `json:"regionName"`         // 地区名称
 	RelationRegionCode string `json:"relationRegionCode"` // 关联地区国标编号
 	RelationRegionName string `json:"relationRegionName"` // zlm节点关联地区
+	SelectStrategy     string `json:"selectStrategy"`     // zlm节点选择策略，为空使用一致性哈希
 	Remarks            string `json:"remarks"`            // 备注
 }
 
@@ -22,6 +30,7 @@ type ZlmNodeInfo struct {
 	ZlmNodeRegion string `json:"zlmNodeRegion"` // zlm所在地区名
 	RegionCode    string `json:"regionCode"`    // 地区编号
 	ZlmNodeStatus string `json:"zlmNodeStatus"` // 启用状态（enable/disable）
+	Weight        int    `json:"weight"`        // 权重，按节点承载能力分配设备
 	Remarks       string `json:"remarks"`       // 备注
 }
 
@@ -33,6 +42,7 @@ type ZlmNodeInfoSaveDTO struct {
 	ZlmSecret     string `json:"zlmSecret" binding:"required" example:"mySecretKey"`     // zlm的secret
 	ZlmNodeRegion string `json:"zlmNodeRegion" binding:"required" example:"北京"`          // zlm所在地区名
 	RegionCode    string `json:"regionCode" binding:"required" example:"11"`             // 地区编号
+	Weight        int    `json:"weight" example:"1"`                                     // 权重，默认1
 	Remarks       string `json:"remarks" example:"主用节点"`                                 // 备注
 }
 
@@ -46,18 +56,27 @@ type ZlmNodeInfoRedisDTO struct {
 
 // ZlmRegionDTO 表示 zlm 节点信息新增请求的 DTO
 type ZlmRegionDTO struct {
-	RegionCode string `json:"regionCode" binding:"required"` // 地区国标编号（如北京11）
-	RegionName string `json:"regionName" binding:"required"` // 地区名称
+	RegionCode         string `json:"regionCode" binding:"required"`         // 地区国标编号（如北京11）
+	RegionName         string `json:"regionName" binding:"required"`         // 地区名称
+	RelationRegionCode string `json:"relationRegionCode" binding:"required"` // 关联地区国标编号
+	RelationRegionName string `json:"relationRegionName" binding:"required"` // zlm节点关联地区
+	SelectStrategy     string `json:"selectStrategy"`                        // zlm节点选择策略，为空使用一致性哈希
+	Remarks            string `json:"remarks"`                               // 备注
 }
 
 type ZlmRegionInfoDTO struct {
 	RelationRegionCode string `json:"relationRegionCode" binding:"required"` // 关联地区国标编号
 	RelationRegionName string `json:"relationRegionName" binding:"required"` // zlm节点关联地区
+	SelectStrategy     string `json:"selectStrategy"`                        // zlm节点选择策略
 	Remarks            string `json:"remarks"`                               // 备注
 }
 
 // FromZlmNodeInfoSaveDTO 将 DTO 转为实体
 func FromZlmNodeInfoSaveDTO(dto *ZlmNodeInfoSaveDTO) *ZlmNodeInfo {
+	weight := dto.Weight
+	if weight <= 0 {
+		weight = 1
+	}
 	return &ZlmNodeInfo{
 		ZlmIP:         dto.ZlmIP,
 		ZlmPort:       dto.ZlmPort,
@@ -66,7 +85,8 @@ func FromZlmNodeInfoSaveDTO(dto *ZlmNodeInfoSaveDTO) *ZlmNodeInfo {
 		ZlmNodeRegion: dto.ZlmNodeRegion,
 		RegionCode:    dto.RegionCode,
 		Remarks:       dto.Remarks,
-		ZlmNodeStatus: "enable", // 默认值
+		ZlmNodeStatus: ZlmNodeStatusEnable, // 默认值
+		Weight:        weight,
 	}
 }
 
@@ -88,3 +108,14 @@ func FromZlmNodeInfoToRedisDTO(dto *ZlmNodeInfo) *ZlmNodeInfoRedisDTO {
 		ZlmPort:   strconv.Itoa(dto.ZlmPort),
 	}
 }
+
+// zlm节点健康状态变化告警事件
+type ZlmNodeAlertEvent struct {
+	ZlmDomain  string   `json:"zlmDomain"`  // zlm域名
+	RegionCode string   `json:"regionCode"` // 地区编号
+	Status     string   `json:"status"`     // 变化后的节点状态
+	Reason     string   `json:"reason"`     // 原因
+	Devices    []string `json:"devices"`    // 重新分配zlm的设备
+	Failed     []string `json:"failed"`     // 没有可用zlm节点的设备
+	Time       int64    `json:"time"`
+}
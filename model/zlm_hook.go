@@ -9,6 +9,10 @@ type ZLMStreamChangedData struct {
 	Schema        string `json:"schema"`
 }
 
+type ZlmServerKeepaliveData struct {
+	MediaServerId string `json:"mediaServerId"`
+}
+
 type ZlmInfo struct {
 	ZlmIp     string `json:"zlmIp" validate:"required"`     // ZLM IP
 	ZlmDomain string `json:"zlmDomain" validate:"required"` // ZLM 域名
@@ -25,19 +29,32 @@ type ZlmAndRegionInfo struct {
 	ZlmNodeRegion string `json:"zlmNodeRegion"`
 	RegionCode    string `json:"regionCode"`
 	ZlmNodeStatus string `json:"zlmNodeStatus"`
+	Weight        int    `json:"weight"`
 	Remarks       string `json:"remarks"`
+
+	Load *ZlmNodeLoad `json:"-"` // 选择节点时填充的负载采样
 }
 
+// zlm节点负载, wvp健康检查时采集
+type ZlmNodeLoad struct {
+	Streams    int   `json:"streams"`    // 流数量
+	BytesSpeed int64 `json:"bytesSpeed"` // 所有流的数据产生速度, 单位byte/s
+	ThreadLoad int   `json:"threadLoad"` // 网络线程平均负载百分比, -1表示采集失败
+	UpdateTime int64 `json:"updateTime"` // 采集时间戳, 单位秒
+}
+
+// zlm拼接流参数
 type StreamMergeConfigDTO struct {
-	GapV   int        `json:"gapv"`
-	GapH   int        `json:"gaph"`
-	Width  int        `json:"width"`
-	Height int        `json:"height"`
-	Row    int        `json:"row"`
-	Col    int        `json:"col"`
-	ID     string     `json:"id"`
-	URL    [][]string `json:"url"`
-	Span   []int      `json:"span"`
+	GapV   float64     `json:"gapv"` // 垂直间距, 占输出高度的比例
+	GapH   float64     `json:"gaph"` // 水平间距, 占输出宽度的比例
+	Width  int         `json:"width"`
+	Height int         `json:"height"`
+	Fps    int         `json:"fps,omitempty"`
+	Row    int         `json:"row"`
+	Col    int         `json:"col"`
+	ID     string      `json:"id"`
+	URL    [][]string  `json:"url"`  // row*col的拉流地址, 被合并的格子为空
+	Span   [][2][2]int `json:"span"` // 合并的格子 [[开始行,开始列],[结束行,结束列]]
 }
 
 type StreamMergeInfoDTO struct {
@@ -60,6 +77,9 @@ type ZLMRecordMp4Data struct {
 	URL                string  `json:"url"`
 	Vhost              string  `json:"vhost"`
 	FileOssDownloadUrl string  `json:"file_oss_download_url"`
+	OssKey             string  `json:"oss_key"`
+	FileMd5            string  `json:"file_md5"`
+	InitSize           int64   `json:"init_size"` // fmp4初始化段大小, 0表示非fmp4
 }
 
 type ZLMStreamNotFoundData struct {
@@ -115,3 +135,83 @@ type ZlmStreamPublishData struct {
 	Stream        string `json:"stream"`        // 流名称
 	VHost         string `json:"vhost"`         // 虚拟主机
 }
+
+type ZlmFlowReportData struct {
+	MediaServerID string `json:"mediaServerId"`
+	App           string `json:"app"`
+	Duration      int    `json:"duration"`   // tcp链接维持时间，单位秒
+	Params        string `json:"params"`     // 推流或播放url参数
+	Player        bool   `json:"player"`     // true为播放器，false为推流器
+	Schema        string `json:"schema"`     // 播放或推流的协议
+	Stream        string `json:"stream"`     // 流ID
+	TotalBytes    int64  `json:"totalBytes"` // 耗费上下行流量总和，单位字节
+	Vhost         string `json:"vhost"`
+	IP            string `json:"ip"`
+	Port          int    `json:"port"`
+	ID            string `json:"id"`
+}
+
+type ZlmHttpAccessData struct {
+	MediaServerID string `json:"mediaServerId"`
+	HeaderAccept  string `json:"header.Accept"`
+	HeaderHost    string `json:"header.Host"`
+	ID            string `json:"id"`
+	IP            string `json:"ip"`
+	IsDir         bool   `json:"is_dir"` // 是否为文件夹
+	Params        string `json:"params"`
+	Path          string `json:"path"` // 请求访问的文件或目录
+	Port          int    `json:"port"`
+}
+
+// 与on_record_mp4格式相同
+type ZlmRecordTsData = ZLMRecordMp4Data
+
+type ZlmRtspRealmData struct {
+	MediaServerID string `json:"mediaServerId"`
+	App           string `json:"app"`
+	ID            string `json:"id"`
+	IP            string `json:"ip"`
+	Params        string `json:"params"`
+	Port          int    `json:"port"`
+	Schema        string `json:"schema"`
+	Stream        string `json:"stream"`
+	Vhost         string `json:"vhost"`
+}
+
+type ZlmRtspAuthData struct {
+	MediaServerID string `json:"mediaServerId"`
+	App           string `json:"app"`
+	ID            string `json:"id"`
+	IP            string `json:"ip"`
+	MustNoEncrypt bool   `json:"must_no_encrypt"` // 请求的密码是否必须为明文
+	Params        string `json:"params"`
+	Port          int    `json:"port"`
+	Realm         string `json:"realm"`
+	Schema        string `json:"schema"`
+	Stream        string `json:"stream"`
+	UserName      string `json:"user_name"`
+	Vhost         string `json:"vhost"`
+}
+
+type ZlmShellLoginData struct {
+	MediaServerID string `json:"mediaServerId"`
+	ID            string `json:"id"`
+	IP            string `json:"ip"`
+	Passwd        string `json:"passwd"`
+	Port          int    `json:"port"`
+	UserName      string `json:"user_name"`
+}
+
+type ZlmServerExitedData struct {
+	MediaServerID string `json:"mediaServerId"`
+}
+
+type ZlmSendRtpStoppedData struct {
+	MediaServerID string `json:"mediaServerId"`
+	App           string `json:"app"`
+	Stream        string `json:"stream"`
+	Vhost         string `json:"vhost"`
+	Ssrc          string `json:"ssrc"`
+	Err           int    `json:"err"`
+	Msg           string `json:"msg"`
+}
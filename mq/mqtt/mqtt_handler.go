@@ -10,11 +10,15 @@ import (
 	pb "go-sip/signaling"
 
 	"strings"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"go.uber.org/zap"
 )
 
+// 设备升级命令的超时时间，覆盖固件下载和升级
+const deviceOTATimeout = 30 * time.Minute
+
 // 非保留发布消息方法
 func SimplePublishMessage(topic string, msg interface{}, qos byte) error {
 	// 将对象转为 JSON
@@ -119,16 +123,13 @@ func handleFirmwareReply(payload []byte) {
 		return
 	}
 
+	// 固件下载和升级耗时较长，异步执行，进度通过操作id查询
 	sip_server := grpc_server.GetSipServer()
-	_, err = sip_server.ExecuteCommand(reply.DeviceID, &pb.ServerCommand{
+	opId := sip_server.StartOperation(reply.DeviceID, &pb.ServerCommand{
 		MsgID:   m.MsgID_DeviceOTA,
 		Method:  m.DeviceOTA,
 		Payload: d,
-	})
-	if err != nil {
-		Logger.Error("设备升级失败", zap.Any("device_id", reply.DeviceID), zap.Error(err))
-		return
-	}
-	Logger.Info("设备升级成功", zap.Any("device_id", reply.DeviceID))
+	}, deviceOTATimeout)
+	Logger.Info("设备升级已下发", zap.Any("device_id", reply.DeviceID), zap.String("op id", opId))
 
 }
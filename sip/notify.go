@@ -93,14 +93,14 @@ func notify(notifyData *Notify) {
 		req.Vhost = "__defaultVhost__"
 		// 推送标清流到本地zlm
 		req.StreamID = notifyData.DeviceID + "_0"
-		resp := zlm_api.ZlmGetRtpInfo(Local_ZLM_Host, m.CMConfig.ZlmSecret, req)
+		resp := zlm_api.ZlmGetRtpInfo(Local_ZLM_Host, m.ClientZlmSecret(), req)
 		if resp.Code == 0 && !resp.Exist {
 
 			SipStopPlay(req.StreamID)
 
 			Logger.Info("本地ZLM不存在流，开始推送本地标清流", zap.Any("deviceID", notifyData.DeviceID))
 			// 不存在，调用openRtpServer让本地zlm打开一个收流端口
-			rtp_info := zlm_api.ZlmStartRtpServer(Local_ZLM_Host, m.CMConfig.ZlmSecret, req.StreamID, req.App, 0)
+			rtp_info := zlm_api.ZlmStartRtpServer(Local_ZLM_Host, m.ClientZlmSecret(), req.StreamID, req.App, 0)
 			if rtp_info.Code != 0 || rtp_info.Port == 0 {
 				Logger.Error("open rtp server fail", zap.Int("code", rtp_info.Code))
 			} else {
@@ -116,13 +116,13 @@ func notify(notifyData *Notify) {
 		}
 		// 推送高清流到本地zlm
 		// req.StreamID = notifyData.DeviceID + "_1"
-		// resp2 := zlm_api.ZlmGetRtpInfo(Local_ZLM_Host, m.CMConfig.ZlmSecret, req)
+		// resp2 := zlm_api.ZlmGetRtpInfo(Local_ZLM_Host, m.ClientZlmSecret(), req)
 		// if resp2.Code == 0 && !resp2.Exist {
 		// 	Logger.Info("本地ZLM不存在流，开始推送本地高清流", zap.Any("deviceID", notifyData.DeviceID))
 
 		// 	SipStopPlay(req.StreamID)
 		// 	// 不存在，调用openRtpServer让本地zlm打开一个收流端口
-		// 	rtp_info := zlm_api.ZlmStartRtpServer(Local_ZLM_Host, m.CMConfig.ZlmSecret, req.StreamID, 0)
+		// 	rtp_info := zlm_api.ZlmStartRtpServer(Local_ZLM_Host, m.ClientZlmSecret(), req.StreamID, 0)
 		// 	if rtp_info.Code != 0 || rtp_info.Port == 0 {
 		// 		Logger.Error("open rtp server fail", zap.Int("code", rtp_info.Code))
 		// 	} else {
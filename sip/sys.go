@@ -48,6 +48,16 @@ func (a *ActiveDevices) Get(key string) (Devices, bool) {
 	return Devices{}, false
 }
 
+// 当前活跃设备列表
+func ActiveDeviceList() []Devices {
+	list := []Devices{}
+	_activeDevices.Range(func(key, value any) bool {
+		list = append(list, value.(Devices))
+		return true
+	})
+	return list
+}
+
 func LoadSYSInfo() {
 
 	config = m.CMConfig
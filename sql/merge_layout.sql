@@ -0,0 +1,15 @@
+-- 合屏布局, 画面列表以json格式存储
+CREATE TABLE IF NOT EXISTS gowvp_merge_layout (
+    id      BIGINT       NOT NULL AUTO_INCREMENT,
+    name    VARCHAR(128) NOT NULL COMMENT '布局名称',
+    width   INT          NOT NULL COMMENT '输出分辨率宽',
+    height  INT          NOT NULL COMMENT '输出分辨率高',
+    fps     INT                   DEFAULT 0 COMMENT '输出帧率',
+    row_num INT          NOT NULL COMMENT '网格行数',
+    col_num INT          NOT NULL COMMENT '网格列数',
+    gap_v   DOUBLE                DEFAULT 0 COMMENT '垂直间距, 占输出高度的比例',
+    gap_h   DOUBLE                DEFAULT 0 COMMENT '水平间距, 占输出宽度的比例',
+    tiles   TEXT         NOT NULL COMMENT '画面列表json',
+    remarks VARCHAR(255)          DEFAULT '' COMMENT '备注',
+    PRIMARY KEY (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '合屏布局';
@@ -0,0 +1,18 @@
+-- 推流目标, 将ipc的流转推到第三方直播平台, 推流状态由server上报到redis, 不落库
+CREATE TABLE IF NOT EXISTS gowvp_push_target (
+    id           BIGINT        NOT NULL AUTO_INCREMENT,
+    name         VARCHAR(128)           DEFAULT '' COMMENT '名称',
+    ipc_id       VARCHAR(64)   NOT NULL COMMENT 'ipc id',
+    device_id    VARCHAR(64)            DEFAULT '' COMMENT 'ipc关联的设备id',
+    protocol     VARCHAR(16)   NOT NULL COMMENT '推流协议 rtmp rtsp srt',
+    dst_url      VARCHAR(1024) NOT NULL COMMENT '推流地址',
+    stream_type  TINYINT                DEFAULT 0 COMMENT '码流 0标清 1高清',
+    windows      TEXT COMMENT '每周推流时间段json, 为空表示全天推流',
+    auto_restart TINYINT(1)    NOT NULL DEFAULT 0 COMMENT '推流失败或断开后是否自动重启',
+    enable       TINYINT(1)    NOT NULL DEFAULT 0 COMMENT '是否启用',
+    remarks      VARCHAR(255)           DEFAULT '' COMMENT '备注',
+    version      BIGINT        NOT NULL DEFAULT 1 COMMENT '版本号，每次更新加1',
+    update_time  BIGINT                 DEFAULT 0 COMMENT '更新时间戳, 单位秒',
+    PRIMARY KEY (id),
+    KEY idx_ipc_id (ipc_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '推流目标';
@@ -0,0 +1,15 @@
+-- ipc录像计划, 每个ipc一个计划, 由uk_ipc_id保证
+CREATE TABLE IF NOT EXISTS gowvp_record_plan (
+    id             BIGINT       NOT NULL AUTO_INCREMENT,
+    ipc_id         VARCHAR(64)  NOT NULL COMMENT 'ipc id',
+    device_id      VARCHAR(64)           DEFAULT '' COMMENT 'ipc关联的设备id',
+    mode           VARCHAR(16)  NOT NULL COMMENT '录像模式 continuous schedule event',
+    stream_type    TINYINT               DEFAULT 0 COMMENT '码流 0标清 1高清',
+    windows        TEXT COMMENT '每周录像时间段json, schedule模式有效',
+    retention_days INT                   DEFAULT 0 COMMENT '录像保留天数, 0表示不删除',
+    enable         TINYINT(1)   NOT NULL DEFAULT 0 COMMENT '是否启用',
+    remarks        VARCHAR(255)          DEFAULT '' COMMENT '备注',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_ipc_id (ipc_id),
+    KEY idx_device_id (device_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '录像计划';
@@ -0,0 +1,21 @@
+-- 录像片段, zlm每个mp4切片一条记录, 同一个oss文件重复上报时依赖uk_oss_key忽略
+CREATE TABLE IF NOT EXISTS gowvp_record_segment (
+    id           BIGINT       NOT NULL AUTO_INCREMENT,
+    ipc_id       VARCHAR(64)  NOT NULL COMMENT 'ipc id',
+    device_id    VARCHAR(64)  NOT NULL DEFAULT '' COMMENT '设备id',
+    stream_id    VARCHAR(64)  NOT NULL COMMENT '流id',
+    record_type  VARCHAR(64)  NOT NULL COMMENT '录像类型, 即录像目录',
+    trigger_type VARCHAR(16)  NOT NULL DEFAULT 'ai' COMMENT '触发方式 ai plan',
+    ai_class     VARCHAR(64)           DEFAULT '' COMMENT 'ai模型类别, 计划录像为空',
+    start_time   BIGINT       NOT NULL COMMENT '开始时间戳, 单位秒',
+    end_time     BIGINT       NOT NULL COMMENT '结束时间戳, 单位秒',
+    duration     DOUBLE       NOT NULL DEFAULT 0 COMMENT '时长, 单位秒',
+    file_size    BIGINT                DEFAULT 0 COMMENT '文件大小, 单位字节',
+    oss_key      VARCHAR(255) NOT NULL COMMENT 'oss文件key',
+    file_url     VARCHAR(1024)         DEFAULT '' COMMENT '文件下载地址',
+    checksum     VARCHAR(64)           DEFAULT '' COMMENT '文件md5',
+    init_size    BIGINT                DEFAULT 0 COMMENT 'fmp4初始化段大小, 0表示非fmp4',
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_oss_key (oss_key),
+    KEY idx_ipc_time (ipc_id, start_time, end_time)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '录像片段';
@@ -0,0 +1,6 @@
+-- zlm节点权重和地区节点选择策略, 已有数据库升级时执行
+ALTER TABLE gowvp_zlm_node_info
+    ADD COLUMN weight INT NOT NULL DEFAULT 1 COMMENT '权重，按节点承载能力分配设备' AFTER zlm_node_status;
+
+ALTER TABLE gowvp_zlm_region_info
+    ADD COLUMN select_strategy VARCHAR(32) NOT NULL DEFAULT '' COMMENT 'zlm节点选择策略，为空使用一致性哈希' AFTER relation_region_name;
@@ -0,0 +1,39 @@
+package stream_auth
+
+// 未单独配置密钥的租户使用默认租户的密钥
+const DefaultTenant = "default"
+
+// 租户密钥和吊销记录的存储, 由各服务基于自己的redis实现
+type KeyStore interface {
+	// 租户的签名密钥, 租户没有单独的密钥时返回默认租户的密钥
+	TenantKey(tenant string) (string, error)
+	IsRevoked(id string) bool
+}
+
+// 校验token是否允许ip按scope访问stream, tenant为stream所属租户
+func Check(store KeyStore, token, tenant, stream, ip, scope string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrTokenInvalid
+	}
+	key, err := store.TenantKey(tenant)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := Verify(key, token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Tenant != tenant {
+		return nil, ErrTenantMismatch
+	}
+	if claims.TokenScope() != scope {
+		return nil, ErrScopeMismatch
+	}
+	if err := claims.Allow(stream, ip); err != nil {
+		return nil, err
+	}
+	if store.IsRevoked(claims.ID) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
@@ -0,0 +1,150 @@
+package stream_auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 播放地址中携带token的参数名
+const TokenParam = "token"
+
+// token用途, 播放token不能用于推流
+const (
+	ScopePlay    = "play"
+	ScopePublish = "publish"
+)
+
+var (
+	ErrTokenInvalid   = errors.New("token格式错误")
+	ErrTokenSign      = errors.New("token签名错误")
+	ErrTokenExpired   = errors.New("token已过期")
+	ErrTokenRevoked   = errors.New("token已吊销")
+	ErrStreamMismatch = errors.New("token与流不匹配")
+	ErrIPMismatch     = errors.New("token与客户端ip不匹配")
+	ErrTenantMismatch = errors.New("token与租户不匹配")
+	ErrScopeMismatch  = errors.New("token用途不匹配")
+)
+
+// token内容, token格式为 base64url(claims).base64url(hmac-sha256(key, base64url(claims)))
+type Claims struct {
+	ID     string `json:"jti"`           // token id, 用于吊销
+	Stream string `json:"sub"`           // 流id, 只有ipcId时该ipc的所有码流都可使用
+	Viewer string `json:"vid"`           // 观看者标识
+	Tenant string `json:"tid,omitempty"` // 租户, 决定签名使用的密钥
+	IP     string `json:"ip,omitempty"`  // 绑定的客户端ip, 为空不校验
+	Scope  string `json:"scp,omitempty"` // 用途 play publish, 为空视为play
+	Expire int64  `json:"exp"`           // 过期时间戳, 单位秒
+}
+
+// 生成token, claims.ID为空时自动生成
+func Sign(key string, claims *Claims) (string, error) {
+	if key == "" {
+		return "", errors.New("签名密钥不能为空")
+	}
+	if claims.ID == "" {
+		claims.ID = NewTokenID()
+	}
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signature(key, payload), nil
+}
+
+// 解析token内容, 不校验签名, 只用于吊销等已鉴权的场景
+func Parse(token string) (*Claims, error) {
+	payload, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrTokenInvalid
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(data, claims); err != nil || claims.ID == "" {
+		return nil, ErrTokenInvalid
+	}
+	return claims, nil
+}
+
+// 校验签名和有效期
+func Verify(key, token string) (*Claims, error) {
+	payload, sign, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrTokenInvalid
+	}
+	if !hmac.Equal([]byte(sign), []byte(signature(key, payload))) {
+		return nil, ErrTokenSign
+	}
+	claims, err := Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() >= claims.Expire {
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// 校验token是否允许ip访问stream
+func (c *Claims) Allow(stream, ip string) error {
+	if c.Stream != stream && c.Stream != strings.Split(stream, "_")[0] {
+		return ErrStreamMismatch
+	}
+	if c.IP != "" {
+		bind, client := net.ParseIP(c.IP), net.ParseIP(ip)
+		if bind == nil || client == nil || !bind.Equal(client) {
+			return ErrIPMismatch
+		}
+	}
+	return nil
+}
+
+// token用途, 未携带用途的token只能用于播放
+func (c *Claims) TokenScope() string {
+	if c.Scope == "" {
+		return ScopePlay
+	}
+	return c.Scope
+}
+
+// 从hook的params中获取token
+func TokenFromParams(params string) string {
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return ""
+	}
+	return values.Get(TokenParam)
+}
+
+func NewTokenID() string {
+	return randomHex(16)
+}
+
+// 生成租户签名密钥
+func GenerateKey() string {
+	return randomHex(32)
+}
+
+func signature(key, payload string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
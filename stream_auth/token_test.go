@@ -0,0 +1,156 @@
+package stream_auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testKeyStore struct {
+	keys    map[string]string
+	revoked map[string]bool
+}
+
+func (s testKeyStore) TenantKey(tenant string) (string, error) {
+	if key, ok := s.keys[tenant]; ok {
+		return key, nil
+	}
+	return s.keys[DefaultTenant], nil
+}
+
+func (s testKeyStore) IsRevoked(id string) bool {
+	return s.revoked[id]
+}
+
+func TestSignVerify(t *testing.T) {
+	now := time.Now().Unix()
+	valid, err := Sign("key1", &Claims{Stream: "ipc1_0", Expire: now + 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := Sign("key1", &Claims{Stream: "ipc1_0", Expire: now - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _, _ := strings.Cut(valid, ".")
+	tampered, _ := Sign("key1", &Claims{Stream: "ipc2_0", Expire: now + 60})
+	_, tamperedSign, _ := strings.Cut(tampered, ".")
+
+	tests := []struct {
+		name  string
+		key   string
+		token string
+		err   error
+	}{
+		{"有效token", "key1", valid, nil},
+		{"密钥错误", "key2", valid, ErrTokenSign},
+		{"已过期", "key1", expired, ErrTokenExpired},
+		{"签名被替换", "key1", payload + "." + tamperedSign, ErrTokenSign},
+		{"缺少签名", "key1", payload, ErrTokenInvalid},
+		{"空token", "key1", "", ErrTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Verify(tt.key, tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() err = %v, want %v", err, tt.err)
+			}
+			if err == nil && claims.Stream != "ipc1_0" {
+				t.Fatalf("Verify() stream = %s", claims.Stream)
+			}
+		})
+	}
+}
+
+func TestSignEmptyKey(t *testing.T) {
+	if _, err := Sign("", &Claims{Stream: "ipc1_0"}); err == nil {
+		t.Fatal("Sign() with empty key should fail")
+	}
+}
+
+func TestClaimsAllow(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims Claims
+		stream string
+		ip     string
+		err    error
+	}{
+		{"流id一致", Claims{Stream: "ipc1_1"}, "ipc1_1", "10.0.0.1", nil},
+		{"ipcId匹配所有码流", Claims{Stream: "ipc1"}, "ipc1_0", "10.0.0.1", nil},
+		{"码流不一致", Claims{Stream: "ipc1_1"}, "ipc1_0", "10.0.0.1", ErrStreamMismatch},
+		{"其他ipc", Claims{Stream: "ipc1"}, "ipc2_0", "10.0.0.1", ErrStreamMismatch},
+		{"ip一致", Claims{Stream: "ipc1", IP: "10.0.0.1"}, "ipc1_0", "10.0.0.1", nil},
+		{"ip不一致", Claims{Stream: "ipc1", IP: "10.0.0.1"}, "ipc1_0", "10.0.0.2", ErrIPMismatch},
+		{"客户端ip格式错误", Claims{Stream: "ipc1", IP: "10.0.0.1"}, "ipc1_0", "", ErrIPMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.claims.Allow(tt.stream, tt.ip); !errors.Is(err, tt.err) {
+				t.Fatalf("Allow() err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	store := testKeyStore{
+		keys:    map[string]string{DefaultTenant: "default-key", "store1": "store1-key"},
+		revoked: map[string]bool{"revoked-id": true},
+	}
+	expire := time.Now().Add(time.Minute).Unix()
+	sign := func(key string, claims Claims) string {
+		claims.Expire = expire
+		token, err := Sign(key, &claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		tenant string
+		scope  string
+		err    error
+	}{
+		{"租户密钥", sign("store1-key", Claims{Stream: "ipc1", Tenant: "store1", Scope: ScopePlay}), "store1", ScopePlay, nil},
+		{"默认租户密钥", sign("default-key", Claims{Stream: "ipc1", Tenant: "store2", Scope: ScopePlay}), "store2", ScopePlay, nil},
+		{"其他租户的token", sign("store1-key", Claims{Stream: "ipc1", Tenant: "store1", Scope: ScopePlay}), "store2", ScopePlay, ErrTokenSign},
+		{"租户不一致", sign("default-key", Claims{Stream: "ipc1", Tenant: "store3", Scope: ScopePlay}), "store2", ScopePlay, ErrTenantMismatch},
+		{"已吊销", sign("store1-key", Claims{ID: "revoked-id", Stream: "ipc1", Tenant: "store1", Scope: ScopePlay}), "store1", ScopePlay, ErrTokenRevoked},
+		{"流不匹配", sign("store1-key", Claims{Stream: "ipc2", Tenant: "store1", Scope: ScopePlay}), "store1", ScopePlay, ErrStreamMismatch},
+		{"空token", "", "store1", ScopePlay, ErrTokenInvalid},
+		{"未携带用途视为播放", sign("store1-key", Claims{Stream: "ipc1", Tenant: "store1"}), "store1", ScopePlay, nil},
+		{"播放token不能推流", sign("store1-key", Claims{Stream: "ipc1", Tenant: "store1", Scope: ScopePlay}), "store1", ScopePublish, ErrScopeMismatch},
+		{"未携带用途不能推流", sign("store1-key", Claims{Stream: "ipc1", Tenant: "store1"}), "store1", ScopePublish, ErrScopeMismatch},
+		{"推流token不能播放", sign("store1-key", Claims{Stream: "ipc1", Tenant: "store1", Scope: ScopePublish}), "store1", ScopePlay, ErrScopeMismatch},
+		{"推流token", sign("store1-key", Claims{Stream: "ipc1", Tenant: "store1", Scope: ScopePublish}), "store1", ScopePublish, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Check(store, tt.token, tt.tenant, "ipc1_0", "10.0.0.1", tt.scope); !errors.Is(err, tt.err) {
+				t.Fatalf("Check() err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTokenFromParams(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{"token=abc.def", "abc.def"},
+		{"mode=0&token=abc.def", "abc.def"},
+		{"mode=0", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := TokenFromParams(tt.params); got != tt.want {
+			t.Errorf("TokenFromParams(%q) = %q, want %q", tt.params, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // 判断目录是否存在
@@ -47,6 +49,12 @@ func FileDirHandler(rootPath, subPath string) (string, error) {
 
 // downloadFileTo 下载文件到指定路径，并返回文件的 MD5 值
 func DownloadFileTo(saveDir string, firmwareDownloadUrl string) (string, error) {
+	return DownloadFileToWithContext(context.Background(), saveDir, firmwareDownloadUrl, nil)
+}
+
+// 下载文件到指定路径，支持取消和进度回调，返回文件的 MD5 值
+// progress 参数为已下载字节数和文件总字节数（未知时为-1）
+func DownloadFileToWithContext(ctx context.Context, saveDir string, firmwareDownloadUrl string, progress func(written, total int64)) (string, error) {
 	// 解码 URL
 	decodedURL, err := DecodeURLFromJSON(firmwareDownloadUrl)
 	if err != nil {
@@ -54,7 +62,11 @@ func DownloadFileTo(saveDir string, firmwareDownloadUrl string) (string, error)
 	}
 
 	// 发起请求
-	resp, err := http.Get(decodedURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, decodedURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("请求失败: %w", err)
 	}
@@ -72,9 +84,12 @@ func DownloadFileTo(saveDir string, firmwareDownloadUrl string) (string, error)
 
 	// 计算 MD5
 	hash := md5.New()
-	tee := io.TeeReader(resp.Body, hash)
+	var reader io.Reader = io.TeeReader(resp.Body, hash)
+	if progress != nil {
+		reader = &progressReader{r: reader, total: resp.ContentLength, progress: progress}
+	}
 
-	if _, err = io.Copy(outFile, tee); err != nil {
+	if _, err = io.Copy(outFile, reader); err != nil {
 		return "", fmt.Errorf("写入文件失败: %w", err)
 	}
 
@@ -83,6 +98,21 @@ func DownloadFileTo(saveDir string, firmwareDownloadUrl string) (string, error)
 	return md5sum, nil
 }
 
+// 统计已读取字节数并回调下载进度
+type progressReader struct {
+	r        io.Reader
+	written  int64
+	total    int64
+	progress func(written, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.written += int64(n)
+	p.progress(p.written, p.total)
+	return n, err
+}
+
 // 计算文件的MD5
 func ComputeFileMD5(filePath string) (string, error) {
 	f, err := os.Open(filePath)
@@ -98,3 +128,54 @@ func ComputeFileMD5(filePath string) (string, error) {
 
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
+
+// 统计目录占用空间
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// 读取文件最后n行, 从文件末尾按块向前读取, 避免读入整个大文件
+func TailFile(filePath string, n int) ([]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunkSize = 64 * 1024
+	offset := info.Size()
+	var data []byte
+	for offset > 0 && strings.Count(string(data), "\n") <= n {
+		readSize := int64(chunkSize)
+		if offset < readSize {
+			readSize = offset
+		}
+		offset -= readSize
+		buf := make([]byte, readSize)
+		if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(buf, data...)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 计算fmp4文件初始化段(ftyp+moov)的大小, 即第一个moof box的偏移
+// 非fmp4文件返回0
+func Mp4InitSegmentSize(filePath string) (int64, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	fileSize := info.Size()
+
+	var offset int64
+	header := make([]byte, 16)
+	for offset+8 <= fileSize {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return 0, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		switch boxSize {
+		case 0:
+			// box延续到文件末尾
+			boxSize = fileSize - offset
+		case 1:
+			// 64位box大小
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil && err != io.EOF {
+				return 0, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		if boxType == "moof" {
+			return offset, nil
+		}
+		if boxSize < 8 {
+			return 0, fmt.Errorf("mp4 box大小错误: %s %d", boxType, boxSize)
+		}
+		offset += boxSize
+	}
+	return 0, nil
+}
@@ -0,0 +1,574 @@
+package zlm_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-sip/model"
+)
+
+const (
+	defaultClientTimeout = 10 * time.Second
+	defaultClientRetries = 2 // 幂等接口传输失败后的重试次数
+	retryBackoff         = 200 * time.Millisecond
+	defaultVhost         = "__defaultVhost__"
+)
+
+// zlm接口传输失败, 如连接失败、超时、响应不是json
+type TransportError struct {
+	API string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("zlm %s 请求失败: %v", e.API, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// zlm接口返回的错误码
+type APIError struct {
+	API  string
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("zlm %s 返回错误, code: %d, msg: %s", e.API, e.Code, e.Msg)
+}
+
+// 获取错误对应的zlm错误码, 传输失败返回-1
+func ErrorCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return -1
+}
+
+// 单个zlm节点的客户端
+type Client struct {
+	baseURL    string
+	secret     string
+	httpClient *http.Client
+	retries    int
+}
+
+type ClientOption func(c *Client)
+
+// 单次请求超时时间
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// 幂等接口的重试次数
+func WithRetries(retries int) ClientOption {
+	return func(c *Client) {
+		c.retries = retries
+	}
+}
+
+// baseURL如 http://127.0.0.1:9092
+func NewClient(baseURL, secret string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		secret:     secret,
+		httpClient: &http.Client{Timeout: defaultClientTimeout},
+		retries:    defaultClientRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// 根据ip和http端口创建客户端
+func NewClientByHost(ip, port, secret string, opts ...ClientOption) *Client {
+	return NewClient(fmt.Sprintf("http://%s:%s", ip, port), secret, opts...)
+}
+
+// 根据zlm节点信息创建客户端, 使用zlm域名访问
+func NewClientByZlmInfo(info *model.ZlmInfo, opts ...ClientOption) *Client {
+	return NewClient(info.ZlmDomain, info.ZlmSecret, opts...)
+}
+
+type apiResult struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// 发送请求并解析结果, idempotent为true时传输失败会重试
+func (c *Client) do(ctx context.Context, api string, params url.Values, body any, idempotent bool, out any) error {
+	raw, err := c.doRaw(ctx, api, params, body, idempotent)
+	if err != nil {
+		return err
+	}
+	result := apiResult{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return &TransportError{API: api, Err: err}
+	}
+	if result.Code != 0 {
+		return &APIError{API: api, Code: result.Code, Msg: result.Msg}
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return &TransportError{API: api, Err: err}
+		}
+	}
+	return nil
+}
+
+func (c *Client) doRaw(ctx context.Context, api string, params url.Values, body any, idempotent bool) ([]byte, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("secret", c.secret)
+	reqURL := c.baseURL + "/index/api/" + api + "?" + params.Encode()
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts += c.retries
+	}
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, &TransportError{API: api, Err: ctx.Err()}
+			case <-time.After(retryBackoff * time.Duration(i)):
+			}
+		}
+		raw, err := c.send(ctx, reqURL, data)
+		if err == nil {
+			return raw, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, &TransportError{API: api, Err: lastErr}
+}
+
+func (c *Client) send(ctx context.Context, reqURL string, data []byte) ([]byte, error) {
+	var req *http.Request
+	var err error
+	if data != nil {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json;charset=UTF-8")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http状态码: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func setIfNotEmpty(params url.Values, key, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
+
+// 获取zlm版本信息
+func (c *Client) Version(ctx context.Context) (*ZlmVersionResp, error) {
+	res := &ZlmVersionResp{}
+	return res, c.do(ctx, "version", nil, nil, true, res)
+}
+
+// zlm网络线程负载
+type ZlmThreadLoad struct {
+	Load  int `json:"load"`  // 负载百分比
+	Delay int `json:"delay"` // 任务延时, 单位ms
+}
+
+// 获取zlm网络线程负载
+func (c *Client) GetThreadsLoad(ctx context.Context) ([]ZlmThreadLoad, error) {
+	res := &struct {
+		Data []ZlmThreadLoad `json:"data"`
+	}{}
+	if err := c.do(ctx, "getThreadsLoad", nil, nil, true, res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// 开启rtp接收端口, tcpMode: 0 udp模式, 1 tcp被动模式, 2 tcp主动模式, 返回接收端口
+func (c *Client) OpenRtpServer(ctx context.Context, streamID, app string, tcpMode int) (int, error) {
+	params := url.Values{}
+	params.Set("stream_id", streamID)
+	params.Set("app", app)
+	params.Set("port", "0")
+	params.Set("tcp_mode", strconv.Itoa(tcpMode))
+	res := &OpenRtpRsp{}
+	if err := c.do(ctx, "openRtpServer", params, nil, false, res); err != nil {
+		return 0, err
+	}
+	return res.Port, nil
+}
+
+// 关闭rtp接收端口, 返回关闭的数量
+func (c *Client) CloseRtpServer(ctx context.Context, streamID string) (int, error) {
+	params := url.Values{}
+	params.Set("stream_id", streamID)
+	res := &CloseRtpRsp{}
+	if err := c.do(ctx, "closeRtpServer", params, nil, true, res); err != nil {
+		return 0, err
+	}
+	return res.Hit, nil
+}
+
+// 获取rtp流信息, 返回流是否存在
+func (c *Client) GetRtpInfo(ctx context.Context, req ZlmGetRtpInfoReq) (bool, error) {
+	params := url.Values{}
+	setIfNotEmpty(params, "stream_id", req.StreamID)
+	setIfNotEmpty(params, "app", req.App)
+	setIfNotEmpty(params, "vhost", req.Vhost)
+	res := &ZlmGetRtpInfoResp{}
+	if err := c.do(ctx, "getRtpInfo", params, nil, true, res); err != nil {
+		return false, err
+	}
+	return res.Exist, nil
+}
+
+func (c *Client) PauseRtpCheck(ctx context.Context, streamID string) error {
+	params := url.Values{}
+	params.Set("app", "rtp")
+	params.Set("stream_id", streamID)
+	return c.do(ctx, "pauseRtpCheck", params, nil, true, nil)
+}
+
+func (c *Client) ResumeRtpCheck(ctx context.Context, streamID string) error {
+	params := url.Values{}
+	params.Set("app", "rtp")
+	params.Set("stream_id", streamID)
+	return c.do(ctx, "resumeRtpCheck", params, nil, true, nil)
+}
+
+// active模式发送rtp, 返回本地端口
+func (c *Client) StartSendRtp(ctx context.Context, req ZlmStartSendRtpReq) (int, error) {
+	params := url.Values{}
+	setIfNotEmpty(params, "stream", req.StreamID)
+	setIfNotEmpty(params, "app", req.App)
+	setIfNotEmpty(params, "vhost", req.Vhost)
+	setIfNotEmpty(params, "dst_url", req.DstUrl)
+	setIfNotEmpty(params, "dst_port", req.DstPort)
+	setIfNotEmpty(params, "is_udp", req.IsUdp)
+	setIfNotEmpty(params, "ssrc", req.Ssrc)
+	res := &ZlmStartSendRtpResp{}
+	if err := c.do(ctx, "startSendRtp", params, nil, false, res); err != nil {
+		return 0, err
+	}
+	return res.LocalPort, nil
+}
+
+// 被动模式发送广播音频rtp, 返回本地端口
+func (c *Client) StartSendRtpPassive(ctx context.Context, streamID string) (int, error) {
+	params := url.Values{}
+	params.Set("stream", streamID)
+	params.Set("ssrc", "1")
+	params.Set("app", "broadcast")
+	params.Set("vhost", defaultVhost)
+	params.Set("only_audio", "1")
+	params.Set("pt", "8")
+	params.Set("use_ps", "0")
+	params.Set("is_udp", "0")
+	res := &OpenSendRtpRsp{}
+	if err := c.do(ctx, "startSendRtpPassive", params, nil, false, res); err != nil {
+		return 0, err
+	}
+	return res.LocalPort, nil
+}
+
+func (c *Client) StopSendRtp(ctx context.Context, req ZlmStopSendRtpReq) error {
+	params := url.Values{}
+	setIfNotEmpty(params, "stream", req.StreamID)
+	setIfNotEmpty(params, "app", req.App)
+	setIfNotEmpty(params, "vhost", req.Vhost)
+	setIfNotEmpty(params, "ssrc", req.Ssrc)
+	return c.do(ctx, "stopSendRtp", params, nil, true, nil)
+}
+
+// 获取流列表
+func (c *Client) GetMediaList(ctx context.Context, req ZlmGetMediaListReq) ([]ZlmGetMediaListDataResp, error) {
+	params := url.Values{}
+	setIfNotEmpty(params, "stream", req.StreamID)
+	setIfNotEmpty(params, "app", req.App)
+	setIfNotEmpty(params, "schema", req.Schema)
+	setIfNotEmpty(params, "vhost", req.Vhost)
+	res := &ZlmGetMediaListResp{}
+	if err := c.do(ctx, "getMediaList", params, nil, true, res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// 关闭rtp应用下的流, streamID为空时关闭所有流
+func (c *Client) CloseStreams(ctx context.Context, streamID string) error {
+	params := url.Values{}
+	params.Set("vhost", defaultVhost)
+	params.Set("force", "1")
+	if streamID != "" {
+		params.Set("stream", streamID)
+		params.Set("schema", "rtsp")
+		params.Set("app", "rtp")
+	}
+	return c.do(ctx, "close_streams", params, nil, true, nil)
+}
+
+// 强制关闭指定流的所有协议
+func (c *Client) CloseStream(ctx context.Context, app, streamID string) error {
+	params := url.Values{}
+	params.Set("vhost", defaultVhost)
+	params.Set("force", "1")
+	params.Set("app", app)
+	params.Set("stream", streamID)
+	return c.do(ctx, "close_streams", params, nil, true, nil)
+}
+
+// 获取流的播放者列表
+func (c *Client) GetMediaPlayerList(ctx context.Context, schema, app, streamID string) ([]ZlmMediaPlayer, error) {
+	params := url.Values{}
+	params.Set("schema", schema)
+	params.Set("vhost", defaultVhost)
+	params.Set("app", app)
+	params.Set("stream", streamID)
+	res := &struct {
+		Data []ZlmMediaPlayer `json:"data"`
+	}{}
+	if err := c.do(ctx, "getMediaPlayerList", params, nil, true, res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// 开始拼接流
+func (c *Client) StackStart(ctx context.Context, cfg model.StreamMergeConfigDTO) error {
+	return c.do(ctx, "stack/start", nil, cfg, false, nil)
+}
+
+// 切换拼接流的画面
+func (c *Client) StackReset(ctx context.Context, cfg model.StreamMergeConfigDTO) error {
+	return c.do(ctx, "stack/reset", nil, cfg, false, nil)
+}
+
+// 停止拼接流
+func (c *Client) StackStop(ctx context.Context, id string) error {
+	params := url.Values{}
+	params.Set("id", id)
+	return c.do(ctx, "stack/stop", params, nil, true, nil)
+}
+
+// 开始mp4录制, customizedPath为空时使用zlm配置的目录, maxSecond为0时使用zlm配置的切片时长
+func (c *Client) StartRecord(ctx context.Context, streamID, customizedPath string, maxSecond int) error {
+	params := recordParams(streamID)
+	setIfNotEmpty(params, "customized_path", customizedPath)
+	if maxSecond > 0 {
+		params.Set("max_second", strconv.Itoa(maxSecond))
+	}
+	res := &ZlmRecordRes{}
+	if err := c.do(ctx, "startRecord", params, nil, true, res); err != nil {
+		return err
+	}
+	if !res.Result {
+		return &APIError{API: "startRecord", Code: -1, Msg: "开始录制失败"}
+	}
+	return nil
+}
+
+// 停止mp4录制
+func (c *Client) StopRecord(ctx context.Context, streamID string) error {
+	res := &ZlmRecordRes{}
+	if err := c.do(ctx, "stopRecord", recordParams(streamID), nil, true, res); err != nil {
+		return err
+	}
+	if !res.Result {
+		return &APIError{API: "stopRecord", Code: -1, Msg: "停止录制失败"}
+	}
+	return nil
+}
+
+// 查询是否正在mp4录制
+func (c *Client) IsRecording(ctx context.Context, streamID string) (bool, error) {
+	res := &ZlmRecordStatusRes{}
+	if err := c.do(ctx, "isRecording", recordParams(streamID), nil, true, res); err != nil {
+		return false, err
+	}
+	return res.Status, nil
+}
+
+func recordParams(streamID string) url.Values {
+	params := url.Values{}
+	params.Set("type", "1") // 0为hls，1为mp4
+	params.Set("app", "rtp")
+	params.Set("vhost", defaultVhost)
+	params.Set("stream", streamID)
+	return params
+}
+
+// 截图, 返回jpeg图片内容
+func (c *Client) Snap(ctx context.Context, streamURL string, timeoutSec, expireSec int) ([]byte, error) {
+	params := url.Values{}
+	params.Set("url", streamURL)
+	params.Set("timeout_sec", strconv.Itoa(timeoutSec))
+	params.Set("expire_sec", strconv.Itoa(expireSec))
+	data, err := c.doRaw(ctx, "getSnap", params, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	// 截图失败时zlm返回json错误信息
+	result := apiResult{}
+	if json.Unmarshal(data, &result) == nil && result.Code != 0 {
+		return nil, &APIError{API: "getSnap", Code: result.Code, Msg: result.Msg}
+	}
+	return data, nil
+}
+
+// 修改zlm配置, key为配置项全名, 如record.enableFmp4
+func (c *Client) SetServerConfig(ctx context.Context, config map[string]string) error {
+	params := url.Values{}
+	for k, v := range config {
+		params.Set(k, v)
+	}
+	return c.do(ctx, "setServerConfig", params, nil, true, nil)
+}
+
+// 拉流代理请求
+type StreamProxyReq struct {
+	App        string
+	Stream     string
+	URL        string
+	RtpType    int  // rtsp拉流方式 0:tcp 1:udp 2:组播
+	EnableMp4  bool // 是否mp4录制
+	RetryCount int  // 拉流失败重试次数, -1为无限重试, 0使用zlm默认配置
+}
+
+type streamProxyRsp struct {
+	Data struct {
+		Key string `json:"key"`
+	} `json:"data"`
+}
+
+// 添加拉流代理, 返回代理key
+func (c *Client) AddStreamProxy(ctx context.Context, req StreamProxyReq) (string, error) {
+	params := url.Values{}
+	params.Set("vhost", defaultVhost)
+	params.Set("app", req.App)
+	params.Set("stream", req.Stream)
+	params.Set("url", req.URL)
+	params.Set("rtp_type", strconv.Itoa(req.RtpType))
+	if req.EnableMp4 {
+		params.Set("enable_mp4", "1")
+	}
+	if req.RetryCount != 0 {
+		params.Set("retry_count", strconv.Itoa(req.RetryCount))
+	}
+	res := &streamProxyRsp{}
+	if err := c.do(ctx, "addStreamProxy", params, nil, false, res); err != nil {
+		return "", err
+	}
+	return res.Data.Key, nil
+}
+
+// 删除拉流代理
+func (c *Client) DelStreamProxy(ctx context.Context, key string) error {
+	params := url.Values{}
+	params.Set("key", key)
+	return c.do(ctx, "delStreamProxy", params, nil, true, nil)
+}
+
+// 拉流代理信息
+type StreamProxyInfo struct {
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	Status      int    `json:"status"`      // 拉流状态, 0为成功
+	LiveSecs    int64  `json:"liveSecs"`    // 拉流成功后的持续时间, 单位秒
+	RePullCount int    `json:"rePullCount"` // 重试次数
+	Src         struct {
+		Vhost  string `json:"vhost"`
+		App    string `json:"app"`
+		Stream string `json:"stream"`
+	} `json:"src"`
+}
+
+type listStreamProxyRsp struct {
+	Data []StreamProxyInfo `json:"data"`
+}
+
+// 查询所有拉流代理
+func (c *Client) ListStreamProxy(ctx context.Context) ([]StreamProxyInfo, error) {
+	res := &listStreamProxyRsp{}
+	if err := c.do(ctx, "listStreamProxy", url.Values{}, nil, true, res); err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// 推流代理参数
+type StreamPusherProxyReq struct {
+	Schema     string // 源流协议 rtsp rtmp ts
+	App        string
+	Stream     string
+	DstURL     string // 推流目标地址
+	RetryCount int    // 推流失败重试次数, -1为无限重试, 0使用zlm默认配置
+}
+
+// 添加推流代理, 将zlm上的流推到第三方服务器, 返回代理key
+func (c *Client) AddStreamPusherProxy(ctx context.Context, req StreamPusherProxyReq) (string, error) {
+	params := url.Values{}
+	params.Set("schema", req.Schema)
+	params.Set("vhost", defaultVhost)
+	params.Set("app", req.App)
+	params.Set("stream", req.Stream)
+	params.Set("dst_url", req.DstURL)
+	if req.RetryCount != 0 {
+		params.Set("retry_count", strconv.Itoa(req.RetryCount))
+	}
+	res := &streamProxyRsp{}
+	if err := c.do(ctx, "addStreamPusherProxy", params, nil, false, res); err != nil {
+		return "", err
+	}
+	return res.Data.Key, nil
+}
+
+// 删除推流代理
+func (c *Client) DelStreamPusherProxy(ctx context.Context, key string) error {
+	params := url.Values{}
+	params.Set("key", key)
+	return c.do(ctx, "delStreamPusherProxy", params, nil, true, nil)
+}
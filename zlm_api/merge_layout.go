@@ -0,0 +1,179 @@
+package zlm_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "go-sip/logger"
+	"go-sip/model"
+
+	"go.uber.org/zap"
+)
+
+// sub_ipc使用合屏布局时的前缀, 格式为 layout:<布局id>[:ipc1_ipc2]
+// 布局中未绑定ipc的画面按顺序使用后面的ipc列表
+const MergeLayoutPrefix = "layout:"
+
+// 解析sub_ipc中的布局id和ipc列表
+func ParseLayoutSubIpc(subIpc string) (layoutId string, ipcList []string, ok bool) {
+	if !strings.HasPrefix(subIpc, MergeLayoutPrefix) {
+		return "", nil, false
+	}
+	layoutId, ipcs, _ := strings.Cut(strings.TrimPrefix(subIpc, MergeLayoutPrefix), ":")
+	if layoutId == "" {
+		return "", nil, false
+	}
+	if ipcs != "" {
+		ipcList = strings.Split(ipcs, "_")
+	}
+	return layoutId, ipcList, true
+}
+
+// 校验合屏布局, 画面不能超出网格且不能重叠
+func ValidateMergeLayout(layout *model.MergeLayout) error {
+	if layout.Row <= 0 || layout.Col <= 0 || layout.Row > 16 || layout.Col > 16 {
+		return errors.New("行列数必须在1-16之间")
+	}
+	if layout.Width <= 0 || layout.Height <= 0 || layout.Width%2 != 0 || layout.Height%2 != 0 {
+		return errors.New("输出分辨率必须为正偶数")
+	}
+	if layout.GapV < 0 || layout.GapV >= 0.5 || layout.GapH < 0 || layout.GapH >= 0.5 {
+		return errors.New("间距比例必须在0-0.5之间")
+	}
+	if len(layout.Tiles) == 0 {
+		return errors.New("画面列表不能为空")
+	}
+	used := make([][]bool, layout.Row)
+	for i := range used {
+		used[i] = make([]bool, layout.Col)
+	}
+	for i, tile := range layout.Tiles {
+		rowSpan, colSpan := tileSpan(tile)
+		if tile.Row < 0 || tile.Col < 0 || tile.Row+rowSpan > layout.Row || tile.Col+colSpan > layout.Col {
+			return fmt.Errorf("第%d个画面超出网格", i+1)
+		}
+		if tile.StreamType != 0 && tile.StreamType != 1 {
+			return fmt.Errorf("第%d个画面码流错误", i+1)
+		}
+		for r := tile.Row; r < tile.Row+rowSpan; r++ {
+			for c := tile.Col; c < tile.Col+colSpan; c++ {
+				if used[r][c] {
+					return fmt.Errorf("第%d个画面与其他画面重叠", i+1)
+				}
+				used[r][c] = true
+			}
+		}
+	}
+	return nil
+}
+
+func tileSpan(tile model.MergeLayoutTile) (int, int) {
+	rowSpan, colSpan := tile.RowSpan, tile.ColSpan
+	if rowSpan <= 0 {
+		rowSpan = 1
+	}
+	if colSpan <= 0 {
+		colSpan = 1
+	}
+	return rowSpan, colSpan
+}
+
+// 按布局生成拼接流参数, 返回参数和需要拉取的流id列表
+// ipcList按顺序填充未绑定ipc的画面, 没有ipc的画面为空白
+func BuildMergeLayoutConfig(layout *model.MergeLayout, streamId string, zlmInfo *model.ZlmInfo, ipcList []string) (model.StreamMergeConfigDTO, []string, error) {
+	cfg := model.StreamMergeConfigDTO{}
+	if err := ValidateMergeLayout(layout); err != nil {
+		return cfg, nil, err
+	}
+	cfg = model.StreamMergeConfigDTO{
+		GapV:   layout.GapV,
+		GapH:   layout.GapH,
+		Width:  layout.Width,
+		Height: layout.Height,
+		Fps:    layout.Fps,
+		Row:    layout.Row,
+		Col:    layout.Col,
+		ID:     streamId,
+		URL:    make([][]string, layout.Row),
+		Span:   [][2][2]int{},
+	}
+	for i := range cfg.URL {
+		cfg.URL[i] = make([]string, layout.Col)
+	}
+
+	streamIdList := []string{}
+	next := 0
+	for _, tile := range layout.Tiles {
+		ipcId := tile.IpcId
+		if ipcId == "" && next < len(ipcList) {
+			ipcId = ipcList[next]
+			next++
+		}
+		rowSpan, colSpan := tileSpan(tile)
+		if rowSpan > 1 || colSpan > 1 {
+			cfg.Span = append(cfg.Span, [2][2]int{{tile.Row, tile.Col}, {tile.Row + rowSpan - 1, tile.Col + colSpan - 1}})
+		}
+		if ipcId == "" {
+			continue
+		}
+		stream := fmt.Sprintf("%s_%d", ipcId, tile.StreamType)
+		cfg.URL[tile.Row][tile.Col] = fmt.Sprintf("rtsp://%s:554/rtp/%s?originTypeStr=rtp_push&mode=1", zlmInfo.ZlmIp, stream)
+		streamIdList = append(streamIdList, stream)
+	}
+	if len(streamIdList) == 0 {
+		return cfg, nil, errors.New("布局没有绑定任何ipc")
+	}
+	return cfg, streamIdList, nil
+}
+
+// 按拼接流参数合屏, reset为true时切换已存在的拼接流的布局
+func ZlmMergeStreamConfig(cfg model.StreamMergeConfigDTO, zlmInfo *model.ZlmInfo, reset bool) StreamMergeInfoVO {
+	if zlmInfo == nil {
+		Logger.Error("zlmInfo 不能为空")
+		return StreamMergeInfoVO{Code: -1}
+	}
+	Logger.Info("zlm进行拼接流", zap.String("zlmDomain", zlmInfo.ZlmDomain), zap.Bool("reset", reset), zap.Any("streamMergeConfigDTO", cfg))
+
+	client := NewClientByZlmInfo(zlmInfo)
+	var err error
+	if reset {
+		// 切屏
+		err = client.StackReset(context.Background(), cfg)
+	} else {
+		// 合屏
+		err = client.StackStart(context.Background(), cfg)
+	}
+	if err != nil {
+		if reset {
+			Logger.Error("切屏失败", zap.Error(err))
+		} else {
+			Logger.Error("合屏失败", zap.Error(err))
+		}
+		return StreamMergeInfoVO{Code: ErrorCode(err)}
+	}
+	return StreamMergeInfoVO{}
+}
+
+// 按布局合屏, 拼接流已存在时切换布局, 不存在时开始合屏
+func ZlmMergeStreamByLayout(layout *model.MergeLayout, streamId string, ipcList []string, zlmInfo *model.ZlmInfo) StreamMergeInfoVO {
+	if zlmInfo == nil {
+		Logger.Error("zlmInfo 不能为空")
+		return StreamMergeInfoVO{Code: -1}
+	}
+	cfg, _, err := BuildMergeLayoutConfig(layout, streamId, zlmInfo, ipcList)
+	if err != nil {
+		Logger.Error("合屏布局错误", zap.Int64("layoutId", layout.ID), zap.Error(err))
+		return StreamMergeInfoVO{Code: -1}
+	}
+	client := NewClientByZlmInfo(zlmInfo)
+	mediaList, err := client.GetMediaList(context.Background(), ZlmGetMediaListReq{
+		App:      "rtp",
+		Vhost:    "__defaultVhost__",
+		Schema:   "rtsp",
+		StreamID: streamId,
+	})
+	exists := err == nil && len(mediaList) > 0
+	return ZlmMergeStreamConfig(cfg, zlmInfo, exists)
+}
@@ -0,0 +1,124 @@
+package zlm_api
+
+import (
+	"reflect"
+	"testing"
+
+	"go-sip/model"
+)
+
+func TestParseLayoutSubIpc(t *testing.T) {
+	tests := []struct {
+		subIpc   string
+		layoutId string
+		ipcList  []string
+		ok       bool
+	}{
+		{"layout:12", "12", nil, true},
+		{"layout:12:ipc1_ipc2", "12", []string{"ipc1", "ipc2"}, true},
+		{"layout:12:", "12", nil, true},
+		{"layout:", "", nil, false},
+		{"ipc1_ipc2", "", nil, false},
+	}
+	for _, tt := range tests {
+		layoutId, ipcList, ok := ParseLayoutSubIpc(tt.subIpc)
+		if layoutId != tt.layoutId || !reflect.DeepEqual(ipcList, tt.ipcList) || ok != tt.ok {
+			t.Errorf("ParseLayoutSubIpc(%q) = %q, %v, %v", tt.subIpc, layoutId, ipcList, ok)
+		}
+	}
+}
+
+// 2x2网格, 第一个画面占满第一行
+func testMergeLayout() model.MergeLayout {
+	return model.MergeLayout{
+		Width:  1920,
+		Height: 1080,
+		Row:    2,
+		Col:    2,
+		Tiles: []model.MergeLayoutTile{
+			{Row: 0, Col: 0, ColSpan: 2, IpcId: "ipc1", StreamType: 1},
+			{Row: 1, Col: 0},
+			{Row: 1, Col: 1},
+		},
+	}
+}
+
+func TestValidateMergeLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(layout *model.MergeLayout)
+		wantErr bool
+	}{
+		{"合法布局", func(layout *model.MergeLayout) {}, false},
+		{"行数为0", func(layout *model.MergeLayout) { layout.Row = 0 }, true},
+		{"列数超过16", func(layout *model.MergeLayout) { layout.Col = 17 }, true},
+		{"分辨率为奇数", func(layout *model.MergeLayout) { layout.Width = 1921 }, true},
+		{"分辨率为0", func(layout *model.MergeLayout) { layout.Height = 0 }, true},
+		{"间距为负数", func(layout *model.MergeLayout) { layout.GapV = -0.1 }, true},
+		{"间距过大", func(layout *model.MergeLayout) { layout.GapH = 0.5 }, true},
+		{"画面为空", func(layout *model.MergeLayout) { layout.Tiles = nil }, true},
+		{"画面超出网格", func(layout *model.MergeLayout) { layout.Tiles[1].RowSpan = 2 }, true},
+		{"画面坐标为负数", func(layout *model.MergeLayout) { layout.Tiles[1].Col = -1 }, true},
+		{"码流错误", func(layout *model.MergeLayout) { layout.Tiles[2].StreamType = 2 }, true},
+		{"画面重叠", func(layout *model.MergeLayout) { layout.Tiles[2].Row = 0 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := testMergeLayout()
+			tt.modify(&layout)
+			if err := ValidateMergeLayout(&layout); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateMergeLayout() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildMergeLayoutConfig(t *testing.T) {
+	zlmInfo := &model.ZlmInfo{ZlmIp: "10.0.0.1"}
+	layout := testMergeLayout()
+	cfg, streams, err := BuildMergeLayoutConfig(&layout, "merge1", zlmInfo, []string{"ipc2", "ipc3", "ipc4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantURL := [][]string{
+		{"rtsp://10.0.0.1:554/rtp/ipc1_1?originTypeStr=rtp_push&mode=1", ""},
+		{"rtsp://10.0.0.1:554/rtp/ipc2_0?originTypeStr=rtp_push&mode=1", "rtsp://10.0.0.1:554/rtp/ipc3_0?originTypeStr=rtp_push&mode=1"},
+	}
+	if !reflect.DeepEqual(cfg.URL, wantURL) {
+		t.Fatalf("URL = %v, want %v", cfg.URL, wantURL)
+	}
+	if wantSpan := [][2][2]int{{{0, 0}, {0, 1}}}; !reflect.DeepEqual(cfg.Span, wantSpan) {
+		t.Fatalf("Span = %v, want %v", cfg.Span, wantSpan)
+	}
+	if wantStreams := []string{"ipc1_1", "ipc2_0", "ipc3_0"}; !reflect.DeepEqual(streams, wantStreams) {
+		t.Fatalf("streams = %v, want %v", streams, wantStreams)
+	}
+	if cfg.ID != "merge1" || cfg.Row != 2 || cfg.Col != 2 || cfg.Width != 1920 || cfg.Height != 1080 {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+}
+
+func TestBuildMergeLayoutConfigBlank(t *testing.T) {
+	zlmInfo := &model.ZlmInfo{ZlmIp: "10.0.0.1"}
+	layout := testMergeLayout()
+	// ipc不足时剩余画面为空白
+	cfg, streams, err := BuildMergeLayoutConfig(&layout, "merge1", zlmInfo, []string{"ipc2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.URL[1][1] != "" || len(streams) != 2 {
+		t.Fatalf("URL = %v, streams = %v", cfg.URL, streams)
+	}
+
+	// 没有绑定任何ipc
+	layout.Tiles[0].IpcId = ""
+	if _, _, err := BuildMergeLayoutConfig(&layout, "merge1", zlmInfo, nil); err == nil {
+		t.Fatal("没有绑定ipc应返回错误")
+	}
+
+	// 布局非法
+	layout.Row = 0
+	if _, _, err := BuildMergeLayoutConfig(&layout, "merge1", zlmInfo, []string{"ipc2"}); err == nil {
+		t.Fatal("布局非法应返回错误")
+	}
+}
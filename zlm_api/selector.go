@@ -0,0 +1,216 @@
+package zlm_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	. "go-sip/logger"
+	"go-sip/model"
+
+	"go.uber.org/zap"
+)
+
+// zlm节点选择策略
+const (
+	SelectStrategyConsistentHash = "consistent_hash" // 一致性哈希, 增删节点只影响少量设备
+	SelectStrategyWeighted       = "weighted"        // 按节点权重的一致性哈希
+	SelectStrategyLeastStreams   = "least_streams"   // 流数量/权重最少的节点
+	SelectStrategyLeastBandwidth = "least_bandwidth" // 带宽/权重最低的节点
+	SelectStrategyLeastLoad      = "least_load"      // 网络线程平均负载最低的节点
+
+	nodeLoadExpire = 2 * time.Minute // 超过该时间未更新的负载采样视为无效
+)
+
+// zlm节点选择器, key一般为设备id
+type NodeSelector interface {
+	Select(nodes []model.ZlmAndRegionInfo, key string) (*model.ZlmAndRegionInfo, error)
+}
+
+type NodeSelectorFunc func(nodes []model.ZlmAndRegionInfo, key string) (*model.ZlmAndRegionInfo, error)
+
+func (f NodeSelectorFunc) Select(nodes []model.ZlmAndRegionInfo, key string) (*model.ZlmAndRegionInfo, error) {
+	return f(nodes, key)
+}
+
+var (
+	selectorMu sync.RWMutex
+	selectors  = map[string]NodeSelector{
+		SelectStrategyConsistentHash: NodeSelectorFunc(selectConsistentHash),
+		SelectStrategyWeighted:       NodeSelectorFunc(selectWeighted),
+		SelectStrategyLeastStreams:   leastSelector(streamCount),
+		SelectStrategyLeastBandwidth: leastSelector(bandwidth),
+		SelectStrategyLeastLoad:      leastSelector(threadsLoad),
+	}
+)
+
+// 注册自定义选择策略, 同名策略会被覆盖
+func RegisterNodeSelector(strategy string, selector NodeSelector) {
+	selectorMu.Lock()
+	defer selectorMu.Unlock()
+	selectors[strategy] = selector
+}
+
+// 策略是否存在, 空字符串为默认策略
+func IsValidSelectStrategy(strategy string) bool {
+	if strategy == "" {
+		return true
+	}
+	selectorMu.RLock()
+	defer selectorMu.RUnlock()
+	_, ok := selectors[strategy]
+	return ok
+}
+
+// 按策略从节点列表中选出一个节点, 策略为空或不存在时使用一致性哈希
+// loads为redis中zlmDomain关联的节点负载采样, 供按负载选择的策略使用
+func SelectZlmNode(strategy string, nodes []model.ZlmAndRegionInfo, key string, loads map[string]string) (*model.ZlmAndRegionInfo, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("配置列表为空")
+	}
+	if key == "" {
+		return nil, errors.New("key不能为空")
+	}
+	parseNodeLoads(nodes, loads, time.Now())
+	selectorMu.RLock()
+	selector, ok := selectors[strategy]
+	selectorMu.RUnlock()
+	if !ok {
+		if strategy != "" {
+			Logger.Warn("zlm节点选择策略不存在, 使用一致性哈希", zap.String("strategy", strategy))
+		}
+		selector = NodeSelectorFunc(selectConsistentHash)
+	}
+	return selector.Select(nodes, key)
+}
+
+func nodeWeight(node *model.ZlmAndRegionInfo) float64 {
+	if node.Weight <= 0 {
+		return 1
+	}
+	return float64(node.Weight)
+}
+
+// 节点对key的哈希得分, 取值(0,1)
+func hashScore(key, domain string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(domain))
+	// fnv的高位对末尾字节变化不敏感, 域名只差最后一位时得分接近, 用splitmix64的混合函数打散
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return (float64(x>>11) + 0.5) / (1 << 53)
+}
+
+// 最高随机权重哈希(rendezvous hashing), 增删节点时只有该节点上的key会迁移
+func selectConsistentHash(nodes []model.ZlmAndRegionInfo, key string) (*model.ZlmAndRegionInfo, error) {
+	best, bestScore := -1, -1.0
+	for i := range nodes {
+		if score := hashScore(key, nodes[i].ZlmDomain); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return &nodes[best], nil
+}
+
+// 加权rendezvous hashing, 每个节点分到的key数量与权重成正比
+func selectWeighted(nodes []model.ZlmAndRegionInfo, key string) (*model.ZlmAndRegionInfo, error) {
+	best, bestScore := -1, math.Inf(-1)
+	for i := range nodes {
+		score := -nodeWeight(&nodes[i]) / math.Log(hashScore(key, nodes[i].ZlmDomain))
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return &nodes[best], nil
+}
+
+// 节点的负载指标, 值越小越空闲, 没有采样时返回false
+type nodeMetric func(load *model.ZlmNodeLoad) (float64, bool)
+
+// 按zlm节点健康检查时采样的节点负载选择 指标/权重 最小的节点, 不在选择时请求zlm
+// 所有节点都没有有效采样时退化为加权一致性哈希
+func leastSelector(metric nodeMetric) NodeSelector {
+	return NodeSelectorFunc(func(nodes []model.ZlmAndRegionInfo, key string) (*model.ZlmAndRegionInfo, error) {
+		best, bestValue := -1, math.Inf(1)
+		for i := range nodes {
+			if nodes[i].Load == nil {
+				continue
+			}
+			value, ok := metric(nodes[i].Load)
+			if !ok {
+				continue
+			}
+			value = value / nodeWeight(&nodes[i])
+			// 负载相同时按哈希得分选择, 避免设备集中到同一节点
+			if value < bestValue || (value == bestValue && hashScore(key, nodes[i].ZlmDomain) > hashScore(key, nodes[best].ZlmDomain)) {
+				best, bestValue = i, value
+			}
+		}
+		if best < 0 {
+			Logger.Warn("zlm节点没有有效的负载采样, 使用加权一致性哈希")
+			return selectWeighted(nodes, key)
+		}
+		return &nodes[best], nil
+	})
+}
+
+func streamCount(load *model.ZlmNodeLoad) (float64, bool) {
+	return float64(load.Streams), true
+}
+
+func bandwidth(load *model.ZlmNodeLoad) (float64, bool) {
+	return float64(load.BytesSpeed), true
+}
+
+func threadsLoad(load *model.ZlmNodeLoad) (float64, bool) {
+	return float64(load.ThreadLoad), load.ThreadLoad >= 0
+}
+
+// 采集zlm节点负载, 流数量和带宽按rtsp协议的流列表统计, 线程负载采集失败时为-1
+func SampleNodeLoad(ctx context.Context, client *Client, now time.Time) (*model.ZlmNodeLoad, error) {
+	media_list, err := client.GetMediaList(ctx, ZlmGetMediaListReq{Schema: "rtsp"})
+	if err != nil {
+		return nil, err
+	}
+	load := &model.ZlmNodeLoad{Streams: len(media_list), ThreadLoad: -1, UpdateTime: now.Unix()}
+	for _, media := range media_list {
+		load.BytesSpeed += media.BytesSpeed
+	}
+	if thread_loads, err := client.GetThreadsLoad(ctx); err == nil && len(thread_loads) > 0 {
+		total := 0
+		for _, thread_load := range thread_loads {
+			total += thread_load.Load
+		}
+		load.ThreadLoad = total / len(thread_loads)
+	}
+	return load, nil
+}
+
+// 解析redis中的节点负载采样, 忽略过期的采样
+func parseNodeLoads(nodes []model.ZlmAndRegionInfo, loads map[string]string, now time.Time) {
+	for i := range nodes {
+		nodes[i].Load = nil
+		load_str, ok := loads[nodes[i].ZlmDomain]
+		if !ok {
+			continue
+		}
+		load := &model.ZlmNodeLoad{}
+		if err := json.Unmarshal([]byte(load_str), load); err != nil {
+			continue
+		}
+		if now.Unix()-load.UpdateTime > int64(nodeLoadExpire/time.Second) {
+			continue
+		}
+		nodes[i].Load = load
+	}
+}
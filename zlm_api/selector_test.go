@@ -0,0 +1,179 @@
+package zlm_api
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	. "go-sip/logger"
+	"go-sip/model"
+
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+func testNodes() []model.ZlmAndRegionInfo {
+	return []model.ZlmAndRegionInfo{
+		{ZlmDomain: "zlm1", Weight: 1},
+		{ZlmDomain: "zlm2", Weight: 2},
+		{ZlmDomain: "zlm3", Weight: 1},
+	}
+}
+
+func testLoad(streams int, bytesSpeed int64, threadLoad int, updateTime int64) string {
+	return fmt.Sprintf(`{"streams":%d,"bytesSpeed":%d,"threadLoad":%d,"updateTime":%d}`, streams, bytesSpeed, threadLoad, updateTime)
+}
+
+func TestSelectZlmNodeArgs(t *testing.T) {
+	if _, err := SelectZlmNode(SelectStrategyConsistentHash, nil, "dev1", nil); err == nil {
+		t.Fatal("节点列表为空应返回错误")
+	}
+	if _, err := SelectZlmNode(SelectStrategyConsistentHash, testNodes(), "", nil); err == nil {
+		t.Fatal("key为空应返回错误")
+	}
+}
+
+func TestSelectZlmNodeHash(t *testing.T) {
+	for _, strategy := range []string{SelectStrategyConsistentHash, SelectStrategyWeighted, "", "unknown"} {
+		t.Run(strategy, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("dev%d", i)
+				first, err := SelectZlmNode(strategy, testNodes(), key, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				second, _ := SelectZlmNode(strategy, testNodes(), key, nil)
+				if first.ZlmDomain != second.ZlmDomain {
+					t.Fatalf("key %s 两次选择结果不一致: %s %s", key, first.ZlmDomain, second.ZlmDomain)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectConsistentHashRemoveNode(t *testing.T) {
+	nodes := testNodes()
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("dev%d", i)
+		before, _ := selectConsistentHash(nodes, key)
+		if before.ZlmDomain == "zlm3" {
+			continue
+		}
+		// 删除其他节点时, 不在该节点上的key不迁移
+		after, _ := selectConsistentHash(nodes[:2], key)
+		if after.ZlmDomain != before.ZlmDomain {
+			t.Fatalf("key %s 从 %s 迁移到 %s", key, before.ZlmDomain, after.ZlmDomain)
+		}
+	}
+}
+
+func TestSelectWeightedDistribution(t *testing.T) {
+	nodes := testNodes()
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		node, _ := selectWeighted(nodes, fmt.Sprintf("dev%d", i))
+		counts[node.ZlmDomain]++
+	}
+	// zlm2权重是其他节点的两倍, 分到的key约为一半
+	if counts["zlm2"] < 1700 || counts["zlm2"] > 2300 {
+		t.Fatalf("权重分配不均: %v", counts)
+	}
+}
+
+func TestSelectZlmNodeLeast(t *testing.T) {
+	now := time.Now().Unix()
+	stale := now - int64(nodeLoadExpire/time.Second) - 1
+	tests := []struct {
+		name     string
+		strategy string
+		loads    map[string]string
+		want     string
+	}{
+		{"流数量最少", SelectStrategyLeastStreams, map[string]string{
+			"zlm1": testLoad(10, 0, 0, now), "zlm2": testLoad(30, 0, 0, now), "zlm3": testLoad(5, 0, 0, now),
+		}, "zlm3"},
+		{"流数量按权重折算", SelectStrategyLeastStreams, map[string]string{
+			"zlm1": testLoad(10, 0, 0, now), "zlm2": testLoad(16, 0, 0, now), "zlm3": testLoad(9, 0, 0, now),
+		}, "zlm2"},
+		{"带宽最低", SelectStrategyLeastBandwidth, map[string]string{
+			"zlm1": testLoad(0, 1000, 0, now), "zlm2": testLoad(0, 4000, 0, now), "zlm3": testLoad(0, 3000, 0, now),
+		}, "zlm1"},
+		{"线程负载最低", SelectStrategyLeastLoad, map[string]string{
+			"zlm1": testLoad(0, 0, 50, now), "zlm2": testLoad(0, 0, 80, now), "zlm3": testLoad(0, 0, 30, now),
+		}, "zlm3"},
+		{"忽略采集失败的线程负载", SelectStrategyLeastLoad, map[string]string{
+			"zlm1": testLoad(0, 0, 40, now), "zlm2": testLoad(0, 0, 90, now), "zlm3": testLoad(0, 0, -1, now),
+		}, "zlm1"},
+		{"忽略过期采样", SelectStrategyLeastStreams, map[string]string{
+			"zlm1": testLoad(10, 0, 0, now), "zlm2": testLoad(30, 0, 0, now), "zlm3": testLoad(0, 0, 0, stale),
+		}, "zlm1"},
+		{"忽略格式错误的采样", SelectStrategyLeastStreams, map[string]string{
+			"zlm1": testLoad(10, 0, 0, now), "zlm2": testLoad(30, 0, 0, now), "zlm3": "{",
+		}, "zlm1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := SelectZlmNode(tt.strategy, testNodes(), "dev1", tt.loads)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if node.ZlmDomain != tt.want {
+				t.Fatalf("SelectZlmNode() = %s, want %s", node.ZlmDomain, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectZlmNodeLeastFallback(t *testing.T) {
+	stale := time.Now().Unix() - int64(nodeLoadExpire/time.Second) - 1
+	tests := []struct {
+		name     string
+		strategy string
+		loads    map[string]string
+	}{
+		{"没有采样", SelectStrategyLeastStreams, nil},
+		{"采样全部过期", SelectStrategyLeastBandwidth, map[string]string{
+			"zlm1": testLoad(1, 1, 1, stale), "zlm2": testLoad(1, 1, 1, stale), "zlm3": testLoad(1, 1, 1, stale),
+		}},
+		{"线程负载全部采集失败", SelectStrategyLeastLoad, map[string]string{
+			"zlm1": testLoad(0, 0, -1, time.Now().Unix()), "zlm2": testLoad(0, 0, -1, time.Now().Unix()),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				key := fmt.Sprintf("dev%d", i)
+				node, err := SelectZlmNode(tt.strategy, testNodes(), key, tt.loads)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := selectWeighted(testNodes(), key)
+				if node.ZlmDomain != want.ZlmDomain {
+					t.Fatalf("key %s 应退化为加权一致性哈希: %s, want %s", key, node.ZlmDomain, want.ZlmDomain)
+				}
+			}
+		})
+	}
+}
+
+func TestIsValidSelectStrategy(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     bool
+	}{
+		{"", true},
+		{SelectStrategyConsistentHash, true},
+		{SelectStrategyLeastLoad, true},
+		{"round_robin", false},
+	}
+	for _, tt := range tests {
+		if got := IsValidSelectStrategy(tt.strategy); got != tt.want {
+			t.Errorf("IsValidSelectStrategy(%q) = %v, want %v", tt.strategy, got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,9 @@
 package zlm_api
 
 import (
+	"context"
 	. "go-sip/logger"
 	"go-sip/model"
-	"go-sip/utils"
-	"strconv"
 	"time"
 
 	"fmt"
@@ -59,151 +58,98 @@ type ZlmGetRtpInfoResp struct {
 	Exist bool `json:"exist"`
 }
 type ZlmGetMediaListDataResp struct {
-	App        string                  `json:"app"`
-	Stream     string                  `json:"stream"`
-	Schema     string                  `json:"schema"`
-	OriginType int                     `json:"originType"`
-	Tracks     []ZlmGetMediaListTracks `json:"tracks"`
+	App              string                  `json:"app"`
+	Stream           string                  `json:"stream"`
+	Schema           string                  `json:"schema"`
+	OriginType       int                     `json:"originType"`
+	OriginTypeStr    string                  `json:"originTypeStr"`
+	BytesSpeed       int64                   `json:"bytesSpeed"`       // 数据产生速度，单位byte/s
+	ReaderCount      int                     `json:"readerCount"`      // 本协议观看人数
+	TotalReaderCount int                     `json:"totalReaderCount"` // 所有协议观看人数
+	CreateStamp      int64                   `json:"createStamp"`      // 流创建时间戳，单位秒
+	AliveSecond      int64                   `json:"aliveSecond"`      // 存活时间，单位秒
+	Tracks           []ZlmGetMediaListTracks `json:"tracks"`
 }
 type ZlmGetMediaListTracks struct {
-	Type    int     `json:"codec_type"`
-	CodecID int     `json:"codec_id"`
-	Height  int     `json:"height"`
-	Width   int     `json:"width"`
-	FPS     float64 `json:"fps"`
+	Type        int     `json:"codec_type"` // 0视频 1音频
+	CodecID     int     `json:"codec_id"`
+	CodecIdName string  `json:"codec_id_name"`
+	Height      int     `json:"height"`
+	Width       int     `json:"width"`
+	FPS         float64 `json:"fps"`
+}
+
+// 流的播放者
+type ZlmMediaPlayer struct {
+	Identifier string `json:"identifier"`
+	LocalIp    string `json:"local_ip"`
+	LocalPort  int    `json:"local_port"`
+	PeerIp     string `json:"peer_ip"`
+	PeerPort   int    `json:"peer_port"`
+	TypeId     string `json:"typeid"` // 播放协议会话类型
 }
 
 // Zlm 开始active模式发送rtp
 // 作为zlm客户端，启动ps-rtp推流，支持rtp/udp方式；该接口支持rtsp/rtmp等协议转ps-rtp推流。第一次推流失败会直接返回错误，成功一次后，后续失败也将无限重试。
 func ZlmStartSendRtp(url, secret string, req ZlmStartSendRtpReq) ZlmStartSendRtpResp {
-	res := ZlmStartSendRtpResp{}
-	reqStr := "/index/api/startSendRtp?secret=" + secret
-	if req.StreamID != "" {
-		reqStr += "&stream=" + req.StreamID
-	}
-	if req.App != "" {
-		reqStr += "&app=" + req.App
-	}
-	if req.Vhost != "" {
-		reqStr += "&vhost=" + req.Vhost
-	}
-	if req.DstUrl != "" {
-		reqStr += "&dst_url=" + req.DstUrl
-	}
-	if req.DstPort != "" {
-		reqStr += "&dst_port=" + req.DstPort
-	}
-	if req.IsUdp != "" {
-		reqStr += "&is_udp=" + req.IsUdp
-	}
-	if req.Ssrc != "" {
-		reqStr += "&ssrc=" + req.Ssrc
-	}
-	reqUrl := url + reqStr
-	Logger.Info("开始active模式发送rtp", zap.Any("reqUrl", reqUrl))
-	body, err := utils.GetRequest(reqUrl)
+	Logger.Info("开始active模式发送rtp", zap.String("url", url), zap.Any("req", req))
+	port, err := NewClient(url, secret).StartSendRtp(context.Background(), req)
 	if err != nil {
-		Logger.Error("ZlmStartSendRtp fail, 1", zap.Error(err))
-		return res
-	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("ZlmStartSendRtp fail, 2", zap.Error(err))
-		return res
-	}
-	if res.Code != 0 {
-		Logger.Error("ZlmStartSendRtp fail, 3", zap.Error(err))
-		return res
+		Logger.Error("ZlmStartSendRtp fail", zap.Error(err))
+		return ZlmStartSendRtpResp{Code: ErrorCode(err)}
 	}
+	res := ZlmStartSendRtpResp{LocalPort: port}
 	Logger.Info("ZlmStartSendRtp res", zap.Any("res", res))
 	return res
 }
 
 // Zlm 停止GB28181 ps-rtp推流
 func ZlmStopSendRtp(url, secret string, req ZlmStopSendRtpReq) ZlmStopSendRtpResp {
-	res := ZlmStopSendRtpResp{}
-	reqStr := "/index/api/stopSendRtp?secret=" + secret
-	if req.StreamID != "" {
-		reqStr += "&stream=" + req.StreamID
+	Logger.Info("停止rtp推流", zap.String("url", url), zap.Any("req", req))
+	if err := NewClient(url, secret).StopSendRtp(context.Background(), req); err != nil {
+		Logger.Error("ZlmStopSendRtp fail", zap.Error(err))
+		return ZlmStopSendRtpResp{Code: ErrorCode(err)}
 	}
-	if req.App != "" {
-		reqStr += "&app=" + req.App
-	}
-	if req.Vhost != "" {
-		reqStr += "&vhost=" + req.Vhost
-	}
-	if req.Ssrc != "" {
-		reqStr += "&ssrc=" + req.Ssrc
-	}
-	reqUrl := url + reqStr
-	Logger.Info("停止rtp推流", zap.Any("reqUrl", reqUrl))
-	body, err := utils.GetRequest(reqUrl)
-	if err != nil {
-		Logger.Error("ZlmStopSendRtp fail, 1", zap.Error(err))
-		return res
-	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("ZlmStopSendRtp fail, 2", zap.Error(err))
-		return res
-	}
-	if res.Code != 0 {
-		Logger.Error("ZlmStopSendRtp fail, 3", zap.Error(err))
-		return res
-	}
-	Logger.Info("ZlmStopSendRtp res", zap.Any("res", res))
-	return res
+	return ZlmStopSendRtpResp{}
 }
 
 // Zlm 获取RTP流信息
 func ZlmGetRtpInfo(url, secret string, req ZlmGetRtpInfoReq) ZlmGetRtpInfoResp {
-	res := ZlmGetRtpInfoResp{}
-	reqStr := "/index/api/getRtpInfo?secret=" + secret
-	if req.StreamID != "" {
-		reqStr += "&stream_id=" + req.StreamID
-	}
-	if req.App != "" {
-		reqStr += "&app=" + req.App
-	}
-	if req.Vhost != "" {
-		reqStr += "&vhost=" + req.Vhost
-	}
-	body, err := utils.GetRequest(url + reqStr)
+	exist, err := NewClient(url, secret).GetRtpInfo(context.Background(), req)
 	if err != nil {
-		Logger.Error("get stream rtpInfo fail, 1", zap.Error(err))
-		return res
+		Logger.Error("get stream rtpInfo fail", zap.Error(err))
+		return ZlmGetRtpInfoResp{Code: ErrorCode(err)}
 	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("get stream rtpInfo fail, 2", zap.Error(err))
-		return res
-	}
-	return res
+	return ZlmGetRtpInfoResp{Exist: exist}
 }
 
 // Zlm 获取流列表信息
 func ZlmGetMediaList(url, secret string, req ZlmGetMediaListReq) ZlmGetMediaListResp {
-	res := ZlmGetMediaListResp{}
-	reqStr := "/index/api/getMediaList?secret=" + secret
-	if req.StreamID != "" {
-		reqStr += "&stream=" + req.StreamID
-	}
-	if req.App != "" {
-		reqStr += "&app=" + req.App
-	}
-	if req.Schema != "" {
-		reqStr += "&schema=" + req.Schema
-	}
-	if req.Vhost != "" {
-		reqStr += "&vhost=" + req.Vhost
-	}
-	body, err := utils.GetRequest(url + reqStr)
+	data, err := NewClient(url, secret).GetMediaList(context.Background(), req)
 	if err != nil {
-		Logger.Error("get stream mediaList fail, 1", zap.Error(err))
-		return res
+		Logger.Error("get stream mediaList fail", zap.Error(err))
+		return ZlmGetMediaListResp{Code: ErrorCode(err)}
 	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("get stream mediaList fail, 2", zap.Error(err))
-		return res
+	return ZlmGetMediaListResp{Data: data}
+}
+
+type ZlmVersionResp struct {
+	Code int `json:"code"`
+	Data struct {
+		BranchName string `json:"branchName"`
+		BuildTime  string `json:"buildTime"`
+		CommitHash string `json:"commitHash"`
+	} `json:"data"`
+}
+
+// Zlm 获取版本信息
+func ZlmGetVersion(url, secret string) ZlmVersionResp {
+	res, err := NewClient(url, secret).Version(context.Background())
+	if err != nil {
+		Logger.Error("get zlm version fail", zap.Error(err))
+		return ZlmVersionResp{Code: ErrorCode(err)}
 	}
-	return res
+	return *res
 }
 
 var ZlmDeviceVFMap = map[int]string{
@@ -228,27 +174,26 @@ type RtpInfo struct {
 
 // 获取流在Zlm上的信息
 func ZlmGetMediaInfo(url, secret, stream_id string) RtpInfo {
-	res := RtpInfo{}
-	body, err := utils.GetRequest(url + "/index/api/getRtpInfo?secret=" + secret + "&stream_id=" + stream_id)
+	exist, err := NewClient(url, secret).GetRtpInfo(context.Background(), ZlmGetRtpInfoReq{StreamID: stream_id})
 	if err != nil {
-		Logger.Error("get stream rtpInfo fail 1", zap.Error(err))
-		return res
-	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("get stream rtpInfo fail 2", zap.Error(err))
-		return res
+		Logger.Error("get stream rtpInfo fail", zap.Error(err))
+		return RtpInfo{Code: ErrorCode(err)}
 	}
-	return res
+	return RtpInfo{Exist: exist}
 }
 
 // Zlm 关闭流
 func ZlmCloseStreams(url, secret, stream_id string) {
-	utils.GetRequest(url + "/index/api/close_streams?secret=" + secret + "&stream=" + stream_id + "&schema=rtsp&vhost=__defaultVhost__&app=rtp&force=1")
+	if err := NewClient(url, secret).CloseStreams(context.Background(), stream_id); err != nil {
+		Logger.Error("close streams fail", zap.String("stream_id", stream_id), zap.Error(err))
+	}
 }
 
 // Zlm 强制关闭所有流
 func ZlmCloseAllStreams(url, secret string) {
-	utils.GetRequest(url + "/index/api/close_streams?secret=" + secret + "&vhost=__defaultVhost__&force=1")
+	if err := NewClient(url, secret).CloseStreams(context.Background(), ""); err != nil {
+		Logger.Error("close all streams fail", zap.Error(err))
+	}
 }
 
 func ZlmStartRtpServer(url, secret, stream_id, app string, tcp_mode int) OpenRtpRsp {
@@ -262,18 +207,12 @@ type OpenRtpRsp struct {
 
 // /openRtpServer
 func ZlmOpenRtpServer(url, secret, stream_id, app string, tcp_mode int) OpenRtpRsp {
-	res := OpenRtpRsp{}
-	// port: 接收端口，0则为随机端口
-	// tcp_mode: 0 udp 模式，1 tcp 被动模式, 2 tcp 主动模式
-	body, err := utils.GetRequest(url + "/index/api/openRtpServer?secret=" + secret + "&stream_id=" + stream_id + "&app=" + app + "&port=0" + "&tcp_mode=" + fmt.Sprintf("%d", tcp_mode))
+	port, err := NewClient(url, secret).OpenRtpServer(context.Background(), stream_id, app, tcp_mode)
 	if err != nil {
-		Logger.Error("open server rtp fail 1", zap.Error(err))
-		return res
-	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("open server rtp fail 2", zap.Error(err))
-		return res
+		Logger.Error("open server rtp fail", zap.Error(err))
+		return OpenRtpRsp{Code: ErrorCode(err)}
 	}
+	res := OpenRtpRsp{Port: port}
 	Logger.Info("open server rtp", zap.Any("res", res))
 	return res
 }
@@ -283,20 +222,14 @@ type CloseRtpRsp struct {
 	Hit  int `json:"hit"`
 }
 
-// /openRtpServer
+// /closeRtpServer
 func ZlmCloseRtpServer(url, secret, stream_id string) CloseRtpRsp {
-	res := CloseRtpRsp{}
-
-	body, err := utils.GetRequest(url + "/index/api/closeRtpServer?secret=" + secret + "&stream_id=" + stream_id)
+	hit, err := NewClient(url, secret).CloseRtpServer(context.Background(), stream_id)
 	if err != nil {
-		Logger.Error("close server rtp fail 1", zap.Error(err))
-		return res
+		Logger.Error("close server rtp fail", zap.Error(err))
+		return CloseRtpRsp{Code: ErrorCode(err)}
 	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("close server rtp fail 2", zap.Error(err))
-		return res
-	}
-	return res
+	return CloseRtpRsp{Hit: hit}
 }
 
 type StreamMergeInfoVO struct {
@@ -305,8 +238,17 @@ type StreamMergeInfoVO struct {
 
 // zlm视频流合屏
 func ZlmMergeStream(dto model.StreamMergeInfoDTO, zlmInfo *model.ZlmInfo) StreamMergeInfoVO {
+	// 检查 ipcIdList 并赋值 spanEnd
+	if len(dto.IpcIdList) == 0 {
+		Logger.Error("ipcIdList 不能为空")
+		return StreamMergeInfoVO{Code: -1}
+	}
+
+	if zlmInfo == nil {
+		Logger.Error("zlmInfo 不能为空")
+		return StreamMergeInfoVO{Code: -1}
+	}
 
-	res := StreamMergeInfoVO{}
 	streamMergeConfigDTO := model.StreamMergeConfigDTO{
 		GapV:   0,
 		GapH:   0,
@@ -317,18 +259,7 @@ func ZlmMergeStream(dto model.StreamMergeInfoDTO, zlmInfo *model.ZlmInfo) Stream
 		ID:     dto.StreamId,
 	}
 
-	streamMergeConfigDTO.Span = []int{}
-
-	// 检查 ipcIdList 并赋值 spanEnd
-	if len(dto.IpcIdList) == 0 {
-		Logger.Error("ipcIdList 不能为空")
-		return res
-	}
-
-	if zlmInfo == nil {
-		Logger.Error("zlmInfo 不能为空")
-		return res
-	}
+	streamMergeConfigDTO.Span = [][2][2]int{}
 
 	// 遍历 ipcIdList
 	urlList := [][]string{}
@@ -338,40 +269,16 @@ func ZlmMergeStream(dto model.StreamMergeInfoDTO, zlmInfo *model.ZlmInfo) Stream
 	}
 	urlList = append(urlList, urlSubList)
 	streamMergeConfigDTO.URL = urlList
-	// 使用http客户端调用 zlm 接口
-	var zlmUrl string
-	if dto.Type == 1 {
-		// 合屏
-		zlmUrl = fmt.Sprintf("%s/index/api/stack/start?secret=%s", zlmInfo.ZlmDomain, zlmInfo.ZlmSecret)
-	} else if dto.Type == 2 {
-		// 切屏
-		zlmUrl = fmt.Sprintf("%s/index/api/stack/reset?secret=%s", zlmInfo.ZlmDomain, zlmInfo.ZlmSecret)
-	}
-	Logger.Info("zlm进行拼接流", zap.String("zlmUrl", zlmUrl), zap.Any("streamMergeConfigDTO", streamMergeConfigDTO))
-
-	resp, err := utils.PostJSONRequest(zlmUrl, streamMergeConfigDTO)
-	if err != nil || resp == nil {
-		if dto.Type == 0 {
-			Logger.Error("合屏失败", zap.Error(err))
-		} else {
-			Logger.Error("切屏失败", zap.Error(err))
-		}
-		return res
-	}
-	res.Code = 0
-	return res
+	return ZlmMergeStreamConfig(streamMergeConfigDTO, zlmInfo, dto.Type == 2)
 }
 
 // 重置合屏，先关停合屏，延时2s再开启
 func ZlmResetMergeStream(dto model.StreamMergeInfoDTO, zlmInfo *model.ZlmInfo) StreamMergeInfoVO {
-	res := StreamMergeInfoVO{}
 	// 停止合屏
-	zlmUrl := fmt.Sprintf("%s/index/api/stack/stop?secret=%s&id=%s", zlmInfo.ZlmDomain, zlmInfo.ZlmSecret, dto.StreamId)
-	Logger.Info("停止合屏", zap.String("zlmUrl", zlmUrl))
-	result, err := utils.GetRequest(zlmUrl)
-	if err != nil || result == nil {
+	Logger.Info("停止合屏", zap.String("zlmDomain", zlmInfo.ZlmDomain), zap.String("id", dto.StreamId))
+	if err := NewClientByZlmInfo(zlmInfo).StackStop(context.Background(), dto.StreamId); err != nil {
 		Logger.Error("停止合屏失败", zap.Error(err))
-		return res
+		return StreamMergeInfoVO{Code: ErrorCode(err)}
 	}
 	time.Sleep(2 * time.Second)
 	return ZlmMergeStream(dto, zlmInfo)
@@ -382,33 +289,19 @@ type PauseRtpRsp struct {
 }
 
 func ZlmPauseRtpCheck(url, secret, stream_id string) PauseRtpRsp {
-	res := PauseRtpRsp{}
-
-	body, err := utils.GetRequest(url + "/index/api/pauseRtpCheck?secret=" + secret + "&app=rtp" + "&stream_id=" + stream_id)
-	if err != nil {
-		Logger.Error("pause server rtp check fail 1", zap.Error(err))
-		return res
+	if err := NewClient(url, secret).PauseRtpCheck(context.Background(), stream_id); err != nil {
+		Logger.Error("pause server rtp check fail", zap.Error(err))
+		return PauseRtpRsp{Code: ErrorCode(err)}
 	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("pause server rtp check fail 2", zap.Error(err))
-		return res
-	}
-	return res
+	return PauseRtpRsp{}
 }
 
 func ZlmResumeRtpCheck(url, secret, stream_id string) PauseRtpRsp {
-	res := PauseRtpRsp{}
-
-	body, err := utils.GetRequest(url + "/index/api/resumeRtpCheck?secret=" + secret + "&app=rtp" + "&stream_id=" + stream_id)
-	if err != nil {
-		Logger.Error("resume server rtp check fail 1", zap.Error(err))
-		return res
-	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("resume server rtp fail 2", zap.Error(err))
-		return res
+	if err := NewClient(url, secret).ResumeRtpCheck(context.Background(), stream_id); err != nil {
+		Logger.Error("resume server rtp check fail", zap.Error(err))
+		return PauseRtpRsp{Code: ErrorCode(err)}
 	}
-	return res
+	return PauseRtpRsp{}
 }
 
 type OpenSendRtpRsp struct {
@@ -417,19 +310,12 @@ type OpenSendRtpRsp struct {
 }
 
 func ZlmStartSendRtpPassive(zlmDomain, secret, stream_id string) OpenSendRtpRsp {
-	res := OpenSendRtpRsp{}
-	url := zlmDomain + "/index/api/startSendRtpPassive?secret=" + secret +
-		"&stream=" + stream_id + "&ssrc=1&app=broadcast&vhost=__defaultVhost__&only_audio=1&pt=8&use_ps=0&is_udp=0"
-	body, err := utils.GetRequest(url)
+	port, err := NewClient(zlmDomain, secret).StartSendRtpPassive(context.Background(), stream_id)
 	if err != nil {
-		Logger.Error("start rtp passive fail 1", zap.Error(err))
-		return res
+		Logger.Error("start rtp passive fail", zap.Error(err))
+		return OpenSendRtpRsp{Code: ErrorCode(err)}
 	}
-	if err = utils.JSONDecode(body, &res); err != nil {
-		Logger.Error("start rtp passive fail 2", zap.Error(err))
-		return res
-	}
-	return res
+	return OpenSendRtpRsp{LocalPort: port}
 }
 
 type ZlmRecordRes struct {
@@ -437,9 +323,16 @@ type ZlmRecordRes struct {
 	Result bool `json:"result"`
 }
 
+// 传输失败时录制接口沿用500错误码
+func recordErrorCode(err error) int {
+	if code := ErrorCode(err); code != -1 {
+		return code
+	}
+	return 500
+}
+
 // Zlm 开始录制视频流
 func ZlmStartRecord(zlmDomain, secret, stream_id, streamType string) ZlmRecordRes {
-	res := ZlmRecordRes{}
 	// 录像文件保存自定义根目录，为空则采用配置文件设置
 	var customizedPath string
 	if streamType == "" {
@@ -449,44 +342,20 @@ func ZlmStartRecord(zlmDomain, secret, stream_id, streamType string) ZlmRecordRe
 	}
 	// mp4录像切片时间大小,单位秒，置0则采用配置项3600秒
 	maxSecond := 1800
-	// type: 0为hls，1为mp4
-	respBody, err := utils.GetRequest(zlmDomain + "/index/api/startRecord?type=1&app=rtp&vhost=__defaultVhost__&secret=" + secret +
-		"&stream=" + stream_id + "&customized_path=" + customizedPath + "&max_second=" + strconv.Itoa(maxSecond))
-	if err != nil {
-		Logger.Error("zlm start record GetRequest fail:", zap.Error(err))
-		res.Code = 500
-		res.Result = false
-		return res
-	}
-
-	if err = utils.JSONDecode(respBody, &res); err != nil {
-		Logger.Error("zlm start record json decode fail:", zap.Error(err))
-		res.Code = 500
-		res.Result = false
-		return res
+	if err := NewClient(zlmDomain, secret).StartRecord(context.Background(), stream_id, customizedPath, maxSecond); err != nil {
+		Logger.Error("zlm start record fail", zap.Error(err))
+		return ZlmRecordRes{Code: recordErrorCode(err)}
 	}
-	return res
+	return ZlmRecordRes{Result: true}
 }
 
 // Zlm 停止录制
 func ZlmStopRecord(zlmDomain, secret, stream_id string) ZlmRecordRes {
-	res := ZlmRecordRes{}
-	// type: 0为hls，1为mp4
-	respBody, err := utils.GetRequest(zlmDomain + "/index/api/stopRecord?type=1&app=rtp&vhost=__defaultVhost__&secret=" + secret + "&stream=" + stream_id)
-	if err != nil {
-		Logger.Error("zlm start record GetRequest fail:", zap.Error(err))
-		res.Code = 500
-		res.Result = false
-		return res
+	if err := NewClient(zlmDomain, secret).StopRecord(context.Background(), stream_id); err != nil {
+		Logger.Error("zlm stop record fail", zap.Error(err))
+		return ZlmRecordRes{Code: recordErrorCode(err)}
 	}
-
-	if err = utils.JSONDecode(respBody, &res); err != nil {
-		Logger.Error("zlm start record json decode fail:", zap.Error(err))
-		res.Code = 500
-		res.Result = false
-		return res
-	}
-	return res
+	return ZlmRecordRes{Result: true}
 }
 
 type ZlmRecordStatusRes struct {
@@ -496,21 +365,10 @@ type ZlmRecordStatusRes struct {
 
 // zlm 获取流录制状态
 func ZlmGetRecordStatus(zlmDomain, secret, stream_id string) ZlmRecordStatusRes {
-	res := ZlmRecordStatusRes{}
-	// type: 0为hls，1为mp4
-	respBody, err := utils.GetRequest(zlmDomain + "/index/api/isRecording?type=1&app=rtp&vhost=__defaultVhost__&secret=" + secret + "&stream=" + stream_id)
+	status, err := NewClient(zlmDomain, secret).IsRecording(context.Background(), stream_id)
 	if err != nil {
-		Logger.Error("zlm start record GetRequest fail:", zap.Error(err))
-		res.Code = 500
-		res.Status = false
-		return res
+		Logger.Error("zlm get record status fail", zap.Error(err))
+		return ZlmRecordStatusRes{Code: recordErrorCode(err)}
 	}
-
-	if err = utils.JSONDecode(respBody, &res); err != nil {
-		Logger.Error("zlm start record json decode fail:", zap.Error(err))
-		res.Code = 500
-		res.Status = false
-		return res
-	}
-	return res
+	return ZlmRecordStatusRes{Status: status}
 }
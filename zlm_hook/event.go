@@ -0,0 +1,28 @@
+package zlm_hook
+
+import "go-sip/model"
+
+// zlm hook事件, T为hook请求体类型
+type Event[T any] struct {
+	Name string
+}
+
+var (
+	FlowReport       = Event[model.ZlmFlowReportData]{"on_flow_report"}              // 流量统计
+	HttpAccess       = Event[model.ZlmHttpAccessData]{"on_http_access"}              // http文件服务器访问鉴权
+	Play             = Event[model.ZlmStreamOnPlayData]{"on_play"}                   // 播放鉴权
+	Publish          = Event[model.ZlmStreamPublishData]{"on_publish"}               // 推流鉴权
+	RecordMp4        = Event[model.ZLMRecordMp4Data]{"on_record_mp4"}                // mp4录制完成
+	RecordTs         = Event[model.ZlmRecordTsData]{"on_record_ts"}                  // hls ts切片完成
+	RtspRealm        = Event[model.ZlmRtspRealmData]{"on_rtsp_realm"}                // rtsp是否开启专属鉴权
+	RtspAuth         = Event[model.ZlmRtspAuthData]{"on_rtsp_auth"}                  // rtsp专属鉴权
+	ShellLogin       = Event[model.ZlmShellLoginData]{"on_shell_login"}              // shell登录鉴权
+	StreamChanged    = Event[model.ZLMStreamChangedData]{"on_stream_changed"}        // 流注册和注销
+	StreamNoneReader = Event[model.ZLMStreamNoneReaderData]{"on_stream_none_reader"} // 流无人观看
+	StreamNotFound   = Event[model.ZLMStreamNotFoundData]{"on_stream_not_found"}     // 播放时流不存在
+	ServerStarted    = Event[model.ZlmServerStartDate]{"on_server_started"}          // zlm启动
+	ServerExited     = Event[model.ZlmServerExitedData]{"on_server_exited"}          // zlm退出
+	ServerKeepalive  = Event[model.ZlmServerKeepaliveData]{"on_server_keepalive"}    // zlm心跳
+	RtpServerTimeout = Event[model.ZlmRtpServerTimeoutData]{"on_rtp_server_timeout"} // rtp server长时间未收到数据
+	SendRtpStopped   = Event[model.ZlmSendRtpStoppedData]{"on_send_rtp_stopped"}     // rtp推流停止
+)
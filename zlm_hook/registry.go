@@ -0,0 +1,83 @@
+package zlm_hook
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	. "go-sip/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type handler func(c *gin.Context, body []byte) error
+
+// 未订阅事件的处理, 如网关转发到sip服务
+type FallbackFunc func(c *gin.Context, event string, body []byte)
+
+// zlm hook分发器, 按事件名分发给订阅的handler
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string][]handler
+	fallback FallbackFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: map[string][]handler{}}
+}
+
+// 订阅hook事件, 请求体按事件类型解析后传给fn
+// 同一事件可以订阅多个handler, 按订阅顺序执行, 没有handler回复时使用默认回复
+func On[T any](r *Registry, e Event[T], fn func(c *gin.Context, req *T)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[e.Name] = append(r.handlers[e.Name], func(c *gin.Context, body []byte) error {
+		req := new(T)
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, req); err != nil {
+				return err
+			}
+		}
+		fn(c, req)
+		return nil
+	})
+}
+
+// 设置未订阅事件的处理
+func (r *Registry) Fallback(fn FallbackFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = fn
+}
+
+// gin handler, 事件名取自路径参数method
+func (r *Registry) Handle(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	if err != nil {
+		Response(c, -1, "body error")
+		return
+	}
+	r.Dispatch(c, c.Param("method"), body)
+}
+
+func (r *Registry) Dispatch(c *gin.Context, event string, body []byte) {
+	r.mu.RLock()
+	handlers := r.handlers[event]
+	fallback := r.fallback
+	r.mu.RUnlock()
+
+	if len(handlers) == 0 && fallback != nil {
+		fallback(c, event, body)
+		return
+	}
+	for _, h := range handlers {
+		if err := h(c, body); err != nil {
+			Logger.Error("zlm hook请求体解析失败", zap.String("event", event), zap.Error(err))
+			Response(c, -1, "body error")
+			return
+		}
+	}
+	defaultResponse(c, event)
+}
@@ -0,0 +1,83 @@
+package zlm_hook
+
+import (
+	"net/http"
+
+	"go-sip/m"
+
+	"github.com/gin-gonic/gin"
+)
+
+// on_http_access回复
+type HttpAccessResponse struct {
+	Code   int    `json:"code"`
+	Err    string `json:"err"`    // 不为空时表示无访问权限
+	Path   string `json:"path"`   // 有访问权限的目录，为空表示只有path参数的访问权限
+	Second int    `json:"second"` // 访问权限有效期，单位秒
+}
+
+// on_publish回复
+type PublishResponse struct {
+	Code        int    `json:"code"`
+	Msg         string `json:"msg"`
+	EnableAudio bool   `json:"enable_audio"`
+	EnableMP4   bool   `json:"enable_mp4"`
+}
+
+// on_rtsp_realm回复, realm为空表示不开启rtsp专属鉴权
+type RtspRealmResponse struct {
+	Code  int    `json:"code"`
+	Realm string `json:"realm"`
+}
+
+// on_rtsp_auth回复
+type RtspAuthResponse struct {
+	Code      int    `json:"code"`
+	Encrypted bool   `json:"encrypted"` // 密码是否为md5(username:realm:password)
+	Passwd    string `json:"passwd"`
+}
+
+// on_stream_not_found、on_stream_none_reader回复
+type CloseResponse struct {
+	Code  int  `json:"code"`
+	Close bool `json:"close"` // 是否关闭流
+}
+
+// 回复zlm, 同一个请求只回复一次, 多个handler时以第一个回复为准
+func Response(c *gin.Context, code int, msg string) {
+	if c.Writer.Written() {
+		return
+	}
+	m.ZlmWebHookResponse(c, code, msg)
+}
+
+func Success(c *gin.Context) {
+	Response(c, 0, "success")
+}
+
+func Unauthorized(c *gin.Context, msg string) {
+	Response(c, 401, msg)
+}
+
+// 回复自定义结构
+func JSON(c *gin.Context, v any) {
+	if c.Writer.Written() {
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+// 没有handler回复时的默认回复
+func defaultResponse(c *gin.Context, event string) {
+	switch event {
+	case HttpAccess.Name:
+		JSON(c, HttpAccessResponse{Code: 0, Second: 600})
+	case RtspRealm.Name:
+		JSON(c, RtspRealmResponse{Code: 0})
+	case ShellLogin.Name:
+		// 未订阅时禁止shell登录
+		Response(c, -1, "shell login disabled")
+	default:
+		Success(c)
+	}
+}
@@ -0,0 +1,149 @@
+package zlm_hook
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrStreamWaitTimeout = errors.New("等待流超时")
+
+// 等待流注册的统计
+type StreamWaiterStats struct {
+	Pending   int   `json:"pending"`   // 正在等待的流数量
+	Waiters   int   `json:"waiters"`   // 正在等待的请求数量
+	Joined    int64 `json:"joined"`    // 累计等待请求数
+	Started   int64 `json:"started"`   // 累计拉起流次数, 同一流的并发请求只拉起一次
+	Ready     int64 `json:"ready"`     // 累计等待成功的请求数
+	Timeout   int64 `json:"timeout"`   // 累计等待超时的请求数
+	Failed    int64 `json:"failed"`    // 累计拉起失败的请求数
+	AvgWaitMs int64 `json:"avgWaitMs"` // 等待成功的平均耗时, 单位毫秒
+}
+
+type streamWaitEntry struct {
+	done    chan struct{} // 流注册或拉起失败时关闭
+	err     error
+	waiters int
+}
+
+// 流注册等待表, on_stream_not_found时等待流注册, on_stream_changed流注册时唤醒所有等待的请求
+// 同一流的并发请求共享一次拉起, 流注册前到达的请求都会被唤醒
+type StreamWaiter struct {
+	mu      sync.Mutex
+	entries map[string]*streamWaitEntry
+
+	joined  int64
+	started int64
+	ready   int64
+	timeout int64
+	failed  int64
+	waitMs  int64
+}
+
+func NewStreamWaiter() *StreamWaiter {
+	return &StreamWaiter{entries: map[string]*streamWaitEntry{}}
+}
+
+// 等待表的key, 不同zlm节点上的同名流分别等待
+func StreamWaitKey(media_server_id, app, stream string) string {
+	return fmt.Sprintf("%s|%s|%s", media_server_id, app, stream)
+}
+
+// 一次等待请求, 必须调用Leave释放
+type StreamWait struct {
+	w     *StreamWaiter
+	key   string
+	entry *streamWaitEntry
+	start time.Time
+	once  sync.Once
+}
+
+// 加入等待, first为true表示第一个等待该流的请求, 由该请求负责拉起流
+func (w *StreamWaiter) Join(key string) (wait *StreamWait, first bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry, ok := w.entries[key]
+	if !ok {
+		entry = &streamWaitEntry{done: make(chan struct{})}
+		w.entries[key] = entry
+		atomic.AddInt64(&w.started, 1)
+	}
+	entry.waiters++
+	atomic.AddInt64(&w.joined, 1)
+	return &StreamWait{w: w, key: key, entry: entry, start: time.Now()}, !ok
+}
+
+// 流注册, 唤醒所有等待该流的请求
+func (w *StreamWaiter) Ready(key string) {
+	w.finish(key, nil, nil)
+}
+
+// 等待流注册, 超时或拉起失败时返回错误
+func (s *StreamWait) Wait(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-s.entry.done:
+		if s.entry.err != nil {
+			atomic.AddInt64(&s.w.failed, 1)
+			return s.entry.err
+		}
+		atomic.AddInt64(&s.w.ready, 1)
+		atomic.AddInt64(&s.w.waitMs, time.Since(s.start).Milliseconds())
+		return nil
+	case <-timer.C:
+		atomic.AddInt64(&s.w.timeout, 1)
+		return ErrStreamWaitTimeout
+	}
+}
+
+// 拉起流失败, 唤醒所有等待该流的请求, 之后的请求重新拉起
+func (s *StreamWait) Fail(err error) {
+	s.w.finish(s.key, s.entry, err)
+}
+
+// 离开等待, 最后一个请求离开时移除等待, 之后的请求重新拉起
+func (s *StreamWait) Leave() {
+	s.once.Do(func() {
+		s.w.mu.Lock()
+		defer s.w.mu.Unlock()
+		s.entry.waiters--
+		if s.entry.waiters <= 0 && s.w.entries[s.key] == s.entry {
+			delete(s.w.entries, s.key)
+		}
+	})
+}
+
+// 结束等待, entry不为空时只结束该次等待
+func (w *StreamWaiter) finish(key string, entry *streamWaitEntry, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cur, ok := w.entries[key]
+	if !ok || (entry != nil && cur != entry) {
+		return
+	}
+	cur.err = err
+	close(cur.done)
+	delete(w.entries, key)
+}
+
+// 等待统计, 累计值从进程启动开始计算
+func (w *StreamWaiter) Stats() StreamWaiterStats {
+	w.mu.Lock()
+	stats := StreamWaiterStats{Pending: len(w.entries)}
+	for _, entry := range w.entries {
+		stats.Waiters += entry.waiters
+	}
+	w.mu.Unlock()
+	stats.Joined = atomic.LoadInt64(&w.joined)
+	stats.Started = atomic.LoadInt64(&w.started)
+	stats.Ready = atomic.LoadInt64(&w.ready)
+	stats.Timeout = atomic.LoadInt64(&w.timeout)
+	stats.Failed = atomic.LoadInt64(&w.failed)
+	if stats.Ready > 0 {
+		stats.AvgWaitMs = atomic.LoadInt64(&w.waitMs) / stats.Ready
+	}
+	return stats
+}
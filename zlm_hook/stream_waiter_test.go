@@ -0,0 +1,171 @@
+package zlm_hook
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamWaiterJoinFirst(t *testing.T) {
+	w := NewStreamWaiter()
+	key := StreamWaitKey("zlm1", "rtp", "ipc1_0")
+	const n = 50
+	var firsts int64
+	var wg sync.WaitGroup
+	waits := make(chan *StreamWait, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, first := w.Join(key)
+			if first {
+				atomic.AddInt64(&firsts, 1)
+			}
+			waits <- wait
+		}()
+	}
+	wg.Wait()
+	close(waits)
+	if firsts != 1 {
+		t.Fatalf("并发加入等待时first数量 = %d, want 1", firsts)
+	}
+	stats := w.Stats()
+	if stats.Pending != 1 || stats.Waiters != n || stats.Joined != n || stats.Started != 1 {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+	for wait := range waits {
+		wait.Leave()
+	}
+}
+
+func TestStreamWaiterReady(t *testing.T) {
+	w := NewStreamWaiter()
+	key := StreamWaitKey("zlm1", "rtp", "ipc1_0")
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	joined := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, _ := w.Join(key)
+			defer wait.Leave()
+			joined <- struct{}{}
+			errs <- wait.Wait(5 * time.Second)
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-joined
+	}
+	w.Ready(key)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Wait() err = %v, want nil", err)
+		}
+	}
+	stats := w.Stats()
+	if stats.Pending != 0 || stats.Ready != n {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+}
+
+func TestStreamWaiterFail(t *testing.T) {
+	w := NewStreamWaiter()
+	key := StreamWaitKey("zlm1", "rtp", "ipc1_0")
+	errPlay := errors.New("点播失败")
+	first, isFirst := w.Join(key)
+	other, _ := w.Join(key)
+	defer first.Leave()
+	defer other.Leave()
+	if !isFirst {
+		t.Fatal("第一个请求first应为true")
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- other.Wait(5 * time.Second)
+	}()
+	first.Fail(errPlay)
+	if err := <-done; !errors.Is(err, errPlay) {
+		t.Fatalf("Wait() err = %v, want %v", err, errPlay)
+	}
+	if err := first.Wait(time.Second); !errors.Is(err, errPlay) {
+		t.Fatalf("Wait() err = %v, want %v", err, errPlay)
+	}
+
+	// 失败后的请求重新拉起, 旧请求再次Fail不影响新的等待
+	retry, isFirst := w.Join(key)
+	defer retry.Leave()
+	if !isFirst {
+		t.Fatal("拉起失败后的请求first应为true")
+	}
+	first.Fail(errPlay)
+	if w.Stats().Pending != 1 {
+		t.Fatal("旧请求Fail不应结束新的等待")
+	}
+}
+
+func TestStreamWaiterTimeout(t *testing.T) {
+	w := NewStreamWaiter()
+	wait, _ := w.Join(StreamWaitKey("zlm1", "rtp", "ipc1_0"))
+	defer wait.Leave()
+	if err := wait.Wait(10 * time.Millisecond); !errors.Is(err, ErrStreamWaitTimeout) {
+		t.Fatalf("Wait() err = %v, want %v", err, ErrStreamWaitTimeout)
+	}
+	if w.Stats().Timeout != 1 {
+		t.Fatalf("Stats() = %+v", w.Stats())
+	}
+}
+
+func TestStreamWaiterLeave(t *testing.T) {
+	w := NewStreamWaiter()
+	key := StreamWaitKey("zlm1", "rtp", "ipc1_0")
+	a, _ := w.Join(key)
+	b, _ := w.Join(key)
+	a.Leave()
+	// 重复Leave只计一次
+	a.Leave()
+	if stats := w.Stats(); stats.Pending != 1 || stats.Waiters != 1 {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+	b.Leave()
+	if stats := w.Stats(); stats.Pending != 0 || stats.Waiters != 0 {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+	// 所有请求离开后重新拉起
+	c, first := w.Join(key)
+	defer c.Leave()
+	if !first {
+		t.Fatal("所有请求离开后first应为true")
+	}
+}
+
+func TestStreamWaiterLateReady(t *testing.T) {
+	w := NewStreamWaiter()
+	key := StreamWaitKey("zlm1", "rtp", "ipc1_0")
+	// 没有等待时流注册
+	w.Ready(key)
+
+	wait, _ := w.Join(key)
+	wait.Wait(time.Millisecond)
+	wait.Leave()
+	// 超时离开后流才注册, 重复注册
+	w.Ready(key)
+	w.Ready(key)
+
+	wait, _ = w.Join(key)
+	w.Ready(key)
+	w.Ready(key)
+	wait.Fail(errors.New("点播失败"))
+	if err := wait.Wait(time.Second); err != nil {
+		t.Fatalf("Wait() err = %v, want nil", err)
+	}
+	wait.Leave()
+	if w.Stats().Pending != 0 {
+		t.Fatalf("Stats() = %+v", w.Stats())
+	}
+}